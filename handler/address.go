@@ -0,0 +1,519 @@
+package handler
+
+import (
+	"be_ecommerce/config"
+	"be_ecommerce/model"
+	"be_ecommerce/shipping"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Batas jumlah alamat dalam buku alamat seorang pelanggan
+const maxAddresses = 20
+
+var (
+	phonePattern      = regexp.MustCompile(`^(\+62|62|0)8[0-9]{7,12}$`)
+	postalCodePattern = regexp.MustCompile(`^[0-9]{5}$`)
+
+	errAddressNotFound = errors.New("address not found")
+)
+
+// addressError adalah kesalahan validasi alamat yang aman ditampilkan ke klien
+type addressError struct {
+	message string
+}
+
+func (e addressError) Error() string {
+	return e.message
+}
+
+func getRegionCollection() *mongo.Collection {
+	return config.MongoClient.Database("petapedia").Collection("region")
+}
+
+// validateAddress memeriksa field wajib lalu mencocokkan provinsi, kabupaten/kota,
+// kecamatan dan desa/kelurahan dengan data model.Region. Nama wilayah dinormalisasi
+// mengikuti penulisan di data region. Bila koordinat diisi, titik tersebut harus
+// berada di dalam batas desa/kelurahan yang dipilih.
+func validateAddress(ctx context.Context, address *model.Address) error {
+	address.Recipient = strings.TrimSpace(address.Recipient)
+	address.Phone = strings.ReplaceAll(strings.TrimSpace(address.Phone), " ", "")
+	address.Street = strings.TrimSpace(address.Street)
+	address.PostalCode = strings.TrimSpace(address.PostalCode)
+
+	switch {
+	case address.Recipient == "":
+		return addressError{"Recipient is required"}
+	case !phonePattern.MatchString(address.Phone):
+		return addressError{"Invalid phone number"}
+	case address.Street == "":
+		return addressError{"Street is required"}
+	case !postalCodePattern.MatchString(address.PostalCode):
+		return addressError{"Postal code must be 5 digits"}
+	case (address.Latitude == nil) != (address.Longitude == nil):
+		return addressError{"Latitude and longitude must be provided together"}
+	}
+
+	// Diperiksa berurutan dari provinsi sampai desa agar pesan kesalahannya selalu sama
+	levels := []struct {
+		field string
+		value string
+	}{
+		{"province", strings.TrimSpace(address.Province)},
+		{"district", strings.TrimSpace(address.District)},
+		{"sub_district", strings.TrimSpace(address.SubDistrict)},
+		{"village", strings.TrimSpace(address.Village)},
+	}
+	filter := bson.M{}
+	for _, level := range levels {
+		if level.value == "" {
+			return addressError{fmt.Sprintf("%s is required", level.field)}
+		}
+		filter[level.field] = level.value
+	}
+
+	// Pencocokan tanpa membedakan huruf besar/kecil
+	var region model.Region
+	err := getRegionCollection().FindOne(ctx, filter, options.FindOne().
+		SetProjection(bson.M{"border": 0}).
+		SetCollation(&options.Collation{Locale: "en", Strength: 2}),
+	).Decode(&region)
+	if err == mongo.ErrNoDocuments {
+		return addressError{"Province, district, sub-district and village do not match a known region"}
+	}
+	if err != nil {
+		return err
+	}
+
+	if address.Latitude != nil {
+		count, err := getRegionCollection().CountDocuments(ctx, bson.M{
+			"_id": region.ID,
+			"border": bson.M{"$geoIntersects": bson.M{"$geometry": bson.M{
+				"type":        "Point",
+				"coordinates": []float64{*address.Longitude, *address.Latitude},
+			}}},
+		})
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return addressError{"Coordinates are outside the selected village"}
+		}
+	}
+
+	address.Province = region.Province
+	address.District = region.District
+	address.SubDistrict = region.SubDistrict
+	address.Village = region.Village
+	return nil
+}
+
+// addressErrorStatus memetakan error alamat ke status HTTP dan pesan untuk klien
+func addressErrorStatus(err error) (int, string) {
+	var validationErr addressError
+	if errors.As(err, &validationErr) {
+		return fiber.StatusBadRequest, validationErr.message
+	}
+	if errors.Is(err, errAddressNotFound) {
+		return fiber.StatusNotFound, "Address not found"
+	}
+	return fiber.StatusInternalServerError, ""
+}
+
+// addressErrorResponse menulis response error untuk endpoint buku alamat
+func addressErrorResponse(c *fiber.Ctx, err error) error {
+	status, message := addressErrorStatus(err)
+	if status == fiber.StatusInternalServerError {
+		log.Println("Error saving address:", err)
+		message = "Failed to save address"
+	}
+	return c.Status(status).JSON(fiber.Map{"message": message})
+}
+
+// shippingAddressError menulis response error alamat untuk checkout; key mengikuti
+// format response handler pemanggil ("error" atau "message")
+func shippingAddressError(c *fiber.Ctx, key string, err error) error {
+	status, message := addressErrorStatus(err)
+	if status == fiber.StatusInternalServerError {
+		log.Println("Error resolving shipping address:", err)
+		message = "Failed to resolve shipping address"
+	}
+	return c.Status(status).JSON(fiber.Map{key: message})
+}
+
+// resolveShippingAddress menentukan alamat pengiriman order: alamat dari buku alamat
+// (address_id), alamat yang dikirim langsung, atau alamat default pengguna
+func resolveShippingAddress(ctx context.Context, userID primitive.ObjectID, addressID string, inline *model.Address) (*model.Address, error) {
+	if addressID == "" && inline != nil {
+		address := *inline
+		address.ID = primitive.NewObjectID()
+		address.IsDefault = false
+		if err := validateAddress(ctx, &address); err != nil {
+			return nil, err
+		}
+		return &address, nil
+	}
+
+	var user model.User
+	if err := getUserCollection().FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		return nil, err
+	}
+	for _, address := range user.Addresses {
+		if (addressID != "" && address.ID.Hex() == addressID) || (addressID == "" && address.IsDefault) {
+			address.IsDefault = false
+			return &address, nil
+		}
+	}
+	if addressID == "" {
+		return nil, addressError{"Shipping address is required"}
+	}
+	return nil, errAddressNotFound
+}
+
+// prepareStoreAddress memvalidasi alamat toko, mengisi FullAddress dari alamat tersebut
+// dan menentukan titik lokasi toko. Nama penerima default ke nama toko.
+func prepareStoreAddress(ctx context.Context, storeInfo *model.StoreInfo) error {
+	address := storeInfo.Address
+	if address.Recipient == "" {
+		address.Recipient = storeInfo.StoreName
+	}
+	if err := validateAddress(ctx, address); err != nil {
+		return err
+	}
+	address.ID = primitive.NewObjectID()
+	address.IsDefault = false
+	storeInfo.FullAddress = address.String()
+
+	long, lat, err := shipping.Locate(ctx, *address)
+	if err != nil {
+		return err
+	}
+	storeInfo.Location = model.NewPoint(long, lat)
+	return nil
+}
+
+// storeAddressFromForm membaca alamat terstruktur dari field multipart/form-data
+func storeAddressFromForm(c *fiber.Ctx) (*model.Address, error) {
+	address := &model.Address{
+		Label:       c.FormValue("address_label"),
+		Recipient:   c.FormValue("recipient"),
+		Phone:       c.FormValue("phone"),
+		Street:      c.FormValue("street"),
+		Village:     c.FormValue("village"),
+		SubDistrict: c.FormValue("sub_district"),
+		District:    c.FormValue("district"),
+		Province:    c.FormValue("province"),
+		PostalCode:  c.FormValue("postal_code"),
+	}
+	for field, target := range map[string]**float64{"lat": &address.Latitude, "long": &address.Longitude} {
+		value := c.FormValue(field)
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s", field)
+		}
+		*target = &parsed
+	}
+	return address, nil
+}
+
+// GetAddresses mengembalikan buku alamat pengguna
+func GetAddresses(c *fiber.Ctx) error {
+	var user model.User
+	err := getUserCollection().FindOne(context.Background(), bson.M{"_id": currentUserID(c)},
+		options.FindOne().SetProjection(bson.M{"addresses": 1}),
+	).Decode(&user)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "User not found",
+		})
+	}
+
+	addresses := user.Addresses
+	if addresses == nil {
+		addresses = []model.Address{}
+	}
+	return c.JSON(fiber.Map{
+		"message": "Addresses fetched successfully",
+		"data":    addresses,
+	})
+}
+
+// AddAddress menambahkan alamat ke buku alamat. Alamat pertama otomatis menjadi default.
+func AddAddress(c *fiber.Ctx) error {
+	var address model.Address
+	if err := c.BodyParser(&address); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+		})
+	}
+	if err := validateAddress(context.Background(), &address); err != nil {
+		return addressErrorResponse(c, err)
+	}
+	address.ID = primitive.NewObjectID()
+
+	userID := currentUserID(c)
+	err := config.WithTransaction(context.Background(), func(sessCtx mongo.SessionContext) error {
+		var user model.User
+		if err := getUserCollection().FindOne(sessCtx, bson.M{"_id": userID}).Decode(&user); err != nil {
+			return err
+		}
+		if len(user.Addresses) >= maxAddresses {
+			return addressError{fmt.Sprintf("You can save at most %d addresses", maxAddresses)}
+		}
+		if len(user.Addresses) == 0 {
+			address.IsDefault = true
+		}
+		if address.IsDefault {
+			if err := clearDefaultAddress(sessCtx, userID); err != nil {
+				return err
+			}
+		}
+		_, err := getUserCollection().UpdateOne(sessCtx, bson.M{"_id": userID}, bson.M{"$push": bson.M{"addresses": address}})
+		return err
+	})
+	if err != nil {
+		return addressErrorResponse(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "Address added successfully",
+		"data":    address,
+	})
+}
+
+// UpdateAddress mengganti isi alamat dalam buku alamat
+func UpdateAddress(c *fiber.Ctx) error {
+	addressID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid address ID",
+		})
+	}
+
+	var address model.Address
+	if err := c.BodyParser(&address); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+		})
+	}
+	if err := validateAddress(context.Background(), &address); err != nil {
+		return addressErrorResponse(c, err)
+	}
+	address.ID = addressID
+
+	userID := currentUserID(c)
+	err = config.WithTransaction(context.Background(), func(sessCtx mongo.SessionContext) error {
+		var user model.User
+		if err := getUserCollection().FindOne(sessCtx, bson.M{"_id": userID, "addresses._id": addressID}).Decode(&user); err != nil {
+			if err == mongo.ErrNoDocuments {
+				return errAddressNotFound
+			}
+			return err
+		}
+
+		// Alamat default tidak bisa dilepas lewat update, pilih default lain sebagai gantinya
+		for _, existing := range user.Addresses {
+			if existing.ID == addressID && existing.IsDefault {
+				address.IsDefault = true
+			}
+		}
+		if address.IsDefault {
+			if err := clearDefaultAddress(sessCtx, userID); err != nil {
+				return err
+			}
+		}
+
+		_, err := getUserCollection().UpdateOne(sessCtx,
+			bson.M{"_id": userID, "addresses._id": addressID},
+			bson.M{"$set": bson.M{"addresses.$": address}},
+		)
+		return err
+	})
+	if err != nil {
+		return addressErrorResponse(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Address updated successfully",
+		"data":    address,
+	})
+}
+
+// DeleteAddress menghapus alamat; jika yang dihapus adalah default, alamat pertama yang tersisa menjadi default
+func DeleteAddress(c *fiber.Ctx) error {
+	addressID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid address ID",
+		})
+	}
+
+	userID := currentUserID(c)
+	err = config.WithTransaction(context.Background(), func(sessCtx mongo.SessionContext) error {
+		var user model.User
+		err := getUserCollection().FindOneAndUpdate(sessCtx,
+			bson.M{"_id": userID, "addresses._id": addressID},
+			bson.M{"$pull": bson.M{"addresses": bson.M{"_id": addressID}}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&user)
+		if err == mongo.ErrNoDocuments {
+			return errAddressNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(user.Addresses) == 0 {
+			return nil
+		}
+		for _, address := range user.Addresses {
+			if address.IsDefault {
+				return nil
+			}
+		}
+		_, err = getUserCollection().UpdateOne(sessCtx,
+			bson.M{"_id": userID, "addresses._id": user.Addresses[0].ID},
+			bson.M{"$set": bson.M{"addresses.$.is_default": true}},
+		)
+		return err
+	})
+	if err != nil {
+		return addressErrorResponse(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Address deleted successfully",
+	})
+}
+
+// SetDefaultAddress menjadikan alamat sebagai alamat default untuk checkout
+func SetDefaultAddress(c *fiber.Ctx) error {
+	addressID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid address ID",
+		})
+	}
+
+	userID := currentUserID(c)
+	err = config.WithTransaction(context.Background(), func(sessCtx mongo.SessionContext) error {
+		if err := clearDefaultAddress(sessCtx, userID); err != nil {
+			return err
+		}
+		result, err := getUserCollection().UpdateOne(sessCtx,
+			bson.M{"_id": userID, "addresses._id": addressID},
+			bson.M{"$set": bson.M{"addresses.$.is_default": true}},
+		)
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return errAddressNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return addressErrorResponse(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Default address updated successfully",
+	})
+}
+
+// clearDefaultAddress melepas tanda default dari semua alamat pengguna
+func clearDefaultAddress(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := getUserCollection().UpdateOne(ctx,
+		bson.M{"_id": userID, "addresses": bson.M{"$exists": true}},
+		bson.M{"$set": bson.M{"addresses.$[].is_default": false}},
+	)
+	return err
+}
+
+// GetRegionOptions mengembalikan pilihan wilayah bertingkat untuk formulir alamat:
+// tanpa parameter daftar provinsi, ?province= daftar kabupaten/kota, dan seterusnya
+func GetRegionOptions(c *fiber.Ctx) error {
+	filter := bson.M{}
+	field := "province"
+	for _, level := range []struct{ param, next string }{
+		{"province", "district"},
+		{"district", "sub_district"},
+		{"sub_district", "village"},
+	} {
+		value := c.Query(level.param)
+		if value == "" {
+			break
+		}
+		filter[level.param] = value
+		field = level.next
+	}
+
+	values, err := getRegionCollection().Distinct(context.Background(), field, filter,
+		options.Distinct().SetCollation(&options.Collation{Locale: "en", Strength: 2}),
+	)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch regions",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Regions fetched successfully",
+		"level":   field,
+		"data":    values,
+	})
+}
+
+// UpdateStoreAddress mengatur alamat asal pengiriman toko milik seller yang sedang login
+func UpdateStoreAddress(c *fiber.Ctx) error {
+	var address model.Address
+	if err := c.BodyParser(&address); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+		})
+	}
+
+	var seller model.User
+	err := getUserCollection().FindOne(context.Background(), bson.M{"_id": currentUserID(c), "roles": "seller"}).Decode(&seller)
+	if err != nil || seller.StoreInfo == nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "Forbidden: User is not a seller",
+		})
+	}
+
+	storeInfo := *seller.StoreInfo
+	storeInfo.Address = &address
+	if err := prepareStoreAddress(context.Background(), &storeInfo); err != nil {
+		return addressErrorResponse(c, err)
+	}
+
+	_, err = getUserCollection().UpdateOne(context.Background(), bson.M{"_id": seller.ID}, bson.M{"$set": bson.M{
+		"store_info.address":      storeInfo.Address,
+		"store_info.full_address": storeInfo.FullAddress,
+		"store_info.location":     storeInfo.Location,
+	}})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to update store address",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Store address updated successfully",
+		"data":    storeInfo.Address,
+	})
+}
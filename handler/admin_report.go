@@ -0,0 +1,586 @@
+package handler
+
+import (
+	"be_ecommerce/config"
+	"be_ecommerce/model"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Status order yang dihitung sebagai transaksi berhasil (masuk GMV)
+var paidOrderStatuses = []string{"Processing", "Confirmed", "Shipped", "Delivered", "Completed"}
+
+const (
+	reportDateLayout        = "2006-01-02"
+	defaultReportRangeDays  = 7
+	defaultStuckOrderWindow = 24 * time.Hour
+)
+
+// parseReportRange membaca query `from` dan `to` (YYYY-MM-DD, inklusif).
+// Tanpa filter, laporan mencakup 7 hari terakhir termasuk hari ini.
+func parseReportRange(c *fiber.Ctx) (time.Time, time.Time, error) {
+	now := time.Now().UTC()
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, 1)
+	if raw := c.Query("to"); raw != "" {
+		parsed, err := time.Parse(reportDateLayout, raw)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("invalid 'to' date, expected YYYY-MM-DD")
+		}
+		to = parsed.AddDate(0, 0, 1)
+	}
+
+	from := to.AddDate(0, 0, -defaultReportRangeDays)
+	if raw := c.Query("from"); raw != "" {
+		parsed, err := time.Parse(reportDateLayout, raw)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("invalid 'from' date, expected YYYY-MM-DD")
+		}
+		from = parsed
+	}
+
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, errors.New("'from' must not be after 'to'")
+	}
+	return from, to, nil
+}
+
+// objectIDRange membuat filter _id berdasarkan waktu pembuatan dokumen
+func objectIDRange(from, to time.Time) bson.M {
+	return bson.M{
+		"$gte": primitive.NewObjectIDFromTimestamp(from),
+		"$lt":  primitive.NewObjectIDFromTimestamp(to),
+	}
+}
+
+// sendReport mengirim laporan sebagai JSON, atau CSV jika `format=csv`
+func sendReport(c *fiber.Ctx, name string, from, to time.Time, header []string, rows [][]string, data interface{}) error {
+	if c.Query("format") != "csv" {
+		return c.JSON(fiber.Map{
+			"message": "Report generated successfully",
+			"from":    from.Format(reportDateLayout),
+			"to":      to.AddDate(0, 0, -1).Format(reportDateLayout),
+			"data":    data,
+		})
+	}
+
+	filename := fmt.Sprintf("%s_%s_%s.csv", name, from.Format(reportDateLayout), to.AddDate(0, 0, -1).Format(reportDateLayout))
+	c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+
+	writer := csv.NewWriter(c.Response().BodyWriter())
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	if err := writer.WriteAll(rows); err != nil {
+		return err
+	}
+	return writer.Error()
+}
+
+func reportRangeError(c *fiber.Ctx, err error) error {
+	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		"message": err.Error(),
+	})
+}
+
+// GET /admin/reports/summary → Ringkasan metrik platform untuk periode tertentu
+func GetAdminReportSummary(c *fiber.Ctx) error {
+	from, to, err := parseReportRange(c)
+	if err != nil {
+		return reportRangeError(c, err)
+	}
+
+	ctx := context.Background()
+	db := config.MongoClient.Database("ecommerce")
+	orders := db.Collection("orders")
+	users := db.Collection("users")
+	reviews := db.Collection("reviews")
+
+	// GMV dan jumlah order berhasil
+	var gmv struct {
+		Orders int64 `bson:"orders"`
+		GMV    int64 `bson:"gmv"`
+	}
+	cursor, err := orders.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"status":     bson.M{"$in": paidOrderStatuses},
+			"created_at": bson.M{"$gte": from, "$lt": to},
+		}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":    nil,
+			"orders": bson.M{"$sum": 1},
+			"gmv":    bson.M{"$sum": "$total_amount"},
+		}}},
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to calculate GMV"})
+	}
+	if cursor.Next(ctx) {
+		if err := cursor.Decode(&gmv); err != nil {
+			cursor.Close(ctx)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to parse GMV"})
+		}
+	}
+	cursor.Close(ctx)
+
+	newRegistrations, err := users.CountDocuments(ctx, bson.M{"_id": objectIDRange(from, to)})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to count registrations"})
+	}
+
+	activeSellers, err := users.CountDocuments(ctx, bson.M{"roles": "seller", "store_status": "approved"})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to count active sellers"})
+	}
+
+	pendingApplications, err := users.CountDocuments(ctx, bson.M{"store_status": "pending"})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to count seller applications"})
+	}
+
+	suspendedAccounts, err := users.CountDocuments(ctx, activeSuspensionFilter())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to count suspended accounts"})
+	}
+
+	stuckOrders, err := orders.CountDocuments(ctx, bson.M{
+		"status":     "Pending",
+		"created_at": bson.M{"$lt": time.Now().Add(-defaultStuckOrderWindow)},
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to count pending orders"})
+	}
+
+	// Jumlah dan rata-rata rating ulasan baru
+	var reviewStats struct {
+		Reviews   int64   `bson:"reviews"`
+		AvgRating float64 `bson:"avg_rating"`
+	}
+	cursor, err = reviews.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"created_at": bson.M{"$gte": from.Unix(), "$lt": to.Unix()}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":        nil,
+			"reviews":    bson.M{"$sum": 1},
+			"avg_rating": bson.M{"$avg": "$rating"},
+		}}},
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to calculate review statistics"})
+	}
+	if cursor.Next(ctx) {
+		if err := cursor.Decode(&reviewStats); err != nil {
+			cursor.Close(ctx)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to parse review statistics"})
+		}
+	}
+	cursor.Close(ctx)
+
+	summary := fiber.Map{
+		"gmv":                         gmv.GMV,
+		"paid_orders":                 gmv.Orders,
+		"new_registrations":           newRegistrations,
+		"active_sellers":              activeSellers,
+		"pending_seller_applications": pendingApplications,
+		"suspended_accounts":          suspendedAccounts,
+		"stuck_pending_orders":        stuckOrders,
+		"new_reviews":                 reviewStats.Reviews,
+		"average_rating":              reviewStats.AvgRating,
+	}
+
+	rows := [][]string{
+		{"gmv", strconv.FormatInt(gmv.GMV, 10)},
+		{"paid_orders", strconv.FormatInt(gmv.Orders, 10)},
+		{"new_registrations", strconv.FormatInt(newRegistrations, 10)},
+		{"active_sellers", strconv.FormatInt(activeSellers, 10)},
+		{"pending_seller_applications", strconv.FormatInt(pendingApplications, 10)},
+		{"suspended_accounts", strconv.FormatInt(suspendedAccounts, 10)},
+		{"stuck_pending_orders", strconv.FormatInt(stuckOrders, 10)},
+		{"new_reviews", strconv.FormatInt(reviewStats.Reviews, 10)},
+		{"average_rating", strconv.FormatFloat(reviewStats.AvgRating, 'f', 2, 64)},
+	}
+
+	return sendReport(c, "summary", from, to, []string{"metric", "value"}, rows, summary)
+}
+
+// GET /admin/reports/gmv → GMV harian
+func GetAdminGMVReport(c *fiber.Ctx) error {
+	from, to, err := parseReportRange(c)
+	if err != nil {
+		return reportRangeError(c, err)
+	}
+
+	collection := config.MongoClient.Database("ecommerce").Collection("orders")
+	cursor, err := collection.Aggregate(context.Background(), mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"status":     bson.M{"$in": paidOrderStatuses},
+			"created_at": bson.M{"$gte": from, "$lt": to},
+		}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":           bson.M{"$dateToString": bson.M{"format": "%Y-%m-%d", "date": "$created_at"}},
+			"orders":        bson.M{"$sum": 1},
+			"gmv":           bson.M{"$sum": "$total_amount"},
+			"shipping_cost": bson.M{"$sum": "$shipping_cost"},
+		}}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to generate GMV report"})
+	}
+	defer cursor.Close(context.Background())
+
+	var days []struct {
+		Date         string `bson:"_id" json:"date"`
+		Orders       int64  `bson:"orders" json:"orders"`
+		GMV          int64  `bson:"gmv" json:"gmv"`
+		ShippingCost int64  `bson:"shipping_cost" json:"shipping_cost"`
+	}
+	if err := cursor.All(context.Background(), &days); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to parse GMV report"})
+	}
+
+	rows := make([][]string, 0, len(days))
+	for _, day := range days {
+		rows = append(rows, []string{
+			day.Date,
+			strconv.FormatInt(day.Orders, 10),
+			strconv.FormatInt(day.GMV, 10),
+			strconv.FormatInt(day.ShippingCost, 10),
+		})
+	}
+
+	return sendReport(c, "gmv", from, to, []string{"date", "orders", "gmv", "shipping_cost"}, rows, days)
+}
+
+// GET /admin/reports/registrations → Pendaftaran pengguna baru per hari
+func GetAdminRegistrationReport(c *fiber.Ctx) error {
+	from, to, err := parseReportRange(c)
+	if err != nil {
+		return reportRangeError(c, err)
+	}
+
+	// Waktu pendaftaran diambil dari timestamp ObjectID
+	collection := getUserCollection()
+	cursor, err := collection.Aggregate(context.Background(), mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"_id": objectIDRange(from, to)}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":           bson.M{"$dateToString": bson.M{"format": "%Y-%m-%d", "date": bson.M{"$toDate": "$_id"}}},
+			"registrations": bson.M{"$sum": 1},
+			"sellers": bson.M{"$sum": bson.M{"$cond": bson.A{
+				bson.M{"$in": bson.A{"seller", bson.M{"$ifNull": bson.A{"$roles", bson.A{}}}}}, 1, 0,
+			}}},
+		}}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to generate registration report"})
+	}
+	defer cursor.Close(context.Background())
+
+	var days []struct {
+		Date          string `bson:"_id" json:"date"`
+		Registrations int64  `bson:"registrations" json:"registrations"`
+		Sellers       int64  `bson:"sellers" json:"sellers"`
+	}
+	if err := cursor.All(context.Background(), &days); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to parse registration report"})
+	}
+
+	rows := make([][]string, 0, len(days))
+	for _, day := range days {
+		rows = append(rows, []string{day.Date, strconv.FormatInt(day.Registrations, 10), strconv.FormatInt(day.Sellers, 10)})
+	}
+
+	return sendReport(c, "registrations", from, to, []string{"date", "registrations", "sellers"}, rows, days)
+}
+
+// GET /admin/reports/sellers → Seller aktif beserta GMV dan jumlah produk
+func GetAdminSellerReport(c *fiber.Ctx) error {
+	from, to, err := parseReportRange(c)
+	if err != nil {
+		return reportRangeError(c, err)
+	}
+
+	collection := getUserCollection()
+	cursor, err := collection.Aggregate(context.Background(), mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"roles": "seller", "store_status": "approved"}}},
+		// GMV dihitung dari item milik seller, karena satu order bisa berisi item dari beberapa toko
+		{{Key: "$lookup", Value: bson.M{
+			"from": "orders",
+			"let":  bson.M{"seller": "$_id"},
+			"pipeline": bson.A{
+				bson.M{"$match": bson.M{
+					"$expr":      bson.M{"$in": bson.A{"$$seller", bson.M{"$ifNull": bson.A{"$items.seller_id", bson.A{}}}}},
+					"status":     bson.M{"$in": paidOrderStatuses},
+					"created_at": bson.M{"$gte": from, "$lt": to},
+				}},
+				bson.M{"$unwind": "$items"},
+				bson.M{"$match": bson.M{"$expr": bson.M{"$eq": bson.A{"$items.seller_id", "$$seller"}}}},
+				bson.M{"$group": bson.M{"_id": "$_id", "gmv": bson.M{"$sum": bson.M{"$multiply": bson.A{"$items.price", "$items.quantity"}}}}},
+				bson.M{"$group": bson.M{"_id": nil, "orders": bson.M{"$sum": 1}, "gmv": bson.M{"$sum": "$gmv"}}},
+			},
+			"as": "sales",
+		}}},
+		// Hanya produk aktif yang belum dihapus yang dihitung
+		{{Key: "$lookup", Value: bson.M{
+			"from": "products",
+			"let":  bson.M{"seller": "$_id"},
+			"pipeline": bson.A{
+				bson.M{"$match": onlyActiveProducts(bson.M{
+					"$expr":      bson.M{"$eq": bson.A{"$seller_id", "$$seller"}},
+					"deleted_at": bson.M{"$exists": false},
+				}, "")},
+				bson.M{"$count": "count"},
+			},
+			"as": "products",
+		}}},
+		{{Key: "$project", Value: bson.M{
+			"email":      1,
+			"store_name": "$store_info.store_name",
+			"products":   bson.M{"$ifNull": bson.A{bson.M{"$first": "$products.count"}, 0}},
+			"orders":     bson.M{"$ifNull": bson.A{bson.M{"$first": "$sales.orders"}, 0}},
+			"gmv":        bson.M{"$ifNull": bson.A{bson.M{"$first": "$sales.gmv"}, 0}},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "gmv", Value: -1}, {Key: "_id", Value: 1}}}},
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to generate seller report"})
+	}
+	defer cursor.Close(context.Background())
+
+	var sellers []struct {
+		ID        primitive.ObjectID `bson:"_id" json:"seller_id"`
+		StoreName string             `bson:"store_name" json:"store_name"`
+		Email     string             `bson:"email" json:"email"`
+		Products  int64              `bson:"products" json:"products"`
+		Orders    int64              `bson:"orders" json:"orders"`
+		GMV       int64              `bson:"gmv" json:"gmv"`
+	}
+	if err := cursor.All(context.Background(), &sellers); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to parse seller report"})
+	}
+
+	rows := make([][]string, 0, len(sellers))
+	for _, seller := range sellers {
+		rows = append(rows, []string{
+			seller.ID.Hex(),
+			seller.StoreName,
+			seller.Email,
+			strconv.FormatInt(seller.Products, 10),
+			strconv.FormatInt(seller.Orders, 10),
+			strconv.FormatInt(seller.GMV, 10),
+		})
+	}
+
+	return sendReport(c, "sellers", from, to, []string{"seller_id", "store_name", "email", "products", "orders", "gmv"}, rows, sellers)
+}
+
+// GET /admin/reports/seller-applications → Permohonan seller yang belum diproses dan diajukan
+// dalam periode laporan. Permohonan lama tanpa store_applied_at memakai tanggal registrasi akun.
+func GetAdminPendingApplicationsReport(c *fiber.Ctx) error {
+	from, to, err := parseReportRange(c)
+	if err != nil {
+		return reportRangeError(c, err)
+	}
+
+	collection := getUserCollection()
+	cursor, err := collection.Find(context.Background(),
+		bson.M{"store_status": "pending", "$or": bson.A{
+			bson.M{"store_applied_at": bson.M{"$gte": from, "$lt": to}},
+			bson.M{"store_applied_at": bson.M{"$exists": false}, "_id": objectIDRange(from, to)},
+		}},
+		options.Find().SetSort(bson.D{{Key: "store_applied_at", Value: 1}, {Key: "_id", Value: 1}}),
+	)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to fetch seller applications"})
+	}
+	defer cursor.Close(context.Background())
+
+	var applications []struct {
+		ID        primitive.ObjectID `bson:"_id" json:"user_id"`
+		Username  string             `bson:"username" json:"username"`
+		Email     string             `bson:"email" json:"email"`
+		AppliedAt *time.Time         `bson:"store_applied_at" json:"applied_at,omitempty"`
+		StoreInfo struct {
+			StoreName   string `bson:"store_name" json:"store_name"`
+			FullAddress string `bson:"full_address" json:"full_address"`
+		} `bson:"store_info" json:"store_info"`
+	}
+	if err := cursor.All(context.Background(), &applications); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to parse seller applications"})
+	}
+
+	rows := make([][]string, 0, len(applications))
+	for _, app := range applications {
+		appliedAt := app.ID.Timestamp()
+		if app.AppliedAt != nil {
+			appliedAt = *app.AppliedAt
+		}
+		rows = append(rows, []string{
+			app.ID.Hex(),
+			app.Username,
+			app.Email,
+			app.StoreInfo.StoreName,
+			app.StoreInfo.FullAddress,
+			appliedAt.UTC().Format(time.RFC3339),
+		})
+	}
+
+	return sendReport(c, "seller_applications", from, to, []string{"user_id", "username", "email", "store_name", "full_address", "applied_at"}, rows, applications)
+}
+
+// GET /admin/reports/suspended → Akun yang sedang disuspend, ditangguhkan dalam periode laporan
+func GetAdminSuspendedAccountsReport(c *fiber.Ctx) error {
+	from, to, err := parseReportRange(c)
+	if err != nil {
+		return reportRangeError(c, err)
+	}
+
+	filter := activeSuspensionFilter()
+	filter["suspension.suspended_at"] = bson.M{"$gte": from, "$lt": to}
+	collection := getUserCollection()
+	cursor, err := collection.Find(context.Background(),
+		filter,
+		options.Find().SetSort(bson.M{"_id": 1}),
+	)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to fetch suspended accounts"})
+	}
+	defer cursor.Close(context.Background())
+
+	var accounts []struct {
+		ID         primitive.ObjectID `bson:"_id" json:"user_id"`
+		Username   string             `bson:"username" json:"username"`
+		Email      string             `bson:"email" json:"email"`
+		Roles      []string           `bson:"roles" json:"roles"`
+		Suspension *model.Suspension  `bson:"suspension" json:"suspension"`
+	}
+	if err := cursor.All(context.Background(), &accounts); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to parse suspended accounts"})
+	}
+
+	rows := make([][]string, 0, len(accounts))
+	for _, account := range accounts {
+		reason, suspendedAt, expiresAt := "", "", ""
+		if account.Suspension != nil {
+			reason = account.Suspension.Reason
+			suspendedAt = account.Suspension.SuspendedAt.UTC().Format(time.RFC3339)
+			if account.Suspension.ExpiresAt != nil {
+				expiresAt = account.Suspension.ExpiresAt.UTC().Format(time.RFC3339)
+			}
+		}
+		rows = append(rows, []string{account.ID.Hex(), account.Username, account.Email, strings.Join(account.Roles, "|"), reason, suspendedAt, expiresAt})
+	}
+
+	return sendReport(c, "suspended_accounts", from, to, []string{"user_id", "username", "email", "roles", "reason", "suspended_at", "expires_at"}, rows, accounts)
+}
+
+// GET /admin/reports/stuck-orders → Order yang tertahan di status Pending
+func GetAdminStuckOrdersReport(c *fiber.Ctx) error {
+	from, to, err := parseReportRange(c)
+	if err != nil {
+		return reportRangeError(c, err)
+	}
+
+	window := defaultStuckOrderWindow
+	if raw := c.Query("older_than_hours"); raw != "" {
+		hours, err := strconv.Atoi(raw)
+		if err != nil || hours < 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid older_than_hours"})
+		}
+		window = time.Duration(hours) * time.Hour
+	}
+
+	cutoff := time.Now().Add(-window)
+	if to.Before(cutoff) {
+		cutoff = to
+	}
+
+	collection := config.MongoClient.Database("ecommerce").Collection("orders")
+	cursor, err := collection.Find(context.Background(),
+		bson.M{"status": "Pending", "created_at": bson.M{"$gte": from, "$lt": cutoff}},
+		options.Find().SetSort(bson.M{"created_at": 1}),
+	)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to fetch pending orders"})
+	}
+	defer cursor.Close(context.Background())
+
+	var orders []struct {
+		ID          primitive.ObjectID `bson:"_id" json:"order_id"`
+		UserID      primitive.ObjectID `bson:"user_id" json:"user_id"`
+		SellerID    primitive.ObjectID `bson:"seller_id" json:"seller_id"`
+		TotalAmount int                `bson:"total_amount" json:"total_amount"`
+		CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+	}
+	if err := cursor.All(context.Background(), &orders); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to parse pending orders"})
+	}
+
+	rows := make([][]string, 0, len(orders))
+	for _, order := range orders {
+		rows = append(rows, []string{
+			order.ID.Hex(),
+			order.UserID.Hex(),
+			order.SellerID.Hex(),
+			strconv.Itoa(order.TotalAmount),
+			order.CreatedAt.UTC().Format(time.RFC3339),
+			strconv.Itoa(int(time.Since(order.CreatedAt).Hours())),
+		})
+	}
+
+	return sendReport(c, "stuck_orders", from, to, []string{"order_id", "user_id", "seller_id", "total_amount", "created_at", "age_hours"}, rows, orders)
+}
+
+// GET /admin/reports/reviews → Jumlah ulasan dan rata-rata rating per hari
+func GetAdminReviewReport(c *fiber.Ctx) error {
+	from, to, err := parseReportRange(c)
+	if err != nil {
+		return reportRangeError(c, err)
+	}
+
+	// created_at pada review disimpan dalam detik (Unix)
+	collection := config.MongoClient.Database("ecommerce").Collection("reviews")
+	cursor, err := collection.Aggregate(context.Background(), mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"created_at": bson.M{"$gte": from.Unix(), "$lt": to.Unix()}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id": bson.M{"$dateToString": bson.M{
+				"format": "%Y-%m-%d",
+				"date":   bson.M{"$toDate": bson.M{"$multiply": bson.A{"$created_at", 1000}}},
+			}},
+			"reviews":    bson.M{"$sum": 1},
+			"avg_rating": bson.M{"$avg": "$rating"},
+		}}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to generate review report"})
+	}
+	defer cursor.Close(context.Background())
+
+	var days []struct {
+		Date      string  `bson:"_id" json:"date"`
+		Reviews   int64   `bson:"reviews" json:"reviews"`
+		AvgRating float64 `bson:"avg_rating" json:"avg_rating"`
+	}
+	if err := cursor.All(context.Background(), &days); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to parse review report"})
+	}
+
+	rows := make([][]string, 0, len(days))
+	for _, day := range days {
+		rows = append(rows, []string{day.Date, strconv.FormatInt(day.Reviews, 10), strconv.FormatFloat(day.AvgRating, 'f', 2, 64)})
+	}
+
+	return sendReport(c, "reviews", from, to, []string{"date", "reviews", "avg_rating"}, rows, days)
+}
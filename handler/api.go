@@ -140,26 +140,10 @@ func GetRegion(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(respn)
 	}
 
-	// Menghubungkan ke database MongoDB
-	Collection := config.MongoClient.Database("petapedia").Collection("region")
+	// Mencari region yang berpotongan dengan titik
 	Ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-
-	// Geospatial Query Filter untuk mencari region berdasarkan border
-	Filter := bson.M{
-		"border": bson.M{
-			"$geoIntersects": bson.M{
-				"$geometry": bson.M{
-					"type":        "Point", // Memastikan jenis geometri adalah "Point"
-					"coordinates": []float64{longlat.Longitude, longlat.Latitude},
-				},
-			},
-		},
-	}
-
-	// Melakukan query untuk menemukan region yang berdekatan
-	var region model.Region
-	err = Collection.FindOne(Ctx, Filter).Decode(&region)
+	region, err := regionAt(Ctx, longlat.Longitude, longlat.Latitude)
 	if err != nil {
 		var respn model.Response
 		respn.Status = "Error : Region tidak ditemukan"
@@ -195,3 +179,23 @@ func GetRegion(c *fiber.Ctx) error {
 	// Mengembalikan response dalam format GeoJSON
 	return c.Status(fiber.StatusOK).JSON(geojsonResponse)
 }
+
+// regionAt mencari region (desa/kelurahan) yang batasnya memuat titik long/lat
+func regionAt(ctx context.Context, long, lat float64) (model.Region, error) {
+	var region model.Region
+	Collection := config.MongoClient.Database("petapedia").Collection("region")
+
+	// Geospatial Query Filter untuk mencari region berdasarkan border
+	Filter := bson.M{
+		"border": bson.M{
+			"$geoIntersects": bson.M{
+				"$geometry": bson.M{
+					"type":        "Point", // Memastikan jenis geometri adalah "Point"
+					"coordinates": []float64{long, lat},
+				},
+			},
+		},
+	}
+	err := Collection.FindOne(ctx, Filter).Decode(&region)
+	return region, err
+}
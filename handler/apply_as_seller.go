@@ -6,6 +6,7 @@ import (
 	"be_ecommerce/utils"
 	"context"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"go.mongodb.org/mongo-driver/bson"
@@ -63,12 +64,19 @@ func ApplyAsSeller(c *fiber.Ctx) error {
 	}
 
 	// Validasi data tambahan
-	if storeInfo.StoreName == "" || storeInfo.FullAddress == "" || storeInfo.NIK == "" {
+	if storeInfo.StoreName == "" || (storeInfo.FullAddress == "" && storeInfo.Address == nil) || storeInfo.NIK == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": "Store name, full address, and NIK are required",
+			"message": "Store name, address, and NIK are required",
 		})
 	}
 
+	// Alamat toko terstruktur divalidasi terhadap data wilayah; full_address diisi darinya
+	if storeInfo.Address != nil {
+		if err := prepareStoreAddress(context.Background(), &storeInfo); err != nil {
+			return addressErrorResponse(c, err)
+		}
+	}
+
 	// Ambil user dari database
 	collection := config.MongoClient.Database("ecommerce").Collection("users")
 	var user model.User
@@ -79,6 +87,13 @@ func ApplyAsSeller(c *fiber.Ctx) error {
 		})
 	}
 
+	// Hanya pengguna dengan email terverifikasi yang boleh mengajukan diri sebagai seller
+	if !isEmailVerified(user) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "Please verify your email before applying as a seller",
+		})
+	}
+
 	// Periksa apakah user sudah memiliki permohonan pending
 	if user.StoreStatus != nil && *user.StoreStatus == "pending" {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -90,8 +105,9 @@ func ApplyAsSeller(c *fiber.Ctx) error {
 	pendingStatus := "pending"
 	_, err = collection.UpdateOne(context.Background(), bson.M{"_id": user.ID}, bson.M{
 		"$set": bson.M{
-			"store_status": pendingStatus,
-			"store_info":   storeInfo,
+			"store_status":     pendingStatus,
+			"store_info":       storeInfo,
+			"store_applied_at": time.Now(),
 		},
 	})
 	if err != nil {
@@ -3,7 +3,9 @@ package handler
 import (
 	"be_ecommerce/config"
 	"be_ecommerce/model"
+	"be_ecommerce/notifications"
 	"context"
+	"log"
 	"net/http"
 
 	"github.com/gofiber/fiber/v2"
@@ -17,6 +19,7 @@ func ApproveSeller(c *fiber.Ctx) error {
 	var request struct {
 		UserID string `json:"user_id"` // ID pengguna
 		Status string `json:"status"`  // "approved" atau "rejected"
+		Reason string `json:"reason"`  // Alasan penolakan (opsional)
 	}
 
 	// Parse request body
@@ -69,21 +72,32 @@ func ApproveSeller(c *fiber.Ctx) error {
 	}
 	update["roles"] = user.Roles
 
-	// Perbarui data user di database
-	_, err = collection.UpdateOne(context.Background(), bson.M{"_id": user.ID}, bson.M{"$set": update})
+	// Tentukan pesan berdasarkan status
+	message := "Application rejected"
+	tmpl := notifications.TemplateSellerRejected
+	if request.Status == "approved" {
+		message = "Application approved, user is now a seller"
+		tmpl = notifications.TemplateSellerApproved
+	}
+
+	// Perbarui data user dan beritahu pengguna tentang keputusan admin dalam satu transaksi
+	err = config.WithTransaction(context.Background(), func(sessCtx mongo.SessionContext) error {
+		if _, err := collection.UpdateOne(sessCtx, bson.M{"_id": user.ID}, bson.M{"$set": update}); err != nil {
+			return err
+		}
+		if err := pushSellerApplication(sessCtx, user.ID, request.Status, request.Reason); err != nil {
+			return err
+		}
+		return notifyUser(sessCtx, user, tmpl, sellerApplicationEmail(user, request.Reason))
+	})
 	if err != nil {
+		log.Println("Error updating seller application:", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Failed to update application status",
 		})
 	}
 
-	// Tentukan pesan berdasarkan status
-	message := "Application rejected"
-	if request.Status == "approved" {
-		message = "Application approved, user is now a seller"
-	}
-
 	return c.JSON(fiber.Map{
 		"status":  "success",
 		"message": message,
@@ -93,6 +107,7 @@ func ApproveSeller(c *fiber.Ctx) error {
 type RejectRequest struct {
 	UserID string `json:"user_id"`
 	Status string `json:"status"`
+	Reason string `json:"reason"`
 }
 
 func RejectSeller(c *fiber.Ctx) error {
@@ -151,8 +166,16 @@ func RejectSeller(c *fiber.Ctx) error {
 		"store_status": req.Status,
 	}
 
-	// Update the user in the database
-	_, err = collection.UpdateOne(context.Background(), bson.M{"_id": objectID}, bson.M{"$set": update})
+	// Update the user and notify the applicant in a single transaction
+	err = config.WithTransaction(context.Background(), func(sessCtx mongo.SessionContext) error {
+		if _, err := collection.UpdateOne(sessCtx, bson.M{"_id": objectID}, bson.M{"$set": update}); err != nil {
+			return err
+		}
+		if err := pushSellerApplication(sessCtx, objectID, req.Status, req.Reason); err != nil {
+			return err
+		}
+		return notifyUser(sessCtx, user, notifications.TemplateSellerRejected, sellerApplicationEmail(user, req.Reason))
+	})
 	if err != nil {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
 			"message": "Failed to update user status",
@@ -167,6 +190,15 @@ func RejectSeller(c *fiber.Ctx) error {
 	})
 }
 
+// sellerApplicationEmail menyusun data email keputusan pengajuan seller
+func sellerApplicationEmail(user model.User, reason string) notifications.SellerApplicationEmail {
+	email := notifications.SellerApplicationEmail{Name: user.Username, Reason: reason}
+	if user.StoreInfo != nil {
+		email.StoreName = user.StoreInfo.StoreName
+	}
+	return email
+}
+
 // Utility function to check if a role exists in roles slice
 func contains(roles []string, role string) bool {
 	for _, r := range roles {
@@ -3,6 +3,7 @@ package handler
 import (
 	"be_ecommerce/config"
 	"be_ecommerce/model"
+	"be_ecommerce/notifications"
 	"be_ecommerce/utils"
 	"context"
 	"fmt"
@@ -10,6 +11,7 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -54,17 +56,136 @@ func Register(c *fiber.Ctx) error {
 		}
 	}
 
-	// Simpan pengguna ke database
+	// Akun baru tidak pernah dalam keadaan disuspend dan emailnya belum terverifikasi
+	user.Suspended = false
+	user.Suspension = nil
+	verified := false
+	user.EmailVerified = &verified
+	user.OneTimeCodes = nil
+
+	// Simpan pengguna ke database bersama OTP dan email verifikasinya
 	user.ID = primitive.NewObjectID()
-	_, err = collection.InsertOne(context.Background(), user)
+	err = config.WithTransaction(context.Background(), func(sessCtx mongo.SessionContext) error {
+		if _, err := collection.InsertOne(sessCtx, user); err != nil {
+			return err
+		}
+		return sendVerificationEmail(sessCtx, user)
+	})
 	if err != nil {
+		fmt.Println("Error registering user:", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"message": "Error saving user to database",
 		})
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
-		"message": "User registered successfully",
+		"message": "User registered successfully, please check your email to verify your account",
+	})
+}
+
+// sendVerificationEmail membuat OTP verifikasi email lalu menitipkannya ke outbox.
+// Panggil di dalam config.WithTransaction agar OTP dan email tersimpan bersamaan.
+func sendVerificationEmail(ctx context.Context, user model.User) error {
+	code, err := issueOTP(ctx, user, otpPurposeEmailVerification)
+	if err != nil {
+		return err
+	}
+	return notifyUser(ctx, user, notifications.TemplateEmailVerification, notifications.CodeEmail{
+		Name:           user.Username,
+		Code:           code,
+		ExpiresMinutes: int(otpTTL[otpPurposeEmailVerification].Minutes()),
+	})
+}
+
+// VerifyEmail menandai email pengguna sebagai terverifikasi menggunakan OTP
+func VerifyEmail(c *fiber.Ctx) error {
+	var body struct {
+		Email string `json:"email"`
+		Code  string `json:"code"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+		})
+	}
+
+	user, err := checkOTP(context.Background(), body.Email, otpPurposeEmailVerification, body.Code)
+	if err != nil {
+		status := otpErrorStatus(err)
+		if status == fiber.StatusInternalServerError {
+			return c.Status(status).JSON(fiber.Map{
+				"message": "Failed to verify email",
+			})
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	collection := config.MongoClient.Database("ecommerce").Collection("users")
+	_, err = collection.UpdateOne(context.Background(), bson.M{"_id": user.ID}, bson.M{
+		"$set":   bson.M{"email_verified": true},
+		"$unset": bson.M{"one_time_codes." + otpPurposeEmailVerification: ""},
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to verify email",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Email verified successfully",
+	})
+}
+
+// ResendVerificationEmail mengirim ulang OTP verifikasi email dengan cooldown
+func ResendVerificationEmail(c *fiber.Ctx) error {
+	var body struct {
+		Email string `json:"email"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+		})
+	}
+
+	var user model.User
+	collection := config.MongoClient.Database("ecommerce").Collection("users")
+	err := collection.FindOne(context.Background(), bson.M{"email": body.Email}).Decode(&user)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": "Email not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch user data",
+		})
+	}
+
+	if isEmailVerified(user) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Email already verified",
+		})
+	}
+
+	err = config.WithTransaction(context.Background(), func(sessCtx mongo.SessionContext) error {
+		return sendVerificationEmail(sessCtx, user)
+	})
+	if status := otpErrorStatus(err); status == fiber.StatusTooManyRequests {
+		return c.Status(status).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	if err != nil {
+		fmt.Println("Error sending verification email:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to send verification email",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Verification email sent successfully",
 	})
 }
 
@@ -78,7 +199,6 @@ func Login(c *fiber.Ctx) error {
 	}
 
 	fmt.Println("Email:", req.Email)
-	fmt.Println("Password:", req.Password)
 
 	// Cari user di database
 	var user model.User
@@ -102,6 +222,14 @@ func Login(c *fiber.Ctx) error {
 		})
 	}
 
+	// Tolak login jika akun sedang disuspend
+	if isSuspended(user) {
+		return c.Status(fiber.StatusForbidden).JSON(suspensionResponse(user))
+	}
+	if err := liftExpiredSuspension(c.Context(), user); err != nil {
+		fmt.Println("Error lifting expired suspension:", err)
+	}
+
 	// Generate token, tambahkan seller_id jika ada
 	var sellerID string
 	if user.SellerID != nil {
@@ -115,15 +243,27 @@ func Login(c *fiber.Ctx) error {
 		})
 	}
 
-	fmt.Println("Generated token:", token)
+	// Gabungkan keranjang tamu (jika ada) ke keranjang akun
+	cartToken := req.CartToken
+	if cartToken == "" {
+		cartToken = c.Get(cartTokenHeader)
+	}
+	cartMerged := 0
+	if guestID, err := utils.ValidateCartToken(cartToken); err == nil {
+		if cartMerged, err = mergeGuestCart(c.Context(), guestID, user.ID.Hex()); err != nil {
+			fmt.Println("Error merging guest cart:", err)
+		}
+	}
 
 	// Response login
 	response := fiber.Map{
-		"status":  "success",
-		"message": "Login successful",
-		"role":    user.Roles[0],
-		"token":   token,
-		"user_id": user.ID.Hex(),
+		"status":         "success",
+		"message":        "Login successful",
+		"role":           user.Roles[0],
+		"token":          token,
+		"user_id":        user.ID.Hex(),
+		"email_verified": isEmailVerified(user),
+		"cart_merged":    cartMerged,
 	}
 
 	// Jika user memiliki seller_id, tambahkan seller info
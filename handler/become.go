@@ -2,6 +2,7 @@ package handler
 
 import (
 	"be_ecommerce/config"
+	"be_ecommerce/model"
 	"be_ecommerce/utils"
 	"context"
 	"log"
@@ -55,6 +56,19 @@ func BecomeSeller(c *fiber.Ctx) error {
 		})
 	}
 
+	// Hanya pengguna dengan email terverifikasi yang boleh mengajukan diri sebagai seller
+	verified, err := isUserEmailVerified(context.Background(), objectID)
+	if err != nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"message": "User not found",
+		})
+	}
+	if !verified {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{
+			"message": "Please verify your email before applying as a seller",
+		})
+	}
+
 	// Ambil data dari form-data
 	storeName := c.FormValue("store_name")
 	fullAddress := c.FormValue("full_address")
@@ -68,12 +82,27 @@ func BecomeSeller(c *fiber.Ctx) error {
 	}
 
 	// Validasi input
-	if storeName == "" || fullAddress == "" || nik == "" {
+	if storeName == "" || (fullAddress == "" && c.FormValue("province") == "") || nik == "" {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
-			"message": "All fields are required (store_name, full_address, nik, photo)",
+			"message": "All fields are required (store_name, full_address or structured address, nik, photo)",
 		})
 	}
 
+	// Alamat toko terstruktur dikirim sebagai field form terpisah
+	storeInfo := model.StoreInfo{StoreName: storeName, FullAddress: fullAddress}
+	if c.FormValue("province") != "" {
+		address, err := storeAddressFromForm(c)
+		if err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+		storeInfo.Address = address
+		if err := prepareStoreAddress(context.Background(), &storeInfo); err != nil {
+			return addressErrorResponse(c, err)
+		}
+	}
+
 	// Tentukan direktori upload
 	uploadDir := "uploads/seller_photos"
 	if _, err := os.Stat(uploadDir); os.IsNotExist(err) {
@@ -105,7 +134,9 @@ func BecomeSeller(c *fiber.Ctx) error {
 		"$set": bson.M{
 			"store_info": bson.M{
 				"store_name":   storeName,
-				"full_address": fullAddress,
+				"full_address": storeInfo.FullAddress,
+				"address":      storeInfo.Address,
+				"location":     storeInfo.Location,
 				"nik":          nik,
 				"photo_path":   photoPath,
 			},
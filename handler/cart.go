@@ -4,7 +4,11 @@ import (
 	"be_ecommerce/config"
 	"be_ecommerce/model"
 	"context"
+	"errors"
 	"fmt"
+	"log"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"go.mongodb.org/mongo-driver/bson"
@@ -12,7 +16,9 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
-// AddToCart menambahkan produk ke keranjang pengguna
+// AddToCart menambahkan produk ke keranjang pengguna yang login, atau ke keranjang tamu bila
+// tanpa token login. Keranjang tamu baru dibuat bila X-Cart-Token tidak dikirim; cart token-nya
+// dikembalikan di response dan harus dikirim pada request keranjang berikutnya.
 func AddToCart(c *fiber.Ctx) error {
 	var cartItem model.CartItem
 	if err := c.BodyParser(&cartItem); err != nil {
@@ -20,41 +26,118 @@ func AddToCart(c *fiber.Ctx) error {
 	}
 
 	// Validasi input
-	if cartItem.UserID == "" || cartItem.ProductID == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "User ID and Product ID are required"})
+	if cartItem.ProductID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Product ID is required"})
+	}
+	cartItem.Note = strings.TrimSpace(cartItem.Note)
+	if msg := validateCartNote(cartItem.Note); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": msg})
+	}
+	owner, _, err := resolveCartOwner(c, true)
+	if err != nil {
+		return cartOwnerError(c)
 	}
 
+	cartItem.Quantity = 1 // Default jumlah jika tidak diberikan
+	if err := addToCart(context.Background(), owner, cartItem); err != nil {
+		return cartErrorResponse(c, err)
+	}
+
+	return c.JSON(withCartToken(c, owner, fiber.Map{"message": "Product added to cart successfully"}))
+}
+
+// cartErrorResponse menulis response kesalahan addToCart
+func cartErrorResponse(c *fiber.Ctx, err error) error {
+	var checkoutErr checkoutError
+	if errors.As(err, &checkoutErr) {
+		return c.Status(checkoutErr.status).JSON(fiber.Map{"message": checkoutErr.message})
+	}
+	log.Println("Error updating cart:", err)
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to update cart"})
+}
+
+// addToCart memvalidasi produk (aktif, stok, varian) lalu menambahkan item ke keranjang pemiliknya.
+// Kesalahan validasi dikembalikan sebagai checkoutError.
+func addToCart(ctx context.Context, owner cartOwner, cartItem model.CartItem) error {
 	// Konversi ProductID ke ObjectID
 	productObjectID, err := primitive.ObjectIDFromHex(cartItem.ProductID)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid Product ID format"})
+		return checkoutError{fiber.StatusBadRequest, "Invalid Product ID format"}
 	}
 
-	cartItem.Quantity = 1                      // Default jumlah jika tidak diberikan
+	// Produk bervarian wajib memilih varian
+	var product model.Product
+	err = config.MongoClient.Database("ecommerce").Collection("products").FindOne(ctx, bson.M{"_id": productObjectID}).Decode(&product)
+	if err != nil {
+		return checkoutError{fiber.StatusNotFound, "Product not found"}
+	}
+	if !product.IsActive() {
+		return checkoutError{fiber.StatusNotFound, "Product not found"}
+	}
+	if product.OutOfStock || product.Stock <= 0 {
+		return checkoutError{fiber.StatusConflict, "Product is out of stock"}
+	}
+
+	// Harga efektif saat ditambahkan disimpan untuk mendeteksi perubahan harga di GET /cart
+	priced := []model.Product{product}
+	if err := withEffectivePrices(ctx, priced); err != nil {
+		return fmt.Errorf("price product: %w", err)
+	}
+	price := *priced[0].EffectivePrice
+	if len(product.Variants) > 0 {
+		variantID, err := primitive.ObjectIDFromHex(cartItem.VariantID)
+		if err != nil {
+			return checkoutError{fiber.StatusBadRequest, "Please choose a variant"}
+		}
+		variant, ok := product.Variant(variantID)
+		if !ok {
+			return checkoutError{fiber.StatusBadRequest, "Invalid variant"}
+		}
+		if variant.Stock <= 0 {
+			return checkoutError{fiber.StatusConflict, "Variant is out of stock"}
+		}
+		price = variantEffectivePrice(price, variant.Price)
+	} else {
+		cartItem.VariantID = ""
+	}
+
+	if cartItem.Quantity < 1 {
+		cartItem.Quantity = 1
+	}
 	cartItem.ProductID = productObjectID.Hex() // Pastikan format string
+	cartItem.UserID = owner.UserID
+	cartItem.ProductName, cartItem.Price = product.Name, price.Price
+	cartItem.Deselected = false
 
 	// Ambil koleksi keranjang
-	collection := config.MongoClient.Database("ecommerce").Collection("carts")
+	collection := getCartCollection()
 
-	// Periksa apakah keranjang sudah ada untuk user
+	// Periksa apakah keranjang sudah ada untuk pemiliknya
 	var cart model.Cart
-	err = collection.FindOne(context.Background(), bson.M{"user_id": cartItem.UserID}).Decode(&cart)
+	err = collection.FindOne(ctx, owner.filter()).Decode(&cart)
 	if err == mongo.ErrNoDocuments {
 		// Jika tidak ada keranjang, buat baru
 		cart = model.Cart{
-			UserID:   cartItem.UserID,
-			Products: []model.CartItem{cartItem},
+			UserID:    owner.UserID,
+			GuestID:   owner.GuestID,
+			Products:  []model.CartItem{cartItem},
+			UpdatedAt: time.Now(),
 		}
-		_, err = collection.InsertOne(context.Background(), cart)
+		_, err = collection.InsertOne(ctx, cart)
 		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to create cart"})
+			return fmt.Errorf("create cart: %w", err)
 		}
 	} else if err == nil {
 		// Jika keranjang sudah ada, tambahkan produk
 		found := false
 		for i, item := range cart.Products {
-			if item.ProductID == cartItem.ProductID {
+			if item.ProductID == cartItem.ProductID && item.VariantID == cartItem.VariantID {
 				cart.Products[i].Quantity += cartItem.Quantity
+				cart.Products[i].ProductName, cart.Products[i].Price = cartItem.ProductName, cartItem.Price
+				cart.Products[i].Deselected = false // Produk yang ditambahkan lagi kembali dipilih
+				if cartItem.Note != "" {
+					cart.Products[i].Note = cartItem.Note
+				}
 				found = true
 				break
 			}
@@ -63,94 +146,90 @@ func AddToCart(c *fiber.Ctx) error {
 			cart.Products = append(cart.Products, cartItem)
 		}
 
+		// Produk yang ditambahkan ke keranjang tidak lagi disimpan untuk nanti
+		if i := cartItemIndex(cart.SavedForLater, cartItem.ProductID, cartItem.VariantID); i >= 0 {
+			cart.SavedForLater = append(cart.SavedForLater[:i], cart.SavedForLater[i+1:]...)
+		}
+
 		// Perbarui keranjang
-		_, err = collection.UpdateOne(context.Background(), bson.M{"user_id": cartItem.UserID}, bson.M{"$set": bson.M{"products": cart.Products}})
+		_, err = collection.UpdateOne(ctx, owner.filter(), bson.M{"$set": bson.M{"products": cart.Products, "saved_for_later": cart.SavedForLater, "updated_at": time.Now()}})
 		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to update cart"})
+			return fmt.Errorf("update cart: %w", err)
 		}
 	} else {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Error fetching cart"})
+		return fmt.Errorf("fetch cart: %w", err)
 	}
-
-	return c.JSON(fiber.Map{"message": "Product added to cart successfully"})
+	return nil
 }
 
-// FetchCart mengambil data keranjang berdasarkan user_id
+// FetchCart mengambil keranjang pengguna yang login (token login) atau keranjang tamu (X-Cart-Token).
+// Setiap item diperiksa ulang terhadap harga, stok, status produk dan seller saat ini; perubahannya
+// dilaporkan di "warnings" per item. Item juga dikelompokkan per toko di "stores" dengan subtotal
+// item yang dipilih untuk checkout; item yang disimpan untuk nanti ada di "saved_for_later".
 func FetchCart(c *fiber.Ctx) error {
-	// Ambil user_id dari query
-	userID := c.Query("user_id")
-	if userID == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "user_id is required"})
+	owner, ok, err := resolveCartOwner(c, false)
+	if err != nil {
+		return cartOwnerError(c)
+	}
+	if !ok {
+		return c.JSON(emptyCartResponse())
 	}
 
-	// Ambil koleksi keranjang
-	cartCollection := config.MongoClient.Database("ecommerce").Collection("carts")
-	productCollection := config.MongoClient.Database("ecommerce").Collection("products")
-
-	// Cari keranjang berdasarkan user_id
+	// Cari keranjang berdasarkan pemiliknya
+	cartCollection := getCartCollection()
 	var cart model.Cart
-	err := cartCollection.FindOne(context.Background(), bson.M{"user_id": userID}).Decode(&cart)
+	err = cartCollection.FindOne(context.Background(), owner.filter()).Decode(&cart)
 	if err == mongo.ErrNoDocuments {
 		// Jika keranjang tidak ditemukan, kembalikan keranjang kosong
-		return c.JSON(fiber.Map{
-			"products": []fiber.Map{},
-		})
+		return c.JSON(emptyCartResponse())
 	} else if err != nil {
 		// Jika terjadi kesalahan, kembalikan status error
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to fetch cart"})
 	}
 
-	// Format ulang data produk sebelum dikembalikan
-	products := make([]fiber.Map, len(cart.Products))
-	for i, item := range cart.Products {
-		// Ambil data produk berdasarkan product_id
-		var product model.Product
-		productID, err := primitive.ObjectIDFromHex(item.ProductID)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid product_id"})
-		}
-
-		err = productCollection.FindOne(context.Background(), bson.M{"_id": productID}).Decode(&product)
-		if err != nil {
-			if err == mongo.ErrNoDocuments {
-				// Jika produk tidak ditemukan, gunakan data default
-				products[i] = fiber.Map{
-					"product_id":  item.ProductID,
-					"name":        "Unknown Product",
-					"image":       "/images/default.jpg", // Gambar default jika tidak ditemukan
-					"price":       item.Price,
-					"quantity":    item.Quantity,
-					"total_price": item.Price * item.Quantity,
-				}
-				continue
-			}
-
-			// Jika terjadi kesalahan lain, kembalikan status error
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to fetch product details"})
+	// Item aktif dan item yang disimpan untuk nanti diperiksa ulang bersama
+	active := len(cart.Products)
+	review := model.Cart{Products: append(append([]model.CartItem{}, cart.Products...), cart.SavedForLater...)}
+	lines, changed, err := reviewCart(context.Background(), &review)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to fetch product details"})
+	}
+	cart.Products, cart.SavedForLater = review.Products[:active], review.Products[active:]
+
+	// Simpan kuantitas yang diturunkan dan harga terbaru, kecuali keranjang sudah diubah request lain
+	if changed {
+		filter := owner.filter()
+		filter["updated_at"] = cart.UpdatedAt
+		update := bson.M{"$set": bson.M{"products": cart.Products, "saved_for_later": cart.SavedForLater}}
+		if _, err := cartCollection.UpdateOne(context.Background(), filter, update); err != nil {
+			log.Println("Error saving revalidated cart:", err)
 		}
+	}
 
-		// Jika produk ditemukan, gunakan data dari database
-		products[i] = fiber.Map{
-			"product_id":  product.ID.Hex(),
-			"name":        product.Name,
-			"image":       product.Image,
-			"price":       product.Price,
-			"quantity":    item.Quantity,
-			"total_price": product.Price * item.Quantity,
+	// Keranjang pengguna yang masih dibuka tidak ikut dikosongkan purgeExpiredData. Dicatat terpisah dari
+	// updated_at agar pengecekan perubahan bersamaan di atas tidak terganggu.
+	if owner.UserID != "" {
+		if _, err := cartCollection.UpdateOne(context.Background(), owner.filter(), bson.M{"$set": bson.M{"viewed_at": time.Now()}}); err != nil {
+			log.Println("Error recording cart view:", err)
 		}
 	}
 
-	// Kembalikan hanya properti "products"
-	return c.JSON(fiber.Map{
-		"products": products,
-	})
+	products, stores, subtotal := groupCartByStore(lines[:active], cart.StoreNotes)
+
+	// Kembalikan properti "products" (dan cart token yang diperbarui untuk tamu)
+	return c.JSON(withCartToken(c, owner, fiber.Map{
+		"products":        products,
+		"stores":          stores,
+		"saved_for_later": savedItemsResponse(lines[active:]),
+		"subtotal":        subtotal,
+	}))
 }
 
 // UpdateCartItem memperbarui kuantitas produk dalam keranjang
 func UpdateCartItem(c *fiber.Ctx) error {
 	var request struct {
-		UserID    string `json:"user_id"`
 		ProductID string `json:"product_id"`
+		VariantID string `json:"variant_id"`
 		Quantity  int    `json:"quantity"`
 	}
 
@@ -164,9 +243,12 @@ func UpdateCartItem(c *fiber.Ctx) error {
 	fmt.Printf("Request Data: %+v\n", request)
 
 	// Validasi Input
-	if request.UserID == "" {
-		fmt.Println("Error: Missing user_id")
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "User ID is required"})
+	owner, ok, err := resolveCartOwner(c, false)
+	if err != nil {
+		return cartOwnerError(c)
+	}
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Cart not found"})
 	}
 	if request.ProductID == "" {
 		fmt.Println("Error: Missing product_id")
@@ -178,11 +260,11 @@ func UpdateCartItem(c *fiber.Ctx) error {
 	}
 
 	// Proses Update Cart
-	collection := config.MongoClient.Database("ecommerce").Collection("carts")
+	collection := getCartCollection()
 	var cart model.Cart
-	err := collection.FindOne(context.Background(), bson.M{"user_id": request.UserID}).Decode(&cart)
+	err = collection.FindOne(context.Background(), owner.filter()).Decode(&cart)
 	if err == mongo.ErrNoDocuments {
-		fmt.Println("Error: Cart not found for user_id", request.UserID)
+		fmt.Println("Error: Cart not found")
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Cart not found"})
 	} else if err != nil {
 		fmt.Printf("Error: Failed to fetch cart: %v\n", err)
@@ -192,7 +274,7 @@ func UpdateCartItem(c *fiber.Ctx) error {
 	// Update Quantity
 	updated := false
 	for i, item := range cart.Products {
-		if item.ProductID == request.ProductID {
+		if item.ProductID == request.ProductID && item.VariantID == request.VariantID {
 			cart.Products[i].Quantity = request.Quantity
 			updated = true
 			fmt.Printf("Updated product %s with new quantity %d\n", request.ProductID, request.Quantity)
@@ -206,18 +288,18 @@ func UpdateCartItem(c *fiber.Ctx) error {
 	}
 
 	// Simpan Perubahan
-	_, err = collection.UpdateOne(context.Background(), bson.M{"user_id": request.UserID}, bson.M{"$set": bson.M{"products": cart.Products}})
+	_, err = collection.UpdateOne(context.Background(), owner.filter(), bson.M{"$set": bson.M{"products": cart.Products, "updated_at": time.Now()}})
 	if err != nil {
 		fmt.Printf("Error: Failed to update cart: %v\n", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to update cart"})
 	}
 
-	return c.JSON(fiber.Map{"message": "Cart updated successfully"})
+	return c.JSON(withCartToken(c, owner, fiber.Map{"message": "Cart updated successfully"}))
 }
 func RemoveFromCart(c *fiber.Ctx) error {
 	var request struct {
-		UserID    string `json:"user_id"`
 		ProductID string `json:"product_id"`
+		VariantID string `json:"variant_id"` // Kosong = hapus semua varian produk ini
 	}
 
 	if err := c.BodyParser(&request); err != nil {
@@ -226,15 +308,31 @@ func RemoveFromCart(c *fiber.Ctx) error {
 		})
 	}
 
-	if request.UserID == "" || request.ProductID == "" {
+	if request.ProductID == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": "User ID and Product ID are required",
+			"message": "Product ID is required",
+		})
+	}
+	owner, ok, err := resolveCartOwner(c, false)
+	if err != nil {
+		return cartOwnerError(c)
+	}
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "Cart not found",
 		})
 	}
 
-	collection := config.MongoClient.Database("ecommerce").Collection("carts")
-	filter := bson.M{"user_id": request.UserID}
-	update := bson.M{"$pull": bson.M{"products": bson.M{"product_id": request.ProductID}}}
+	collection := getCartCollection()
+	filter := owner.filter()
+	pull := bson.M{"product_id": request.ProductID}
+	if request.VariantID != "" {
+		pull["variant_id"] = request.VariantID
+	}
+	update := bson.M{
+		"$pull": bson.M{"products": pull},
+		"$set":  bson.M{"updated_at": time.Now()},
+	}
 
 	result, err := collection.UpdateOne(context.Background(), filter, update)
 	if err != nil || result.ModifiedCount == 0 {
@@ -243,5 +341,5 @@ func RemoveFromCart(c *fiber.Ctx) error {
 		})
 	}
 
-	return c.JSON(fiber.Map{"message": "Product removed successfully"})
+	return c.JSON(withCartToken(c, owner, fiber.Map{"message": "Product removed successfully"}))
 }
@@ -0,0 +1,392 @@
+package handler
+
+import (
+	"be_ecommerce/model"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Kode peringatan item keranjang pada GET /cart
+const (
+	cartWarningPriceChanged    = "price_changed"
+	cartWarningOutOfStock      = "out_of_stock"
+	cartWarningQuantityReduced = "quantity_reduced"
+	cartWarningProductArchived = "product_archived"
+	cartWarningSellerSuspended = "seller_suspended"
+)
+
+// cartWarning menjelaskan perubahan pada item keranjang sejak terakhir dilihat pembeli
+type cartWarning struct {
+	Code     string `json:"code"`
+	Message  string `json:"message"`
+	Previous int    `json:"previous,omitempty"` // Harga atau kuantitas sebelumnya
+	Current  int    `json:"current,omitempty"`  // Harga atau kuantitas sekarang
+}
+
+// cartLine adalah satu item keranjang setelah diperiksa ulang terhadap data produk terkini
+type cartLine struct {
+	Item      model.CartItem
+	Product   *model.Product // nil bila produk sudah tidak ada
+	Price     model.EffectivePrice
+	Variant   string
+	SKU       string
+	Image     string
+	StoreName string
+	Warnings  []cartWarning
+	Available bool // Dapat di-checkout: produk aktif, seller tidak ditangguhkan dan stok tersedia
+}
+
+// cartStore adalah item keranjang dari satu toko beserta subtotal item yang dipilih
+type cartStore struct {
+	SellerID      string      `json:"seller_id"`
+	StoreName     string      `json:"store_name"`
+	Note          string      `json:"note,omitempty"` // Catatan pembeli untuk toko ini
+	Items         []fiber.Map `json:"items"`
+	SelectedItems int         `json:"selected_items"`
+	Subtotal      int         `json:"subtotal"`
+}
+
+// cartCatalog adalah data produk, harga dan seller terkini untuk memeriksa item keranjang
+type cartCatalog struct {
+	products   map[primitive.ObjectID]model.Product
+	prices     map[primitive.ObjectID]model.EffectivePrice
+	storeNames map[primitive.ObjectID]string
+	suspended  map[primitive.ObjectID]bool
+}
+
+// reviewCart memuat produk dan seller item keranjang lalu menjalankan reviewCartLines
+func reviewCart(ctx context.Context, cart *model.Cart) (lines []cartLine, changed bool, err error) {
+	catalog, err := loadCartCatalog(ctx, cart)
+	if err != nil {
+		return nil, false, err
+	}
+	lines, changed = reviewCartLines(cart, catalog)
+	return lines, changed, nil
+}
+
+// loadCartCatalog mengambil produk, harga efektif, nama toko dan status penangguhan seller
+// untuk semua item keranjang
+func loadCartCatalog(ctx context.Context, cart *model.Cart) (cartCatalog, error) {
+	catalog := cartCatalog{
+		products:   map[primitive.ObjectID]model.Product{},
+		storeNames: map[primitive.ObjectID]string{},
+		suspended:  map[primitive.ObjectID]bool{},
+	}
+	productIDs := make([]primitive.ObjectID, 0, len(cart.Products))
+	for _, item := range cart.Products {
+		if id, err := primitive.ObjectIDFromHex(item.ProductID); err == nil {
+			productIDs = append(productIDs, id)
+		}
+	}
+	cursor, err := getProductCollection().Find(ctx, bson.M{"_id": bson.M{"$in": productIDs}})
+	if err != nil {
+		return catalog, err
+	}
+	var products []model.Product
+	if err := cursor.All(ctx, &products); err != nil {
+		return catalog, err
+	}
+	if catalog.prices, err = effectivePrices(ctx, products); err != nil {
+		return catalog, err
+	}
+	sellerIDs := []primitive.ObjectID{}
+	for _, product := range products {
+		catalog.products[product.ID] = product
+		sellerIDs = append(sellerIDs, product.SellerID)
+	}
+
+	// Nama toko dan status penangguhan seller
+	cursor, err = getUserCollection().Find(ctx, bson.M{"_id": bson.M{"$in": sellerIDs}},
+		options.Find().SetProjection(bson.M{"store_info": 1, "suspended": 1, "suspension": 1}))
+	if err != nil {
+		return catalog, err
+	}
+	var sellers []model.User
+	if err := cursor.All(ctx, &sellers); err != nil {
+		return catalog, err
+	}
+	for _, seller := range sellers {
+		if seller.StoreInfo != nil {
+			catalog.storeNames[seller.ID] = seller.StoreInfo.StoreName
+		}
+		catalog.suspended[seller.ID] = isSuspended(seller)
+	}
+	return catalog, nil
+}
+
+// reviewCartLines memeriksa ulang setiap item keranjang terhadap produk, varian, stok dan seller di
+// catalog. Kuantitas yang melebihi stok diturunkan dan harga yang dilihat pembeli diperbarui langsung
+// pada cart.Products, sehingga peringatan perubahan harga hanya muncul sekali; changed bernilai true
+// bila keranjang perlu disimpan ulang.
+func reviewCartLines(cart *model.Cart, catalog cartCatalog) (lines []cartLine, changed bool) {
+	byID, prices, storeNames, suspended := catalog.products, catalog.prices, catalog.storeNames, catalog.suspended
+	lines = make([]cartLine, 0, len(cart.Products))
+	for i := range cart.Products {
+		item := &cart.Products[i]
+		line := cartLine{Image: "/images/default.jpg", Warnings: []cartWarning{}}
+
+		productID, _ := primitive.ObjectIDFromHex(item.ProductID)
+		product, ok := byID[productID]
+		if ok {
+			line.Product = &product
+			line.Image = product.Image
+			line.StoreName = storeNames[product.SellerID]
+		}
+		if !ok || product.DeletedAt != nil || !product.IsActive() {
+			name := item.ProductName
+			if ok {
+				name = product.Name
+			}
+			line.Price = model.EffectivePrice{Price: item.Price, OriginalPrice: item.Price}
+			line.Warnings = append(line.Warnings, cartWarning{
+				Code:    cartWarningProductArchived,
+				Message: fmt.Sprintf("%s is no longer available", name),
+			})
+			line.Item = *item
+			lines = append(lines, line)
+			continue
+		}
+
+		// Item bervarian memakai harga, stok, gambar dan label variannya
+		price := prices[productID]
+		stock := product.Stock
+		if product.OutOfStock && len(product.Variants) == 0 {
+			stock = 0
+		}
+		variantOK := true
+		if len(product.Variants) > 0 {
+			variant, found := model.ProductVariant{}, false
+			if variantID, err := primitive.ObjectIDFromHex(item.VariantID); err == nil {
+				variant, found = product.Variant(variantID)
+			}
+			if found {
+				price = variantEffectivePrice(price, variant.Price)
+				line.Variant, line.SKU, stock = variant.Label(product.Options), variant.SKU, variant.Stock
+				if variant.Image != "" {
+					line.Image = variant.Image
+				}
+			} else {
+				variantOK = false
+				line.Warnings = append(line.Warnings, cartWarning{
+					Code:    cartWarningProductArchived,
+					Message: fmt.Sprintf("The selected variant of %s is no longer available", product.Name),
+				})
+			}
+		}
+		line.Price = price
+
+		if suspended[product.SellerID] {
+			line.Warnings = append(line.Warnings, cartWarning{
+				Code:    cartWarningSellerSuspended,
+				Message: fmt.Sprintf("The seller of %s is temporarily unavailable", product.Name),
+			})
+		}
+		if variantOK {
+			switch {
+			case stock <= 0:
+				line.Warnings = append(line.Warnings, cartWarning{
+					Code:    cartWarningOutOfStock,
+					Message: fmt.Sprintf("%s is out of stock", product.Name),
+				})
+			case item.Quantity > stock:
+				line.Warnings = append(line.Warnings, cartWarning{
+					Code:     cartWarningQuantityReduced,
+					Message:  fmt.Sprintf("Only %d left of %s, quantity has been reduced", stock, product.Name),
+					Previous: item.Quantity,
+					Current:  stock,
+				})
+				item.Quantity = stock
+				changed = true
+			}
+		}
+		if item.Price != price.Price {
+			// Item lama tanpa harga tersimpan tidak dianggap berubah harga
+			if item.Price > 0 {
+				line.Warnings = append(line.Warnings, cartWarning{
+					Code:     cartWarningPriceChanged,
+					Message:  fmt.Sprintf("The price of %s has changed", product.Name),
+					Previous: item.Price,
+					Current:  price.Price,
+				})
+			}
+			item.Price = price.Price
+			changed = true
+		}
+		if item.ProductName != product.Name {
+			item.ProductName = product.Name
+			changed = true
+		}
+
+		line.Available = variantOK && stock > 0 && !suspended[product.SellerID]
+		line.Item = *item
+		lines = append(lines, line)
+	}
+	return lines, changed
+}
+
+// cartLineResponse memformat item keranjang untuk response GET /cart
+func cartLineResponse(line cartLine) fiber.Map {
+	name, sellerID := line.Item.ProductName, ""
+	if line.Product != nil {
+		name, sellerID = line.Product.Name, line.Product.SellerID.Hex()
+	}
+	return fiber.Map{
+		"product_id":      line.Item.ProductID,
+		"variant_id":      line.Item.VariantID,
+		"variant":         line.Variant,
+		"sku":             line.SKU,
+		"name":            name,
+		"image":           line.Image,
+		"seller_id":       sellerID,
+		"price":           line.Price.Price,
+		"original_price":  line.Price.OriginalPrice,
+		"effective_price": line.Price,
+		"quantity":        line.Item.Quantity,
+		"total_price":     line.Price.Price * line.Item.Quantity,
+		"selected":        !line.Item.Deselected,
+		"available":       line.Available,
+		"note":            line.Item.Note,
+		"warnings":        line.Warnings,
+	}
+}
+
+// groupCartByStore mengelompokkan item keranjang per toko sesuai urutan kemunculannya, beserta
+// catatan tokonya. Subtotal hanya menghitung item yang dipilih dan dapat di-checkout.
+func groupCartByStore(lines []cartLine, storeNotes map[string]string) (products []fiber.Map, stores []cartStore, subtotal int) {
+	products = make([]fiber.Map, 0, len(lines))
+	stores = []cartStore{}
+	index := map[string]int{}
+	for _, line := range lines {
+		response := cartLineResponse(line)
+		products = append(products, response)
+
+		sellerID := response["seller_id"].(string)
+		i, ok := index[sellerID]
+		if !ok {
+			i = len(stores)
+			index[sellerID] = i
+			stores = append(stores, cartStore{SellerID: sellerID, StoreName: line.StoreName, Note: storeNotes[sellerID], Items: []fiber.Map{}})
+		}
+		stores[i].Items = append(stores[i].Items, response)
+		if !line.Item.Deselected && line.Available {
+			stores[i].SelectedItems++
+			stores[i].Subtotal += line.Price.Price * line.Item.Quantity
+			subtotal += line.Price.Price * line.Item.Quantity
+		}
+	}
+	return products, stores, subtotal
+}
+
+// savedItemsResponse memformat item yang disimpan untuk nanti; item ini tidak ikut checkout
+func savedItemsResponse(lines []cartLine) []fiber.Map {
+	items := make([]fiber.Map, 0, len(lines))
+	for _, line := range lines {
+		response := cartLineResponse(line)
+		delete(response, "selected")
+		items = append(items, response)
+	}
+	return items
+}
+
+// emptyCartResponse adalah response GET /cart untuk keranjang kosong
+func emptyCartResponse() fiber.Map {
+	return fiber.Map{
+		"products":        []fiber.Map{},
+		"stores":          []cartStore{},
+		"saved_for_later": []fiber.Map{},
+		"subtotal":        0,
+	}
+}
+
+// SelectCartItems memilih atau membatalkan pilihan item keranjang untuk checkout:
+// {"selected": false, "items": [{"product_id": "...", "variant_id": "..."}]}. Tanpa items,
+// semua item keranjang ikut diubah; variant_id kosong mencakup semua varian produk tersebut.
+func SelectCartItems(c *fiber.Ctx) error {
+	var request struct {
+		Selected *bool `json:"selected"`
+		Items    []struct {
+			ProductID string `json:"product_id"`
+			VariantID string `json:"variant_id"`
+		} `json:"items"`
+	}
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request body"})
+	}
+	if request.Selected == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Selected is required"})
+	}
+	owner, ok, err := resolveCartOwner(c, false)
+	if err != nil {
+		return cartOwnerError(c)
+	}
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Cart not found"})
+	}
+
+	collection := getCartCollection()
+	var cart model.Cart
+	err = collection.FindOne(context.Background(), owner.filter()).Decode(&cart)
+	if err == mongo.ErrNoDocuments {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Cart not found"})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Error fetching cart"})
+	}
+
+	matched, selected := 0, 0
+	for i, item := range cart.Products {
+		match := len(request.Items) == 0
+		for _, requested := range request.Items {
+			if requested.ProductID == item.ProductID && (requested.VariantID == "" || requested.VariantID == item.VariantID) {
+				match = true
+			}
+		}
+		if match {
+			cart.Products[i].Deselected = !*request.Selected
+			matched++
+		}
+		if !cart.Products[i].Deselected {
+			selected++
+		}
+	}
+	if matched == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Product not found in cart"})
+	}
+
+	_, err = collection.UpdateOne(context.Background(), owner.filter(), bson.M{"$set": bson.M{"products": cart.Products, "updated_at": time.Now()}})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to update cart"})
+	}
+
+	return c.JSON(withCartToken(c, owner, fiber.Map{
+		"message":        "Cart selection updated successfully",
+		"selected_items": selected,
+	}))
+}
+
+// removeOrderedCartItems menghapus item yang sudah dipesan dari keranjang pengguna; item lain,
+// termasuk yang tidak dipilih untuk checkout, tetap tersimpan
+func removeOrderedCartItems(ctx context.Context, userID string, items []model.OrderItem) error {
+	ordered := make(bson.A, 0, len(items))
+	for _, item := range items {
+		match := bson.M{"product_id": item.ProductID.Hex(), "variant_id": nil}
+		if item.VariantID != nil {
+			match["variant_id"] = item.VariantID.Hex()
+		}
+		ordered = append(ordered, match)
+	}
+	if len(ordered) == 0 {
+		return nil
+	}
+	_, err := getCartCollection().UpdateOne(ctx, bson.M{"user_id": userID}, bson.M{
+		"$pull": bson.M{"products": bson.M{"$or": ordered}},
+		"$set":  bson.M{"updated_at": time.Now()},
+	})
+	return err
+}
@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"be_ecommerce/model"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestReviewCartLines(t *testing.T) {
+	seller, suspendedSeller := primitive.NewObjectID(), primitive.NewObjectID()
+	variantID := primitive.NewObjectID()
+	product := model.Product{ID: primitive.NewObjectID(), Name: "Kaos", SellerID: seller, Stock: 5}
+	variantProduct := model.Product{
+		ID:       primitive.NewObjectID(),
+		Name:     "Sepatu",
+		SellerID: seller,
+		Stock:    3,
+		Options:  []model.ProductOption{{Name: "size", Values: []string{"42"}}},
+		Variants: []model.ProductVariant{{ID: variantID, SKU: "SPT-42", Options: map[string]string{"size": "42"}, Price: 250000, Stock: 3}},
+	}
+	archived := model.Product{ID: primitive.NewObjectID(), Name: "Topi", SellerID: seller, Stock: 5, Status: model.ProductArchived}
+	soldOut := model.Product{ID: primitive.NewObjectID(), Name: "Jaket", SellerID: seller, Stock: 0}
+	suspendedProduct := model.Product{ID: primitive.NewObjectID(), Name: "Tas", SellerID: suspendedSeller, Stock: 5}
+
+	catalog := cartCatalog{
+		products:   map[primitive.ObjectID]model.Product{},
+		prices:     map[primitive.ObjectID]model.EffectivePrice{},
+		storeNames: map[primitive.ObjectID]string{seller: "Toko Budi"},
+		suspended:  map[primitive.ObjectID]bool{suspendedSeller: true},
+	}
+	for _, p := range []model.Product{product, variantProduct, archived, soldOut, suspendedProduct} {
+		catalog.products[p.ID] = p
+		catalog.prices[p.ID] = model.EffectivePrice{Price: 100000, OriginalPrice: 100000}
+	}
+
+	tests := []struct {
+		name          string
+		item          model.CartItem
+		wantWarnings  []string
+		wantAvailable bool
+		wantQuantity  int
+		wantChanged   bool
+	}{
+		{
+			name:          "unchanged item",
+			item:          model.CartItem{ProductID: product.ID.Hex(), ProductName: "Kaos", Price: 100000, Quantity: 2},
+			wantWarnings:  []string{},
+			wantAvailable: true,
+			wantQuantity:  2,
+		},
+		{
+			name:          "price change is reported",
+			item:          model.CartItem{ProductID: product.ID.Hex(), ProductName: "Kaos", Price: 90000, Quantity: 1},
+			wantWarnings:  []string{cartWarningPriceChanged},
+			wantAvailable: true,
+			wantQuantity:  1,
+			wantChanged:   true,
+		},
+		{
+			name:          "legacy item without stored price is not a price change",
+			item:          model.CartItem{ProductID: product.ID.Hex(), ProductName: "Kaos", Quantity: 1},
+			wantWarnings:  []string{},
+			wantAvailable: true,
+			wantQuantity:  1,
+			wantChanged:   true,
+		},
+		{
+			name:          "quantity above stock is reduced",
+			item:          model.CartItem{ProductID: product.ID.Hex(), ProductName: "Kaos", Price: 100000, Quantity: 8},
+			wantWarnings:  []string{cartWarningQuantityReduced},
+			wantAvailable: true,
+			wantQuantity:  5,
+			wantChanged:   true,
+		},
+		{
+			name:          "variant uses its own price and stock",
+			item:          model.CartItem{ProductID: variantProduct.ID.Hex(), VariantID: variantID.Hex(), ProductName: "Sepatu", Price: 250000, Quantity: 4},
+			wantWarnings:  []string{cartWarningQuantityReduced},
+			wantAvailable: true,
+			wantQuantity:  3,
+			wantChanged:   true,
+		},
+		{
+			name:          "missing variant",
+			item:          model.CartItem{ProductID: variantProduct.ID.Hex(), VariantID: primitive.NewObjectID().Hex(), ProductName: "Sepatu", Price: 100000, Quantity: 1},
+			wantWarnings:  []string{cartWarningProductArchived},
+			wantAvailable: false,
+			wantQuantity:  1,
+		},
+		{
+			name:          "archived product",
+			item:          model.CartItem{ProductID: archived.ID.Hex(), ProductName: "Topi", Price: 100000, Quantity: 1},
+			wantWarnings:  []string{cartWarningProductArchived},
+			wantAvailable: false,
+			wantQuantity:  1,
+		},
+		{
+			name:          "deleted product",
+			item:          model.CartItem{ProductID: primitive.NewObjectID().Hex(), ProductName: "Lama", Price: 100000, Quantity: 1},
+			wantWarnings:  []string{cartWarningProductArchived},
+			wantAvailable: false,
+			wantQuantity:  1,
+		},
+		{
+			name:          "out of stock",
+			item:          model.CartItem{ProductID: soldOut.ID.Hex(), ProductName: "Jaket", Price: 100000, Quantity: 1},
+			wantWarnings:  []string{cartWarningOutOfStock},
+			wantAvailable: false,
+			wantQuantity:  1,
+		},
+		{
+			name:          "suspended seller",
+			item:          model.CartItem{ProductID: suspendedProduct.ID.Hex(), ProductName: "Tas", Price: 100000, Quantity: 1},
+			wantWarnings:  []string{cartWarningSellerSuspended},
+			wantAvailable: false,
+			wantQuantity:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cart := model.Cart{Products: []model.CartItem{tt.item}}
+			lines, changed := reviewCartLines(&cart, catalog)
+			if len(lines) != 1 {
+				t.Fatalf("got %d lines, want 1", len(lines))
+			}
+			codes := []string{}
+			for _, warning := range lines[0].Warnings {
+				codes = append(codes, warning.Code)
+			}
+			if !reflect.DeepEqual(codes, tt.wantWarnings) {
+				t.Errorf("warnings = %v, want %v", codes, tt.wantWarnings)
+			}
+			if lines[0].Available != tt.wantAvailable {
+				t.Errorf("available = %v, want %v", lines[0].Available, tt.wantAvailable)
+			}
+			if cart.Products[0].Quantity != tt.wantQuantity {
+				t.Errorf("quantity = %d, want %d", cart.Products[0].Quantity, tt.wantQuantity)
+			}
+			if changed != tt.wantChanged {
+				t.Errorf("changed = %v, want %v", changed, tt.wantChanged)
+			}
+		})
+	}
+}
+
+func TestSelectedOrderItems(t *testing.T) {
+	productID, variantID := primitive.NewObjectID(), primitive.NewObjectID()
+	lines := []cartLine{
+		{Item: model.CartItem{ProductID: productID.Hex(), VariantID: variantID.Hex(), Quantity: 2}, Available: true},
+		{Item: model.CartItem{ProductID: primitive.NewObjectID().Hex(), Quantity: 1, Deselected: true}, Available: true},
+		{Item: model.CartItem{ProductID: primitive.NewObjectID().Hex(), Quantity: 1}, Available: false},
+		{Item: model.CartItem{ProductID: "invalid", Quantity: 1}, Available: true},
+	}
+
+	items := selectedOrderItems(lines)
+	if len(items) != 1 {
+		t.Fatalf("got %d items, want 1", len(items))
+	}
+	if items[0].ProductID != productID || items[0].VariantID == nil || *items[0].VariantID != variantID || items[0].Quantity != 2 {
+		t.Errorf("unexpected item %+v", items[0])
+	}
+}
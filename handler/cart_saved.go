@@ -0,0 +1,297 @@
+package handler
+
+import (
+	"be_ecommerce/model"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// cartNoteMaxLength adalah panjang maksimum catatan item dan catatan toko, dalam karakter
+const cartNoteMaxLength = 300
+
+// cartItemRequest adalah body request yang menunjuk satu item keranjang
+type cartItemRequest struct {
+	ProductID string `json:"product_id"`
+	VariantID string `json:"variant_id"`
+}
+
+// validateCartNote memeriksa panjang catatan pembeli, string kosong berarti valid
+func validateCartNote(note string) string {
+	if len([]rune(note)) > cartNoteMaxLength {
+		return fmt.Sprintf("Note must be at most %d characters", cartNoteMaxLength)
+	}
+	return ""
+}
+
+// clipCartNote memotong catatan yang melebihi cartNoteMaxLength
+func clipCartNote(note string) string {
+	note = strings.TrimSpace(note)
+	if runes := []rune(note); len(runes) > cartNoteMaxLength {
+		return string(runes[:cartNoteMaxLength])
+	}
+	return note
+}
+
+// cartItemIndex mencari posisi item berdasarkan produk dan varian, -1 bila tidak ada
+func cartItemIndex(items []model.CartItem, productID, variantID string) int {
+	for i, item := range items {
+		if item.ProductID == productID && item.VariantID == variantID {
+			return i
+		}
+	}
+	return -1
+}
+
+// findOwnerCart mengambil keranjang milik pengguna atau tamu yang mengirim request
+func findOwnerCart(c *fiber.Ctx) (cartOwner, model.Cart, error) {
+	var cart model.Cart
+	owner, ok, err := resolveCartOwner(c, false)
+	if err != nil {
+		return owner, cart, err
+	}
+	if !ok {
+		return owner, cart, mongo.ErrNoDocuments
+	}
+	err = getCartCollection().FindOne(c.Context(), owner.filter()).Decode(&cart)
+	return owner, cart, err
+}
+
+// cartNotFound menulis response untuk kesalahan findOwnerCart
+func cartNotFound(c *fiber.Ctx, err error) error {
+	if errors.Is(err, errInvalidCartToken) {
+		return cartOwnerError(c)
+	}
+	if err == mongo.ErrNoDocuments {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Cart not found"})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Error fetching cart"})
+}
+
+// moveCartItem memindahkan item dari satu daftar ke daftar lain; kuantitas dijumlahkan bila item
+// yang sama sudah ada di tujuan. Mengembalikan false bila item tidak ada di daftar asal.
+func moveCartItem(from, to []model.CartItem, productID, variantID string) ([]model.CartItem, []model.CartItem, bool) {
+	index := cartItemIndex(from, productID, variantID)
+	if index < 0 {
+		return from, to, false
+	}
+	item := from[index]
+	from = append(from[:index], from[index+1:]...)
+	item.Deselected = false
+
+	if existing := cartItemIndex(to, productID, variantID); existing >= 0 {
+		to[existing].Quantity += item.Quantity
+		if to[existing].Note == "" {
+			to[existing].Note = item.Note
+		}
+		return from, to, true
+	}
+	return from, append(to, item), true
+}
+
+// saveCart menyimpan daftar item aktif dan item yang disimpan untuk nanti
+func saveCart(ctx context.Context, owner cartOwner, cart model.Cart) error {
+	_, err := getCartCollection().UpdateOne(ctx, owner.filter(), bson.M{"$set": bson.M{
+		"products":        cart.Products,
+		"saved_for_later": cart.SavedForLater,
+		"updated_at":      time.Now(),
+	}})
+	return err
+}
+
+// SaveForLater memindahkan item dari keranjang aktif ke daftar simpan untuk nanti:
+// {"product_id": "...", "variant_id": "..."}. Item yang disimpan tidak ikut checkout.
+func SaveForLater(c *fiber.Ctx) error {
+	var request cartItemRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request body"})
+	}
+	if request.ProductID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Product ID is required"})
+	}
+	owner, cart, err := findOwnerCart(c)
+	if err != nil {
+		return cartNotFound(c, err)
+	}
+
+	var moved bool
+	cart.Products, cart.SavedForLater, moved = moveCartItem(cart.Products, cart.SavedForLater, request.ProductID, request.VariantID)
+	if !moved {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Product not found in cart"})
+	}
+	if err := saveCart(c.Context(), owner, cart); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to update cart"})
+	}
+	return c.JSON(withCartToken(c, owner, fiber.Map{"message": "Product saved for later"}))
+}
+
+// MoveSavedToCart memindahkan item dari daftar simpan untuk nanti kembali ke keranjang aktif.
+// Ketersediaan dan harganya diperiksa ulang pada GET /cart berikutnya.
+func MoveSavedToCart(c *fiber.Ctx) error {
+	var request cartItemRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request body"})
+	}
+	if request.ProductID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Product ID is required"})
+	}
+	owner, cart, err := findOwnerCart(c)
+	if err != nil {
+		return cartNotFound(c, err)
+	}
+
+	var moved bool
+	cart.SavedForLater, cart.Products, moved = moveCartItem(cart.SavedForLater, cart.Products, request.ProductID, request.VariantID)
+	if !moved {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Product not found in saved items"})
+	}
+	if err := saveCart(c.Context(), owner, cart); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to update cart"})
+	}
+	return c.JSON(withCartToken(c, owner, fiber.Map{"message": "Product moved to cart"}))
+}
+
+// RemoveSavedItem menghapus item dari daftar simpan untuk nanti; variant_id kosong menghapus
+// semua varian produk tersebut
+func RemoveSavedItem(c *fiber.Ctx) error {
+	var request cartItemRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request body"})
+	}
+	if request.ProductID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Product ID is required"})
+	}
+	owner, ok, err := resolveCartOwner(c, false)
+	if err != nil {
+		return cartOwnerError(c)
+	}
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Cart not found"})
+	}
+
+	pull := bson.M{"product_id": request.ProductID}
+	if request.VariantID != "" {
+		pull["variant_id"] = request.VariantID
+	}
+	result, err := getCartCollection().UpdateOne(c.Context(), owner.filter(), bson.M{
+		"$pull": bson.M{"saved_for_later": pull},
+		"$set":  bson.M{"updated_at": time.Now()},
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to remove saved item"})
+	}
+	if result.ModifiedCount == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Product not found in saved items"})
+	}
+	return c.JSON(withCartToken(c, owner, fiber.Map{"message": "Saved item removed successfully"}))
+}
+
+// SetCartItemNote menyimpan catatan pembeli untuk satu item, baik di keranjang aktif maupun
+// yang disimpan untuk nanti: {"product_id": "...", "variant_id": "...", "note": "..."}.
+// Catatan kosong menghapusnya.
+func SetCartItemNote(c *fiber.Ctx) error {
+	var request struct {
+		cartItemRequest
+		Note string `json:"note"`
+	}
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request body"})
+	}
+	if request.ProductID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Product ID is required"})
+	}
+	request.Note = strings.TrimSpace(request.Note)
+	if msg := validateCartNote(request.Note); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": msg})
+	}
+	owner, cart, err := findOwnerCart(c)
+	if err != nil {
+		return cartNotFound(c, err)
+	}
+
+	if i := cartItemIndex(cart.Products, request.ProductID, request.VariantID); i >= 0 {
+		cart.Products[i].Note = request.Note
+	} else if i := cartItemIndex(cart.SavedForLater, request.ProductID, request.VariantID); i >= 0 {
+		cart.SavedForLater[i].Note = request.Note
+	} else {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Product not found in cart"})
+	}
+	if err := saveCart(c.Context(), owner, cart); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to update cart"})
+	}
+	return c.JSON(withCartToken(c, owner, fiber.Map{"message": "Note saved successfully"}))
+}
+
+// SetCartStoreNote menyimpan catatan pembeli untuk satu toko: {"seller_id": "...", "note": "..."}.
+// Catatan kosong menghapusnya.
+func SetCartStoreNote(c *fiber.Ctx) error {
+	var request struct {
+		SellerID string `json:"seller_id"`
+		Note     string `json:"note"`
+	}
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request body"})
+	}
+	sellerID, err := primitive.ObjectIDFromHex(request.SellerID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid seller ID"})
+	}
+	request.Note = strings.TrimSpace(request.Note)
+	if msg := validateCartNote(request.Note); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": msg})
+	}
+	owner, ok, err := resolveCartOwner(c, false)
+	if err != nil {
+		return cartOwnerError(c)
+	}
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Cart not found"})
+	}
+
+	field := "store_notes." + sellerID.Hex()
+	update := bson.M{"$set": bson.M{field: request.Note, "updated_at": time.Now()}}
+	if request.Note == "" {
+		update = bson.M{"$unset": bson.M{field: ""}, "$set": bson.M{"updated_at": time.Now()}}
+	}
+	result, err := getCartCollection().UpdateOne(c.Context(), owner.filter(), update)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to update cart"})
+	}
+	if result.MatchedCount == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Cart not found"})
+	}
+	return c.JSON(withCartToken(c, owner, fiber.Map{"message": "Note saved successfully"}))
+}
+
+// applyCartNotes melengkapi item order dengan catatan item dan catatan toko dari keranjang
+// pengguna, kecuali item sudah membawa catatannya sendiri dari request checkout
+func applyCartNotes(ctx context.Context, userID primitive.ObjectID, items []model.OrderItem) error {
+	var cart model.Cart
+	err := getCartCollection().FindOne(ctx, bson.M{"user_id": userID.Hex()}).Decode(&cart)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return err
+	}
+	for i, item := range items {
+		if item.Note == "" {
+			variantID := ""
+			if item.VariantID != nil {
+				variantID = item.VariantID.Hex()
+			}
+			if index := cartItemIndex(cart.Products, item.ProductID.Hex(), variantID); index >= 0 {
+				items[i].Note = cart.Products[index].Note
+			}
+		}
+		if item.StoreNote == "" {
+			items[i].StoreNote = cart.StoreNotes[item.SellerID.Hex()]
+		}
+		items[i].Note, items[i].StoreNote = clipCartNote(items[i].Note), clipCartNote(items[i].StoreNote)
+	}
+	return nil
+}
@@ -4,38 +4,129 @@ import (
 	"be_ecommerce/config"
 	"be_ecommerce/model"
 	"context"
+	"fmt"
+	"regexp"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
-// AddCategory handles adding a new category
-func AddCategory(c *fiber.Ctx) error {
-	var category model.Category
-	if err := c.BodyParser(&category); err != nil {
+// categoryInput adalah body untuk membuat kategori. ParentID kosong berarti kategori akar.
+type categoryInput struct {
+	Name       string                    `json:"name"`
+	Slug       string                    `json:"slug"`
+	ParentID   string                    `json:"parent_id"`
+	Order      int                       `json:"order"`
+	Icon       string                    `json:"icon"`
+	Attributes []model.CategoryAttribute `json:"attributes"`
+}
+
+// siblingNameTaken memeriksa apakah nama sudah dipakai kategori lain dengan induk yang sama
+func siblingNameTaken(ctx context.Context, parentID *primitive.ObjectID, name string, except primitive.ObjectID) (bool, error) {
+	filter := bson.M{
+		"name": primitive.Regex{Pattern: "^" + regexp.QuoteMeta(name) + "$", Options: "i"},
+		"_id":  bson.M{"$ne": except},
+	}
+	if parentID == nil {
+		filter["parent_id"] = bson.M{"$exists": false}
+	} else {
+		filter["parent_id"] = *parentID
+	}
+	count, err := getCategoryCollection().CountDocuments(ctx, filter)
+	return count > 0, err
+}
+
+// resolveSlug menentukan slug kategori: slug yang diminta harus belum dipakai,
+// tanpa slug dibuat otomatis dari nama
+func resolveSlug(ctx context.Context, requested, name string, except primitive.ObjectID) (string, string, error) {
+	if requested == "" {
+		slug, err := uniqueSlug(ctx, slugify(name), except)
+		return slug, "", err
+	}
+	slug := slugify(requested)
+	if slug == "" {
+		return "", "Invalid slug", nil
+	}
+	count, err := getCategoryCollection().CountDocuments(ctx, bson.M{"slug": slug, "_id": bson.M{"$ne": except}})
+	if err != nil {
+		return "", "", err
+	}
+	if count > 0 {
+		return "", "Slug is already used by another category", nil
+	}
+	return slug, "", nil
+}
+
+// createCategory menyimpan kategori baru di bawah induknya (jika ada) dan menulis response
+func createCategory(c *fiber.Ctx, input categoryInput, idKey string) error {
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": "Invalid request body",
-			"error":   err.Error(),
+			"message": "Name is required",
+		})
+	}
+	if message := validateAttributeSchema(input.Attributes); message != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": message,
 		})
 	}
 
-	// Validasi duplikasi kategori berdasarkan nama
-	collection := config.MongoClient.Database("ecommerce").Collection("categories")
-	existingCategory := model.Category{}
-	err := collection.FindOne(context.Background(), bson.M{"name": category.Name}).Decode(&existingCategory)
-	if err == nil {
+	category := model.Category{
+		ID:         primitive.NewObjectID(),
+		Name:       input.Name,
+		Ancestors:  []primitive.ObjectID{},
+		Order:      input.Order,
+		Icon:       input.Icon,
+		Attributes: input.Attributes,
+	}
+	if input.ParentID != "" {
+		parentID, err := primitive.ObjectIDFromHex(input.ParentID)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "Invalid parent ID",
+			})
+		}
+		var parent model.Category
+		if err := getCategoryCollection().FindOne(context.Background(), bson.M{"_id": parentID}).Decode(&parent); err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": "Parent category not found",
+			})
+		}
+		category.ParentID = &parent.ID
+		category.Ancestors = append(append([]primitive.ObjectID{}, parent.Ancestors...), parent.ID)
+	}
+
+	// Validasi duplikasi nama di antara kategori dengan induk yang sama
+	taken, err := siblingNameTaken(context.Background(), category.ParentID, category.Name, category.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to save category",
+		})
+	}
+	if taken {
 		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
 			"message": "Category already exists",
 		})
 	}
 
-	// Set ID untuk kategori
-	category.ID = primitive.NewObjectID()
+	slug, message, err := resolveSlug(context.Background(), input.Slug, category.Name, category.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to save category",
+		})
+	}
+	if message != "" {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"message": message,
+		})
+	}
+	category.Slug = slug
 
 	// Simpan kategori ke database
-	_, err = collection.InsertOne(context.Background(), category)
-	if err != nil {
+	if _, err := getCategoryCollection().InsertOne(context.Background(), category); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"message": "Failed to save category",
 			"error":   err.Error(),
@@ -43,224 +134,418 @@ func AddCategory(c *fiber.Ctx) error {
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
-		"message":    "Category added successfully",
-		"category_id": category.ID,
+		"message": "Category added successfully",
+		idKey:     category.ID,
+		"slug":    category.Slug,
 	})
 }
 
-// AddSubCategory handles adding a new sub-category to an existing category
+// AddCategory handles adding a new category: {"name", "slug", "parent_id", "order", "icon", "attributes"}.
+// Tanpa parent_id kategori menjadi akar.
+func AddCategory(c *fiber.Ctx) error {
+	var input categoryInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+			"error":   err.Error(),
+		})
+	}
+	return createCategory(c, input, "category_id")
+}
+
+// AddSubCategory handles adding a new sub-category to an existing category.
+// Dipertahankan untuk klien lama; sama dengan AddCategory dengan parent_id = category_id.
 func AddSubCategory(c *fiber.Ctx) error {
 	var request struct {
-		CategoryID primitive.ObjectID `json:"category_id"`
-		Name       string             `json:"name"`
+		categoryInput
+		CategoryID string `json:"category_id"`
 	}
-
 	if err := c.BodyParser(&request); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"message": "Invalid request body",
 			"error":   err.Error(),
 		})
 	}
-
-	// Periksa apakah kategori ada
-	collection := config.MongoClient.Database("ecommerce").Collection("categories")
-	var category model.Category
-	err := collection.FindOne(context.Background(), bson.M{"_id": request.CategoryID}).Decode(&category)
-	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"message": "Category not found",
+	if request.CategoryID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Category ID is required",
 		})
 	}
+	request.categoryInput.ParentID = request.CategoryID
+	return createCategory(c, request.categoryInput, "sub_category_id")
+}
 
-	// Validasi jika sub-kategori dengan nama yang sama sudah ada
-	for _, subCategory := range category.SubCategories {
-		if subCategory.Name == request.Name {
-			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
-				"message": "Sub-category already exists in this category",
-			})
-		}
-	}
-
-	// Tambahkan sub-kategori
-	subCategory := model.SubCategory{
-		ID:   primitive.NewObjectID(),
-		Name: request.Name,
-	}
-	category.SubCategories = append(category.SubCategories, subCategory)
-
-	// Perbarui kategori dengan sub-kategori baru
-	_, err = collection.UpdateOne(context.Background(), bson.M{"_id": request.CategoryID}, bson.M{
-		"$set": bson.M{"sub_categories": category.SubCategories},
-	})
+// GetCategories handles fetching the category tree. Setiap kategori berisi children
+// (seluruh turunan) dan sub_categories (anak langsung, untuk klien lama), urut berdasarkan order.
+func GetCategories(c *fiber.Ctx) error {
+	tree, err := loadCategoryTree(context.Background())
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Failed to add sub-category",
+			"message": "Failed to fetch categories",
 			"error":   err.Error(),
 		})
 	}
 
+	categories := []model.Category{}
+	for _, root := range tree.children[primitive.NilObjectID] {
+		categories = append(categories, tree.node(root))
+	}
+
 	return c.JSON(fiber.Map{
-		"message":        "Sub-category added successfully",
-		"sub_category_id": subCategory.ID,
+		"message": "Categories fetched successfully",
+		"data":    categories,
 	})
 }
 
-// GetCategories handles fetching all categories and their sub-categories
-func GetCategories(c *fiber.Ctx) error {
-	collection := config.MongoClient.Database("ecommerce").Collection("categories")
-
-	cursor, err := collection.Find(context.Background(), bson.M{})
+// GetCategory mengembalikan satu kategori berdasarkan ID atau slug beserta breadcrumb,
+// turunan dan skema atribut efektif (termasuk warisan induk)
+func GetCategory(c *fiber.Ctx) error {
+	tree, err := loadCategoryTree(context.Background())
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Failed to fetch categories",
-			"error":   err.Error(),
+			"message": "Failed to fetch category",
 		})
 	}
-	defer cursor.Close(context.Background())
-
-	var categories []model.Category
-	if err := cursor.All(context.Background(), &categories); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Failed to parse categories",
-			"error":   err.Error(),
+	category, ok := tree.find(c.Params("id"))
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "Category not found",
 		})
 	}
 
+	category = tree.node(category)
+	category.Breadcrumb = tree.breadcrumb(category.ID)
+	category.Schema = tree.schema(category.ID)
+	if category.Schema == nil {
+		category.Schema = []model.CategoryAttribute{}
+	}
+
 	return c.JSON(fiber.Map{
-		"message": "Categories fetched successfully",
-		"data":    categories,
+		"message": "Category fetched successfully",
+		"data":    category,
 	})
 }
-// UpdateCategory handles updating a category by its ID
-func UpdateCategory(c *fiber.Ctx) error {
-	id := c.Params("id")
-	objectID, err := primitive.ObjectIDFromHex(id)
+
+// updateCategory mengubah kategori. Field yang tidak dikirim tidak berubah; parent_id ""
+// memindahkan kategori menjadi akar. Saat dipindah, ancestors turunannya dan category_path
+// produk di dalamnya ikut diperbarui dalam satu transaksi; perpindahan ditolak bila atribut
+// produk tidak valid terhadap skema induk baru.
+func updateCategory(c *fiber.Ctx, label string) error {
+	objectID, err := primitive.ObjectIDFromHex(c.Params("id"))
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": "Invalid category ID",
+			"message": fmt.Sprintf("Invalid %s ID", strings.ToLower(label)),
 		})
 	}
 
 	var payload struct {
-		Name string `json:"name"`
+		Name       *string                    `json:"name"`
+		Slug       *string                    `json:"slug"`
+		ParentID   *string                    `json:"parent_id"`
+		Order      *int                       `json:"order"`
+		Icon       *string                    `json:"icon"`
+		Attributes *[]model.CategoryAttribute `json:"attributes"`
 	}
-
 	if err := c.BodyParser(&payload); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"message": "Invalid request body",
 		})
 	}
 
-	collection := config.MongoClient.Database("ecommerce").Collection("categories")
-	filter := bson.M{"_id": objectID}
-	update := bson.M{"$set": bson.M{"name": payload.Name}}
-
-	_, err = collection.UpdateOne(context.Background(), filter, update)
+	tree, err := loadCategoryTree(context.Background())
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Failed to update category",
+			"message": fmt.Sprintf("Failed to update %s", strings.ToLower(label)),
+		})
+	}
+	category, ok := tree.byID[objectID]
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": fmt.Sprintf("%s not found", label),
 		})
 	}
 
-	return c.JSON(fiber.Map{
-		"message": "Category updated successfully",
-	})
-}
+	set := bson.M{}
+	unset := bson.M{}
+	name := category.Name
+	if payload.Name != nil {
+		name = strings.TrimSpace(*payload.Name)
+		if name == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "Name is required",
+			})
+		}
+		set["name"] = name
+	}
+	if payload.Order != nil {
+		set["order"] = *payload.Order
+	}
+	if payload.Icon != nil {
+		set["icon"] = *payload.Icon
+	}
+	if payload.Attributes != nil {
+		if message := validateAttributeSchema(*payload.Attributes); message != "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": message,
+			})
+		}
+		set["attributes"] = *payload.Attributes
+	}
+	if payload.Slug != nil {
+		slug, message, err := resolveSlug(context.Background(), *payload.Slug, name, category.ID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": fmt.Sprintf("Failed to update %s", strings.ToLower(label)),
+			})
+		}
+		if message != "" {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"message": message,
+			})
+		}
+		set["slug"] = slug
+	}
 
-// UpdateSubCategory handles updating a sub-category by its ID
-func UpdateSubCategory(c *fiber.Ctx) error {
-	id := c.Params("id")
-	objectID, err := primitive.ObjectIDFromHex(id)
-	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": "Invalid sub-category ID",
-		})
+	// Tentukan induk baru bila kategori dipindah
+	parentID := category.ParentID
+	moved := false
+	newAncestors := category.Ancestors
+	if payload.ParentID != nil {
+		parentID = nil
+		newAncestors = []primitive.ObjectID{}
+		if *payload.ParentID != "" {
+			id, err := primitive.ObjectIDFromHex(*payload.ParentID)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"message": "Invalid parent ID",
+				})
+			}
+			parent, ok := tree.byID[id]
+			if !ok {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"message": "Parent category not found",
+				})
+			}
+			if tree.inSubtree(parent, category.ID) {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"message": "A category cannot be moved into itself or its descendants",
+				})
+			}
+			parentID = &parent.ID
+			newAncestors = tree.path(parent.ID)
+		}
+		moved = (parentID == nil) != (category.ParentID == nil) || (parentID != nil && *parentID != *category.ParentID)
+		if moved {
+			set["ancestors"] = newAncestors
+			if parentID == nil {
+				unset["parent_id"] = ""
+			} else {
+				set["parent_id"] = *parentID
+			}
+		}
 	}
 
-	var payload struct {
-		CategoryID primitive.ObjectID `json:"category_id"`
-		Name       string             `json:"name"`
+	if payload.Name != nil || moved {
+		taken, err := siblingNameTaken(context.Background(), parentID, name, category.ID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": fmt.Sprintf("Failed to update %s", strings.ToLower(label)),
+			})
+		}
+		if taken {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"message": "Category already exists",
+			})
+		}
 	}
 
-	if err := c.BodyParser(&payload); err != nil {
+	// Atribut produk di dalam kategori harus tetap valid terhadap skema warisan induk barunya
+	if moved {
+		movedTree := tree.withParent(category.ID, parentID)
+		count, issues, err := productsFailingSchema(context.Background(), bson.M{"category_path": category.ID}, movedTree.schema)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": fmt.Sprintf("Failed to update %s", strings.ToLower(label)),
+			})
+		}
+		if count > 0 {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"message":  fmt.Sprintf("%d products do not match the attributes of the new parent, update them first", count),
+				"products": issues,
+			})
+		}
+	}
+
+	update := bson.M{}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	if len(update) == 0 {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": "Invalid request body",
+			"message": "Nothing to update",
 		})
 	}
 
-	collection := config.MongoClient.Database("ecommerce").Collection("categories")
-	filter := bson.M{"_id": payload.CategoryID, "sub_categories._id": objectID}
-	update := bson.M{"$set": bson.M{"sub_categories.$.name": payload.Name}}
+	err = config.WithTransaction(context.Background(), func(sessCtx mongo.SessionContext) error {
+		if _, err := getCategoryCollection().UpdateOne(sessCtx, bson.M{"_id": category.ID}, update); err != nil {
+			return err
+		}
+		if !moved {
+			return nil
+		}
 
-	_, err = collection.UpdateOne(context.Background(), filter, update)
+		// Ganti bagian jalur di atas kategori ini dengan induk barunya
+		rebase := func(field string) bson.M {
+			return bson.M{"$concatArrays": bson.A{
+				newAncestors,
+				bson.M{"$slice": bson.A{"$" + field, bson.M{"$indexOfArray": bson.A{"$" + field, category.ID}}, 1000}},
+			}}
+		}
+		_, err := getCategoryCollection().UpdateMany(sessCtx,
+			bson.M{"ancestors": category.ID},
+			mongo.Pipeline{{{Key: "$set", Value: bson.M{"ancestors": rebase("ancestors")}}}},
+		)
+		if err != nil {
+			return err
+		}
+		_, err = getProductCollection().UpdateMany(sessCtx,
+			bson.M{"category_path": category.ID},
+			mongo.Pipeline{
+				{{Key: "$set", Value: bson.M{"category_path": rebase("category_path")}}},
+				{{Key: "$set", Value: bson.M{"category_id": bson.M{"$arrayElemAt": bson.A{"$category_path", 0}}}}},
+			},
+		)
+		return err
+	})
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Failed to update sub-category",
+			"message": fmt.Sprintf("Failed to update %s", strings.ToLower(label)),
 		})
 	}
 
 	return c.JSON(fiber.Map{
-		"message": "Sub-category updated successfully",
+		"message": fmt.Sprintf("%s updated successfully", label),
 	})
 }
 
-// DeleteCategory handles deleting a category by its ID
-func DeleteCategory(c *fiber.Ctx) error {
-	id := c.Params("id")
-	objectID, err := primitive.ObjectIDFromHex(id)
+// UpdateCategory handles updating a category by its ID
+func UpdateCategory(c *fiber.Ctx) error {
+	return updateCategory(c, "Category")
+}
+
+// UpdateSubCategory handles updating a sub-category by its ID. Sub-kategori kini adalah
+// kategori biasa sehingga category_id di body tidak lagi diperlukan.
+func UpdateSubCategory(c *fiber.Ctx) error {
+	return updateCategory(c, "Sub-category")
+}
+
+// deleteCategory menghapus kategori tanpa turunan. Bila masih ada produk di dalamnya,
+// ?reassign_to= wajib diisi kategori tujuan (tanpa turunan) yang skemanya cocok dengan atribut
+// produk, lalu produk dipindahkan ke sana.
+func deleteCategory(c *fiber.Ctx, label string) error {
+	objectID, err := primitive.ObjectIDFromHex(c.Params("id"))
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": "Invalid category ID",
+			"message": fmt.Sprintf("Invalid %s ID", strings.ToLower(label)),
 		})
 	}
 
-	collection := config.MongoClient.Database("ecommerce").Collection("categories")
-	_, err = collection.DeleteOne(context.Background(), bson.M{"_id": objectID})
+	tree, err := loadCategoryTree(context.Background())
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Failed to delete category",
+			"message": fmt.Sprintf("Failed to delete %s", strings.ToLower(label)),
 		})
 	}
-
-	return c.JSON(fiber.Map{
-		"message": "Category deleted successfully",
-	})
-}
-
-// DeleteSubCategory handles deleting a sub-category by its ID
-func DeleteSubCategory(c *fiber.Ctx) error {
-	id := c.Params("id")
-	objectID, err := primitive.ObjectIDFromHex(id)
-	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": "Invalid sub-category ID",
+	if _, ok := tree.byID[objectID]; !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": fmt.Sprintf("%s not found", label),
 		})
 	}
-
-	var payload struct {
-		CategoryID primitive.ObjectID `json:"category_id"`
+	if len(tree.children[objectID]) > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"message": "Move or delete the sub-categories first",
+		})
 	}
 
-	if err := c.BodyParser(&payload); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": "Invalid request body",
+	productFilter := bson.M{"category_path": objectID}
+	products, err := getProductCollection().CountDocuments(context.Background(), productFilter)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": fmt.Sprintf("Failed to delete %s", strings.ToLower(label)),
 		})
 	}
 
-	collection := config.MongoClient.Database("ecommerce").Collection("categories")
-	filter := bson.M{"_id": payload.CategoryID}
-	update := bson.M{
-		"$pull": bson.M{"sub_categories": bson.M{"_id": objectID}},
+	var target model.Category
+	if products > 0 {
+		targetID, err := primitive.ObjectIDFromHex(c.Query("reassign_to"))
+		if err != nil {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"message":  fmt.Sprintf("%d products still use this %s, set reassign_to to move them", products, strings.ToLower(label)),
+				"products": products,
+			})
+		}
+		var ok bool
+		target, ok = tree.byID[targetID]
+		if !ok || target.ID == objectID || len(tree.children[target.ID]) > 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "reassign_to must be another category without sub-categories",
+			})
+		}
+
+		// Atribut produk harus valid terhadap skema kategori tujuan
+		targetSchema := tree.schema(target.ID)
+		count, issues, err := productsFailingSchema(context.Background(), productFilter, func(primitive.ObjectID) []model.CategoryAttribute {
+			return targetSchema
+		})
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": fmt.Sprintf("Failed to delete %s", strings.ToLower(label)),
+			})
+		}
+		if count > 0 {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"message":  fmt.Sprintf("%d products do not match the attributes of %s, update them or choose another category", count, target.Name),
+				"products": issues,
+			})
+		}
 	}
 
-	_, err = collection.UpdateOne(context.Background(), filter, update)
+	err = config.WithTransaction(context.Background(), func(sessCtx mongo.SessionContext) error {
+		if products > 0 {
+			path := tree.path(target.ID)
+			_, err := getProductCollection().UpdateMany(sessCtx, productFilter, bson.M{"$set": bson.M{
+				"category_path":   path,
+				"category_id":     path[0],
+				"sub_category_id": target.ID,
+			}})
+			if err != nil {
+				return err
+			}
+		}
+		_, err := getCategoryCollection().DeleteOne(sessCtx, bson.M{"_id": objectID})
+		return err
+	})
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Failed to delete sub-category",
+			"message": fmt.Sprintf("Failed to delete %s", strings.ToLower(label)),
 		})
 	}
 
 	return c.JSON(fiber.Map{
-		"message": "Sub-category deleted successfully",
+		"message":             fmt.Sprintf("%s deleted successfully", label),
+		"reassigned_products": products,
 	})
-}
\ No newline at end of file
+}
+
+// DeleteCategory handles deleting a category by its ID
+func DeleteCategory(c *fiber.Ctx) error {
+	return deleteCategory(c, "Category")
+}
+
+// DeleteSubCategory handles deleting a sub-category by its ID
+func DeleteSubCategory(c *fiber.Ctx) error {
+	return deleteCategory(c, "Sub-category")
+}
@@ -0,0 +1,522 @@
+package handler
+
+import (
+	"be_ecommerce/config"
+	"be_ecommerce/model"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func getCategoryCollection() *mongo.Collection {
+	return config.MongoClient.Database("ecommerce").Collection("categories")
+}
+
+// categoryTree adalah seluruh kategori yang dimuat sekali untuk validasi dan tampilan.
+// Jumlah kategori kecil sehingga lebih murah dimuat utuh daripada di-query per langkah.
+type categoryTree struct {
+	byID     map[primitive.ObjectID]model.Category
+	children map[primitive.ObjectID][]model.Category // Kunci ObjectID kosong untuk kategori akar
+}
+
+func loadCategoryTree(ctx context.Context) (categoryTree, error) {
+	tree := categoryTree{
+		byID:     map[primitive.ObjectID]model.Category{},
+		children: map[primitive.ObjectID][]model.Category{},
+	}
+	cursor, err := getCategoryCollection().Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "order", Value: 1}, {Key: "name", Value: 1}}))
+	if err != nil {
+		return tree, err
+	}
+	var categories []model.Category
+	if err := cursor.All(ctx, &categories); err != nil {
+		return tree, err
+	}
+	for _, category := range categories {
+		tree.byID[category.ID] = category
+		parent := primitive.NilObjectID
+		if category.ParentID != nil {
+			parent = *category.ParentID
+		}
+		tree.children[parent] = append(tree.children[parent], category)
+	}
+	return tree, nil
+}
+
+// path mengembalikan ID dari akar sampai kategori itu sendiri
+func (t categoryTree) path(id primitive.ObjectID) []primitive.ObjectID {
+	category := t.byID[id]
+	return append(append([]primitive.ObjectID{}, category.Ancestors...), id)
+}
+
+func (t categoryTree) breadcrumb(id primitive.ObjectID) []model.CategoryCrumb {
+	crumbs := []model.CategoryCrumb{}
+	for _, step := range t.path(id) {
+		if category, ok := t.byID[step]; ok {
+			crumbs = append(crumbs, model.CategoryCrumb{ID: category.ID, Name: category.Name, Slug: category.Slug})
+		}
+	}
+	return crumbs
+}
+
+// schema menggabungkan atribut kategori dan semua induknya; atribut dengan nama yang sama
+// di kategori yang lebih dalam menggantikan milik induknya
+func (t categoryTree) schema(id primitive.ObjectID) []model.CategoryAttribute {
+	var schema []model.CategoryAttribute
+	index := map[string]int{}
+	for _, step := range t.path(id) {
+		for _, attribute := range t.byID[step].Attributes {
+			if i, ok := index[strings.ToLower(attribute.Name)]; ok {
+				schema[i] = attribute
+				continue
+			}
+			index[strings.ToLower(attribute.Name)] = len(schema)
+			schema = append(schema, attribute)
+		}
+	}
+	return schema
+}
+
+// node menyusun kategori beserta seluruh turunannya untuk response
+func (t categoryTree) node(category model.Category) model.Category {
+	category.Children = []model.Category{}
+	category.SubCategories = []model.SubCategory{}
+	for _, child := range t.children[category.ID] {
+		category.Children = append(category.Children, t.node(child))
+		category.SubCategories = append(category.SubCategories, model.SubCategory{ID: child.ID, Name: child.Name})
+	}
+	return category
+}
+
+// find mencari kategori berdasarkan ID atau slug
+func (t categoryTree) find(value string) (model.Category, bool) {
+	if id, err := primitive.ObjectIDFromHex(value); err == nil {
+		category, ok := t.byID[id]
+		return category, ok
+	}
+	for _, category := range t.byID {
+		if category.Slug == value {
+			return category, true
+		}
+	}
+	return model.Category{}, false
+}
+
+// findRoot mencari kategori akar berdasarkan ID, slug atau nama
+func (t categoryTree) findRoot(value string) (model.Category, bool) {
+	if category, ok := t.find(value); ok {
+		return category, category.ParentID == nil
+	}
+	for _, category := range t.children[primitive.NilObjectID] {
+		if strings.EqualFold(category.Name, value) {
+			return category, true
+		}
+	}
+	return model.Category{}, false
+}
+
+// findDescendant mencari turunan akar berdasarkan ID, slug atau nama. Mengembalikan pesan
+// kesalahan bila tidak ditemukan atau nama dipakai lebih dari satu kategori.
+func (t categoryTree) findDescendant(root model.Category, value string) (model.Category, string) {
+	if category, ok := t.find(value); ok && t.inSubtree(category, root.ID) {
+		return category, ""
+	}
+	var matches []model.Category
+	for _, category := range t.byID {
+		if t.inSubtree(category, root.ID) && category.ID != root.ID && strings.EqualFold(category.Name, value) {
+			matches = append(matches, category)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return model.Category{}, fmt.Sprintf("Sub-category %q does not belong to category %q", value, root.Name)
+	case 1:
+		return matches[0], ""
+	default:
+		return model.Category{}, fmt.Sprintf("Sub-category name %q is ambiguous, use its slug or ID", value)
+	}
+}
+
+// inSubtree memeriksa apakah kategori adalah root itu sendiri atau turunannya
+func (t categoryTree) inSubtree(category model.Category, root primitive.ObjectID) bool {
+	if category.ID == root {
+		return true
+	}
+	for _, ancestor := range category.Ancestors {
+		if ancestor == root {
+			return true
+		}
+	}
+	return false
+}
+
+// withParent mengembalikan salinan pohon dengan kategori id dipindah ke bawah parentID (nil
+// berarti akar) beserta ancestors turunannya, untuk memeriksa skema sebelum perpindahan disimpan.
+// Hanya byID yang diperbarui, cukup untuk path dan schema.
+func (t categoryTree) withParent(id primitive.ObjectID, parentID *primitive.ObjectID) categoryTree {
+	newAncestors := []primitive.ObjectID{}
+	if parentID != nil {
+		newAncestors = t.path(*parentID)
+	}
+	moved := categoryTree{byID: make(map[primitive.ObjectID]model.Category, len(t.byID)), children: t.children}
+	for key, category := range t.byID {
+		switch {
+		case category.ID == id:
+			category.ParentID = parentID
+			category.Ancestors = newAncestors
+		case t.inSubtree(category, id):
+			for i, ancestor := range category.Ancestors {
+				if ancestor == id {
+					category.Ancestors = append(append([]primitive.ObjectID{}, newAncestors...), category.Ancestors[i:]...)
+					break
+				}
+			}
+		}
+		moved.byID[key] = category
+	}
+	return moved
+}
+
+// categoryAssignment adalah kategori dan atribut produk yang sudah divalidasi
+type categoryAssignment struct {
+	Path       []primitive.ObjectID
+	Attributes map[string]string
+}
+
+// assign memvalidasi pasangan category_id (akar) dan sub_category_id (kategori terdalam,
+// boleh sama dengan akar bila akar tidak punya turunan) serta atribut produk terhadap
+// skema kategorinya. Mengembalikan pesan kesalahan, atau string kosong jika valid.
+func (t categoryTree) assign(categoryID, subCategoryID primitive.ObjectID, attributes map[string]string) (categoryAssignment, string) {
+	root, ok := t.byID[categoryID]
+	if !ok || root.ParentID != nil {
+		return categoryAssignment{}, "Invalid Category ID"
+	}
+	leaf, ok := t.byID[subCategoryID]
+	if !ok || !t.inSubtree(leaf, root.ID) || (leaf.ID == root.ID && len(t.children[root.ID]) > 0) {
+		return categoryAssignment{}, "Invalid Sub-Category ID"
+	}
+	normalized, message := validateProductAttributes(t.schema(leaf.ID), attributes)
+	if message != "" {
+		return categoryAssignment{}, message
+	}
+	return categoryAssignment{Path: t.path(leaf.ID), Attributes: normalized}, ""
+}
+
+// assignCategory memuat pohon kategori lalu menjalankan categoryTree.assign
+func assignCategory(ctx context.Context, categoryID, subCategoryID primitive.ObjectID, attributes map[string]string) (categoryAssignment, string, error) {
+	tree, err := loadCategoryTree(ctx)
+	if err != nil {
+		return categoryAssignment{}, "", err
+	}
+	assignment, message := tree.assign(categoryID, subCategoryID, attributes)
+	return assignment, message, nil
+}
+
+// assignProductCategory membaca atribut dari field form "attributes" lalu menjalankan assignCategory
+func assignProductCategory(ctx context.Context, categoryID, subCategoryID primitive.ObjectID, rawAttributes string) (categoryAssignment, string, error) {
+	attributes, message := parseAttributesInput(rawAttributes)
+	if message != "" {
+		return categoryAssignment{}, message, nil
+	}
+	return assignCategory(ctx, categoryID, subCategoryID, attributes)
+}
+
+// categoryUpdateFields melengkapi update produk dengan category_path dan atribut yang sudah
+// divalidasi bila kategori atau atribut berubah. Nilai yang tidak dikirim diambil dari produk lama.
+func categoryUpdateFields(ctx context.Context, productID primitive.ObjectID, updateData bson.M, rawAttributes string) (string, error) {
+	categoryID, categoryChanged := updateData["category_id"].(primitive.ObjectID)
+	subCategoryID, subCategoryChanged := updateData["sub_category_id"].(primitive.ObjectID)
+	if !categoryChanged && !subCategoryChanged && rawAttributes == "" {
+		return "", nil
+	}
+
+	var existing model.Product
+	if err := getProductCollection().FindOne(ctx, bson.M{"_id": productID}).Decode(&existing); err != nil {
+		return "", err
+	}
+	if !categoryChanged {
+		categoryID = existing.CategoryID
+	}
+	if !subCategoryChanged {
+		subCategoryID = existing.SubCategoryID
+	}
+	attributes := existing.Attributes
+	if rawAttributes != "" {
+		var message string
+		if attributes, message = parseAttributesInput(rawAttributes); message != "" {
+			return message, nil
+		}
+	}
+
+	assignment, message, err := assignCategory(ctx, categoryID, subCategoryID, attributes)
+	if err != nil || message != "" {
+		return message, err
+	}
+	updateData["category_id"] = categoryID
+	updateData["sub_category_id"] = subCategoryID
+	updateData["category_path"] = assignment.Path
+	updateData["attributes"] = assignment.Attributes
+	return "", nil
+}
+
+// maxReportedProductIssues membatasi jumlah produk bermasalah yang dicantumkan di response
+const maxReportedProductIssues = 50
+
+// categoryProductIssue adalah produk yang atributnya tidak valid terhadap skema kategori barunya
+type categoryProductIssue struct {
+	ID      primitive.ObjectID `json:"id"`
+	Name    string             `json:"name"`
+	Message string             `json:"message"`
+}
+
+// productsFailingSchema memeriksa atribut produk yang cocok dengan filter terhadap skema kategori
+// terdalam yang dikembalikan schemaFor. Mengembalikan jumlah produk yang tidak valid beserta
+// rincian paling banyak maxReportedProductIssues produk.
+func productsFailingSchema(ctx context.Context, filter bson.M, schemaFor func(leaf primitive.ObjectID) []model.CategoryAttribute) (int, []categoryProductIssue, error) {
+	cursor, err := getProductCollection().Find(ctx, filter, options.Find().SetProjection(bson.M{
+		"name": 1, "sub_category_id": 1, "attributes": 1,
+	}))
+	if err != nil {
+		return 0, nil, err
+	}
+	defer cursor.Close(ctx)
+
+	count := 0
+	issues := []categoryProductIssue{}
+	for cursor.Next(ctx) {
+		var product model.Product
+		if err := cursor.Decode(&product); err != nil {
+			return 0, nil, err
+		}
+		if _, message := validateProductAttributes(schemaFor(product.SubCategoryID), product.Attributes); message != "" {
+			count++
+			if len(issues) < maxReportedProductIssues {
+				issues = append(issues, categoryProductIssue{ID: product.ID, Name: product.Name, Message: message})
+			}
+		}
+	}
+	return count, issues, cursor.Err()
+}
+
+// parseAttributesInput membaca atribut produk dari field form "attributes" berupa objek JSON,
+// contoh {"RAM": "8 GB", "Screen size": 6.5}
+func parseAttributesInput(raw string) (map[string]string, string) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, ""
+	}
+	var values map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &values); err != nil {
+		return nil, "Attributes must be a JSON object"
+	}
+	attributes := make(map[string]string, len(values))
+	for name, value := range values {
+		if value != nil {
+			attributes[name] = fmt.Sprint(value)
+		}
+	}
+	return attributes, ""
+}
+
+// validateProductAttributes memeriksa atribut produk terhadap skema kategori dan menyeragamkan
+// penulisannya (nama atribut, pilihan select, boolean). Mengembalikan pesan kesalahan, atau string kosong jika valid.
+func validateProductAttributes(schema []model.CategoryAttribute, values map[string]string) (map[string]string, string) {
+	definitions := map[string]model.CategoryAttribute{}
+	for _, attribute := range schema {
+		definitions[strings.ToLower(attribute.Name)] = attribute
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	normalized := map[string]string{}
+	for _, name := range names {
+		value := strings.TrimSpace(values[name])
+		if value == "" {
+			continue
+		}
+		attribute, ok := definitions[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return nil, fmt.Sprintf("Unknown attribute %q for this category", name)
+		}
+		switch attribute.Type {
+		case model.AttributeNumber:
+			if _, err := strconv.ParseFloat(value, 64); err != nil {
+				return nil, fmt.Sprintf("Attribute %s must be a number", attribute.Name)
+			}
+		case model.AttributeBoolean:
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Sprintf("Attribute %s must be true or false", attribute.Name)
+			}
+			value = strconv.FormatBool(b)
+		case model.AttributeSelect:
+			matched := ""
+			for _, option := range attribute.Options {
+				if strings.EqualFold(option, value) {
+					matched = option
+				}
+			}
+			if matched == "" {
+				return nil, fmt.Sprintf("Attribute %s must be one of %s", attribute.Name, strings.Join(attribute.Options, ", "))
+			}
+			value = matched
+		}
+		normalized[attribute.Name] = value
+	}
+
+	for _, attribute := range schema {
+		if _, ok := normalized[attribute.Name]; attribute.Required && !ok {
+			return nil, fmt.Sprintf("Attribute %s is required", attribute.Name)
+		}
+	}
+	if len(normalized) == 0 {
+		return nil, ""
+	}
+	return normalized, ""
+}
+
+// validateAttributeSchema memeriksa skema atribut kategori. Mengembalikan pesan kesalahan,
+// atau string kosong jika valid.
+func validateAttributeSchema(attributes []model.CategoryAttribute) string {
+	seen := map[string]bool{}
+	for i, attribute := range attributes {
+		attribute.Name = strings.TrimSpace(attribute.Name)
+		if attribute.Name == "" || seen[strings.ToLower(attribute.Name)] {
+			return "Attribute names must be unique and not empty"
+		}
+		// Nama atribut menjadi kunci map attributes di dokumen produk, sehingga . dan $ tidak boleh
+		// dipakai agar tetap bisa di-query sebagai attributes.<nama>
+		if strings.ContainsAny(attribute.Name, ".$") {
+			return fmt.Sprintf("Attribute %s must not contain . or $", attribute.Name)
+		}
+		seen[strings.ToLower(attribute.Name)] = true
+
+		switch attribute.Type {
+		case model.AttributeSelect:
+			if len(attribute.Options) == 0 {
+				return fmt.Sprintf("Attribute %s needs at least one option", attribute.Name)
+			}
+		case model.AttributeText, model.AttributeNumber, model.AttributeBoolean:
+			attribute.Options = nil
+		default:
+			return fmt.Sprintf("Attribute %s has an invalid type, use text, number, select or boolean", attribute.Name)
+		}
+		attributes[i] = attribute
+	}
+	return ""
+}
+
+// slugify mengubah nama menjadi slug URL, contoh "Handphone & Tablet" menjadi "handphone-tablet"
+func slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteRune('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// uniqueSlug menambahkan akhiran angka bila slug sudah dipakai kategori lain
+func uniqueSlug(ctx context.Context, base string, except primitive.ObjectID) (string, error) {
+	if base == "" {
+		base = "category"
+	}
+	slug := base
+	for i := 2; ; i++ {
+		count, err := getCategoryCollection().CountDocuments(ctx, bson.M{"slug": slug, "_id": bson.M{"$ne": except}})
+		if err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return slug, nil
+		}
+		slug = fmt.Sprintf("%s-%d", base, i)
+	}
+}
+
+// MigrateCategoryTree mengubah kategori dua tingkat lama (sub_categories tertanam) menjadi
+// dokumen kategori tersendiri dengan ID yang sama, sehingga sub_category_id produk tetap valid,
+// lalu mengisi category_path produk. Aman dijalankan berulang kali.
+func MigrateCategoryTree(ctx context.Context) error {
+	collection := getCategoryCollection()
+
+	cursor, err := collection.Find(ctx, bson.M{"$or": bson.A{
+		bson.M{"slug": bson.M{"$exists": false}},
+		bson.M{"sub_categories.0": bson.M{"$exists": true}},
+	}})
+	if err != nil {
+		return err
+	}
+	var categories []model.Category
+	if err := cursor.All(ctx, &categories); err != nil {
+		return err
+	}
+
+	for _, category := range categories {
+		if category.Slug == "" {
+			slug, err := uniqueSlug(ctx, slugify(category.Name), category.ID)
+			if err != nil {
+				return err
+			}
+			set := bson.M{"slug": slug}
+			if category.Ancestors == nil {
+				set["ancestors"] = bson.A{}
+			}
+			if _, err := collection.UpdateOne(ctx, bson.M{"_id": category.ID}, bson.M{"$set": set}); err != nil {
+				return err
+			}
+		}
+
+		for i, sub := range category.SubCategories {
+			slug, err := uniqueSlug(ctx, slugify(sub.Name), sub.ID)
+			if err != nil {
+				return err
+			}
+			parentID := category.ID
+			_, err = collection.UpdateOne(ctx,
+				bson.M{"_id": sub.ID},
+				bson.M{"$setOnInsert": model.Category{
+					ID:        sub.ID,
+					Name:      sub.Name,
+					Slug:      slug,
+					ParentID:  &parentID,
+					Ancestors: append(append([]primitive.ObjectID{}, category.Ancestors...), category.ID),
+					Order:     i,
+				}},
+				options.Update().SetUpsert(true),
+			)
+			if err != nil {
+				return err
+			}
+		}
+		if len(category.SubCategories) > 0 {
+			if _, err := collection.UpdateOne(ctx, bson.M{"_id": category.ID}, bson.M{"$unset": bson.M{"sub_categories": ""}}); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err = getProductCollection().UpdateMany(ctx,
+		bson.M{"category_path": bson.M{"$exists": false}, "category_id": bson.M{"$exists": true}},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{"category_path": bson.A{"$category_id", "$sub_category_id"}}}}},
+	)
+	return err
+}
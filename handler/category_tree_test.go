@@ -0,0 +1,246 @@
+package handler
+
+import (
+	"be_ecommerce/model"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// testCategoryTree menyusun pohon Elektronik > Handphone > Android dan Fashion (tanpa turunan)
+func testCategoryTree() (categoryTree, map[string]primitive.ObjectID) {
+	ids := map[string]primitive.ObjectID{}
+	for _, name := range []string{"elektronik", "handphone", "android", "fashion"} {
+		ids[name] = primitive.NewObjectID()
+	}
+	elektronik, handphone := ids["elektronik"], ids["handphone"]
+	categories := []model.Category{
+		{ID: ids["elektronik"], Name: "Elektronik", Ancestors: []primitive.ObjectID{}, Attributes: []model.CategoryAttribute{
+			{Name: "Garansi", Type: model.AttributeBoolean},
+		}},
+		{ID: ids["handphone"], Name: "Handphone", ParentID: &elektronik, Ancestors: []primitive.ObjectID{elektronik}, Attributes: []model.CategoryAttribute{
+			{Name: "RAM", Type: model.AttributeSelect, Options: []string{"4 GB", "8 GB"}, Required: true},
+		}},
+		{ID: ids["android"], Name: "Android", ParentID: &handphone, Ancestors: []primitive.ObjectID{elektronik, handphone}, Attributes: []model.CategoryAttribute{
+			{Name: "Layar", Type: model.AttributeNumber, Unit: "inch"},
+		}},
+		{ID: ids["fashion"], Name: "Fashion", Ancestors: []primitive.ObjectID{}},
+	}
+
+	tree := categoryTree{
+		byID:     map[primitive.ObjectID]model.Category{},
+		children: map[primitive.ObjectID][]model.Category{},
+	}
+	for _, category := range categories {
+		tree.byID[category.ID] = category
+		parent := primitive.NilObjectID
+		if category.ParentID != nil {
+			parent = *category.ParentID
+		}
+		tree.children[parent] = append(tree.children[parent], category)
+	}
+	return tree, ids
+}
+
+func TestCategoryTreeAssign(t *testing.T) {
+	tree, ids := testCategoryTree()
+
+	tests := []struct {
+		name        string
+		category    primitive.ObjectID
+		subCategory primitive.ObjectID
+		attributes  map[string]string
+		wantMessage string
+		wantPath    []primitive.ObjectID
+		wantAttrs   map[string]string
+	}{
+		{
+			name:        "inherited schema is applied to the leaf",
+			category:    ids["elektronik"],
+			subCategory: ids["android"],
+			attributes:  map[string]string{"ram": "8 gb", "Layar": "6.5", "garansi": "TRUE"},
+			wantPath:    []primitive.ObjectID{ids["elektronik"], ids["handphone"], ids["android"]},
+			wantAttrs:   map[string]string{"RAM": "8 GB", "Layar": "6.5", "Garansi": "true"},
+		},
+		{
+			name:        "root without children can be its own leaf",
+			category:    ids["fashion"],
+			subCategory: ids["fashion"],
+			wantPath:    []primitive.ObjectID{ids["fashion"]},
+		},
+		{
+			name:        "root with children needs a sub-category",
+			category:    ids["elektronik"],
+			subCategory: ids["elektronik"],
+			wantMessage: "Invalid Sub-Category ID",
+		},
+		{
+			name:        "category must be a root",
+			category:    ids["handphone"],
+			subCategory: ids["android"],
+			wantMessage: "Invalid Category ID",
+		},
+		{
+			name:        "sub-category must be under the root",
+			category:    ids["fashion"],
+			subCategory: ids["android"],
+			wantMessage: "Invalid Sub-Category ID",
+		},
+		{
+			name:        "required inherited attribute",
+			category:    ids["elektronik"],
+			subCategory: ids["android"],
+			attributes:  map[string]string{"Layar": "6.5"},
+			wantMessage: "Attribute RAM is required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assignment, message := tree.assign(tt.category, tt.subCategory, tt.attributes)
+			if message != tt.wantMessage {
+				t.Fatalf("assign() message = %q, want %q", message, tt.wantMessage)
+			}
+			if message != "" {
+				return
+			}
+			if !reflect.DeepEqual(assignment.Path, tt.wantPath) {
+				t.Errorf("assign() path = %v, want %v", assignment.Path, tt.wantPath)
+			}
+			if !reflect.DeepEqual(assignment.Attributes, tt.wantAttrs) {
+				t.Errorf("assign() attributes = %v, want %v", assignment.Attributes, tt.wantAttrs)
+			}
+		})
+	}
+}
+
+func TestCategoryTreeWithParent(t *testing.T) {
+	tree, ids := testCategoryTree()
+
+	// Handphone dipindah ke Fashion: Android ikut pindah dan kehilangan atribut Garansi dari Elektronik
+	fashion := ids["fashion"]
+	moved := tree.withParent(ids["handphone"], &fashion)
+
+	wantPath := []primitive.ObjectID{ids["fashion"], ids["handphone"], ids["android"]}
+	if got := moved.path(ids["android"]); !reflect.DeepEqual(got, wantPath) {
+		t.Errorf("path after move = %v, want %v", got, wantPath)
+	}
+	if _, message := validateProductAttributes(moved.schema(ids["android"]), map[string]string{"RAM": "8 GB", "Garansi": "true"}); message != `Unknown attribute "Garansi" for this category` {
+		t.Errorf("validate after move = %q", message)
+	}
+	if got := tree.path(ids["android"]); got[0] != ids["elektronik"] {
+		t.Errorf("original tree was modified: %v", got)
+	}
+
+	root := moved.withParent(ids["handphone"], nil)
+	if got := root.path(ids["android"]); !reflect.DeepEqual(got, []primitive.ObjectID{ids["handphone"], ids["android"]}) {
+		t.Errorf("path after moving to root = %v", got)
+	}
+}
+
+func TestValidateProductAttributes(t *testing.T) {
+	schema := []model.CategoryAttribute{
+		{Name: "Warna", Type: model.AttributeSelect, Options: []string{"Hitam", "Putih"}, Required: true},
+		{Name: "Berat", Type: model.AttributeNumber, Unit: "kg"},
+		{Name: "Tahan Air", Type: model.AttributeBoolean},
+		{Name: "Bahan", Type: model.AttributeText},
+	}
+
+	tests := []struct {
+		name        string
+		values      map[string]string
+		want        map[string]string
+		wantMessage string
+	}{
+		{
+			name:   "names and values are normalized",
+			values: map[string]string{" warna ": "hitam", "berat": "1.5", "tahan air": "1", "Bahan": " Katun "},
+			want:   map[string]string{"Warna": "Hitam", "Berat": "1.5", "Tahan Air": "true", "Bahan": "Katun"},
+		},
+		{
+			name:   "empty values are dropped",
+			values: map[string]string{"Warna": "Putih", "Bahan": "  "},
+			want:   map[string]string{"Warna": "Putih"},
+		},
+		{
+			name:        "unknown attribute",
+			values:      map[string]string{"Warna": "Hitam", "Ukuran": "XL"},
+			wantMessage: `Unknown attribute "Ukuran" for this category`,
+		},
+		{
+			name:        "number",
+			values:      map[string]string{"Warna": "Hitam", "Berat": "ringan"},
+			wantMessage: "Attribute Berat must be a number",
+		},
+		{
+			name:        "boolean",
+			values:      map[string]string{"Warna": "Hitam", "Tahan Air": "ya"},
+			wantMessage: "Attribute Tahan Air must be true or false",
+		},
+		{
+			name:        "select option",
+			values:      map[string]string{"Warna": "Merah"},
+			wantMessage: "Attribute Warna must be one of Hitam, Putih",
+		},
+		{
+			name:        "required",
+			values:      map[string]string{"Bahan": "Katun"},
+			wantMessage: "Attribute Warna is required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, message := validateProductAttributes(schema, tt.values)
+			if message != tt.wantMessage {
+				t.Fatalf("message = %q, want %q", message, tt.wantMessage)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("attributes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateAttributeSchema(t *testing.T) {
+	tests := []struct {
+		name       string
+		attributes []model.CategoryAttribute
+		want       string
+	}{
+		{"valid", []model.CategoryAttribute{{Name: "RAM", Type: model.AttributeSelect, Options: []string{"8 GB"}}, {Name: "Layar", Type: model.AttributeNumber}}, ""},
+		{"duplicate name", []model.CategoryAttribute{{Name: "RAM", Type: model.AttributeText}, {Name: "ram", Type: model.AttributeText}}, "Attribute names must be unique and not empty"},
+		{"dot in name", []model.CategoryAttribute{{Name: "Ukuran 5.5", Type: model.AttributeText}}, "Attribute Ukuran 5.5 must not contain . or $"},
+		{"dollar in name", []model.CategoryAttribute{{Name: "$where", Type: model.AttributeText}}, "Attribute $where must not contain . or $"},
+		{"select without options", []model.CategoryAttribute{{Name: "Warna", Type: model.AttributeSelect}}, "Attribute Warna needs at least one option"},
+		{"invalid type", []model.CategoryAttribute{{Name: "Warna", Type: "color"}}, "Attribute Warna has an invalid type, use text, number, select or boolean"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validateAttributeSchema(tt.attributes); got != tt.want {
+				t.Errorf("validateAttributeSchema() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Handphone & Tablet", "handphone-tablet"},
+		{"  Fashion Pria  ", "fashion-pria"},
+		{"Kamera (DSLR)", "kamera-dslr"},
+		{"Café Öl", "café-öl"},
+		{"TV 4K / 8K", "tv-4k-8k"},
+		{"&&&", ""},
+	}
+	for _, tt := range tests {
+		if got := slugify(tt.name); got != tt.want {
+			t.Errorf("slugify(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,546 @@
+package handler
+
+import (
+	"be_ecommerce/config"
+	"be_ecommerce/model"
+	"context"
+	"fmt"
+	"mime/multipart"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	chatMaxTextLength     = 2000
+	chatMaxAttachments    = 5
+	chatMaxAttachmentSize = 5 * 1024 * 1024
+	// Lampiran chat bersifat pribadi, jadi disimpan di luar folder uploads yang disajikan publik
+	// dan hanya bisa diambil peserta percakapan lewat GetChatAttachment
+	chatUploadDir       = "storage/chat"
+	legacyChatUploadDir = "uploads/chat"
+)
+
+// Ekstensi gambar yang boleh dilampirkan pada pesan
+var chatImageExtensions = map[string]bool{".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".webp": true}
+
+func getConversationCollection() *mongo.Collection {
+	return config.MongoClient.Database("ecommerce").Collection("chat_conversations")
+}
+
+func getChatMessageCollection() *mongo.Collection {
+	return config.MongoClient.Database("ecommerce").Collection("chat_messages")
+}
+
+// conversationRole mengembalikan "customer" atau "seller" sesuai posisi pengguna dalam percakapan
+func conversationRole(conversation model.Conversation, userID primitive.ObjectID) string {
+	switch userID {
+	case conversation.CustomerID:
+		return "customer"
+	case conversation.SellerID:
+		return "seller"
+	}
+	return ""
+}
+
+// findConversationForUser mengambil percakapan dan memastikan pengguna adalah salah satu pesertanya
+func findConversationForUser(c *fiber.Ctx) (model.Conversation, string, *fiber.Error) {
+	var conversation model.Conversation
+	conversationID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return conversation, "", fiber.NewError(fiber.StatusBadRequest, "Invalid conversation ID")
+	}
+
+	err = getConversationCollection().FindOne(context.Background(), bson.M{"_id": conversationID}).Decode(&conversation)
+	if err == mongo.ErrNoDocuments {
+		return conversation, "", fiber.NewError(fiber.StatusNotFound, "Conversation not found")
+	}
+	if err != nil {
+		return conversation, "", fiber.NewError(fiber.StatusInternalServerError, "Failed to fetch conversation")
+	}
+
+	// Percakapan milik orang lain diperlakukan seperti tidak ada
+	role := conversationRole(conversation, currentUserID(c))
+	if role == "" {
+		return conversation, "", fiber.NewError(fiber.StatusNotFound, "Conversation not found")
+	}
+	return conversation, role, nil
+}
+
+// StartConversation membuka (atau mengambil yang sudah ada) percakapan dengan sebuah toko
+func StartConversation(c *fiber.Ctx) error {
+	var request struct {
+		SellerID  string `json:"seller_id"`
+		ProductID string `json:"product_id"`
+		OrderID   string `json:"order_id"`
+	}
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+		})
+	}
+
+	customerID := currentUserID(c)
+	sellerID, err := primitive.ObjectIDFromHex(request.SellerID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid seller ID",
+		})
+	}
+	if sellerID == customerID {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "You cannot start a conversation with your own store",
+		})
+	}
+
+	// Pastikan toko ada dan tidak sedang disuspend
+	var seller model.User
+	err = getUserCollection().FindOne(context.Background(), bson.M{"_id": sellerID, "roles": "seller"}).Decode(&seller)
+	if err != nil || isSuspended(seller) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "Store not found",
+		})
+	}
+
+	var productID, orderID *primitive.ObjectID
+	if request.ProductID != "" {
+		id, err := primitive.ObjectIDFromHex(request.ProductID)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "Invalid product ID",
+			})
+		}
+		productCollection := config.MongoClient.Database("ecommerce").Collection("products")
+		count, err := productCollection.CountDocuments(context.Background(), bson.M{"_id": id, "seller_id": sellerID})
+		if err != nil || count == 0 {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": "Product not found in this store",
+			})
+		}
+		productID = &id
+	}
+	if request.OrderID != "" {
+		id, err := primitive.ObjectIDFromHex(request.OrderID)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "Invalid order ID",
+			})
+		}
+		// Order harus milik pelanggan dan berisi produk dari toko ini
+		orderCollection := config.MongoClient.Database("ecommerce").Collection("orders")
+		count, err := orderCollection.CountDocuments(context.Background(), bson.M{
+			"_id":     id,
+			"user_id": customerID,
+			"$or":     []bson.M{{"seller_id": sellerID}, {"items.seller_id": sellerID}},
+		})
+		if err != nil || count == 0 {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": "Order not found for this store",
+			})
+		}
+		orderID = &id
+	}
+
+	now := time.Now()
+	var conversation model.Conversation
+	err = getConversationCollection().FindOneAndUpdate(context.Background(),
+		bson.M{"customer_id": customerID, "seller_id": sellerID, "product_id": productID, "order_id": orderID},
+		bson.M{"$setOnInsert": bson.M{
+			"_id":             primitive.NewObjectID(),
+			"customer_unread": 0,
+			"seller_unread":   0,
+			"created_at":      now,
+			"updated_at":      now,
+		}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&conversation)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to start conversation",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Conversation ready",
+		"data":    conversation,
+	})
+}
+
+// GetConversations mengembalikan daftar percakapan pengguna.
+// ?as=seller untuk kotak masuk toko; filter: unread=true, product_id, order_id,
+// type=product|order|general
+func GetConversations(c *fiber.Ctx) error {
+	userID := currentUserID(c)
+
+	as := c.Query("as", "customer")
+	filter := bson.M{}
+	switch as {
+	case "customer":
+		filter["customer_id"] = userID
+		if c.QueryBool("unread") {
+			filter["customer_unread"] = bson.M{"$gt": 0}
+		}
+	case "seller":
+		filter["seller_id"] = userID
+		if c.QueryBool("unread") {
+			filter["seller_unread"] = bson.M{"$gt": 0}
+		}
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "as must be customer or seller",
+		})
+	}
+
+	if productID := c.Query("product_id"); productID != "" {
+		id, err := primitive.ObjectIDFromHex(productID)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "Invalid product ID",
+			})
+		}
+		filter["product_id"] = id
+	}
+	if orderID := c.Query("order_id"); orderID != "" {
+		id, err := primitive.ObjectIDFromHex(orderID)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "Invalid order ID",
+			})
+		}
+		filter["order_id"] = id
+	}
+	switch c.Query("type") {
+	case "product":
+		filter["product_id"] = bson.M{"$ne": nil}
+	case "order":
+		filter["order_id"] = bson.M{"$ne": nil}
+	case "general":
+		filter["product_id"] = nil
+		filter["order_id"] = nil
+	}
+
+	limit := c.QueryInt("limit", 20)
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	page := c.QueryInt("page", 1)
+	if page < 1 {
+		page = 1
+	}
+
+	// Sertakan nama lawan bicara dan produk agar kotak masuk bisa langsung ditampilkan
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$sort", Value: bson.D{{Key: "updated_at", Value: -1}}}},
+		{{Key: "$skip", Value: (page - 1) * limit}},
+		{{Key: "$limit", Value: limit}},
+		{{Key: "$lookup", Value: bson.M{"from": "users", "localField": "customer_id", "foreignField": "_id", "as": "customer"}}},
+		{{Key: "$lookup", Value: bson.M{"from": "users", "localField": "seller_id", "foreignField": "_id", "as": "seller"}}},
+		{{Key: "$lookup", Value: bson.M{"from": "products", "localField": "product_id", "foreignField": "_id", "as": "product"}}},
+		{{Key: "$addFields", Value: bson.M{
+			"customer_name": bson.M{"$first": "$customer.username"},
+			"store_name":    bson.M{"$first": "$seller.store_info.store_name"},
+			"product_name":  bson.M{"$first": "$product.name"},
+			"product_image": bson.M{"$first": "$product.image"},
+		}}},
+		{{Key: "$project", Value: bson.M{"customer": 0, "seller": 0, "product": 0}}},
+	}
+
+	cursor, err := getConversationCollection().Aggregate(context.Background(), pipeline)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch conversations",
+		})
+	}
+	defer cursor.Close(context.Background())
+
+	conversations := []bson.M{}
+	if err := cursor.All(context.Background(), &conversations); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to parse conversations",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Conversations fetched successfully",
+		"data":    conversations,
+		"page":    page,
+		"limit":   limit,
+	})
+}
+
+// GetConversationMessages mengembalikan pesan terbaru lebih dulu; ?before=<message_id> untuk halaman berikutnya
+func GetConversationMessages(c *fiber.Ctx) error {
+	conversation, _, lookupErr := findConversationForUser(c)
+	if lookupErr != nil {
+		return c.Status(lookupErr.Code).JSON(fiber.Map{"message": lookupErr.Message})
+	}
+
+	filter := bson.M{"conversation_id": conversation.ID}
+	if before := c.Query("before"); before != "" {
+		beforeID, err := primitive.ObjectIDFromHex(before)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "Invalid before cursor",
+			})
+		}
+		filter["_id"] = bson.M{"$lt": beforeID}
+	}
+
+	limit := c.QueryInt("limit", 30)
+	if limit < 1 || limit > 100 {
+		limit = 30
+	}
+
+	cursor, err := getChatMessageCollection().Find(context.Background(), filter, options.Find().
+		SetSort(bson.D{{Key: "_id", Value: -1}}).
+		SetLimit(int64(limit)+1))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch messages",
+		})
+	}
+	defer cursor.Close(context.Background())
+
+	messages := []model.ChatMessage{}
+	if err := cursor.All(context.Background(), &messages); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to parse messages",
+		})
+	}
+
+	hasMore := len(messages) > limit
+	if hasMore {
+		messages = messages[:limit]
+	}
+
+	// Lampiran lama menunjuk ke /uploads/chat yang tidak lagi disajikan publik
+	for i := range messages {
+		for j, attachment := range messages[i].Attachments {
+			if strings.HasPrefix(attachment.URL, "/"+legacyChatUploadDir+"/") {
+				messages[i].Attachments[j].URL = chatAttachmentURL(conversation.ID, path.Base(attachment.URL))
+			}
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"message":  "Messages fetched successfully",
+		"data":     messages,
+		"has_more": hasMore,
+	})
+}
+
+// SendChatMessage mengirim pesan teks dan/atau gambar (multipart field "images") ke percakapan
+func SendChatMessage(c *fiber.Ctx) error {
+	conversation, role, lookupErr := findConversationForUser(c)
+	if lookupErr != nil {
+		return c.Status(lookupErr.Code).JSON(fiber.Map{"message": lookupErr.Message})
+	}
+
+	var text string
+	var files []*multipart.FileHeader
+	if form, err := c.MultipartForm(); err == nil {
+		if values := form.Value["text"]; len(values) > 0 {
+			text = values[0]
+		}
+		files = form.File["images"]
+	} else {
+		var body struct {
+			Text string `json:"text"`
+		}
+		if err := c.BodyParser(&body); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "Invalid request body",
+			})
+		}
+		text = body.Text
+	}
+
+	text = strings.TrimSpace(text)
+	if text == "" && len(files) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Message text or image is required",
+		})
+	}
+	if len([]rune(text)) > chatMaxTextLength {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": fmt.Sprintf("Message cannot be longer than %d characters", chatMaxTextLength),
+		})
+	}
+	if len(files) > chatMaxAttachments {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": fmt.Sprintf("A message can have at most %d images", chatMaxAttachments),
+		})
+	}
+
+	message := model.ChatMessage{
+		ID:             primitive.NewObjectID(),
+		ConversationID: conversation.ID,
+		SenderID:       currentUserID(c),
+		RecipientID:    conversation.SellerID,
+		Text:           text,
+		CreatedAt:      time.Now(),
+	}
+	unreadField := "seller_unread"
+	if role == "seller" {
+		message.RecipientID = conversation.CustomerID
+		unreadField = "customer_unread"
+	}
+
+	attachments, err := saveChatAttachments(c, conversation.ID, message.ID, files)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	message.Attachments = attachments
+
+	// Pesan dan ringkasan percakapan disimpan bersama agar hitungan belum dibaca selalu konsisten
+	err = config.WithTransaction(context.Background(), func(sessCtx mongo.SessionContext) error {
+		if _, err := getChatMessageCollection().InsertOne(sessCtx, message); err != nil {
+			return err
+		}
+		_, err := getConversationCollection().UpdateOne(sessCtx, bson.M{"_id": conversation.ID}, bson.M{
+			"$set": bson.M{
+				"last_message": model.ChatPreview{
+					SenderID: message.SenderID,
+					Text:     message.Text,
+					HasImage: len(message.Attachments) > 0,
+					SentAt:   message.CreatedAt,
+				},
+				"updated_at": message.CreatedAt,
+			},
+			"$inc": bson.M{unreadField: 1},
+		})
+		return err
+	})
+	if err != nil {
+		removeChatAttachments(conversation.ID, message.Attachments)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to send message",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "Message sent successfully",
+		"data":    message,
+	})
+}
+
+// MarkConversationRead menandai semua pesan untuk pengguna dalam percakapan sebagai sudah dibaca
+func MarkConversationRead(c *fiber.Ctx) error {
+	conversation, role, lookupErr := findConversationForUser(c)
+	if lookupErr != nil {
+		return c.Status(lookupErr.Code).JSON(fiber.Map{"message": lookupErr.Message})
+	}
+
+	now := time.Now()
+	userID := currentUserID(c)
+	err := config.WithTransaction(context.Background(), func(sessCtx mongo.SessionContext) error {
+		_, err := getChatMessageCollection().UpdateMany(sessCtx,
+			bson.M{"conversation_id": conversation.ID, "recipient_id": userID, "read_at": nil},
+			bson.M{"$set": bson.M{"read_at": now}},
+		)
+		if err != nil {
+			return err
+		}
+		// Perubahan percakapan ini diteruskan ke lawan bicara sebagai tanda sudah dibaca
+		_, err = getConversationCollection().UpdateOne(sessCtx, bson.M{"_id": conversation.ID}, bson.M{
+			"$set": bson.M{role + "_unread": 0, role + "_read_at": now},
+		})
+		return err
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to mark conversation as read",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Conversation marked as read",
+		"read_at": now,
+	})
+}
+
+// chatAttachmentURL adalah endpoint lampiran yang memeriksa peserta percakapan
+func chatAttachmentURL(conversationID primitive.ObjectID, name string) string {
+	return "/chats/" + conversationID.Hex() + "/attachments/" + name
+}
+
+// GetChatAttachment mengirim file lampiran hanya kepada peserta percakapan
+func GetChatAttachment(c *fiber.Ctx) error {
+	conversation, _, lookupErr := findConversationForUser(c)
+	if lookupErr != nil {
+		return c.Status(lookupErr.Code).JSON(fiber.Map{"message": lookupErr.Message})
+	}
+
+	name := c.Params("name")
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Attachment not found"})
+	}
+
+	// Lampiran lama masih tersimpan di uploads/chat sampai dipindahkan
+	filePath := filepath.Join(chatUploadDir, conversation.ID.Hex(), name)
+	if _, err := os.Stat(filePath); err != nil {
+		filePath = filepath.Join(legacyChatUploadDir, conversation.ID.Hex(), name)
+		if _, err := os.Stat(filePath); err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Attachment not found"})
+		}
+	}
+
+	c.Set(fiber.HeaderCacheControl, "private, max-age=86400")
+	return c.SendFile(filePath)
+}
+
+// saveChatAttachments memvalidasi lalu menyimpan gambar ke storage/chat/<conversation_id>
+func saveChatAttachments(c *fiber.Ctx, conversationID, messageID primitive.ObjectID, files []*multipart.FileHeader) ([]model.ChatAttachment, error) {
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	for _, file := range files {
+		ext := strings.ToLower(filepath.Ext(file.Filename))
+		if !chatImageExtensions[ext] || !strings.HasPrefix(file.Header.Get("Content-Type"), "image/") {
+			return nil, fmt.Errorf("%s is not a supported image", file.Filename)
+		}
+		if file.Size > chatMaxAttachmentSize {
+			return nil, fmt.Errorf("%s is larger than %d MB", file.Filename, chatMaxAttachmentSize/1024/1024)
+		}
+	}
+
+	dir := filepath.Join(chatUploadDir, conversationID.Hex())
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to prepare upload directory")
+	}
+
+	attachments := make([]model.ChatAttachment, 0, len(files))
+	for i, file := range files {
+		name := fmt.Sprintf("%s-%d%s", messageID.Hex(), i, strings.ToLower(filepath.Ext(file.Filename)))
+		path := filepath.Join(dir, name)
+		if err := c.SaveFile(file, path); err != nil {
+			removeChatAttachments(conversationID, attachments)
+			return nil, fmt.Errorf("failed to save image")
+		}
+		attachments = append(attachments, model.ChatAttachment{
+			Type: "image",
+			URL:  chatAttachmentURL(conversationID, name),
+			Name: file.Filename,
+		})
+	}
+	return attachments, nil
+}
+
+// removeChatAttachments menghapus file lampiran bila pesan gagal disimpan
+func removeChatAttachments(conversationID primitive.ObjectID, attachments []model.ChatAttachment) {
+	for _, attachment := range attachments {
+		os.Remove(filepath.Join(chatUploadDir, conversationID.Hex(), path.Base(attachment.URL)))
+	}
+}
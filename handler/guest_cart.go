@@ -0,0 +1,253 @@
+package handler
+
+import (
+	"be_ecommerce/config"
+	"be_ecommerce/model"
+	"be_ecommerce/utils"
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// cartTokenHeader adalah header tempat klien mengirim cart token keranjang tamu
+const cartTokenHeader = "X-Cart-Token"
+
+var errInvalidCartToken = errors.New("invalid or expired cart token")
+
+func getCartCollection() *mongo.Collection {
+	return config.MongoClient.Database("ecommerce").Collection("carts")
+}
+
+// guestCartExpiry adalah umur keranjang tamu sejak terakhir diubah, diatur lewat
+// GUEST_CART_EXPIRY_DAYS (default 7). Cart token berlaku selama waktu yang sama.
+func guestCartExpiry() time.Duration {
+	return envDuration("GUEST_CART_EXPIRY_DAYS", 24*time.Hour, 7)
+}
+
+// cartOwner adalah pemilik keranjang: pengguna yang login, atau tamu yang diidentifikasi cart token
+type cartOwner struct {
+	UserID  string
+	GuestID string
+}
+
+func (o cartOwner) filter() bson.M {
+	if o.UserID != "" {
+		return bson.M{"user_id": o.UserID}
+	}
+	return bson.M{"guest_id": o.GuestID}
+}
+
+func (o cartOwner) isGuest() bool {
+	return o.UserID == ""
+}
+
+// resolveCartOwner menentukan pemilik keranjang dari token login (header Authorization) atau
+// cart token (header X-Cart-Token). Tanpa keduanya, create menentukan apakah keranjang tamu
+// baru dibuat; bila tidak, ok bernilai false.
+func resolveCartOwner(c *fiber.Ctx, create bool) (owner cartOwner, ok bool, err error) {
+	if authHeader := c.Get("Authorization"); authHeader != "" {
+		if !setAuthLocals(c, strings.TrimPrefix(authHeader, "Bearer ")) {
+			return owner, false, errInvalidCartToken
+		}
+		return cartOwner{UserID: currentUserID(c).Hex()}, true, nil
+	}
+	if token := c.Get(cartTokenHeader); token != "" {
+		guestID, err := utils.ValidateCartToken(token)
+		if err != nil {
+			return owner, false, errInvalidCartToken
+		}
+		return cartOwner{GuestID: guestID}, true, nil
+	}
+	if create {
+		return cartOwner{GuestID: primitive.NewObjectID().Hex()}, true, nil
+	}
+	return owner, false, nil
+}
+
+// cartOwnerError menulis response kesalahan resolveCartOwner
+func cartOwnerError(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "Invalid or expired token"})
+}
+
+// withCartToken menambahkan cart token yang diperbarui ke response keranjang tamu,
+// sehingga masa berlakunya ikut bergeser setiap kali keranjang dipakai
+func withCartToken(c *fiber.Ctx, owner cartOwner, response fiber.Map) fiber.Map {
+	if !owner.isGuest() {
+		return response
+	}
+	token, err := utils.GenerateCartToken(owner.GuestID, guestCartExpiry())
+	if err == nil {
+		response["cart_token"] = token
+		c.Set(cartTokenHeader, token)
+	}
+	return response
+}
+
+// MergeGuestCart menggabungkan keranjang tamu ke keranjang pengguna yang login.
+// Cart token dikirim lewat header X-Cart-Token atau body {"cart_token": "..."}.
+func MergeGuestCart(c *fiber.Ctx) error {
+	var input struct {
+		CartToken string `json:"cart_token"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&input); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request body"})
+		}
+	}
+	if input.CartToken == "" {
+		input.CartToken = c.Get(cartTokenHeader)
+	}
+	guestID, err := utils.ValidateCartToken(input.CartToken)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid or expired cart token"})
+	}
+
+	merged, err := mergeGuestCart(c.Context(), guestID, currentUserID(c).Hex())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to merge cart"})
+	}
+	return c.JSON(fiber.Map{
+		"message": "Cart merged successfully",
+		"merged":  merged,
+	})
+}
+
+// mergeGuestCart memindahkan isi keranjang tamu ke keranjang pengguna. Kuantitas item yang sama
+// dijumlahkan lalu dibatasi stok yang tersedia (tanpa mengurangi kuantitas yang sudah ada di
+// keranjang pengguna); item yang stoknya habis atau produknya tidak aktif dilewati. Item yang
+// disimpan untuk nanti dan catatan toko ikut dibawa. Keranjang tamu dihapus setelah digabung. Mengembalikan jumlah item tamu yang digabung.
+func mergeGuestCart(ctx context.Context, guestID, userID string) (int, error) {
+	// Keranjang tamu dan pengguna dibaca di dalam transaksi agar perubahan keranjang pengguna
+	// dari request lain di antara pembacaan dan penulisan tidak tertimpa
+	merged := 0
+	err := config.WithTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		merged = 0
+		var guest model.Cart
+		err := getCartCollection().FindOne(sessCtx, bson.M{"guest_id": guestID}).Decode(&guest)
+		if err == mongo.ErrNoDocuments {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var cart model.Cart
+		err = getCartCollection().FindOne(sessCtx, bson.M{"user_id": userID}).Decode(&cart)
+		if err != nil && err != mongo.ErrNoDocuments {
+			return err
+		}
+
+		// Stok tersedia per produk dan varian
+		var productIDs []primitive.ObjectID
+		for _, item := range guest.Products {
+			if id, err := primitive.ObjectIDFromHex(item.ProductID); err == nil {
+				productIDs = append(productIDs, id)
+			}
+		}
+		cursor, err := getProductCollection().Find(sessCtx, onlyActiveProducts(bson.M{"_id": bson.M{"$in": productIDs}}, ""))
+		if err != nil {
+			return err
+		}
+		var products []model.Product
+		if err := cursor.All(sessCtx, &products); err != nil {
+			return err
+		}
+		available := map[string]int{}
+		for _, product := range products {
+			if product.DeletedAt != nil {
+				continue
+			}
+			if len(product.Variants) == 0 {
+				available[product.ID.Hex()] = product.Stock
+			}
+			for _, variant := range product.Variants {
+				available[product.ID.Hex()+"/"+variant.ID.Hex()] = variant.Stock
+			}
+		}
+
+		for _, item := range guest.Products {
+			key := item.ProductID
+			if item.VariantID != "" {
+				key += "/" + item.VariantID
+			}
+			stock, ok := available[key]
+			if !ok {
+				continue
+			}
+
+			index := -1
+			for i, existing := range cart.Products {
+				if existing.ProductID == item.ProductID && existing.VariantID == item.VariantID {
+					index = i
+				}
+			}
+			if index < 0 {
+				if quantity := minInt(item.Quantity, stock); quantity > 0 {
+					item.Quantity = quantity
+					item.UserID = userID
+					cart.Products = append(cart.Products, item)
+					merged++
+				}
+				continue
+			}
+			current := cart.Products[index].Quantity
+			if quantity := minInt(current+item.Quantity, stock); quantity > current {
+				cart.Products[index].Quantity = quantity
+				merged++
+			}
+		}
+
+		// Item yang disimpan untuk nanti dan catatan toko tamu ikut dibawa bila belum ada
+		changed := merged > 0
+		for _, item := range guest.SavedForLater {
+			if cartItemIndex(cart.Products, item.ProductID, item.VariantID) < 0 && cartItemIndex(cart.SavedForLater, item.ProductID, item.VariantID) < 0 {
+				item.UserID = userID
+				cart.SavedForLater = append(cart.SavedForLater, item)
+				changed = true
+			}
+		}
+		for sellerID, note := range guest.StoreNotes {
+			if cart.StoreNotes == nil {
+				cart.StoreNotes = map[string]string{}
+			}
+			if cart.StoreNotes[sellerID] == "" {
+				cart.StoreNotes[sellerID] = note
+				changed = true
+			}
+		}
+
+		if changed {
+			_, err := getCartCollection().UpdateOne(sessCtx,
+				bson.M{"user_id": userID},
+				bson.M{"$set": bson.M{
+					"products":        cart.Products,
+					"saved_for_later": cart.SavedForLater,
+					"store_notes":     cart.StoreNotes,
+					"updated_at":      time.Now(),
+				}},
+				options.Update().SetUpsert(true),
+			)
+			if err != nil {
+				return err
+			}
+		}
+		_, err = getCartCollection().DeleteOne(sessCtx, bson.M{"guest_id": guestID})
+		return err
+	})
+	return merged, err
+}
+
+// minInt mengembalikan nilai terkecil dari dua bilangan
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,272 @@
+package handler
+
+import (
+	"be_ecommerce/config"
+	"be_ecommerce/model"
+	"be_ecommerce/realtime"
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Interval komentar keep-alive agar proxy tidak menutup koneksi SSE yang sepi
+const sseHeartbeatInterval = 25 * time.Second
+
+func getNotificationCollection() *mongo.Collection {
+	return config.MongoClient.Database("ecommerce").Collection("notifications")
+}
+
+// pushNotification menyimpan notifikasi in-app. Event real-time dikirim oleh WatchRealtime
+// setelah insert ter-commit, jadi aman dipanggil dengan sessCtx dari config.WithTransaction.
+func pushNotification(ctx context.Context, userID primitive.ObjectID, notifType, title, body string, data map[string]string) error {
+	_, err := getNotificationCollection().InsertOne(ctx, model.Notification{
+		ID:        primitive.NewObjectID(),
+		UserID:    userID,
+		Type:      notifType,
+		Title:     title,
+		Body:      body,
+		Data:      data,
+		CreatedAt: time.Now(),
+	})
+	return err
+}
+
+// pushOrderStatus memberitahu pembeli bahwa status pesanannya berubah
+func pushOrderStatus(ctx context.Context, order model.Order) error {
+	return pushNotification(ctx, order.UserID, model.NotificationOrderStatus,
+		"Order status updated",
+		fmt.Sprintf("Order #%s is now %s", order.ID.Hex(), order.Status),
+		map[string]string{"order_id": order.ID.Hex(), "status": order.Status},
+	)
+}
+
+// pushNewOrder memberitahu setiap seller yang produknya ada di order baru
+func pushNewOrder(ctx context.Context, order model.Order) error {
+	sellers := map[primitive.ObjectID]bool{}
+	if !order.SellerID.IsZero() {
+		sellers[order.SellerID] = true
+	}
+	for _, item := range order.Items {
+		if !item.SellerID.IsZero() {
+			sellers[item.SellerID] = true
+		}
+	}
+
+	for sellerID := range sellers {
+		err := pushNotification(ctx, sellerID, model.NotificationNewOrder,
+			"New order",
+			fmt.Sprintf("You have a new order #%s", order.ID.Hex()),
+			map[string]string{"order_id": order.ID.Hex()},
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pushSellerApplication memberitahu pemohon tentang keputusan pengajuan seller
+func pushSellerApplication(ctx context.Context, userID primitive.ObjectID, status, reason string) error {
+	body := "Your seller application has been " + status
+	data := map[string]string{"status": status}
+	if reason != "" {
+		body += ": " + reason
+		data["reason"] = reason
+	}
+	return pushNotification(ctx, userID, model.NotificationSellerApplication, "Seller application "+status, body, data)
+}
+
+// WatchRealtime meneruskan notifikasi baru, pesan chat dan perubahan percakapan
+// (termasuk tanda sudah dibaca) ke koneksi SSE pesertanya sampai ctx dibatalkan
+func WatchRealtime(ctx context.Context) {
+	var wg sync.WaitGroup
+	watch := func(collection *mongo.Collection, operations []string, route realtime.Router) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			realtime.Default.WatchChanges(ctx, collection, operations, route)
+		}()
+	}
+
+	watch(getNotificationCollection(), []string{"insert"}, func(doc bson.Raw) ([]string, realtime.Event, bool) {
+		var notification model.Notification
+		if err := bson.Unmarshal(doc, &notification); err != nil {
+			return nil, realtime.Event{}, false
+		}
+		return []string{notification.UserID.Hex()}, realtime.Event{Type: "notification", Data: notification}, true
+	})
+	watch(getChatMessageCollection(), []string{"insert"}, func(doc bson.Raw) ([]string, realtime.Event, bool) {
+		var message model.ChatMessage
+		if err := bson.Unmarshal(doc, &message); err != nil {
+			return nil, realtime.Event{}, false
+		}
+		recipients := []string{message.SenderID.Hex(), message.RecipientID.Hex()}
+		return recipients, realtime.Event{Type: "chat_message", Data: message}, true
+	})
+	watch(getConversationCollection(), []string{"insert", "update"}, func(doc bson.Raw) ([]string, realtime.Event, bool) {
+		var conversation model.Conversation
+		if err := bson.Unmarshal(doc, &conversation); err != nil {
+			return nil, realtime.Event{}, false
+		}
+		recipients := []string{conversation.CustomerID.Hex(), conversation.SellerID.Hex()}
+		return recipients, realtime.Event{Type: "chat_conversation", Data: conversation}, true
+	})
+
+	wg.Wait()
+}
+
+// GetNotifications mengembalikan notifikasi pengguna terbaru, ?unread=true untuk yang belum dibaca saja
+func GetNotifications(c *fiber.Ctx) error {
+	userID := currentUserID(c)
+
+	limit := c.QueryInt("limit", 20)
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	page := c.QueryInt("page", 1)
+	if page < 1 {
+		page = 1
+	}
+
+	filter := bson.M{"user_id": userID}
+	if c.QueryBool("unread") {
+		filter["read"] = false
+	}
+
+	collection := getNotificationCollection()
+	cursor, err := collection.Find(context.Background(), filter, options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetSkip(int64((page-1)*limit)).
+		SetLimit(int64(limit)))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch notifications",
+		})
+	}
+	defer cursor.Close(context.Background())
+
+	notifications := []model.Notification{}
+	if err := cursor.All(context.Background(), &notifications); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to parse notifications",
+		})
+	}
+
+	unread, err := collection.CountDocuments(context.Background(), bson.M{"user_id": userID, "read": false})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to count unread notifications",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message":      "Notifications fetched successfully",
+		"data":         notifications,
+		"unread_count": unread,
+		"page":         page,
+		"limit":        limit,
+	})
+}
+
+// MarkNotificationRead menandai satu notifikasi sebagai sudah dibaca
+func MarkNotificationRead(c *fiber.Ctx) error {
+	notificationID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid notification ID",
+		})
+	}
+
+	result, err := getNotificationCollection().UpdateOne(context.Background(),
+		bson.M{"_id": notificationID, "user_id": currentUserID(c)},
+		bson.M{"$set": bson.M{"read": true, "read_at": time.Now()}},
+	)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to update notification",
+		})
+	}
+	if result.MatchedCount == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "Notification not found",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Notification marked as read",
+	})
+}
+
+// MarkAllNotificationsRead menandai semua notifikasi pengguna sebagai sudah dibaca
+func MarkAllNotificationsRead(c *fiber.Ctx) error {
+	result, err := getNotificationCollection().UpdateMany(context.Background(),
+		bson.M{"user_id": currentUserID(c), "read": false},
+		bson.M{"$set": bson.M{"read": true, "read_at": time.Now()}},
+	)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to update notifications",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "All notifications marked as read",
+		"updated": result.ModifiedCount,
+	})
+}
+
+// StreamNotifications membuka koneksi Server-Sent Events yang mengirim notifikasi baru secara langsung
+func StreamNotifications(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(string)
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	events, unsubscribe := realtime.Default.Subscribe(userID)
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer unsubscribe()
+		streamEvents(w, events)
+	})
+	return nil
+}
+
+// streamEvents menulis event dalam format SSE sampai channel ditutup atau klien terputus
+func streamEvents(w *bufio.Writer, events <-chan realtime.Event) {
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	fmt.Fprint(w, "event: ready\ndata: {}\n\n")
+	if err := w.Flush(); err != nil {
+		return
+	}
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(event.Data)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// EnsureIndexes membuat index semua koleksi saat aplikasi dijalankan. Tambahkan index fitur baru
+// ke map di bawah beserta komentar singkat kegunaannya.
+func EnsureIndexes(ctx context.Context) error {
+	indexes := map[*mongo.Collection][]mongo.IndexModel{
+		// Inbox notifikasi per pengguna, terbaru lebih dulu
+		getNotificationCollection(): {
+			{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		},
+		// Satu percakapan per pembeli, seller dan konteks produk/order; daftar percakapan per pihak
+		getConversationCollection(): {
+			{
+				Keys: bson.D{
+					{Key: "customer_id", Value: 1},
+					{Key: "seller_id", Value: 1},
+					{Key: "product_id", Value: 1},
+					{Key: "order_id", Value: 1},
+				},
+				Options: options.Index().SetUnique(true),
+			},
+			{Keys: bson.D{{Key: "customer_id", Value: 1}, {Key: "updated_at", Value: -1}}},
+			{Keys: bson.D{{Key: "seller_id", Value: 1}, {Key: "updated_at", Value: -1}}},
+		},
+		// Riwayat pesan per percakapan
+		getChatMessageCollection(): {
+			{Keys: bson.D{{Key: "conversation_id", Value: 1}, {Key: "_id", Value: -1}}},
+		},
+		getProductCollection(): {
+			// SKU varian unik per seller, seperti SKU produk
+			{
+				Keys:    bson.D{{Key: "seller_id", Value: 1}, {Key: "variants.sku", Value: 1}},
+				Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"variants.sku": bson.M{"$exists": true}}),
+			},
+			// Antrean moderasi admin
+			{Keys: bson.D{{Key: "status", Value: 1}, {Key: "status_updated_at", Value: 1}}},
+			// SKU produk unik per seller; dipakai import CSV untuk mencari produk yang diperbarui
+			{
+				Keys:    bson.D{{Key: "seller_id", Value: 1}, {Key: "sku", Value: 1}},
+				Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"sku": bson.M{"$exists": true}}),
+			},
+			// Produk di dalam kategori mana pun pada pohon kategori
+			{Keys: bson.D{{Key: "category_path", Value: 1}}},
+		},
+		// Keranjang dicari per pengguna atau per cart token tamu
+		getCartCollection(): {
+			{Keys: bson.D{{Key: "user_id", Value: 1}}},
+			{Keys: bson.D{{Key: "guest_id", Value: 1}}, Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"guest_id": bson.M{"$type": "string"}})},
+		},
+		// Nama wishlist unik per pengguna, dan hanya satu wishlist default
+		getWishlistCollection(): {
+			{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "name", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{
+				Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "is_default", Value: 1}},
+				Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"is_default": true}),
+			},
+		},
+		// Slug kategori unik, urutan anak per induk, dan pencarian subtree lewat ancestors
+		getCategoryCollection(): {
+			{
+				Keys:    bson.D{{Key: "slug", Value: 1}},
+				Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"slug": bson.M{"$exists": true}}),
+			},
+			{Keys: bson.D{{Key: "parent_id", Value: 1}, {Key: "order", Value: 1}}},
+			{Keys: bson.D{{Key: "ancestors", Value: 1}}},
+		},
+		// Pencarian toko terdekat
+		getUserCollection(): {
+			{Keys: bson.D{{Key: "store_info.location", Value: "2dsphere"}}},
+		},
+		// Kode voucher unik dan daftar voucher per seller
+		getVoucherCollection(): {
+			{Keys: bson.D{{Key: "code", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "seller_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		},
+		// Index unik ini yang menjaga batas pemakaian per pengguna di redeemVoucher
+		getVoucherUsageCollection(): {
+			{Keys: bson.D{{Key: "voucher_id", Value: 1}, {Key: "user_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		},
+		// Aturan harga yang masih berlaku per produk
+		getPriceRuleCollection(): {
+			{Keys: bson.D{{Key: "product_id", Value: 1}, {Key: "ends_at", Value: 1}}},
+		},
+		// Flash sale yang masih berlaku per produk
+		getFlashSaleCollection(): {
+			{Keys: bson.D{{Key: "items.product_id", Value: 1}, {Key: "ends_at", Value: 1}}},
+		},
+		// Index unik ini yang menjaga batas pembelian per pembeli di reserveFlashSale
+		getFlashSalePurchaseCollection(): {
+			{Keys: bson.D{{Key: "flash_sale_id", Value: 1}, {Key: "product_id", Value: 1}, {Key: "user_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		},
+		// Riwayat import per seller dan antrean import untuk worker
+		getProductImportCollection(): {
+			{Keys: bson.D{{Key: "seller_id", Value: 1}, {Key: "created_at", Value: -1}}},
+			{Keys: bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: 1}}},
+		},
+		// Riwayat ledger stok per produk dan per seller
+		getInventoryMovementCollection(): {
+			{Keys: bson.D{{Key: "product_id", Value: 1}, {Key: "created_at", Value: -1}}},
+			{Keys: bson.D{{Key: "seller_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		},
+		// Pemakaian voucher per order (untuk pembatalan) dan per voucher
+		getVoucherRedemptionCollection(): {
+			{Keys: bson.D{{Key: "order_id", Value: 1}}},
+			{Keys: bson.D{{Key: "voucher_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		},
+	}
+
+	// Index lama yang sudah diganti dihapus agar tidak lagi membatasi data
+	obsolete := map[*mongo.Collection][]string{
+		// Dulu SKU varian unik di seluruh produk, sehingga seller bisa terhalang SKU toko lain
+		getProductCollection(): {"variants.sku_1"},
+	}
+	for collection, names := range obsolete {
+		for _, name := range names {
+			var cmdErr mongo.CommandError
+			// 26 = koleksi belum ada, 27 = index sudah tidak ada
+			if _, err := collection.Indexes().DropOne(ctx, name); err != nil && !(errors.As(err, &cmdErr) && (cmdErr.Code == 26 || cmdErr.Code == 27)) {
+				return err
+			}
+		}
+	}
+
+	for collection, models := range indexes {
+		if _, err := collection.Indexes().CreateMany(ctx, models); err != nil {
+			return err
+		}
+	}
+	return nil
+}
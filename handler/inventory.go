@@ -0,0 +1,496 @@
+package handler
+
+import (
+	"be_ecommerce/config"
+	"be_ecommerce/model"
+	"be_ecommerce/notifications"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func getInventoryMovementCollection() *mongo.Collection {
+	return config.MongoClient.Database("ecommerce").Collection("inventory_movements")
+}
+
+// stockChange adalah satu perubahan stok yang dicatat di ledger
+type stockChange struct {
+	ProductID primitive.ObjectID
+	VariantID *primitive.ObjectID
+	Type      string
+	Quantity  int // Jumlah unit yang terlibat
+	Change    int // Selisih stok; 0 hanya mencatat (contoh: sale)
+	Reason    string
+	ActorID   *primitive.ObjectID
+	ActorRole string
+	OrderID   *primitive.ObjectID
+}
+
+// actorFromCtx mengambil pelaku perubahan stok dari pengguna yang sedang login
+func actorFromCtx(c *fiber.Ctx) (*primitive.ObjectID, string) {
+	actorID := currentUserID(c)
+	role, _ := c.Locals("role").(string)
+	if role != "admin" {
+		role = "seller"
+	}
+	return &actorID, role
+}
+
+// applyStockChange mengubah stok produk (dan variannya) secara atomik, mencatat pergerakannya
+// lalu memperbarui tanda habis/menipis. Pengurangan yang membuat stok negatif ditolak dengan
+// errInsufficientStock. Panggil di dalam transaksi bila menjadi bagian dari perubahan lain.
+func applyStockChange(ctx context.Context, change stockChange) error {
+	filter := bson.M{"_id": change.ProductID}
+	update := bson.M{"$inc": bson.M{"stock": change.Change}}
+	if change.VariantID != nil {
+		variantFilter := bson.M{"_id": *change.VariantID}
+		if change.Change < 0 {
+			variantFilter["stock"] = bson.M{"$gte": -change.Change}
+		}
+		filter["variants"] = bson.M{"$elemMatch": variantFilter}
+		update = bson.M{"$inc": bson.M{"stock": change.Change, "variants.$.stock": change.Change}}
+	}
+	if change.Change < 0 {
+		filter["stock"] = bson.M{"$gte": -change.Change}
+	}
+
+	var product model.Product
+	var err error
+	if change.Change == 0 {
+		err = getProductCollection().FindOne(ctx, filter).Decode(&product)
+	} else {
+		err = getProductCollection().FindOneAndUpdate(ctx, filter, update,
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&product)
+	}
+	if err == mongo.ErrNoDocuments {
+		return errInsufficientStock
+	}
+	if err != nil {
+		return err
+	}
+
+	stockAfter := product.Stock
+	if change.VariantID != nil {
+		if variant, ok := product.Variant(*change.VariantID); ok {
+			stockAfter = variant.Stock
+		}
+	}
+	if err := recordMovement(ctx, product, change, stockAfter); err != nil {
+		return err
+	}
+	if change.Change == 0 {
+		return nil
+	}
+	return updateStockFlags(ctx, product)
+}
+
+// recordMovement menyimpan satu baris ledger untuk perubahan yang sudah diterapkan
+func recordMovement(ctx context.Context, product model.Product, change stockChange, stockAfter int) error {
+	if change.ActorRole == "" {
+		change.ActorRole = "system"
+	}
+	_, err := getInventoryMovementCollection().InsertOne(ctx, model.InventoryMovement{
+		ProductID:  product.ID,
+		VariantID:  change.VariantID,
+		SellerID:   product.SellerID,
+		Type:       change.Type,
+		Quantity:   change.Quantity,
+		Change:     change.Change,
+		StockAfter: stockAfter,
+		Reason:     change.Reason,
+		ActorID:    change.ActorID,
+		ActorRole:  change.ActorRole,
+		OrderID:    change.OrderID,
+		CreatedAt:  time.Now(),
+	})
+	return err
+}
+
+// updateStockFlags menyelaraskan tanda out_of_stock dengan stok produk dan memberi tahu seller
+// sekali saat stok turun ke batas menipis (atau habis); tanda direset saat stok naik lagi
+func updateStockFlags(ctx context.Context, product model.Product) error {
+	outOfStock := product.Stock <= 0
+	low := outOfStock || (product.LowStockThreshold > 0 && product.Stock <= product.LowStockThreshold)
+	if outOfStock == product.OutOfStock && low == product.LowStockAlerted {
+		return nil
+	}
+
+	_, err := getProductCollection().UpdateOne(ctx, bson.M{"_id": product.ID}, bson.M{"$set": bson.M{
+		"out_of_stock":      outOfStock,
+		"low_stock_alerted": low,
+	}})
+	if err != nil || !low || product.LowStockAlerted {
+		return err
+	}
+
+	title := "Low stock"
+	body := fmt.Sprintf("%s has %d left in stock", product.Name, product.Stock)
+	if outOfStock {
+		title = "Out of stock"
+		body = fmt.Sprintf("%s is out of stock and hidden from the catalogue", product.Name)
+	}
+	err = pushNotification(ctx, product.SellerID, model.NotificationLowStock, title, body, map[string]string{
+		"product_id": product.ID.Hex(),
+		"stock":      fmt.Sprint(product.Stock),
+	})
+	if err != nil {
+		return err
+	}
+	return notifyUserByID(ctx, product.SellerID, notifications.TemplateLowStock, func(user model.User) interface{} {
+		return notifications.LowStockEmail{
+			Name:        user.Username,
+			ProductName: product.Name,
+			Stock:       product.Stock,
+			Threshold:   product.LowStockThreshold,
+		}
+	})
+}
+
+// insertProduct menyimpan produk baru milik seller dan mencatat stok awalnya di ledger
+// sebagai restock. Panggil di dalam config.WithTransaction.
+func insertProduct(ctx context.Context, product model.Product, sellerID primitive.ObjectID) error {
+	product.OutOfStock = product.Stock <= 0
+	if product.Status == "" {
+		product.Status = model.ProductPendingReview
+	}
+	now := time.Now()
+	product.StatusUpdatedAt = &now
+	if _, err := getProductCollection().InsertOne(ctx, product); err != nil {
+		return err
+	}
+	if product.Stock <= 0 {
+		return nil
+	}
+	return recordMovement(ctx, product, stockChange{
+		Type:      model.MovementRestock,
+		Quantity:  product.Stock,
+		Change:    product.Stock,
+		Reason:    "Initial stock",
+		ActorID:   &sellerID,
+		ActorRole: "seller",
+	}, product.Stock)
+}
+
+// setStock menyamakan stok produk tanpa varian dengan angka dari form edit produk dan
+// mencatat selisihnya sebagai adjustment
+func setStock(ctx context.Context, product model.Product, stock int, actorID *primitive.ObjectID, role string) error {
+	if stock < 0 {
+		return errInsufficientStock
+	}
+	if stock == product.Stock {
+		return nil
+	}
+	change := stockChange{
+		ProductID: product.ID,
+		Type:      model.MovementAdjustment,
+		Change:    stock - product.Stock,
+		Reason:    "Stock edited on product form",
+		ActorID:   actorID,
+		ActorRole: role,
+	}
+	change.Quantity = change.Change
+	if change.Quantity < 0 {
+		change.Quantity = -change.Quantity
+	}
+	return applyStockChange(ctx, change)
+}
+
+// hideOutOfStock menambahkan filter katalog yang menyembunyikan produk habis, kecuali
+// pembeli meminta ?include_out_of_stock=true. prefix dipakai bila produk berada di sub-dokumen.
+func hideOutOfStock(c *fiber.Ctx, filter bson.M, prefix string) bson.M {
+	if !c.QueryBool("include_out_of_stock") {
+		filter[prefix+"out_of_stock"] = bson.M{"$ne": true}
+	}
+	return filter
+}
+
+// recordSale mencatat item order yang sudah dibayar sebagai penjualan. Stok sudah dipotong
+// saat reservasi, jadi pergerakan ini tidak mengubah stok.
+func recordSale(ctx context.Context, order model.Order) error {
+	if !order.StockReserved {
+		return nil
+	}
+	orderID := order.ID
+	for _, item := range order.Items {
+		err := applyStockChange(ctx, stockChange{
+			ProductID: item.ProductID,
+			VariantID: item.VariantID,
+			Type:      model.MovementSale,
+			Quantity:  item.Quantity,
+			Reason:    "Payment received",
+			OrderID:   &orderID,
+		})
+		if err != nil && err != errInsufficientStock {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddInventoryMovement mencatat perubahan stok manual oleh seller atau admin:
+// {"type": "restock" | "return" | "adjustment", "quantity": 5, "variant_id": "...", "reason": "..."}.
+// Quantity restock dan return harus positif; adjustment boleh negatif.
+func AddInventoryMovement(c *fiber.Ctx) error {
+	productID, err := primitive.ObjectIDFromHex(c.Params("product_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid product ID",
+		})
+	}
+
+	var input struct {
+		Type      string `json:"type"`
+		Quantity  int    `json:"quantity"`
+		VariantID string `json:"variant_id"`
+		OrderID   string `json:"order_id"` // Opsional, untuk return
+		Reason    string `json:"reason"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+		})
+	}
+
+	var product model.Product
+	if err := getProductCollection().FindOne(context.Background(), bson.M{"_id": productID}).Decode(&product); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "Product not found",
+		})
+	}
+	if !canManageProduct(c, product) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "You do not have permission to change this product",
+		})
+	}
+
+	switch input.Type {
+	case model.MovementRestock, model.MovementReturn:
+		if input.Quantity < 1 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "Quantity must be greater than 0",
+			})
+		}
+	case model.MovementAdjustment:
+		if input.Quantity == 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "Quantity must not be 0",
+			})
+		}
+		if input.Reason == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "Reason is required for adjustments",
+			})
+		}
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Type must be restock, return or adjustment",
+		})
+	}
+
+	actorID, role := actorFromCtx(c)
+	change := stockChange{
+		ProductID: productID,
+		Type:      input.Type,
+		Quantity:  input.Quantity,
+		Change:    input.Quantity,
+		Reason:    input.Reason,
+		ActorID:   actorID,
+		ActorRole: role,
+	}
+	if input.Quantity < 0 {
+		change.Quantity = -input.Quantity
+	}
+	if len(product.Variants) > 0 {
+		variantID, err := primitive.ObjectIDFromHex(input.VariantID)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "variant_id is required for products with variants",
+			})
+		}
+		if _, ok := product.Variant(variantID); !ok {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": "Variant not found",
+			})
+		}
+		change.VariantID = &variantID
+	}
+	if input.OrderID != "" {
+		orderID, err := primitive.ObjectIDFromHex(input.OrderID)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "Invalid order ID",
+			})
+		}
+		change.OrderID = &orderID
+	}
+
+	err = config.WithTransaction(context.Background(), func(sessCtx mongo.SessionContext) error {
+		return applyStockChange(sessCtx, change)
+	})
+	if err == errInsufficientStock {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"message": "Stock cannot go below 0",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to update stock",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "Stock updated successfully",
+	})
+}
+
+// GetInventoryMovements mengembalikan riwayat pergerakan stok produk, terbaru lebih dulu.
+// Mendukung ?type=&variant_id=&page=&limit=
+func GetInventoryMovements(c *fiber.Ctx) error {
+	productID, err := primitive.ObjectIDFromHex(c.Params("product_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid product ID",
+		})
+	}
+
+	var product model.Product
+	if err := getProductCollection().FindOne(context.Background(), bson.M{"_id": productID}).Decode(&product); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "Product not found",
+		})
+	}
+	if !canManageProduct(c, product) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "You do not have permission to view this product's inventory",
+		})
+	}
+
+	filter := bson.M{"product_id": productID}
+	if movementType := c.Query("type"); movementType != "" {
+		filter["type"] = movementType
+	}
+	if variantID, err := primitive.ObjectIDFromHex(c.Query("variant_id")); err == nil {
+		filter["variant_id"] = variantID
+	}
+	limit := c.QueryInt("limit", 50)
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+	page := c.QueryInt("page", 1)
+	if page < 1 {
+		page = 1
+	}
+
+	cursor, err := getInventoryMovementCollection().Find(context.Background(), filter,
+		options.Find().
+			SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+			SetSkip(int64((page-1)*limit)).
+			SetLimit(int64(limit)),
+	)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch inventory movements",
+		})
+	}
+	movements := []model.InventoryMovement{}
+	if err := cursor.All(context.Background(), &movements); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to parse inventory movements",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Inventory movements fetched successfully",
+		"data":    movements,
+		"stock":   product.Stock,
+	})
+}
+
+// SetLowStockThreshold mengatur batas stok menipis produk: {"threshold": 5}. 0 berarti
+// seller hanya diberi tahu saat stok habis.
+func SetLowStockThreshold(c *fiber.Ctx) error {
+	productID, err := primitive.ObjectIDFromHex(c.Params("product_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid product ID",
+		})
+	}
+	var input struct {
+		Threshold int `json:"threshold"`
+	}
+	if err := c.BodyParser(&input); err != nil || input.Threshold < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Threshold must be 0 or greater",
+		})
+	}
+
+	var product model.Product
+	if err := getProductCollection().FindOne(context.Background(), bson.M{"_id": productID}).Decode(&product); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "Product not found",
+		})
+	}
+	if !canManageProduct(c, product) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "You do not have permission to change this product",
+		})
+	}
+
+	err = config.WithTransaction(context.Background(), func(sessCtx mongo.SessionContext) error {
+		if _, err := getProductCollection().UpdateOne(sessCtx, bson.M{"_id": productID}, bson.M{"$set": bson.M{"low_stock_threshold": input.Threshold}}); err != nil {
+			return err
+		}
+		product.LowStockThreshold = input.Threshold
+		return updateStockFlags(sessCtx, product)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to update threshold",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Low stock threshold updated successfully",
+	})
+}
+
+// GetLowStockProducts mengembalikan produk milik seller yang stoknya habis atau di bawah batas
+func GetLowStockProducts(c *fiber.Ctx) error {
+	cursor, err := getProductCollection().Find(context.Background(),
+		bson.M{
+			"seller_id":  currentUserID(c),
+			"deleted_at": bson.M{"$exists": false},
+			"$or": bson.A{
+				bson.M{"stock": bson.M{"$lte": 0}},
+				bson.M{"$expr": bson.M{"$and": bson.A{
+					bson.M{"$gt": bson.A{"$low_stock_threshold", 0}},
+					bson.M{"$lte": bson.A{"$stock", "$low_stock_threshold"}},
+				}}},
+			},
+		},
+		options.Find().SetSort(bson.M{"stock": 1}),
+	)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch products",
+		})
+	}
+	products := []model.Product{}
+	if err := cursor.All(context.Background(), &products); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to parse products",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Low stock products fetched successfully",
+		"data":    products,
+	})
+}
@@ -0,0 +1,258 @@
+package handler
+
+import (
+	"be_ecommerce/config"
+	"be_ecommerce/model"
+	"be_ecommerce/notifications"
+	"be_ecommerce/scheduler"
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Batas jumlah dokumen yang diproses per eksekusi job, sisanya diambil pada tick berikutnya
+const jobBatchSize = 100
+
+// envDuration membaca angka dari env dalam satuan unit, atau memakai nilai default
+func envDuration(key string, unit time.Duration, def int) time.Duration {
+	n, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || n < 1 {
+		n = def
+	}
+	return time.Duration(n) * unit
+}
+
+// ScheduledJobs mengembalikan job berkala aplikasi. Jendela waktunya dapat diatur lewat env:
+// ORDER_PAYMENT_TIMEOUT_HOURS (default 24), CART_REMINDER_AFTER_HOURS (default 24),
+// CART_EXPIRY_DAYS (default 30), GUEST_CART_EXPIRY_DAYS (default 7) dan ORDER_AUTO_COMPLETE_DAYS (default 7).
+func ScheduledJobs() []scheduler.Job {
+	paymentTimeout := envDuration("ORDER_PAYMENT_TIMEOUT_HOURS", time.Hour, 24)
+	cartReminderAfter := envDuration("CART_REMINDER_AFTER_HOURS", time.Hour, 24)
+	cartExpiry := envDuration("CART_EXPIRY_DAYS", 24*time.Hour, 30)
+	autoCompleteAfter := envDuration("ORDER_AUTO_COMPLETE_DAYS", 24*time.Hour, 7)
+
+	return []scheduler.Job{
+		{
+			Name:     "cancel_unpaid_orders",
+			Interval: 5 * time.Minute,
+			Run: func(ctx context.Context) error {
+				return cancelUnpaidOrders(ctx, paymentTimeout)
+			},
+		},
+		{
+			Name:     "abandoned_cart_reminders",
+			Interval: 30 * time.Minute,
+			Run: func(ctx context.Context) error {
+				return sendAbandonedCartReminders(ctx, cartReminderAfter)
+			},
+		},
+		{
+			Name:     "purge_expired_data",
+			Interval: time.Hour,
+			Run: func(ctx context.Context) error {
+				return purgeExpiredData(ctx, cartExpiry)
+			},
+		},
+		{
+			Name:     "poll_shipment_tracking",
+			Interval: trackingRefreshInterval,
+			Run:      pollShipmentTracking,
+		},
+		{
+			Name:     "process_product_imports",
+			Interval: 15 * time.Second,
+			Run:      processProductImports,
+		},
+		{
+			Name:     "wishlist_alerts",
+			Interval: 15 * time.Minute,
+			Run:      sendWishlistAlerts,
+		},
+		{
+			Name:     "auto_complete_orders",
+			Interval: time.Hour,
+			Run: func(ctx context.Context) error {
+				return autoCompleteOrders(ctx, autoCompleteAfter)
+			},
+		},
+	}
+}
+
+// cancelUnpaidOrders membatalkan order Pending yang melewati batas waktu pembayaran dan mengembalikan stoknya
+func cancelUnpaidOrders(ctx context.Context, timeout time.Duration) error {
+	collection := config.MongoClient.Database("ecommerce").Collection("orders")
+	cursor, err := collection.Find(ctx,
+		bson.M{"status": "Pending", "created_at": bson.M{"$lte": time.Now().Add(-timeout)}},
+		options.Find().SetProjection(bson.M{"_id": 1}).SetLimit(jobBatchSize),
+	)
+	if err != nil {
+		return err
+	}
+	var orders []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &orders); err != nil {
+		return err
+	}
+
+	cancelled := 0
+	for _, order := range orders {
+		err := config.WithTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+			return cancelPendingOrder(sessCtx, order.ID, "payment_timeout")
+		})
+		if err == mongo.ErrNoDocuments {
+			// Sudah dibayar atau dibatalkan di antara query dan update
+			continue
+		}
+		if err != nil {
+			log.Printf("Error cancelling unpaid order %s: %v", order.ID.Hex(), err)
+			continue
+		}
+		cancelled++
+	}
+	if cancelled > 0 {
+		log.Printf("Cancelled %d unpaid orders", cancelled)
+	}
+	return nil
+}
+
+// sendAbandonedCartReminders mengirim satu pengingat untuk keranjang yang tidak berubah sejak jendela waktu tertentu
+func sendAbandonedCartReminders(ctx context.Context, after time.Duration) error {
+	collection := config.MongoClient.Database("ecommerce").Collection("carts")
+	cursor, err := collection.Find(ctx, bson.M{
+		"user_id":    bson.M{"$exists": true}, // Keranjang tamu tidak punya alamat email
+		"updated_at": bson.M{"$lte": time.Now().Add(-after)},
+		"products.0": bson.M{"$exists": true},
+		"$or": []bson.M{
+			{"reminder_sent_at": bson.M{"$exists": false}},
+			{"$expr": bson.M{"$lt": []string{"$reminder_sent_at", "$updated_at"}}},
+		},
+	}, options.Find().SetLimit(jobBatchSize))
+	if err != nil {
+		return err
+	}
+	var carts []model.Cart
+	if err := cursor.All(ctx, &carts); err != nil {
+		return err
+	}
+
+	for _, cart := range carts {
+		userID, err := primitive.ObjectIDFromHex(cart.UserID)
+		if err != nil {
+			continue
+		}
+
+		err = config.WithTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+			// Tandai terkirim hanya jika keranjang tidak berubah sejak dibaca
+			result, err := collection.UpdateOne(sessCtx,
+				bson.M{"user_id": cart.UserID, "updated_at": cart.UpdatedAt},
+				bson.M{"$set": bson.M{"reminder_sent_at": time.Now()}},
+			)
+			if err != nil || result.ModifiedCount == 0 {
+				return err
+			}
+			return notifyUserByID(sessCtx, userID, notifications.TemplateCartReminder, func(user model.User) interface{} {
+				return cartReminderEmail(user, cart)
+			})
+		})
+		if err != nil && err != mongo.ErrNoDocuments {
+			log.Printf("Error sending cart reminder to %s: %v", cart.UserID, err)
+		}
+	}
+	return nil
+}
+
+// cartReminderEmail menyusun data template email pengingat keranjang
+func cartReminderEmail(user model.User, cart model.Cart) notifications.CartReminderEmail {
+	email := notifications.CartReminderEmail{Name: user.Username}
+	for _, item := range cart.Products {
+		email.Items = append(email.Items, notifications.OrderEmailItem{
+			Name:     item.ProductName,
+			Quantity: item.Quantity,
+			Price:    item.Price * item.Quantity,
+		})
+		email.Total += item.Price * item.Quantity
+	}
+	return email
+}
+
+// purgeExpiredData menghapus OTP/reset grant kedaluwarsa, penghitung rate limit lama, keranjang tamu yang
+// terbengkalai dan item aktif keranjang pengguna yang terbengkalai
+func purgeExpiredData(ctx context.Context, cartExpiry time.Duration) error {
+	now := time.Now()
+
+	for purpose := range otpTTL {
+		field := "one_time_codes." + purpose
+		_, err := getUserCollection().UpdateMany(ctx,
+			bson.M{field + ".expires_at": bson.M{"$lt": now}},
+			bson.M{"$unset": bson.M{field: ""}},
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	rateLimits := config.MongoClient.Database("ecommerce").Collection("otp_rate_limits")
+	if _, err := rateLimits.DeleteMany(ctx, bson.M{"window_start": bson.M{"$lt": now.Add(-otpRateLimitWindow)}}); err != nil {
+		return err
+	}
+
+	// Keranjang lama tanpa updated_at mulai dihitung umurnya sejak purge pertama
+	carts := config.MongoClient.Database("ecommerce").Collection("carts")
+	if _, err := carts.UpdateMany(ctx, bson.M{"updated_at": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"updated_at": now}}); err != nil {
+		return err
+	}
+	result, err := carts.DeleteMany(ctx, bson.M{"guest_id": bson.M{"$exists": true}, "$or": bson.A{
+		bson.M{"updated_at": bson.M{"$lt": now.Add(-cartExpiry)}},
+		bson.M{"updated_at": bson.M{"$lt": now.Add(-guestCartExpiry())}},
+	}})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount > 0 {
+		log.Printf("Deleted %d expired guest carts", result.DeletedCount)
+	}
+
+	// Keranjang pengguna tidak dihapus: hanya item aktif yang dikosongkan, sedangkan item yang
+	// disimpan untuk nanti dan catatan toko tetap ada. Keranjang yang masih dibuka tidak dianggap terbengkalai.
+	expired, err := carts.UpdateMany(ctx, bson.M{
+		"guest_id":   bson.M{"$exists": false},
+		"updated_at": bson.M{"$lt": now.Add(-cartExpiry)},
+		"$or": bson.A{
+			bson.M{"viewed_at": bson.M{"$exists": false}},
+			bson.M{"viewed_at": bson.M{"$lt": now.Add(-cartExpiry)}},
+		},
+		"products.0": bson.M{"$exists": true},
+	}, bson.M{"$set": bson.M{"products": bson.A{}}})
+	if err != nil {
+		return err
+	}
+	if expired.ModifiedCount > 0 {
+		log.Printf("Emptied %d expired carts", expired.ModifiedCount)
+	}
+	return nil
+}
+
+// autoCompleteOrders menyelesaikan order yang sudah Delivered lebih lama dari batas waktu
+func autoCompleteOrders(ctx context.Context, after time.Duration) error {
+	now := time.Now()
+	collection := config.MongoClient.Database("ecommerce").Collection("orders")
+	result, err := collection.UpdateMany(ctx,
+		bson.M{"status": "Delivered", "delivered_at": bson.M{"$lte": now.Add(-after)}},
+		bson.M{"$set": bson.M{"status": "Completed", "completed_at": now}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.ModifiedCount > 0 {
+		log.Printf("Auto-completed %d delivered orders", result.ModifiedCount)
+	}
+	return nil
+}
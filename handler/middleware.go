@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"be_ecommerce/model"
+	"be_ecommerce/utils"
+	"log"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// AdminOnly memastikan request dikirim oleh pengguna dengan role admin
+func AdminOnly(c *fiber.Ctx) error {
+	authHeader := c.Get("Authorization")
+	if authHeader == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "Authorization token is required",
+		})
+	}
+
+	// Validasi token dan ambil klaim
+	claims, err := utils.ValidateJWT(strings.TrimPrefix(authHeader, "Bearer "))
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "Invalid or expired token",
+		})
+	}
+
+	// Hanya admin yang boleh melanjutkan
+	role, _ := claims["role"].(string)
+	if role != "admin" {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "Forbidden: Admin access required",
+		})
+	}
+
+	c.Locals("user_id", claims["user_id"])
+	return c.Next()
+}
+
+// AuthRequired memastikan request membawa token yang valid lalu menyimpan
+// user_id, role dan seller_id dari klaim token di Locals
+func AuthRequired(c *fiber.Ctx) error {
+	authHeader := c.Get("Authorization")
+	if authHeader == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "Authorization token is required",
+		})
+	}
+	if !setAuthLocals(c, strings.TrimPrefix(authHeader, "Bearer ")) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "Invalid or expired token",
+		})
+	}
+	return c.Next()
+}
+
+// AuthRequiredStream sama dengan AuthRequired, tetapi juga menerima token dari query ?token=
+// karena EventSource di browser tidak dapat mengirim header Authorization
+func AuthRequiredStream(c *fiber.Ctx) error {
+	if c.Get("Authorization") != "" {
+		return AuthRequired(c)
+	}
+
+	if !setAuthLocals(c, c.Query("token")) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "Invalid or expired token",
+		})
+	}
+
+	// RejectSuspended hanya membaca header, jadi status akun diperiksa di sini
+	objectID, _ := primitive.ObjectIDFromHex(c.Locals("user_id").(string))
+	var user model.User
+	if err := getUserCollection().FindOne(c.Context(), bson.M{"_id": objectID}).Decode(&user); err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "User not found",
+		})
+	}
+	if isSuspended(user) {
+		return c.Status(fiber.StatusForbidden).JSON(suspensionResponse(user))
+	}
+	return c.Next()
+}
+
+// setAuthLocals memvalidasi token dan menyimpan klaimnya di Locals
+func setAuthLocals(c *fiber.Ctx, token string) bool {
+	if token == "" {
+		return false
+	}
+	claims, err := utils.ValidateJWT(token)
+	if err != nil {
+		return false
+	}
+	userID, _ := claims["user_id"].(string)
+	if _, err := primitive.ObjectIDFromHex(userID); err != nil {
+		return false
+	}
+
+	role, _ := claims["role"].(string)
+	sellerID, _ := claims["seller_id"].(string)
+	c.Locals("user_id", userID)
+	c.Locals("role", role)
+	c.Locals("seller_id", sellerID)
+	return true
+}
+
+// currentUserID mengambil ID pengguna yang disimpan oleh AuthRequired
+func currentUserID(c *fiber.Ctx) primitive.ObjectID {
+	userID, _ := c.Locals("user_id").(string)
+	objectID, _ := primitive.ObjectIDFromHex(userID)
+	return objectID
+}
+
+// RejectSuspended menolak request bertoken dari akun yang sedang disuspend.
+// Request tanpa token atau dengan token tidak valid diteruskan ke handler.
+func RejectSuspended(c *fiber.Ctx) error {
+	authHeader := c.Get("Authorization")
+	if authHeader == "" {
+		return c.Next()
+	}
+
+	claims, err := utils.ValidateJWT(strings.TrimPrefix(authHeader, "Bearer "))
+	if err != nil {
+		return c.Next()
+	}
+
+	userID, _ := claims["user_id"].(string)
+	objectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return c.Next()
+	}
+
+	var user model.User
+	err = getUserCollection().FindOne(c.Context(), bson.M{"_id": objectID}).Decode(&user)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"message": "User not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch user data",
+		})
+	}
+
+	if isSuspended(user) {
+		return c.Status(fiber.StatusForbidden).JSON(suspensionResponse(user))
+	}
+	if err := liftExpiredSuspension(c.Context(), user); err != nil {
+		log.Println("Error lifting expired suspension:", err)
+	}
+
+	return c.Next()
+}
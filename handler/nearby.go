@@ -0,0 +1,239 @@
+package handler
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Radius pencarian terdekat dalam kilometer
+const (
+	defaultNearbyRadiusKm = 10
+	maxNearbyRadiusKm     = 100
+)
+
+// nearbyQuery adalah parameter pencarian terdekat: ?lat=&long=&radius=(km)&page=&limit=
+type nearbyQuery struct {
+	Long, Lat   float64
+	MaxDistance float64 // Meter
+	Skip, Limit int
+}
+
+func parseNearbyQuery(c *fiber.Ctx) (nearbyQuery, bool) {
+	lat, errLat := strconv.ParseFloat(c.Query("lat"), 64)
+	long, errLong := strconv.ParseFloat(c.Query("long"), 64)
+	if errLat != nil || errLong != nil || lat < -90 || lat > 90 || long < -180 || long > 180 {
+		return nearbyQuery{}, false
+	}
+
+	radius := c.QueryFloat("radius", defaultNearbyRadiusKm)
+	if radius <= 0 || radius > maxNearbyRadiusKm {
+		radius = defaultNearbyRadiusKm
+	}
+	limit := c.QueryInt("limit", 20)
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	page := c.QueryInt("page", 1)
+	if page < 1 {
+		page = 1
+	}
+
+	return nearbyQuery{
+		Long:        long,
+		Lat:         lat,
+		MaxDistance: radius * 1000,
+		Skip:        (page - 1) * limit,
+		Limit:       limit,
+	}, true
+}
+
+// nearbyStoresStage membuat tahap $geoNear untuk toko aktif di sekitar titik; jarak
+// dalam meter disimpan di field distance dan hasilnya sudah terurut dari yang terdekat
+func nearbyStoresStage(ctx context.Context, query nearbyQuery) (bson.D, error) {
+	hiddenSellers, err := suspendedSellerIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return bson.D{{Key: "$geoNear", Value: bson.M{
+		"near":          bson.M{"type": "Point", "coordinates": []float64{query.Long, query.Lat}},
+		"distanceField": "distance",
+		"maxDistance":   query.MaxDistance,
+		"key":           "store_info.location",
+		"spherical":     true,
+		"query": bson.M{
+			"roles":        "seller",
+			"store_status": "approved",
+			"_id":          bson.M{"$nin": hiddenSellers},
+		},
+	}}}, nil
+}
+
+// GetNearbyStores mengembalikan toko dalam radius tertentu, terurut dari yang terdekat
+func GetNearbyStores(c *fiber.Ctx) error {
+	query, ok := parseNearbyQuery(c)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Valid lat and long are required",
+		})
+	}
+
+	geoNear, err := nearbyStoresStage(c.Context(), query)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch nearby stores",
+		})
+	}
+
+	pipeline := mongo.Pipeline{
+		geoNear,
+		{{Key: "$skip", Value: query.Skip}},
+		{{Key: "$limit", Value: query.Limit}},
+		{{Key: "$project", Value: bson.M{
+			"_id":          0,
+			"id":           "$_id",
+			"store_name":   "$store_info.store_name",
+			"full_address": "$store_info.full_address",
+			"district":     "$store_info.address.district",
+			"location":     "$store_info.location",
+			"distance":     1,
+		}}},
+	}
+
+	cursor, err := getUserCollection().Aggregate(c.Context(), pipeline)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch nearby stores",
+		})
+	}
+	stores := []bson.M{}
+	if err := cursor.All(c.Context(), &stores); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to parse stores",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Nearby stores fetched successfully",
+		"data":    stores,
+	})
+}
+
+// GetNearbyProducts mengembalikan produk dari toko dalam radius tertentu, terurut dari toko terdekat
+func GetNearbyProducts(c *fiber.Ctx) error {
+	query, ok := parseNearbyQuery(c)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Valid lat and long are required",
+		})
+	}
+
+	geoNear, err := nearbyStoresStage(c.Context(), query)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch nearby products",
+		})
+	}
+
+	pipeline := mongo.Pipeline{
+		geoNear,
+		{{Key: "$lookup", Value: bson.D{
+			{Key: "from", Value: "products"},
+			{Key: "localField", Value: "_id"},
+			{Key: "foreignField", Value: "seller_id"},
+			{Key: "as", Value: "product"},
+		}}},
+		{{Key: "$unwind", Value: "$product"}},
+		{{Key: "$match", Value: hideOutOfStock(c, onlyActiveProducts(bson.M{}, "product."), "product.")}},
+		{{Key: "$replaceRoot", Value: bson.M{"newRoot": bson.M{"$mergeObjects": bson.A{
+			"$product",
+			bson.M{
+				"distance":   "$distance",
+				"store_name": "$store_info.store_name",
+				"district":   "$store_info.address.district",
+			},
+		}}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "distance", Value: 1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$skip", Value: query.Skip}},
+		{{Key: "$limit", Value: query.Limit}},
+	}
+
+	cursor, err := getUserCollection().Aggregate(c.Context(), pipeline)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch nearby products",
+		})
+	}
+	products := []bson.M{}
+	if err := cursor.All(c.Context(), &products); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to parse products",
+		})
+	}
+	if err := withEffectivePriceDocs(c.Context(), products); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to calculate product prices",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Nearby products fetched successfully",
+		"data":    products,
+	})
+}
+
+// catalogueDistrict menentukan kabupaten/kota pembeli untuk filter "satu kota" di katalog:
+// dari ?district= atau dari ?lat=&long= yang di-resolve ke region lewat $geoIntersects.
+// String kosong berarti filter tidak dipakai.
+func catalogueDistrict(c *fiber.Ctx) (string, error) {
+	if district := c.Query("district"); district != "" {
+		return district, nil
+	}
+	if !c.QueryBool("same_city") {
+		return "", nil
+	}
+
+	lat, errLat := strconv.ParseFloat(c.Query("lat"), 64)
+	long, errLong := strconv.ParseFloat(c.Query("long"), 64)
+	if errLat != nil || errLong != nil {
+		return "", addressError{"lat and long are required for same_city"}
+	}
+	region, err := regionAt(c.Context(), long, lat)
+	if err == mongo.ErrNoDocuments {
+		return "", addressError{"Location is outside the known regions"}
+	}
+	if err != nil {
+		return "", err
+	}
+	return region.District, nil
+}
+
+// sellersInDistrict mengembalikan ID toko yang alamatnya berada di kabupaten/kota tertentu
+func sellersInDistrict(ctx context.Context, district string) ([]primitive.ObjectID, error) {
+	cursor, err := getUserCollection().Find(ctx,
+		bson.M{"roles": "seller", "store_info.address.district": district},
+		options.Find().
+			SetProjection(bson.M{"_id": 1}).
+			SetCollation(&options.Collation{Locale: "en", Strength: 2}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var sellers []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &sellers); err != nil {
+		return nil, err
+	}
+	ids := make([]primitive.ObjectID, 0, len(sellers))
+	for _, seller := range sellers {
+		ids = append(ids, seller.ID)
+	}
+	return ids, nil
+}
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"be_ecommerce/model"
+	"be_ecommerce/notifications"
+	"context"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// notifyUser menitipkan email bertemplate dalam bahasa pilihan pengguna ke outbox.
+// Panggil dengan sessCtx dari config.WithTransaction agar email ikut transaksi perubahan datanya.
+func notifyUser(ctx context.Context, user model.User, tmpl notifications.Template, data interface{}) error {
+	return notifications.Enqueue(ctx, user.Email, user.Language, tmpl, data)
+}
+
+// notifyUserByID mengambil data pengguna terlebih dahulu lalu menitipkan email bertemplate
+func notifyUserByID(ctx context.Context, userID primitive.ObjectID, tmpl notifications.Template, data func(user model.User) interface{}) error {
+	var user model.User
+	if err := getUserCollection().FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		return err
+	}
+	return notifyUser(ctx, user, tmpl, data(user))
+}
+
+// orderEmail menyusun data template email dari sebuah order
+func orderEmail(user model.User, order model.Order) notifications.OrderEmail {
+	items := make([]notifications.OrderEmailItem, 0, len(order.Items))
+	for _, item := range order.Items {
+		items = append(items, notifications.OrderEmailItem{
+			Name:     item.Name,
+			Quantity: item.Quantity,
+			Price:    item.Price * item.Quantity,
+		})
+	}
+	data := notifications.OrderEmail{
+		Name:            user.Username,
+		OrderID:         order.ID.Hex(),
+		Items:           items,
+		ShippingCost:    order.ShippingCost,
+		TotalAmount:     order.TotalAmount,
+		ShippingAddress: order.ShippingAddress,
+	}
+	if n := len(order.Shipments); n > 0 {
+		data.Courier = strings.ToUpper(order.Shipments[n-1].Courier)
+		data.Waybill = order.Shipments[n-1].Waybill
+	}
+	return data
+}
+
+// notifyOrder menitipkan email terkait order untuk pembeli
+func notifyOrder(ctx context.Context, order model.Order, tmpl notifications.Template) error {
+	return notifyUserByID(ctx, order.UserID, tmpl, func(user model.User) interface{} {
+		return orderEmail(user, order)
+	})
+}
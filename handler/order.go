@@ -3,22 +3,29 @@ package handler
 import (
 	"be_ecommerce/config"
 	"be_ecommerce/model"
+	"be_ecommerce/notifications"
 	"context"
+	"errors"
+	"log"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 // CheckoutHandler menangani proses checkout dan menyimpan order ke database
 func CheckoutHandler(c *fiber.Ctx) error {
 	var input struct {
 		UserID       string            `json:"user_id"`
-		Shipping     string            `json:"shipping"`
-		Amount       int               `json:"amount"`
-		Items        []model.OrderItem `json:"items"`
-		ShippingCost int               `json:"shipping_cost"`
+		AddressID    string            `json:"address_id"` // Alamat dari buku alamat, kosong = alamat default
+		Address      *model.Address    `json:"address"`    // Atau alamat baru tanpa disimpan ke buku alamat
+		Items        []model.OrderItem `json:"items"`   // Kosong = item keranjang yang dipilih
+		Courier      string            `json:"courier"` // Dari GET /shipping/rates, kosong = termurah
+		Service      string            `json:"service"`
+		VoucherCode  string            `json:"voucher_code"`
 	}
 
 	if err := c.BodyParser(&input); err != nil {
@@ -30,20 +37,88 @@ func CheckoutHandler(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid User ID"})
 	}
 
+	// Checkout hanya untuk pengguna dengan email terverifikasi
+	verified, err := isUserEmailVerified(context.Background(), userID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	}
+	if !verified {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Please verify your email before checkout"})
+	}
+
+	address, err := resolveShippingAddress(context.Background(), userID, input.AddressID, input.Address)
+	if err != nil {
+		return shippingAddressError(c, "error", err)
+	}
+
+	// Harga, ongkos kirim dan potongan voucher dihitung ulang di server, bukan diambil dari klien
+	if len(input.Items) == 0 {
+		input.Items, err = cartOrderItems(context.Background(), userID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch cart"})
+		}
+	}
+	pricing, err := priceOrder(context.Background(), userID, input.Items, address, input.Courier, input.Service, input.VoucherCode)
+	if err != nil {
+		return pricingErrorResponse(c, "error", err)
+	}
+
+	// Catatan pembeli untuk item dan toko ikut tersimpan di order agar terlihat oleh seller
+	if err := applyCartNotes(context.Background(), userID, pricing.Items); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch cart"})
+	}
+
 	order := model.Order{
-		ID:              primitive.NewObjectID(),
-		UserID:          userID,
-		Items:           input.Items,
-		TotalAmount:     input.Amount,
-		ShippingCost:    input.ShippingCost,
-		ShippingAddress: input.Shipping,
-		Status:          "Pending",
-		CreatedAt:       time.Now(),
+		ID:               primitive.NewObjectID(),
+		UserID:           userID,
+		Items:            pricing.Items,
+		TotalAmount:      pricing.Total,
+		ShippingCost:     pricing.ShippingCost,
+		Courier:          pricing.Rate.Courier,
+		ShippingService:  pricing.Rate.Service,
+		VoucherCode:      pricing.VoucherCode,
+		Discount:         pricing.Discount,
+		ShippingDiscount: pricing.ShippingDiscount,
+		ShippingAddress:  address.String(),
+		Address:          address,
+		Status:           "Pending",
+		CreatedAt:        time.Now(),
+		StockReserved:    true,
 	}
 
+	// Stok, order, pemakaian voucher, penghapusan item dari keranjang dan email konfirmasinya disimpan dalam satu transaksi
 	collection := config.MongoClient.Database("ecommerce").Collection("orders")
-	_, err = collection.InsertOne(context.TODO(), order)
+	err = config.WithTransaction(context.TODO(), func(sessCtx mongo.SessionContext) error {
+		if err := reserveStock(sessCtx, order); err != nil {
+			return err
+		}
+		if err := reserveFlashSale(sessCtx, order.UserID, order.Items); err != nil {
+			return err
+		}
+		if _, err := collection.InsertOne(sessCtx, order); err != nil {
+			return err
+		}
+		if pricing.Voucher != nil {
+			if err := redeemVoucher(sessCtx, order, pricing.Voucher); err != nil {
+				return err
+			}
+		}
+		if err := removeOrderedCartItems(sessCtx, input.UserID, order.Items); err != nil {
+			return err
+		}
+		if err := pushNewOrder(sessCtx, order); err != nil {
+			return err
+		}
+		return notifyOrder(sessCtx, order, notifications.TemplateOrderPlaced)
+	})
+	if errors.Is(err, errInsufficientStock) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	}
+	if errors.As(err, &checkoutError{}) {
+		return pricingErrorResponse(c, "error", err)
+	}
 	if err != nil {
+		log.Println("Error placing order:", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to place order"})
 	}
 
@@ -140,6 +215,16 @@ func GetSellerOrderDetailsHandler(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{"message": "Order details fetched successfully", "data": order})
 }
 
+// Perubahan status manual: status tujuan → status asal yang diizinkan. Order tidak bisa kembali
+// ke Pending, karena order Pending yang lewat batas waktu dibatalkan otomatis oleh cancelUnpaidOrders.
+var manualStatusTransitions = map[string][]string{"Confirmed": {"Processing", "Confirmed"}}
+
+// Shipped dan Delivered hanya lewat ShipOrder (resi) dan ConfirmDelivery/tracking, agar order
+// multi-toko tidak dianggap selesai sebelum semua toko mengirim paketnya
+var shipmentStatuses = map[string]bool{"Shipped": true, "Delivered": true}
+
+const errShipmentStatus = "Use PUT /orders/:order_id/ship with a waybill number to ship an order, delivery is confirmed by the buyer or tracking"
+
 // **PUT /orders/:order_id** → Update status pesanan oleh seller
 func UpdateSellerOrderHandler(c *fiber.Ctx) error {
 	// Ambil orderID dari params
@@ -149,20 +234,11 @@ func UpdateSellerOrderHandler(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid Order ID"})
 	}
 
-	// Struct untuk data yang ingin diperbarui
+	// Struct untuk data yang ingin diperbarui. Item dan nominal order (total, ongkir, token pembayaran)
+	// tidak bisa diubah di sini agar sesuai dengan yang dibayar di Midtrans.
 	var updateData struct {
 		Status          string `json:"status"`
 		ShippingAddress string `json:"shipping_address"`
-		Items           []struct {
-			ProductID string `json:"product_id"`
-			Name      string `json:"name"`
-			Quantity  int    `json:"quantity"`
-			Price     int    `json:"price"`
-			SellerID  string `json:"seller_id"`
-		} `json:"items"`
-		TotalAmount    int    `json:"total_amount"`
-		ShippingCost   int    `json:"shipping_cost"`
-		PaymentToken   string `json:"payment_token"`
 	}
 
 	// Parsing data request body
@@ -170,36 +246,46 @@ func UpdateSellerOrderHandler(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
 	}
 
-	// Cek apakah status valid (opsional, tergantung kebutuhan)
-	validStatuses := []string{"Pending", "Confirmed", "Shipped", "Delivered"}
-	statusValid := false
-	for _, validStatus := range validStatuses {
-		if updateData.Status == validStatus {
-			statusValid = true
-			break
+	if shipmentStatuses[updateData.Status] {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": errShipmentStatus})
+	}
+
+	// Hanya field yang dikirim yang diupdate; alamat hanya bisa diubah sebelum pesanan dikirim
+	fields := bson.M{}
+	fromStatuses := cancellableOrderStatuses
+	if updateData.Status != "" {
+		allowed, ok := manualStatusTransitions[updateData.Status]
+		if !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid status"})
 		}
+		fields["status"] = updateData.Status
+		fromStatuses = allowed
 	}
-	if !statusValid {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid status"})
+	if address := strings.TrimSpace(updateData.ShippingAddress); address != "" {
+		fields["shipping_address"] = address
+	}
+	if len(fields) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Nothing to update"})
 	}
 
 	// Collection orders
 	collection := config.MongoClient.Database("ecommerce").Collection("orders")
 
-	// Data yang akan diupdate
-	updateFields := bson.M{
-		"$set": bson.M{
-			"status":           updateData.Status,
-			"shipping_address": updateData.ShippingAddress,
-			"items":            updateData.Items,
-			"total_amount":     updateData.TotalAmount,
-			"shipping_cost":    updateData.ShippingCost,
-			"payment_token":    updateData.PaymentToken,
-		},
+	// Melakukan update data berdasarkan orderID, email "pesanan dikirim" ikut dalam transaksi
+	err = config.WithTransaction(context.TODO(), func(sessCtx mongo.SessionContext) error {
+		var previous model.Order
+		filter := bson.M{"_id": objID, "status": bson.M{"$in": fromStatuses}}
+		if err := collection.FindOneAndUpdate(sessCtx, filter, bson.M{"$set": fields}).Decode(&previous); err != nil {
+			return err
+		}
+		if updateData.Status == "" {
+			return nil
+		}
+		return afterOrderStatusChange(sessCtx, objID, previous.Status, updateData.Status)
+	})
+	if err == mongo.ErrNoDocuments {
+		return orderStatusConflict(c, objID)
 	}
-
-	// Melakukan update data berdasarkan orderID
-	_, err = collection.UpdateOne(context.TODO(), bson.M{"_id": objID}, updateFields)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update order"})
 	}
@@ -208,6 +294,19 @@ func UpdateSellerOrderHandler(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{"message": "Order updated successfully"})
 }
 
+// orderStatusConflict membedakan order yang tidak ada dengan order yang statusnya tidak bisa diubah lagi
+func orderStatusConflict(c *fiber.Ctx, orderID primitive.ObjectID) error {
+	collection := config.MongoClient.Database("ecommerce").Collection("orders")
+	count, err := collection.CountDocuments(context.TODO(), bson.M{"_id": orderID})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update order"})
+	}
+	if count == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Order not found"})
+	}
+	return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Order can no longer be changed to this status"})
+}
+
 // PUT /orders/status/:order_id → Update status order
 func UpdateOrderStatusHandler(c *fiber.Ctx) error {
 	orderID := c.Params("order_id")
@@ -227,14 +326,46 @@ func UpdateOrderStatusHandler(c *fiber.Ctx) error {
 	if statusUpdate.Status == "" {
 	  return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Status is required"})
 	}
+	if shipmentStatuses[statusUpdate.Status] {
+	  return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": errShipmentStatus})
+	}
+  
+	// Pembatalan melewati jalur yang sama dengan pembatalan otomatis agar stok, voucher dan kuota flash sale dikembalikan
+	if statusUpdate.Status == "Cancelled" {
+	  err = config.WithTransaction(context.TODO(), func(sessCtx mongo.SessionContext) error {
+	    return cancelOrder(sessCtx, objID, cancellableOrderStatuses, "cancelled_by_seller")
+	  })
+	  if err == mongo.ErrNoDocuments {
+	    return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Order can no longer be cancelled"})
+	  }
+	  if err != nil {
+	    return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update order status"})
+	  }
+	  return c.JSON(fiber.Map{"message": "Order status updated successfully"})
+	}
   
+	fromStatuses, ok := manualStatusTransitions[statusUpdate.Status]
+	if !ok {
+	  return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid status"})
+	}
+
 	collection := config.MongoClient.Database("ecommerce").Collection("orders")
-	_, err = collection.UpdateOne(
-	  context.TODO(),
-	  bson.M{"_id": objID},
-	  bson.M{"$set": bson.M{"status": statusUpdate.Status}},
-	)
+	err = config.WithTransaction(context.TODO(), func(sessCtx mongo.SessionContext) error {
+	  var previous model.Order
+	  err := collection.FindOneAndUpdate(
+	    sessCtx,
+	    bson.M{"_id": objID, "status": bson.M{"$in": fromStatuses}},
+	    bson.M{"$set": bson.M{"status": statusUpdate.Status}},
+	  ).Decode(&previous)
+	  if err != nil {
+	    return err
+	  }
+	  return afterOrderStatusChange(sessCtx, objID, previous.Status, statusUpdate.Status)
+	})
   
+	if err == mongo.ErrNoDocuments {
+	  return orderStatusConflict(c, objID)
+	}
 	if err != nil {
 	  return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update order status"})
 	}
@@ -251,12 +382,51 @@ func DeleteSellerOrderHandler(c *fiber.Ctx) error {
 	}
 
 	collection := config.MongoClient.Database("ecommerce").Collection("orders")
-	// Menghapus order berdasarkan orderID
-	_, err = collection.DeleteOne(context.TODO(), bson.M{"_id": objID})
+	// Hanya order yang sudah dibatalkan yang bisa dihapus, karena pembatalan (cancelOrder) yang
+	// mengembalikan stok, voucher dan kuota flash sale
+	result, err := collection.DeleteOne(context.TODO(), bson.M{"_id": objID, "status": "Cancelled"})
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete order"})
 	}
+	if result.DeletedCount == 0 {
+		count, err := collection.CountDocuments(context.TODO(), bson.M{"_id": objID})
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete order"})
+		}
+		if count == 0 {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Order not found"})
+		}
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Only cancelled orders can be deleted, cancel the order first"})
+	}
 
 	// Mengembalikan pesan sukses
 	return c.JSON(fiber.Map{"message": "Order deleted successfully"})
 }
+
+// afterOrderStatusChange mencatat waktu pengiriman selesai (dasar auto-complete),
+// mengirim notifikasi in-app ke pembeli dan email saat pesanan mulai dikirim
+func afterOrderStatusChange(ctx context.Context, orderID primitive.ObjectID, previous, current string) error {
+	if previous == current {
+		return nil
+	}
+
+	collection := config.MongoClient.Database("ecommerce").Collection("orders")
+	if current == "Delivered" {
+		_, err := collection.UpdateOne(ctx, bson.M{"_id": orderID}, bson.M{"$set": bson.M{"delivered_at": time.Now()}})
+		if err != nil {
+			return err
+		}
+	}
+
+	var order model.Order
+	if err := collection.FindOne(ctx, bson.M{"_id": orderID}).Decode(&order); err != nil {
+		return err
+	}
+	if err := pushOrderStatus(ctx, order); err != nil {
+		return err
+	}
+	if current == "Shipped" {
+		return notifyOrder(ctx, order, notifications.TemplateOrderShipped)
+	}
+	return nil
+}
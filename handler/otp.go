@@ -0,0 +1,230 @@
+package handler
+
+import (
+	"be_ecommerce/config"
+	"be_ecommerce/model"
+	"be_ecommerce/utils"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Keperluan OTP yang disimpan di users.one_time_codes
+const (
+	otpPurposePasswordReset      = "password_reset"
+	otpPurposePasswordResetGrant = "password_reset_grant"
+	otpPurposeEmailVerification  = "email_verification"
+)
+
+const (
+	otpLength          = 6
+	otpResendCooldown  = time.Minute
+	otpMaxAttempts     = 5
+	otpRateLimitWindow = time.Hour
+	otpRateLimitMax    = 5
+	resetGrantLength   = 32
+)
+
+// Masa berlaku OTP per keperluan
+var otpTTL = map[string]time.Duration{
+	otpPurposePasswordReset:      10 * time.Minute,
+	otpPurposePasswordResetGrant: 15 * time.Minute,
+	otpPurposeEmailVerification:  24 * time.Hour,
+}
+
+var (
+	errOTPInvalid         = errors.New("invalid OTP")
+	errOTPExpired         = errors.New("OTP expired")
+	errOTPCooldown        = errors.New("please wait before requesting a new OTP")
+	errOTPRateLimited     = errors.New("too many OTP requests, please try again later")
+	errOTPTooManyAttempts = errors.New("too many failed attempts, please request a new OTP")
+)
+
+// storeOneTimeCode menyimpan hash kode untuk keperluan tertentu, menggantikan kode sebelumnya
+func storeOneTimeCode(ctx context.Context, userID primitive.ObjectID, purpose, code string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	entry := model.OneTimeCode{
+		CodeHash:  string(hash),
+		ExpiresAt: now.Add(otpTTL[purpose]),
+		SentAt:    now,
+	}
+
+	_, err = getUserCollection().UpdateOne(ctx,
+		bson.M{"_id": userID},
+		bson.M{"$set": bson.M{"one_time_codes." + purpose: entry}},
+	)
+	return err
+}
+
+// allowOTPRequest membatasi jumlah permintaan OTP per email dalam satu jendela waktu
+func allowOTPRequest(ctx context.Context, email, purpose string) (bool, error) {
+	collection := config.MongoClient.Database("ecommerce").Collection("otp_rate_limits")
+	key := purpose + ":" + email
+	now := time.Now()
+
+	var counter struct {
+		Count int `bson:"count"`
+	}
+	err := collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": key, "window_start": bson.M{"$gt": now.Add(-otpRateLimitWindow)}},
+		bson.M{"$inc": bson.M{"count": 1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&counter)
+	if err == mongo.ErrNoDocuments {
+		// Jendela baru
+		_, err = collection.UpdateOne(ctx,
+			bson.M{"_id": key},
+			bson.M{"$set": bson.M{"window_start": now, "count": 1}},
+			options.Update().SetUpsert(true),
+		)
+		return err == nil, err
+	}
+	if err != nil {
+		return false, err
+	}
+	return counter.Count <= otpRateLimitMax, nil
+}
+
+// issueOTP membuat OTP numerik baru untuk keperluan tertentu dan menyimpan hash-nya pada user.
+// Permintaan ulang sebelum cooldown habis atau melebihi batas per email akan ditolak.
+func issueOTP(ctx context.Context, user model.User, purpose string) (string, error) {
+	if existing, ok := user.OneTimeCodes[purpose]; ok && time.Since(existing.SentAt) < otpResendCooldown {
+		return "", errOTPCooldown
+	}
+
+	allowed, err := allowOTPRequest(ctx, user.Email, purpose)
+	if err != nil {
+		return "", err
+	}
+	if !allowed {
+		return "", errOTPRateLimited
+	}
+
+	code, err := utils.GenerateNumericCode(otpLength)
+	if err != nil {
+		return "", err
+	}
+	if err := storeOneTimeCode(ctx, user.ID, purpose, code); err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// issueResetGrant menerbitkan izin sekali pakai untuk mengganti password setelah OTP terverifikasi
+func issueResetGrant(ctx context.Context, userID primitive.ObjectID) (string, error) {
+	grant := utils.GenerateRandomToken(resetGrantLength)
+	if grant == "" {
+		return "", errors.New("failed to generate reset grant")
+	}
+	if err := storeOneTimeCode(ctx, userID, otpPurposePasswordResetGrant, grant); err != nil {
+		return "", err
+	}
+	return grant, nil
+}
+
+// checkOTP memverifikasi kode milik email untuk keperluan tertentu tanpa menghapusnya; pemanggil
+// menghapus kode setelah berhasil. Setiap percobaan dicatat secara atomik sebelum hash dibandingkan,
+// sehingga request paralel tidak bisa melewati batas percobaan. Setelah batas tercapai kode dihapus.
+func checkOTP(ctx context.Context, email, purpose, code string) (model.User, error) {
+	var user model.User
+	if email == "" || code == "" {
+		return user, errOTPInvalid
+	}
+
+	field := "one_time_codes." + purpose
+	err := getUserCollection().FindOneAndUpdate(ctx,
+		bson.M{"email": email, field + ".attempts": bson.M{"$lt": otpMaxAttempts}},
+		bson.M{"$inc": bson.M{field + ".attempts": 1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		// Tidak ada kode aktif, atau batas percobaan sudah habis
+		count, err := getUserCollection().CountDocuments(ctx, bson.M{"email": email, field: bson.M{"$exists": true}})
+		if err != nil {
+			return user, err
+		}
+		if count > 0 {
+			return user, errOTPTooManyAttempts
+		}
+		return user, errOTPInvalid
+	}
+	if err != nil {
+		return user, err
+	}
+
+	entry := user.OneTimeCodes[purpose]
+	if time.Now().After(entry.ExpiresAt) {
+		return user, errOTPExpired
+	}
+	if bcrypt.CompareHashAndPassword([]byte(entry.CodeHash), []byte(code)) != nil {
+		if entry.Attempts >= otpMaxAttempts {
+			_, err := getUserCollection().UpdateOne(ctx,
+				bson.M{"_id": user.ID, field + ".code_hash": entry.CodeHash},
+				bson.M{"$unset": bson.M{field: ""}},
+			)
+			if err != nil {
+				return user, err
+			}
+			return user, errOTPTooManyAttempts
+		}
+		return user, errOTPInvalid
+	}
+	return user, nil
+}
+
+// consumeOTP menghapus kode yang sudah terverifikasi secara atomik agar hanya bisa dipakai sekali
+func consumeOTP(ctx context.Context, user model.User, purpose string) error {
+	entry, ok := user.OneTimeCodes[purpose]
+	if !ok {
+		return errOTPInvalid
+	}
+
+	result, err := getUserCollection().UpdateOne(ctx,
+		bson.M{"_id": user.ID, "one_time_codes." + purpose + ".code_hash": entry.CodeHash},
+		bson.M{"$unset": bson.M{"one_time_codes." + purpose: ""}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.ModifiedCount == 0 {
+		return errOTPInvalid
+	}
+	return nil
+}
+
+// otpErrorStatus memetakan error OTP ke status HTTP yang sesuai
+func otpErrorStatus(err error) int {
+	switch err {
+	case errOTPInvalid, errOTPExpired:
+		return fiber.StatusUnauthorized
+	case errOTPCooldown, errOTPRateLimited, errOTPTooManyAttempts:
+		return fiber.StatusTooManyRequests
+	}
+	return fiber.StatusInternalServerError
+}
+
+// isEmailVerified menganggap akun lama (tanpa field email_verified) sudah terverifikasi
+func isEmailVerified(user model.User) bool {
+	return user.EmailVerified == nil || *user.EmailVerified
+}
+
+// isUserEmailVerified mengambil user berdasarkan ID lalu mengecek status verifikasi email
+func isUserEmailVerified(ctx context.Context, userID primitive.ObjectID) (bool, error) {
+	var user model.User
+	if err := getUserCollection().FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		return false, err
+	}
+	return isEmailVerified(user), nil
+}
@@ -3,9 +3,12 @@ package handler
 import (
 	"be_ecommerce/config"
 	"be_ecommerce/model"
+	"be_ecommerce/notifications"
 	"be_ecommerce/services"
 	"context"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"time"
 
@@ -13,16 +16,19 @@ import (
 	"github.com/veritrans/go-midtrans"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 // CreatePaymentHandler menangani proses pembayaran menggunakan Midtrans
 func CreatePaymentHandler(c *fiber.Ctx) error {
 	var input struct {
 		UserID       string            `json:"user_id"`
-		Shipping     string            `json:"shipping"`
-		Amount       int               `json:"amount"`
-		ShippingCost int               `json:"shipping_cost"`
-		Items        []model.OrderItem `json:"items"`
+		AddressID    string            `json:"address_id"` // Alamat dari buku alamat, kosong = alamat default
+		Address      *model.Address    `json:"address"`    // Atau alamat baru tanpa disimpan ke buku alamat
+		Items        []model.OrderItem `json:"items"`   // Kosong = item keranjang yang dipilih
+		Courier      string            `json:"courier"` // Dari GET /shipping/rates, kosong = termurah
+		Service      string            `json:"service"`
+		VoucherCode  string            `json:"voucher_code"`
 	}
 
 	// 🔥 1. Parse Request Body
@@ -39,43 +45,73 @@ func CreatePaymentHandler(c *fiber.Ctx) error {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"message": "Invalid User ID"})
 	}
 
-	// 🔥 3. Ambil Seller ID dari Produk
-	productCollection := config.MongoClient.Database("ecommerce").Collection("products")
-	for i, item := range input.Items {
-		var product model.Product
-		err := productCollection.FindOne(context.TODO(), bson.M{"_id": item.ProductID}).Decode(&product)
+	// Checkout hanya untuk pengguna dengan email terverifikasi
+	verified, err := isUserEmailVerified(context.Background(), objUserID)
+	if err != nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"message": "User not found"})
+	}
+	if !verified {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "Please verify your email before checkout"})
+	}
+
+	address, err := resolveShippingAddress(context.Background(), objUserID, input.AddressID, input.Address)
+	if err != nil {
+		return shippingAddressError(c, "message", err)
+	}
+
+	// 🔥 3. Hitung harga, ongkos kirim dan potongan voucher di server, bukan diambil dari klien
+	if len(input.Items) == 0 {
+		input.Items, err = cartOrderItems(context.Background(), objUserID)
 		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid product ID"})
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to fetch cart"})
 		}
-		// ✅ Tambahkan seller_id dari produk ke item
-		input.Items[i].SellerID = product.SellerID
+	}
+	pricing, err := priceOrder(context.Background(), objUserID, input.Items, address, input.Courier, input.Service, input.VoucherCode)
+	if err != nil {
+		return pricingErrorResponse(c, "message", err)
 	}
 
-	// 🔥 4. Hitung Total Amount dan Siapkan Data Midtrans
-	var totalAmount int64 = 0
-	var midtransItems []midtrans.ItemDetail
+	// Catatan pembeli untuk item dan toko ikut tersimpan di order agar terlihat oleh seller
+	if err := applyCartNotes(context.Background(), objUserID, pricing.Items); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to fetch cart"})
+	}
 
-	for _, item := range input.Items {
-		totalAmount += int64(item.Price * item.Quantity)
+	// 🔥 4. Siapkan Data Midtrans
+	totalAmount := int64(pricing.Total)
+	var midtransItems []midtrans.ItemDetail
 
-		// ✅ Tambahkan ke Midtrans Items
+	for _, item := range pricing.Items {
+		// ✅ Tambahkan ke Midtrans Items (item bervarian memakai ID varian)
+		itemID, itemName := item.ProductID.Hex(), item.Name
+		if item.VariantID != nil {
+			itemID, itemName = item.VariantID.Hex(), item.Name+" ("+item.Variant+")"
+		}
 		midtransItems = append(midtransItems, midtrans.ItemDetail{
-			ID:    item.ProductID.Hex(),
-			Name:  item.Name,
+			ID:    itemID,
+			Name:  itemName,
 			Qty:   int32(item.Quantity),
 			Price: int64(item.Price),
 		})
 	}
 
 	// ✅ Tambahkan Shipping Cost sebagai item terpisah di Midtrans
-	if input.ShippingCost > 0 {
+	if pricing.ShippingCost > 0 {
 		midtransItems = append(midtransItems, midtrans.ItemDetail{
 			ID:    "SHIPPING",
 			Name:  "Shipping Cost",
 			Qty:   1,
-			Price: int64(input.ShippingCost),
+			Price: int64(pricing.ShippingCost),
+		})
+	}
+
+	// ✅ Potongan voucher sebagai item bernilai negatif agar jumlah item sama dengan GrossAmt
+	if discount := pricing.Discount + pricing.ShippingDiscount; discount > 0 {
+		midtransItems = append(midtransItems, midtrans.ItemDetail{
+			ID:    "VOUCHER",
+			Name:  "Voucher " + pricing.VoucherCode,
+			Qty:   1,
+			Price: -int64(discount),
 		})
-		totalAmount += int64(input.ShippingCost)
 	}
 
 	// 🔥 5. Pastikan Total Amount Tidak 0
@@ -83,34 +119,27 @@ func CreatePaymentHandler(c *fiber.Ctx) error {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"message": "Total transaction amount must be greater than 0"})
 	}
 
-	// 🔥 6. Simpan Order ke Database
+	// 🔥 6. Siapkan Order (ID dibuat lebih dulu untuk order_id Midtrans)
 	order := model.Order{
-		ID:              primitive.NewObjectID(),
-		UserID:          objUserID,
-		SellerID:        input.Items[0].SellerID, // Ambil Seller ID dari item pertama
-		Items:           input.Items,
-		TotalAmount:     int(totalAmount),
-		ShippingCost:    input.ShippingCost,
-		ShippingAddress: input.Shipping,
-		Status:          "Pending",
-		CreatedAt:       time.Now(),
+		ID:               primitive.NewObjectID(),
+		UserID:           objUserID,
+		SellerID:         pricing.Items[0].SellerID, // Ambil Seller ID dari item pertama
+		Items:            pricing.Items,
+		TotalAmount:      pricing.Total,
+		ShippingCost:     pricing.ShippingCost,
+		Courier:          pricing.Rate.Courier,
+		ShippingService:  pricing.Rate.Service,
+		VoucherCode:      pricing.VoucherCode,
+		Discount:         pricing.Discount,
+		ShippingDiscount: pricing.ShippingDiscount,
+		ShippingAddress:  address.String(),
+		Address:          address,
+		Status:           "Pending",
+		CreatedAt:        time.Now(),
 	}
 
-	orderCollection := config.MongoClient.Database("ecommerce").Collection("orders")
-	_, err = orderCollection.InsertOne(context.Background(), order)
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to place order"})
-	}
-
-	// 🔥 7. Hapus Cart Setelah Pembayaran
-	cartCollection := config.MongoClient.Database("ecommerce").Collection("carts")
-	_, err = cartCollection.DeleteOne(context.Background(), bson.M{"user_id": objUserID})
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to clear cart after order placement"})
-	}
-
-	// 🔥 8. Konfigurasi Midtrans
-	orderID := "order-" + time.Now().Format("20060102150405")
+	// 🔥 7. Konfigurasi Midtrans (order_id Midtrans = ID order kita, agar notifikasi bisa dicocokkan)
+	orderID := order.ID.Hex()
 	midtransClient := services.MidtransClient()
 	snapGateway := midtrans.SnapGateway{Client: *midtransClient}
 
@@ -122,22 +151,70 @@ func CreatePaymentHandler(c *fiber.Ctx) error {
 		Items: &midtransItems,
 	}
 
-	// 🔥 9. Kirim Permintaan ke Midtrans
+	// 🔥 8. Simpan Order, potong stok dan catat pemakaian voucher serta flash sale dalam satu transaksi
+	// sebelum menghubungi Midtrans, sehingga tidak ada transaksi Midtrans tanpa order
+	order.StockReserved = true
+	orderCollection := config.MongoClient.Database("ecommerce").Collection("orders")
+	err = config.WithTransaction(context.Background(), func(sessCtx mongo.SessionContext) error {
+		if err := reserveStock(sessCtx, order); err != nil {
+			return err
+		}
+		if err := reserveFlashSale(sessCtx, order.UserID, order.Items); err != nil {
+			return err
+		}
+		if _, err := orderCollection.InsertOne(sessCtx, order); err != nil {
+			return err
+		}
+		if pricing.Voucher != nil {
+			return redeemVoucher(sessCtx, order, pricing.Voucher)
+		}
+		return nil
+	})
+	if errors.Is(err, errInsufficientStock) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": err.Error()})
+	}
+	if errors.As(err, &checkoutError{}) {
+		return pricingErrorResponse(c, "message", err)
+	}
+	if err != nil {
+		log.Println("Error placing order:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to place order"})
+	}
+
+	// 🔥 9. Minta token ke Midtrans. Bila gagal, order dibatalkan agar stok, voucher dan kuota flash sale
+	// kembali; keranjang belum disentuh sehingga pembeli bisa mencoba lagi
 	snapResp, err := snapGateway.GetToken(snapReq)
 	if err != nil {
 		fmt.Printf("Midtrans Error: %+v\n", err)
+		cancelErr := config.WithTransaction(context.Background(), func(sessCtx mongo.SessionContext) error {
+			return cancelPendingOrder(sessCtx, order.ID, "payment_failed")
+		})
+		if cancelErr != nil {
+			log.Println("Error cancelling order after Midtrans failure:", cancelErr)
+		}
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
 			"message": "Failed to create payment",
 			"details": err.Error(),
 		})
 	}
 
-	// 🔥 10. Perbarui Order dengan Payment Token
-	_, err = orderCollection.UpdateOne(context.Background(), bson.M{"_id": order.ID}, bson.M{
-		"$set": bson.M{"payment_token": snapResp.Token},
+	// 🔥 10. Catat token, hapus item yang dipesan dari Cart dan titipkan notifikasi ke outbox dalam satu transaksi.
+	// Order sudah tersimpan dan bisa dibayar, jadi kegagalan di sini hanya dicatat.
+	order.PaymentToken = snapResp.Token
+	err = config.WithTransaction(context.Background(), func(sessCtx mongo.SessionContext) error {
+		if _, err := orderCollection.UpdateOne(sessCtx, bson.M{"_id": order.ID}, bson.M{"$set": bson.M{"payment_token": order.PaymentToken}}); err != nil {
+			return err
+		}
+		if err := removeOrderedCartItems(sessCtx, input.UserID, order.Items); err != nil {
+			return err
+		}
+		if err := pushNewOrder(sessCtx, order); err != nil {
+			return err
+		}
+		return notifyOrder(sessCtx, order, notifications.TemplateOrderPlaced)
 	})
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to update order with payment token"})
+		log.Println("Error finishing order", order.ID.Hex(), "after payment was created:", err)
 	}
 
 	// ✅ 11. Kirim Response ke FE
@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"be_ecommerce/config"
+	"be_ecommerce/model"
+	"be_ecommerce/notifications"
+	"be_ecommerce/services"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var errPaymentAmountMismatch = errors.New("gross amount does not match order total")
+
+// paidAmount mengubah gross_amount Midtrans ("150000.00") menjadi rupiah
+func paidAmount(grossAmount string) (int, bool) {
+	amount, err := strconv.ParseFloat(grossAmount, 64)
+	if err != nil || amount < 0 {
+		return 0, false
+	}
+	return int(amount), true
+}
+
+// PaymentNotificationHandler menerima notifikasi status transaksi dari Midtrans (webhook publik).
+// Notifikasi hanya diproses bila signature_key valid dan, untuk pembayaran berhasil, gross_amount
+// sama dengan total order; order yang sudah tidak Pending diabaikan.
+func PaymentNotificationHandler(c *fiber.Ctx) error {
+	var notification struct {
+		OrderID           string `json:"order_id"`
+		StatusCode        string `json:"status_code"`
+		GrossAmount       string `json:"gross_amount"`
+		SignatureKey      string `json:"signature_key"`
+		TransactionStatus string `json:"transaction_status"`
+		FraudStatus       string `json:"fraud_status"`
+	}
+	if err := c.BodyParser(&notification); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request body"})
+	}
+
+	// Pastikan notifikasi benar-benar dari Midtrans
+	if notification.SignatureKey == "" || !services.VerifyMidtransSignature(notification.OrderID, notification.StatusCode, notification.GrossAmount, notification.SignatureKey) {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"message": "Invalid signature"})
+	}
+
+	orderID, err := primitive.ObjectIDFromHex(notification.OrderID)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"message": "Invalid order ID"})
+	}
+	amount, ok := paidAmount(notification.GrossAmount)
+	if !ok {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"message": "Invalid gross amount"})
+	}
+
+	paid := false
+	switch notification.TransactionStatus {
+	case "capture":
+		if notification.FraudStatus != "accept" {
+			return c.JSON(fiber.Map{"message": "Notification ignored"})
+		}
+		paid = true
+	case "settlement":
+		paid = true
+	case "cancel", "deny", "expire":
+	default:
+		return c.JSON(fiber.Map{"message": "Notification ignored"})
+	}
+
+	// Hanya order yang masih Pending yang diperbarui, notifikasi ganda diabaikan
+	orderCollection := config.MongoClient.Database("ecommerce").Collection("orders")
+	err = config.WithTransaction(context.Background(), func(sessCtx mongo.SessionContext) error {
+		if !paid {
+			return cancelPendingOrder(sessCtx, orderID, "payment_"+notification.TransactionStatus)
+		}
+
+		var pending model.Order
+		if err := orderCollection.FindOne(sessCtx, bson.M{"_id": orderID, "status": "Pending"}).Decode(&pending); err != nil {
+			return err
+		}
+		if pending.TotalAmount != amount {
+			return errPaymentAmountMismatch
+		}
+
+		var order model.Order
+		err := orderCollection.FindOneAndUpdate(sessCtx,
+			bson.M{"_id": orderID, "status": "Pending"},
+			bson.M{"$set": bson.M{"status": "Processing", "payment_date": time.Now()}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&order)
+		if err != nil {
+			return err
+		}
+		if err := recordSale(sessCtx, order); err != nil {
+			return err
+		}
+		if err := pushOrderStatus(sessCtx, order); err != nil {
+			return err
+		}
+		return notifyOrder(sessCtx, order, notifications.TemplatePaymentReceived)
+	})
+	if err == mongo.ErrNoDocuments {
+		return c.JSON(fiber.Map{"message": "Order already processed"})
+	}
+	if errors.Is(err, errPaymentAmountMismatch) {
+		log.Printf("Payment notification for order %s: %v (got %d)", notification.OrderID, err, amount)
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"message": "Gross amount does not match order total"})
+	}
+	if err != nil {
+		log.Println("Error processing payment notification:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to update order"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Notification processed"})
+}
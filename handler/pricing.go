@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"be_ecommerce/config"
+	"be_ecommerce/model"
+	"be_ecommerce/shipping"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// checkoutError adalah kesalahan checkout yang aman ditampilkan ke klien
+type checkoutError struct {
+	status  int
+	message string
+}
+
+func (e checkoutError) Error() string {
+	return e.message
+}
+
+// orderPricing adalah rincian harga order yang dihitung di server
+type orderPricing struct {
+	Items            []model.OrderItem `json:"items"`
+	Subtotal         int               `json:"subtotal"`
+	ShippingCost     int               `json:"shipping_cost"`
+	Discount         int               `json:"discount"`
+	ShippingDiscount int               `json:"shipping_discount"`
+	Total            int               `json:"total"`
+	Rate             *shipping.Rate    `json:"shipping,omitempty"`
+	Voucher          *model.Voucher    `json:"-"`
+	VoucherCode      string            `json:"voucher_code,omitempty"`
+
+	products map[primitive.ObjectID]model.Product
+}
+
+// priceOrder menghitung harga item dari data produk, ongkos kirim ke alamat (jika ada)
+// dan potongan voucher (jika ada). Checkout, pembayaran dan apply-voucher memakai
+// perhitungan yang sama agar total yang ditampilkan sama dengan yang ditagihkan.
+func priceOrder(ctx context.Context, userID primitive.ObjectID, items []model.OrderItem, address *model.Address, courier, service, voucherCode string) (orderPricing, error) {
+	pricing, err := priceItems(ctx, userID, items)
+	if err != nil {
+		return pricing, err
+	}
+
+	if address != nil {
+		rates, err := quoteShipping(ctx, pricing.Items, *address)
+		if err != nil {
+			return pricing, err
+		}
+		rate, err := selectShippingRate(rates, courier, service)
+		if err != nil {
+			return pricing, err
+		}
+		pricing.Rate = &rate
+		pricing.ShippingCost = rate.Cost
+	}
+
+	if voucherCode != "" {
+		if err := applyVoucher(ctx, &pricing, userID, voucherCode); err != nil {
+			return pricing, err
+		}
+	}
+
+	pricing.Total = pricing.Subtotal - pricing.Discount + pricing.ShippingCost - pricing.ShippingDiscount
+	return pricing, nil
+}
+
+// priceItems mengisi nama, harga efektif dan seller setiap item dari data produk
+func priceItems(ctx context.Context, userID primitive.ObjectID, items []model.OrderItem) (orderPricing, error) {
+	pricing := orderPricing{products: map[primitive.ObjectID]model.Product{}}
+	if len(items) == 0 {
+		return pricing, checkoutError{fiber.StatusBadRequest, "Items are required"}
+	}
+
+	productIDs := make([]primitive.ObjectID, 0, len(items))
+	for _, item := range items {
+		if item.Quantity < 1 {
+			return pricing, checkoutError{fiber.StatusBadRequest, "Quantity must be greater than 0"}
+		}
+		productIDs = append(productIDs, item.ProductID)
+	}
+
+	cursor, err := config.MongoClient.Database("ecommerce").Collection("products").Find(ctx, bson.M{"_id": bson.M{"$in": productIDs}})
+	if err != nil {
+		return pricing, err
+	}
+	var products []model.Product
+	if err := cursor.All(ctx, &products); err != nil {
+		return pricing, err
+	}
+	for _, product := range products {
+		pricing.products[product.ID] = product
+	}
+	prices, err := effectivePrices(ctx, products)
+	if err != nil {
+		return pricing, err
+	}
+
+	// Produk dari seller yang sedang disuspend tidak bisa dipesan
+	sellerIDs := make([]primitive.ObjectID, 0, len(products))
+	for _, product := range products {
+		sellerIDs = append(sellerIDs, product.SellerID)
+	}
+	suspendedFilter := activeSuspensionFilter()
+	suspendedFilter["_id"] = bson.M{"$in": sellerIDs}
+	suspendedCursor, err := getUserCollection().Find(ctx, suspendedFilter, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return pricing, err
+	}
+	var suspendedSellers []model.User
+	if err := suspendedCursor.All(ctx, &suspendedSellers); err != nil {
+		return pricing, err
+	}
+	suspended := map[primitive.ObjectID]bool{}
+	for _, seller := range suspendedSellers {
+		suspended[seller.ID] = true
+	}
+
+	pricing.Items = make([]model.OrderItem, 0, len(items))
+	for _, item := range items {
+		product, ok := pricing.products[item.ProductID]
+		if !ok {
+			return pricing, checkoutError{fiber.StatusBadRequest, "Invalid product ID"}
+		}
+		if !product.IsActive() {
+			return pricing, checkoutError{fiber.StatusConflict, fmt.Sprintf("%s is no longer available", product.Name)}
+		}
+		if suspended[product.SellerID] {
+			return pricing, checkoutError{fiber.StatusConflict, fmt.Sprintf("The seller of %s is temporarily unavailable", product.Name)}
+		}
+		price := prices[item.ProductID]
+		requested := item.VariantID
+		item.VariantID, item.Variant, item.SKU = nil, "", ""
+		if len(product.Variants) > 0 || requested != nil {
+			if requested == nil {
+				return pricing, checkoutError{fiber.StatusBadRequest, fmt.Sprintf("Please choose a variant for %s", product.Name)}
+			}
+			variant, ok := product.Variant(*requested)
+			if !ok {
+				return pricing, checkoutError{fiber.StatusBadRequest, fmt.Sprintf("Invalid variant for %s", product.Name)}
+			}
+			variantID := variant.ID
+			price = variantEffectivePrice(price, variant.Price)
+			item.VariantID, item.Variant, item.SKU = &variantID, variant.Label(product.Options), variant.SKU
+		}
+		item.Name = product.Name
+		item.Price = price.Price
+		item.OriginalPrice = price.OriginalPrice
+		item.FlashSaleID = price.FlashSaleID
+		item.SellerID = product.SellerID
+		item.Product = nil
+		if err := checkFlashSaleLimits(ctx, userID, item, price); err != nil {
+			return pricing, err
+		}
+		pricing.Items = append(pricing.Items, item)
+		pricing.Subtotal += item.Price * item.Quantity
+	}
+	return pricing, nil
+}
+
+// pricingErrorResponse menulis response error perhitungan harga; key mengikuti format
+// response handler pemanggil ("error" atau "message")
+func pricingErrorResponse(c *fiber.Ctx, key string, err error) error {
+	var checkoutErr checkoutError
+	if errors.As(err, &checkoutErr) {
+		return c.Status(checkoutErr.status).JSON(fiber.Map{key: checkoutErr.message})
+	}
+	return shippingErrorResponse(c, key, err)
+}
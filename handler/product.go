@@ -7,6 +7,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"mime/multipart"
 	"strconv"
 
 	"github.com/gofiber/fiber/v2"
@@ -81,6 +82,26 @@ func CreateProduct(c *fiber.Ctx) error {
 		})
 	}
 
+	assignment, message, err := assignProductCategory(context.Background(), categoryID, subCategoryID, c.FormValue("attributes"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to validate category",
+			"error":   err.Error(),
+		})
+	}
+	if message != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": message,
+		})
+	}
+
+	weight, dimensions, err := parseProductShipping(form)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
 	// Handle file upload
 	var imagePath string
 	fileHeaders := form.File["image"]
@@ -108,13 +129,19 @@ func CreateProduct(c *fiber.Ctx) error {
 		SellerID:      sellerID,
 		CategoryID:    categoryID,
 		SubCategoryID: subCategoryID,
+		CategoryPath:  assignment.Path,
+		Attributes:    assignment.Attributes,
 		Description:   description[0],
 		Image:         imagePath,
+		Dimensions:    dimensions,
+		Status:        newProductStatus(c.FormValue("status")),
+	}
+	if weight != nil {
+		product.Weight = *weight
 	}
 
 	// Save product to database
-	productCollection := config.MongoClient.Database("ecommerce").Collection("products")
-	result, err := productCollection.InsertOne(context.Background(), product)
+	err = insertProduct(context.Background(), product, sellerID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"message": "Failed to save product",
@@ -124,7 +151,7 @@ func CreateProduct(c *fiber.Ctx) error {
 
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
 		"message":    "Product created successfully",
-		"product_id": result.InsertedID,
+		"product_id": product.ID,
 	})
 }
 
@@ -142,11 +169,8 @@ func DeleteProductByID(c *fiber.Ctx) error {
 		})
 	}
 
-	// Ambil koleksi produk dari database
-	productCollection := config.MongoClient.Database("ecommerce").Collection("products")
-
-	// Cari dan hapus produk berdasarkan ID
-	result, err := productCollection.DeleteOne(context.Background(), bson.M{"_id": objectID})
+	// Produk diarsipkan (soft delete) karena masih direferensikan order lama
+	found, err := archiveProduct(context.Background(), bson.M{"_id": objectID})
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"message": "Failed to delete product",
@@ -155,7 +179,7 @@ func DeleteProductByID(c *fiber.Ctx) error {
 	}
 
 	// Periksa apakah produk ditemukan dan dihapus
-	if result.DeletedCount == 0 {
+	if !found {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"message": "Product not found",
 		})
@@ -198,6 +222,19 @@ func CreateSellerProduct(c *fiber.Ctx) error {
 
 	description := form.Value["description"][0]
 
+	assignment, message, err := assignProductCategory(context.Background(), categoryID, subCategoryID, c.FormValue("attributes"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to validate category", "error": err.Error()})
+	}
+	if message != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": message})
+	}
+
+	weight, dimensions, err := parseProductShipping(form)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
+	}
+
 	// Handle file upload
 	var imagePath string
 	fileHeaders := form.File["image"]
@@ -222,17 +259,23 @@ func CreateSellerProduct(c *fiber.Ctx) error {
 		SellerID:      sellerID,
 		CategoryID:    categoryID,
 		SubCategoryID: subCategoryID,
+		CategoryPath:  assignment.Path,
+		Attributes:    assignment.Attributes,
 		Description:   description,
 		Image:         imagePath,
+		Dimensions:    dimensions,
+		Status:        newProductStatus(c.FormValue("status")),
+	}
+	if weight != nil {
+		product.Weight = *weight
 	}
 
-	productCollection := config.MongoClient.Database("ecommerce").Collection("products")
-	result, err := productCollection.InsertOne(context.Background(), product)
+	err = insertProduct(context.Background(), product, sellerID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to save product", "error": err.Error()})
 	}
 
-	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"message": "Product created successfully", "product_id": result.InsertedID})
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"message": "Product created successfully", "product_id": product.ID})
 }
 
 // **2. Update Product for Seller**
@@ -263,6 +306,11 @@ func UpdateSellerProductByID(c *fiber.Ctx) error {
 	subCategoryID, _ := primitive.ObjectIDFromHex(form.Value["sub_category_id"][0])
 	description := form.Value["description"][0]
 
+	weight, dimensions, err := parseProductShipping(form)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
+	}
+
 	// Handle file upload
 	var imagePath string
 	fileHeaders := form.File["image"]
@@ -284,6 +332,23 @@ func UpdateSellerProductByID(c *fiber.Ctx) error {
 	if imagePath != "" {
 		updateData["image"] = imagePath
 	}
+	if weight != nil {
+		updateData["weight"] = *weight
+	}
+	if dimensions != nil {
+		updateData["dimensions"] = dimensions
+	}
+
+	if err := skipVariantManagedFields(context.Background(), objectID, updateData); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to update product"})
+	}
+	message, err := categoryUpdateFields(context.Background(), objectID, updateData, c.FormValue("attributes"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to update product"})
+	}
+	if message != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": message})
+	}
 
 	productCollection := config.MongoClient.Database("ecommerce").Collection("products")
 
@@ -311,11 +376,9 @@ func DeleteSellerProductByID(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid product ID format", "error": err.Error()})
 	}
 
-	productCollection := config.MongoClient.Database("ecommerce").Collection("products")
-
-	// Pastikan produk dimiliki oleh seller yang sedang login
-	result, err := productCollection.DeleteOne(context.Background(), bson.M{"_id": objectID, "seller_id": sellerID})
-	if err != nil || result.DeletedCount == 0 {
+	// Pastikan produk dimiliki oleh seller yang sedang login; produk hanya diarsipkan (soft delete)
+	found, err := archiveProduct(context.Background(), bson.M{"_id": objectID, "seller_id": sellerID})
+	if err != nil || !found {
 		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "Failed to delete product or product not found"})
 	}
 
@@ -335,7 +398,7 @@ func GetProductDetail(c *fiber.Ctx) error {
 	// Ambil produk dari database
 	productCollection := config.MongoClient.Database("ecommerce").Collection("products")
 	var product model.Product
-	err = productCollection.FindOne(context.Background(), bson.M{"_id": objectID}).Decode(&product)
+	err = productCollection.FindOne(context.Background(), onlyActiveProducts(bson.M{"_id": objectID}, "")).Decode(&product)
 	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"message": "Product not found",
@@ -352,48 +415,57 @@ func GetProductDetail(c *fiber.Ctx) error {
 		})
 	}
 
-	// Pastikan toko aktif (store_status == "approved")
-	if seller.StoreStatus != nil && *seller.StoreStatus != "approved" {
+	// Pastikan toko aktif (store_status == "approved") dan seller tidak disuspend
+	if (seller.StoreStatus != nil && *seller.StoreStatus != "approved") || isSuspended(seller) {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"message": "Store is not active",
 		})
 	}
 
 	// Ambil detail kategori dan sub-kategori
-	categoryCollection := config.MongoClient.Database("ecommerce").Collection("categories")
-	var category model.Category
-	err = categoryCollection.FindOne(context.Background(), bson.M{"_id": product.CategoryID}).Decode(&category)
+	tree, err := loadCategoryTree(context.Background())
 	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch category",
+		})
+	}
+	category, ok := tree.byID[product.CategoryID]
+	if !ok {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"message": "Category not found",
 		})
 	}
-
-	// Cari sub-kategori dalam kategori
-	var subCategoryName string
-	for _, subCat := range category.SubCategories {
-		if subCat.ID == product.SubCategoryID {
-			subCategoryName = subCat.Name
-			break
-		}
-	}
-	if subCategoryName == "" {
+	subCategory, ok := tree.byID[product.SubCategoryID]
+	if !ok {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"message": "Sub-category not found",
 		})
 	}
 
+	// Harga yang berlaku saat ini (diskon, price rule atau flash sale)
+	products := []model.Product{product}
+	if err := withEffectivePrices(context.Background(), products); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to calculate product price",
+		})
+	}
+
 	// Gabungkan data produk, kategori, dan toko
 	response := fiber.Map{
 		"product": fiber.Map{
-			"id":           product.ID,
-			"name":         product.Name,
-			"price":        product.Price,
-			"discount":     product.Discount,
-			"category":     category.Name,
-			"sub_category": subCategoryName,
-			"description":  product.Description,
-			"image":        product.Image,
+			"id":               product.ID,
+			"name":             product.Name,
+			"price":            product.Price,
+			"discount":         product.Discount,
+			"effective_price":  products[0].EffectivePrice,
+			"stock":            product.Stock,
+			"variant_selector": variantSelector(product, *products[0].EffectivePrice),
+			"category":         category.Name,
+			"sub_category":     subCategory.Name,
+			"breadcrumb":       tree.breadcrumb(product.SubCategoryID),
+			"attributes":       product.Attributes,
+			"description":      product.Description,
+			"image":            product.Image,
 		},
 		"store": fiber.Map{
 			"store_name":   seller.StoreInfo.StoreName,
@@ -407,11 +479,75 @@ func GetProductDetail(c *fiber.Ctx) error {
 	return c.JSON(response)
 }
 
-// GetAllProducts fetches all products
+// GetAllProducts fetches all products. Mendukung ?district= atau ?same_city=true&lat=&long=
+// untuk hanya menampilkan produk dari toko di kota yang sama dengan pembeli.
+// ?category= (ID atau slug) membatasi ke kategori dan turunannya; atribut di skema kategori
+// dapat difilter dengan namanya, contoh ?brand=Samsung&ram=8GB, dan jumlah per nilainya
+// dikembalikan di "facets".
 func GetAllProducts(c *fiber.Ctx) error {
 	// Ambil koleksi produk dari MongoDB
 	collection := config.MongoClient.Database("ecommerce").Collection("products")
 
+	// Sembunyikan produk dari seller yang sedang disuspend
+	hiddenSellers, err := suspendedSellerIDs(c.Context())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to fetch products with categories",
+		})
+	}
+
+	// Filter "satu kota": hanya produk dari toko di kabupaten/kota pembeli
+	sellerFilter := bson.M{"$nin": hiddenSellers}
+	district, err := catalogueDistrict(c)
+	if err != nil {
+		if status, message := addressErrorStatus(err); status != fiber.StatusInternalServerError {
+			return c.Status(status).JSON(fiber.Map{
+				"status":  "error",
+				"message": message,
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to resolve location",
+		})
+	}
+	if district != "" {
+		localSellers, err := sellersInDistrict(c.Context(), district)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Failed to fetch products with categories",
+			})
+		}
+		sellerFilter["$in"] = localSellers
+	}
+
+	match := hideOutOfStock(c, onlyActiveProducts(bson.M{"seller_id": sellerFilter}, ""), "")
+
+	// Filter kategori (ID atau slug) mencakup seluruh turunannya; filter dan facet atribut
+	// mengikuti skema kategori tersebut
+	var schema []model.CategoryAttribute
+	if value := c.Query("category"); value != "" {
+		tree, err := loadCategoryTree(c.Context())
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Failed to fetch products with categories",
+			})
+		}
+		category, ok := tree.find(value)
+		if !ok {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Category not found",
+			})
+		}
+		match["category_path"] = category.ID
+		schema = tree.schema(category.ID)
+	}
+	filters := parseAttributeFilters(c.Query, schema)
+
 	// Pipeline agregasi
 	pipeline := mongo.Pipeline{
 		// Lookup kategori berdasarkan category_id
@@ -434,21 +570,17 @@ func GetAllProducts(c *fiber.Ctx) error {
 	}
 
 	// Jalankan agregasi
-	cursor, err := collection.Aggregate(c.Context(), pipeline)
+	products, facets, err := aggregateFacetedProducts(c.Context(), collection, match, pipeline, schema, filters)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Failed to fetch products with categories",
 		})
 	}
-	defer cursor.Close(c.Context())
-
-	// Decode hasil agregasi ke slice
-	var products []bson.M
-	if err := cursor.All(c.Context(), &products); err != nil {
+	if err := withEffectivePriceDocs(c.Context(), products); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"status":  "error",
-			"message": "Failed to parse products",
+			"message": "Failed to calculate product prices",
 		})
 	}
 
@@ -457,6 +589,7 @@ func GetAllProducts(c *fiber.Ctx) error {
 		"status":  "success",
 		"message": "Products fetched successfully",
 		"data":    products,
+		"facets":  facets,
 	})
 }
 
@@ -464,8 +597,19 @@ func GetAllProducts(c *fiber.Ctx) error {
 func GetProductsUnderPrice(c *fiber.Ctx) error {
 	priceLimit := 100000.0
 
+	hiddenSellers, err := suspendedSellerIDs(c.Context())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to fetch products under price limit",
+		})
+	}
+
 	collection := config.MongoClient.Database("ecommerce").Collection("products")
-	cursor, err := collection.Find(c.Context(), bson.M{"price": bson.M{"$lte": priceLimit}})
+	cursor, err := collection.Find(c.Context(), hideOutOfStock(c, onlyActiveProducts(bson.M{
+		"price":     bson.M{"$lte": priceLimit},
+		"seller_id": bson.M{"$nin": hiddenSellers},
+	}, ""), ""))
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"status":  "error",
@@ -481,6 +625,12 @@ func GetProductsUnderPrice(c *fiber.Ctx) error {
 			"message": "Failed to parse products",
 		})
 	}
+	if err := withEffectivePrices(c.Context(), products); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to calculate product prices",
+		})
+	}
 
 	return c.JSON(fiber.Map{
 		"status":  "success",
@@ -491,11 +641,20 @@ func GetProductsUnderPrice(c *fiber.Ctx) error {
 func GetBestSellers(c *fiber.Ctx) error {
 	collection := config.MongoClient.Database("ecommerce").Collection("products")
 
+	hiddenSellers, err := suspendedSellerIDs(context.Background())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch best sellers",
+		})
+	}
+
 	// Filter best sellers: Rating > 4.0 and Reviews > 1000
 	filter := bson.M{
-		"rating":  bson.M{"$gt": 4.0},
-		"reviews": bson.M{"$gt": 1000},
+		"rating":    bson.M{"$gt": 4.0},
+		"reviews":   bson.M{"$gt": 1000},
+		"seller_id": bson.M{"$nin": hiddenSellers},
 	}
+	hideOutOfStock(c, onlyActiveProducts(filter, ""), "")
 
 	cursor, err := collection.Find(context.Background(), filter)
 	if err != nil {
@@ -511,6 +670,11 @@ func GetBestSellers(c *fiber.Ctx) error {
 			"message": "Failed to parse best sellers",
 		})
 	}
+	if err := withEffectivePrices(context.Background(), products); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to calculate product prices",
+		})
+	}
 
 	return c.JSON(fiber.Map{
 		"message": "Best sellers fetched successfully",
@@ -538,8 +702,14 @@ func GetProductsByUserID(c *fiber.Ctx) error {
 	// Ambil koleksi produk
 	productCollection := config.MongoClient.Database("ecommerce").Collection("products")
 
-	// Filter produk berdasarkan user_id
-	filter := bson.M{"seller_id": userID}
+	// Filter produk berdasarkan user_id; produk yang dihapus tidak ditampilkan. Mendukung ?status=
+	filter := bson.M{"seller_id": userID, "deleted_at": bson.M{"$exists": false}}
+	if status := c.Query("status"); status != "" {
+		filter["status"] = status
+		if status == model.ProductActive {
+			onlyActiveProducts(filter, "")
+		}
+	}
 	cursor, err := productCollection.Find(c.Context(), filter)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -557,6 +727,12 @@ func GetProductsByUserID(c *fiber.Ctx) error {
 			"error":   err.Error(),
 		})
 	}
+	if err := withEffectivePrices(c.Context(), products); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to calculate product prices",
+			"error":   err.Error(),
+		})
+	}
 
 	// Kembalikan data produk
 	return c.JSON(fiber.Map{
@@ -634,26 +810,23 @@ func CreateProductForSeller(c *fiber.Ctx) error {
 	subCategoryID, _ := primitive.ObjectIDFromHex(form.Value["sub_category_id"][0])
 	description := form.Value["description"][0]
 
-	// Validasi kategori dan subkategori
-	categoryCollection := config.MongoClient.Database("ecommerce").Collection("categories")
-	var category model.Category
-	err = categoryCollection.FindOne(context.Background(), bson.M{"_id": categoryID}).Decode(&category)
+	// Validasi kategori, subkategori dan atribut produk
+	assignment, message, err := assignProductCategory(context.Background(), categoryID, subCategoryID, c.FormValue("attributes"))
 	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to validate category",
+		})
+	}
+	if message != "" {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": "Invalid Category ID",
+			"message": message,
 		})
 	}
 
-	subCategoryExists := false
-	for _, subCat := range category.SubCategories {
-		if subCat.ID == subCategoryID {
-			subCategoryExists = true
-			break
-		}
-	}
-	if !subCategoryExists {
+	weight, dimensions, err := parseProductShipping(form)
+	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": "Invalid Sub-Category ID",
+			"message": err.Error(),
 		})
 	}
 
@@ -685,12 +858,20 @@ func CreateProductForSeller(c *fiber.Ctx) error {
 		SellerID:      objectID,
 		CategoryID:    categoryID,
 		SubCategoryID: subCategoryID,
+		CategoryPath:  assignment.Path,
+		Attributes:    assignment.Attributes,
 		Description:   description,
 		Image:         imagePath,
+		Dimensions:    dimensions,
+		Status:        newProductStatus(c.FormValue("status")),
+	}
+	if weight != nil {
+		product.Weight = *weight
 	}
 
-	productCollection := config.MongoClient.Database("ecommerce").Collection("products")
-	result, err := productCollection.InsertOne(context.Background(), product)
+	err = config.WithTransaction(context.Background(), func(sessCtx mongo.SessionContext) error {
+		return insertProduct(sessCtx, product, objectID)
+	})
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"message": "Error saving product to database",
@@ -699,7 +880,42 @@ func CreateProductForSeller(c *fiber.Ctx) error {
 
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
 		"message":    "Product created successfully",
-		"product_id": result.InsertedID,
+		"product_id": product.ID,
 		"image_url":  fmt.Sprintf("%s/%s", "http://localhost:3000", imagePath),
 	})
 }
+
+// parseProductShipping membaca berat (gram) dan dimensi kemasan (cm) dari form produk.
+// Field yang tidak dikirim bernilai nil; dimensi harus diisi lengkap bila dikirim.
+func parseProductShipping(form *multipart.Form) (*int, *model.Dimensions, error) {
+	var weight *int
+	if values := form.Value["weight"]; len(values) > 0 && values[0] != "" {
+		w, err := strconv.Atoi(values[0])
+		if err != nil || w < 0 {
+			return nil, nil, fmt.Errorf("invalid weight")
+		}
+		weight = &w
+	}
+
+	var sizes [3]int
+	provided := 0
+	for i, field := range []string{"length", "width", "height"} {
+		values := form.Value[field]
+		if len(values) == 0 || values[0] == "" {
+			continue
+		}
+		size, err := strconv.Atoi(values[0])
+		if err != nil || size <= 0 {
+			return nil, nil, fmt.Errorf("invalid %s", field)
+		}
+		sizes[i] = size
+		provided++
+	}
+	if provided == 0 {
+		return weight, nil, nil
+	}
+	if provided != len(sizes) {
+		return nil, nil, fmt.Errorf("length, width and height must be provided together")
+	}
+	return weight, &model.Dimensions{Length: sizes[0], Width: sizes[1], Height: sizes[2]}, nil
+}
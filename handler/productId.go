@@ -29,12 +29,11 @@ func GetProductByID(c *fiber.Ctx) error {
 
 	// Ambil koleksi produk
 	productCollection := config.MongoClient.Database("ecommerce").Collection("products")
-	categoryCollection := config.MongoClient.Database("ecommerce").Collection("categories")
 	sellerCollection := config.MongoClient.Database("ecommerce").Collection("sellers")
 
-	// Cari produk berdasarkan ID
+	// Cari produk berdasarkan ID; hanya produk active yang tampil di katalog
 	var product model.Product
-	err = productCollection.FindOne(context.Background(), bson.M{"_id": objectID}).Decode(&product)
+	err = productCollection.FindOne(context.Background(), onlyActiveProducts(bson.M{"_id": objectID}, "")).Decode(&product)
 	if err != nil {
 		return c.Status(http.StatusNotFound).JSON(fiber.Map{
 			"status":  "error",
@@ -42,23 +41,25 @@ func GetProductByID(c *fiber.Ctx) error {
 		})
 	}
 
-	// Ambil kategori
-	var category model.Category
-	if err := categoryCollection.FindOne(context.Background(), bson.M{"_id": product.CategoryID}).Decode(&category); err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+	// Produk dari seller yang disuspend tidak ditampilkan
+	var seller model.User
+	if err := getUserCollection().FindOne(context.Background(), bson.M{"_id": product.SellerID}).Decode(&seller); err == nil && isSuspended(seller) {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
 			"status":  "error",
-			"message": "Failed to fetch category",
+			"message": "Product not found",
 		})
 	}
 
-	// Cari sub-kategori
-	var subCategoryName string
-	for _, sub := range category.SubCategories {
-		if sub.ID == product.SubCategoryID {
-			subCategoryName = sub.Name
-			break
-		}
+	// Ambil kategori dan sub-kategori
+	tree, err := loadCategoryTree(context.Background())
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to fetch category",
+		})
 	}
+	category := tree.byID[product.CategoryID]
+	subCategory := tree.byID[product.SubCategoryID]
 
 	// Ambil data toko
 	var store model.StoreInfo
@@ -69,6 +70,15 @@ func GetProductByID(c *fiber.Ctx) error {
 		})
 	}
 
+	// Harga yang berlaku saat ini (diskon, price rule atau flash sale)
+	products := []model.Product{product}
+	if err := withEffectivePrices(context.Background(), products); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to calculate product price",
+		})
+	}
+
 	// Kembalikan data produk dengan detail tambahan
 	return c.JSON(fiber.Map{
 		"status": "success",
@@ -77,10 +87,14 @@ func GetProductByID(c *fiber.Ctx) error {
 			"name":         product.Name,
 			"price":        product.Price,
 			"discount":     product.Discount,
+			"effective_price": products[0].EffectivePrice,
+			"variant_selector": variantSelector(product, *products[0].EffectivePrice),
 			"image":        product.Image,
 			"description":  product.Description,
 			"category":     category.Name,
-			"sub_category": subCategoryName,
+			"sub_category": subCategory.Name,
+			"breadcrumb":   tree.breadcrumb(product.SubCategoryID),
+			"attributes":   product.Attributes,
 			"seller_id": 	product.SellerID,
 		},
 		"store": fiber.Map{
@@ -157,6 +171,13 @@ func UpdateProductByID(c *fiber.Ctx) error {
 		})
 	}
 
+	weight, dimensions, err := parseProductShipping(form)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
 	// Handle file upload
 	var imagePath string
 	fileHeaders := form.File["image"]
@@ -195,6 +216,31 @@ func UpdateProductByID(c *fiber.Ctx) error {
 		"description":     description[0],
 		"image":           imagePath,
 	}
+	if weight != nil {
+		updateData["weight"] = *weight
+	}
+	if dimensions != nil {
+		updateData["dimensions"] = dimensions
+	}
+
+	if err := skipVariantManagedFields(context.Background(), objectID, updateData); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to update product",
+			"error":   err.Error(),
+		})
+	}
+	message, err := categoryUpdateFields(context.Background(), objectID, updateData, c.FormValue("attributes"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to update product",
+			"error":   err.Error(),
+		})
+	}
+	if message != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": message,
+		})
+	}
 
 	// Update produk di database
 	productCollection := config.MongoClient.Database("ecommerce").Collection("products")
@@ -0,0 +1,179 @@
+package handler
+
+import (
+	"be_ecommerce/model"
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// attributeFilter adalah filter atribut dari query string, contoh ?brand=Samsung,Xiaomi&ram=8GB.
+// Beberapa nilai dalam satu atribut digabung dengan OR, antar atribut dengan AND.
+type attributeFilter struct {
+	Attribute model.CategoryAttribute
+	Values    []string
+}
+
+// productFacet adalah jumlah produk per nilai satu atribut pada hasil pencarian saat ini
+type productFacet struct {
+	Name   string              `json:"name"`
+	Key    string              `json:"key"` // Nama parameter query untuk memfilter atribut ini
+	Type   string              `json:"type"`
+	Unit   string              `json:"unit,omitempty"`
+	Values []productFacetValue `json:"values"`
+}
+
+type productFacetValue struct {
+	Value    string `json:"value"`
+	Count    int    `json:"count"`
+	Selected bool   `json:"selected"`
+}
+
+// attributeQueryKey mengubah nama atribut menjadi nama parameter query, contoh "Screen size" menjadi "screen_size"
+func attributeQueryKey(name string) string {
+	return strings.ReplaceAll(slugify(name), "-", "_")
+}
+
+// sameOption membandingkan pilihan atribut tanpa membedakan huruf besar dan spasi, sehingga "8GB" cocok dengan "8 GB"
+func sameOption(a, b string) bool {
+	return strings.EqualFold(strings.ReplaceAll(a, " ", ""), strings.ReplaceAll(b, " ", ""))
+}
+
+// parseAttributeFilters membaca filter atribut dari query (biasanya c.Query) untuk setiap atribut
+// di skema kategori. Nilai select dan boolean diseragamkan ke bentuk yang disimpan di produk.
+func parseAttributeFilters(query func(key string, defaultValue ...string) string, schema []model.CategoryAttribute) []attributeFilter {
+	var filters []attributeFilter
+	for _, attribute := range schema {
+		raw := query(attributeQueryKey(attribute.Name))
+		if raw == "" {
+			continue
+		}
+		filter := attributeFilter{Attribute: attribute}
+		for _, value := range strings.Split(raw, ",") {
+			value = strings.TrimSpace(value)
+			if value == "" {
+				continue
+			}
+			switch attribute.Type {
+			case model.AttributeSelect:
+				for _, option := range attribute.Options {
+					if sameOption(option, value) {
+						value = option
+					}
+				}
+			case model.AttributeBoolean:
+				if b, err := strconv.ParseBool(value); err == nil {
+					value = strconv.FormatBool(b)
+				}
+			}
+			filter.Values = append(filter.Values, value)
+		}
+		if len(filter.Values) > 0 {
+			filters = append(filters, filter)
+		}
+	}
+	return filters
+}
+
+// attributeMatch menyusun $match untuk semua filter atribut kecuali atribut ke-skip (-1 untuk semua)
+func attributeMatch(filters []attributeFilter, skip int) bson.D {
+	match := bson.M{}
+	for i, filter := range filters {
+		if i != skip {
+			match["attributes."+filter.Attribute.Name] = bson.M{"$in": filter.Values}
+		}
+	}
+	return bson.D{{Key: "$match", Value: match}}
+}
+
+// aggregateFacetedProducts menjalankan pipeline katalog dengan filter atribut, lalu menghitung facet
+// untuk setiap atribut di skema dalam satu agregasi $facet terpisah. Produk tidak ikut di $facet
+// karena hasil $facet adalah satu dokumen yang dibatasi 16MB. Jumlah per nilai suatu atribut
+// dihitung dengan filter atribut lain saja, sehingga pilihan lain pada atribut yang sama tetap terlihat.
+func aggregateFacetedProducts(ctx context.Context, collection *mongo.Collection, match bson.M, stages mongo.Pipeline, schema []model.CategoryAttribute, filters []attributeFilter) ([]bson.M, []productFacet, error) {
+	products := []bson.M{}
+	facets := []productFacet{}
+
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: match}}}
+	if len(filters) > 0 {
+		pipeline = append(pipeline, attributeMatch(filters, -1))
+	}
+	cursor, err := collection.Aggregate(ctx, append(pipeline, stages...))
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := cursor.All(ctx, &products); err != nil {
+		return nil, nil, err
+	}
+	if len(schema) == 0 {
+		return products, facets, nil
+	}
+
+	facetStage := bson.M{}
+	for i, attribute := range schema {
+		skip := -1
+		for j, filter := range filters {
+			if filter.Attribute.Name == attribute.Name {
+				skip = j
+			}
+		}
+		facetStage[fmt.Sprintf("attribute_%d", i)] = mongo.Pipeline{
+			attributeMatch(filters, skip),
+			{{Key: "$group", Value: bson.M{"_id": "$attributes." + attribute.Name, "count": bson.M{"$sum": 1}}}},
+			{{Key: "$match", Value: bson.M{"_id": bson.M{"$ne": nil}}}},
+		}
+	}
+
+	cursor, err = collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$project", Value: bson.M{"attributes": 1}}},
+		{{Key: "$facet", Value: facetStage}},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	var results []map[string][]struct {
+		Value string `bson:"_id"`
+		Count int    `bson:"count"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, nil, err
+	}
+	if len(results) == 0 {
+		return products, facets, nil
+	}
+
+	for i, attribute := range schema {
+		selected := map[string]bool{}
+		for _, filter := range filters {
+			if filter.Attribute.Name == attribute.Name {
+				for _, value := range filter.Values {
+					selected[value] = true
+				}
+			}
+		}
+		facet := productFacet{
+			Name:   attribute.Name,
+			Key:    attributeQueryKey(attribute.Name),
+			Type:   attribute.Type,
+			Unit:   attribute.Unit,
+			Values: []productFacetValue{},
+		}
+		for _, bucket := range results[0][fmt.Sprintf("attribute_%d", i)] {
+			facet.Values = append(facet.Values, productFacetValue{Value: bucket.Value, Count: bucket.Count, Selected: selected[bucket.Value]})
+		}
+		sort.Slice(facet.Values, func(a, b int) bool {
+			if facet.Values[a].Count != facet.Values[b].Count {
+				return facet.Values[a].Count > facet.Values[b].Count
+			}
+			return facet.Values[a].Value < facet.Values[b].Value
+		})
+		facets = append(facets, facet)
+	}
+	return products, facets, nil
+}
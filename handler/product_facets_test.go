@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"be_ecommerce/model"
+	"reflect"
+	"testing"
+)
+
+func TestParseAttributeFilters(t *testing.T) {
+	schema := []model.CategoryAttribute{
+		{Name: "Brand", Type: model.AttributeText},
+		{Name: "RAM", Type: model.AttributeSelect, Options: []string{"4 GB", "8 GB"}},
+		{Name: "Screen size", Type: model.AttributeNumber, Unit: "inch"},
+		{Name: "NFC", Type: model.AttributeBoolean},
+	}
+
+	tests := []struct {
+		name  string
+		query map[string]string
+		want  map[string][]string
+	}{
+		{
+			name:  "no query",
+			query: map[string]string{},
+			want:  map[string][]string{},
+		},
+		{
+			name:  "comma separated values",
+			query: map[string]string{"brand": "Samsung, Xiaomi,"},
+			want:  map[string][]string{"Brand": {"Samsung", "Xiaomi"}},
+		},
+		{
+			name:  "select options are normalized",
+			query: map[string]string{"ram": "8gb,4 gb,16GB"},
+			want:  map[string][]string{"RAM": {"8 GB", "4 GB", "16GB"}},
+		},
+		{
+			name:  "multi-word names use underscores",
+			query: map[string]string{"screen_size": "6.5"},
+			want:  map[string][]string{"Screen size": {"6.5"}},
+		},
+		{
+			name:  "booleans are normalized",
+			query: map[string]string{"nfc": "1,F,maybe"},
+			want:  map[string][]string{"NFC": {"true", "false", "maybe"}},
+		},
+		{
+			name:  "unknown keys and empty values are ignored",
+			query: map[string]string{"color": "red", "brand": " , "},
+			want:  map[string][]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := func(key string, defaultValue ...string) string { return tt.query[key] }
+			got := map[string][]string{}
+			for _, filter := range parseAttributeFilters(query, schema) {
+				got[filter.Attribute.Name] = filter.Values
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseAttributeFilters() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAttributeQueryKey(t *testing.T) {
+	tests := map[string]string{
+		"Brand":             "brand",
+		"Screen size":       "screen_size",
+		"Kapasitas Baterai": "kapasitas_baterai",
+		"Wi-Fi":             "wi_fi",
+	}
+	for name, want := range tests {
+		if got := attributeQueryKey(name); got != want {
+			t.Errorf("attributeQueryKey(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,715 @@
+package handler
+
+import (
+	"be_ecommerce/config"
+	"be_ecommerce/model"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	maxImportFileSize = 5 * 1024 * 1024
+	maxImportRows     = 5000
+	maxSKULength      = 64
+	// Progres disimpan setiap sekian baris, sekaligus menjadi tanda job masih berjalan
+	importProgressEvery = 50
+	// Job processing tanpa progres selama ini dianggap ditinggalkan (instance mati) dan dilanjutkan
+	importStaleAfter = 10 * time.Minute
+)
+
+// productCSVColumns adalah kolom CSV import dan export produk. Header wajib memuat sku atau id;
+// kolom lain boleh dihilangkan dan sel kosong berarti nilai lama dipertahankan. Kolom id diisi
+// export sehingga produk tanpa SKU (dibuat lewat form) tetap diperbarui saat file diimport ulang.
+var productCSVColumns = []string{
+	"id", "sku", "name", "description", "price", "discount", "stock",
+	"category", "sub_category", "weight", "length", "width", "height", "image", "attributes",
+}
+
+func getProductImportCollection() *mongo.Collection {
+	return config.MongoClient.Database("ecommerce").Collection("product_imports")
+}
+
+// approvedSeller memastikan pengguna adalah seller dengan toko yang sudah disetujui
+func approvedSeller(ctx context.Context, userID primitive.ObjectID) bool {
+	var seller model.User
+	if err := getUserCollection().FindOne(ctx, bson.M{"_id": userID, "roles": "seller"}).Decode(&seller); err != nil {
+		return false
+	}
+	return seller.StoreStatus != nil && *seller.StoreStatus == "approved" && !isSuspended(seller)
+}
+
+// ImportProducts menerima file CSV produk dan mengantrekannya sebagai job latar belakang.
+// Baris dengan id atau SKU yang sudah ada memperbarui produk tersebut, selain itu produk baru dibuat.
+func ImportProducts(c *fiber.Ctx) error {
+	sellerID := currentUserID(c)
+	if !approvedSeller(c.Context(), sellerID) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "Forbidden: Store is not active or approved",
+		})
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "CSV file is required",
+		})
+	}
+	if strings.ToLower(filepath.Ext(file.Filename)) != ".csv" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Only CSV files are supported, save the spreadsheet as CSV first",
+		})
+	}
+	if file.Size > maxImportFileSize {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": fmt.Sprintf("File must be smaller than %d MB", maxImportFileSize/1024/1024),
+		})
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to read file",
+		})
+	}
+	defer src.Close()
+
+	reader := csv.NewReader(src)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid CSV file: " + err.Error(),
+		})
+	}
+	if len(records) < 2 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "CSV file has no product rows",
+		})
+	}
+	if len(records)-1 > maxImportRows {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": fmt.Sprintf("A file can have at most %d product rows", maxImportRows),
+		})
+	}
+
+	header, message := normalizeImportHeader(records[0])
+	if message != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": message,
+		})
+	}
+
+	job := model.ProductImportJob{
+		ID:        primitive.NewObjectID(),
+		SellerID:  sellerID,
+		FileName:  file.Filename,
+		Status:    model.ImportQueued,
+		Header:    header,
+		Rows:      records[1:],
+		TotalRows: len(records) - 1,
+		CreatedAt: time.Now(),
+	}
+	if _, err := getProductImportCollection().InsertOne(c.Context(), job); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to queue import",
+		})
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message": "Import queued",
+		"data":    job,
+	})
+}
+
+// normalizeImportHeader merapikan nama kolom (huruf kecil, tanpa BOM dari Excel) dan menolak
+// kolom yang tidak dikenal atau ganda. Mengembalikan pesan kesalahan, atau string kosong jika valid.
+func normalizeImportHeader(header []string) ([]string, string) {
+	known := map[string]bool{}
+	for _, column := range productCSVColumns {
+		known[column] = true
+	}
+
+	normalized := make([]string, len(header))
+	seen := map[string]bool{}
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")))
+		if !known[column] {
+			return nil, fmt.Sprintf("Unknown column %q, expected columns are %s", column, strings.Join(productCSVColumns, ", "))
+		}
+		if seen[column] {
+			return nil, fmt.Sprintf("Column %s is listed more than once", column)
+		}
+		seen[column] = true
+		normalized[i] = column
+	}
+	if !seen["sku"] && !seen["id"] {
+		return nil, "Column sku or id is required"
+	}
+	return normalized, ""
+}
+
+// GetProductImports mengembalikan 20 job import terakhir milik seller
+func GetProductImports(c *fiber.Ctx) error {
+	cursor, err := getProductImportCollection().Find(c.Context(),
+		bson.M{"seller_id": currentUserID(c)},
+		options.Find().
+			SetSort(bson.M{"created_at": -1}).
+			SetLimit(20).
+			SetProjection(bson.M{"rows": 0, "header": 0, "errors": 0}),
+	)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch imports",
+		})
+	}
+	jobs := []model.ProductImportJob{}
+	if err := cursor.All(c.Context(), &jobs); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to parse imports",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Imports fetched successfully",
+		"data":    jobs,
+	})
+}
+
+// findProductImport mengambil job import milik seller yang sedang login
+func findProductImport(c *fiber.Ctx, projection bson.M) (model.ProductImportJob, error) {
+	var job model.ProductImportJob
+	jobID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return job, mongo.ErrNoDocuments
+	}
+	err = getProductImportCollection().FindOne(c.Context(),
+		bson.M{"_id": jobID, "seller_id": currentUserID(c)},
+		options.FindOne().SetProjection(projection),
+	).Decode(&job)
+	return job, err
+}
+
+// GetProductImport mengembalikan status dan progres satu job import
+func GetProductImport(c *fiber.Ctx) error {
+	job, err := findProductImport(c, bson.M{"rows": 0, "header": 0, "errors": 0})
+	if err == mongo.ErrNoDocuments {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "Import not found",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch import",
+		})
+	}
+
+	response := fiber.Map{
+		"message": "Import fetched successfully",
+		"data":    job,
+	}
+	if job.Failed > 0 {
+		response["error_report"] = fmt.Sprintf("/seller/products/imports/%s/errors", job.ID.Hex())
+	}
+	return c.JSON(response)
+}
+
+// GetProductImportErrors mengunduh laporan baris yang gagal diimport sebagai CSV
+func GetProductImportErrors(c *fiber.Ctx) error {
+	job, err := findProductImport(c, bson.M{"errors": 1})
+	if err == mongo.ErrNoDocuments {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "Import not found",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch import",
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", "product_import_"+job.ID.Hex()+"_errors.csv"))
+
+	writer := csv.NewWriter(c.Response().BodyWriter())
+	if err := writer.Write([]string{"row", "sku", "error"}); err != nil {
+		return err
+	}
+	for _, rowError := range job.Errors {
+		if err := writer.Write([]string{strconv.Itoa(rowError.Row), rowError.SKU, rowError.Message}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// ExportProducts mengunduh katalog seller dalam format CSV yang sama dengan import
+func ExportProducts(c *fiber.Ctx) error {
+	sellerID := currentUserID(c)
+	cursor, err := getProductCollection().Find(c.Context(), bson.M{"seller_id": sellerID, "deleted_at": bson.M{"$exists": false}}, options.Find().SetSort(bson.M{"name": 1}))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch products",
+		})
+	}
+	var products []model.Product
+	if err := cursor.All(c.Context(), &products); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to parse products",
+		})
+	}
+	categories, err := loadCategoryTree(c.Context())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch categories",
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", "products_"+time.Now().Format(reportDateLayout)+".csv"))
+
+	writer := csv.NewWriter(c.Response().BodyWriter())
+	if err := writer.Write(productCSVColumns); err != nil {
+		return err
+	}
+	for _, product := range products {
+		category := categories.byID[product.CategoryID]
+		subCategory := ""
+		if product.SubCategoryID != product.CategoryID {
+			subCategory = categories.byID[product.SubCategoryID].Name
+		}
+		length, width, height := "", "", ""
+		if product.Dimensions != nil {
+			length = strconv.Itoa(product.Dimensions.Length)
+			width = strconv.Itoa(product.Dimensions.Width)
+			height = strconv.Itoa(product.Dimensions.Height)
+		}
+		err := writer.Write([]string{
+			product.ID.Hex(),
+			product.SKU,
+			product.Name,
+			product.Description,
+			strconv.Itoa(product.Price),
+			strconv.Itoa(product.Discount),
+			strconv.Itoa(product.Stock),
+			category.Name,
+			subCategory,
+			strconv.Itoa(product.Weight),
+			length,
+			width,
+			height,
+			product.Image,
+			formatImportAttributes(product.Attributes),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// parseImportAttributes membaca kolom attributes dengan format "RAM=8 GB; Warna=Hitam"
+func parseImportAttributes(value string) (map[string]string, string) {
+	attributes := map[string]string{}
+	for _, pair := range strings.Split(value, ";") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		name, attributeValue, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Sprintf("attributes must use the format Name=Value; Name=Value, got %q", strings.TrimSpace(pair))
+		}
+		attributes[strings.TrimSpace(name)] = strings.TrimSpace(attributeValue)
+	}
+	return attributes, ""
+}
+
+// formatImportAttributes menulis atribut produk dalam format kolom attributes, urut berdasarkan nama
+func formatImportAttributes(attributes map[string]string) string {
+	pairs := make([]string, 0, len(attributes))
+	for name, value := range attributes {
+		pairs = append(pairs, name+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "; ")
+}
+
+// processProductImports menjalankan job import yang mengantre, termasuk job yang ditinggalkan
+// instance lain, sampai antrean kosong
+func processProductImports(ctx context.Context) error {
+	collection := getProductImportCollection()
+	for ctx.Err() == nil {
+		now := time.Now()
+		var job model.ProductImportJob
+		err := collection.FindOneAndUpdate(ctx,
+			bson.M{"$or": bson.A{
+				bson.M{"status": model.ImportQueued},
+				bson.M{"status": model.ImportProcessing, "heartbeat_at": bson.M{"$lt": now.Add(-importStaleAfter)}},
+			}},
+			bson.M{"$set": bson.M{"status": model.ImportProcessing, "heartbeat_at": now}},
+			options.FindOneAndUpdate().
+				SetSort(bson.M{"created_at": 1}).
+				SetReturnDocument(options.After),
+		).Decode(&job)
+		if err == mongo.ErrNoDocuments {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := runProductImport(ctx, job); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runProductImport memproses baris job mulai dari baris yang belum diproses
+func runProductImport(ctx context.Context, job model.ProductImportJob) error {
+	collection := getProductImportCollection()
+	if job.StartedAt == nil {
+		now := time.Now()
+		if _, err := collection.UpdateOne(ctx, bson.M{"_id": job.ID}, bson.M{"$set": bson.M{"started_at": now}}); err != nil {
+			return err
+		}
+	}
+
+	categories, err := loadCategoryTree(ctx)
+	if err != nil {
+		return finishProductImport(ctx, job, model.ImportFailed, "Failed to load categories")
+	}
+
+	// id/SKU dari baris yang sudah diproses sebelum job dilanjutkan tetap dihitung sebagai duplikat
+	seen := map[string]int{}
+	for i := 0; i < job.ProcessedRows && i < len(job.Rows); i++ {
+		if key := (importRow{header: job.Header, values: job.Rows[i]}).key(); key != "" {
+			seen[key] = i + 2
+		}
+	}
+
+	var created, updated int
+	var rowErrors []model.ProductImportError
+	flush := func(processed int) error {
+		update := bson.M{
+			"$set": bson.M{"processed_rows": processed, "heartbeat_at": time.Now()},
+			"$inc": bson.M{"created": created, "updated": updated, "failed": len(rowErrors)},
+		}
+		if len(rowErrors) > 0 {
+			update["$push"] = bson.M{"errors": bson.M{"$each": rowErrors}}
+		}
+		_, err := collection.UpdateOne(ctx, bson.M{"_id": job.ID}, update)
+		created, updated, rowErrors = 0, 0, nil
+		return err
+	}
+
+	for i := job.ProcessedRows; i < len(job.Rows); i++ {
+		if ctx.Err() != nil {
+			// Dilanjutkan instance lain setelah importStaleAfter
+			return flush(i)
+		}
+
+		row := importRow{header: job.Header, values: job.Rows[i]}
+		sku, key := row.get("sku"), row.key()
+		if row.blank() {
+			continue
+		}
+
+		var isNew bool
+		message := ""
+		if previous, ok := seen[key]; ok && key != "" {
+			message = fmt.Sprintf("Product already listed on row %d", previous)
+		} else {
+			seen[key] = i + 2
+			isNew, message, err = importProductRow(ctx, job.SellerID, row, categories)
+			if err != nil {
+				log.Printf("Product import %s row %d: %v", job.ID.Hex(), i+2, err)
+				message = "Failed to save product"
+			}
+		}
+
+		switch {
+		case message != "":
+			rowErrors = append(rowErrors, model.ProductImportError{Row: i + 2, SKU: sku, Message: message})
+		case isNew:
+			created++
+		default:
+			updated++
+		}
+
+		if (i+1)%importProgressEvery == 0 {
+			if err := flush(i + 1); err != nil {
+				return err
+			}
+		}
+	}
+	if err := flush(len(job.Rows)); err != nil {
+		return err
+	}
+	return finishProductImport(ctx, job, model.ImportCompleted, "")
+}
+
+// finishProductImport menandai job selesai, membuang baris mentahnya dan memberi tahu seller
+func finishProductImport(ctx context.Context, job model.ProductImportJob, status, message string) error {
+	collection := getProductImportCollection()
+	set := bson.M{"status": status, "finished_at": time.Now()}
+	if message != "" {
+		set["message"] = message
+	}
+	err := collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": job.ID},
+		bson.M{"$set": set, "$unset": bson.M{"rows": "", "header": "", "heartbeat_at": ""}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After).SetProjection(bson.M{"errors": 0}),
+	).Decode(&job)
+	if err != nil {
+		return err
+	}
+
+	title := "Product import finished"
+	body := fmt.Sprintf("%s: %d created, %d updated, %d failed", job.FileName, job.Created, job.Updated, job.Failed)
+	if status == model.ImportFailed {
+		title = "Product import failed"
+		body = fmt.Sprintf("%s: %s", job.FileName, message)
+	}
+	return pushNotification(ctx, job.SellerID, model.NotificationProductImport, title, body, map[string]string{
+		"import_id": job.ID.Hex(),
+	})
+}
+
+// importRow adalah satu baris CSV yang dibaca berdasarkan nama kolom header
+type importRow struct {
+	header []string
+	values []string
+}
+
+func (r importRow) get(column string) string {
+	for i, name := range r.header {
+		if name == column && i < len(r.values) {
+			return strings.TrimSpace(r.values[i])
+		}
+	}
+	return ""
+}
+
+// key mengenali produk pada baris: id bila diisi, selain itu SKU
+func (r importRow) key() string {
+	if id := r.get("id"); id != "" {
+		return "id:" + id
+	}
+	if sku := r.get("sku"); sku != "" {
+		return "sku:" + sku
+	}
+	return ""
+}
+
+func (r importRow) blank() bool {
+	for _, value := range r.values {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// getInt membaca sel angka; nil berarti sel kosong
+func (r importRow) getInt(column string, min int) (*int, string) {
+	value := r.get(column)
+	if value == "" {
+		return nil, ""
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min {
+		return nil, fmt.Sprintf("%s must be a whole number of at least %d", column, min)
+	}
+	return &n, ""
+}
+
+// importProductRow membuat atau memperbarui satu produk berdasarkan id atau SKU. Baris dengan id
+// selalu memperbarui produk tersebut (dan mengisi SKU-nya bila diisi). Mengembalikan apakah
+// produk baru dibuat dan pesan kesalahan validasi (string kosong jika berhasil).
+func importProductRow(ctx context.Context, sellerID primitive.ObjectID, row importRow, categories categoryTree) (bool, string, error) {
+	sku, idValue := row.get("sku"), row.get("id")
+	if sku == "" && idValue == "" {
+		return false, "sku or id is required", nil
+	}
+	filter := bson.M{"seller_id": sellerID, "sku": sku, "deleted_at": bson.M{"$exists": false}}
+	if idValue != "" {
+		productID, err := primitive.ObjectIDFromHex(idValue)
+		if err != nil {
+			return false, fmt.Sprintf("Invalid id %q", idValue), nil
+		}
+		filter = bson.M{"_id": productID, "seller_id": sellerID, "deleted_at": bson.M{"$exists": false}}
+	}
+	if len(sku) > maxSKULength {
+		return false, fmt.Sprintf("sku must be at most %d characters", maxSKULength), nil
+	}
+
+	ints := map[string]*int{}
+	for _, column := range []struct {
+		name string
+		min  int
+	}{{"price", 1}, {"discount", 0}, {"stock", 0}, {"weight", 0}, {"length", 1}, {"width", 1}, {"height", 1}} {
+		n, message := row.getInt(column.name, column.min)
+		if message != "" {
+			return false, message, nil
+		}
+		ints[column.name] = n
+	}
+	if ints["discount"] != nil && *ints["discount"] > 100 {
+		return false, "discount must be between 0 and 100", nil
+	}
+	var dimensions *model.Dimensions
+	switch {
+	case ints["length"] != nil && ints["width"] != nil && ints["height"] != nil:
+		dimensions = &model.Dimensions{Length: *ints["length"], Width: *ints["width"], Height: *ints["height"]}
+	case ints["length"] != nil || ints["width"] != nil || ints["height"] != nil:
+		return false, "length, width and height must be filled together", nil
+	}
+
+	var existing model.Product
+	err := getProductCollection().FindOne(ctx, filter).Decode(&existing)
+	isNew := err == mongo.ErrNoDocuments
+	if err != nil && !isNew {
+		return false, "", err
+	}
+	if isNew && idValue != "" {
+		return false, "Product with this id was not found in your store", nil
+	}
+
+	categoryValue, subCategoryValue, attributesValue := row.get("category"), row.get("sub_category"), row.get("attributes")
+	if isNew && (row.get("name") == "" || ints["price"] == nil || categoryValue == "") {
+		return false, "name, price, category and sub_category are required for new products", nil
+	}
+
+	// Sub-kategori dicari di bawah kategori di baris ini, atau kategori produk yang sudah ada.
+	// Kategori tanpa turunan boleh dipakai tanpa sub_category.
+	var root, leaf model.Category
+	if categoryValue != "" {
+		var ok bool
+		if root, ok = categories.findRoot(categoryValue); !ok {
+			return false, fmt.Sprintf("Unknown category %q", categoryValue), nil
+		}
+		leaf = root
+	} else {
+		root, leaf = categories.byID[existing.CategoryID], categories.byID[existing.SubCategoryID]
+	}
+	if subCategoryValue != "" {
+		var message string
+		if leaf, message = categories.findDescendant(root, subCategoryValue); message != "" {
+			return false, message, nil
+		}
+	} else if categoryValue != "" && len(categories.children[root.ID]) > 0 {
+		return false, "sub_category is required when category is set", nil
+	}
+
+	// Atribut divalidasi terhadap skema kategori setiap kali kategori atau atribut berubah
+	var assignment categoryAssignment
+	categoryChanged := isNew || categoryValue != "" || subCategoryValue != "" || attributesValue != ""
+	if categoryChanged {
+		attributes := existing.Attributes
+		if attributesValue != "" {
+			var message string
+			if attributes, message = parseImportAttributes(attributesValue); message != "" {
+				return false, message, nil
+			}
+		}
+		var message string
+		if assignment, message = categories.assign(root.ID, leaf.ID, attributes); message != "" {
+			return false, message, nil
+		}
+	}
+
+	if isNew {
+		product := model.Product{
+			ID:            primitive.NewObjectID(),
+			SKU:           sku,
+			Name:          row.get("name"),
+			Price:         *ints["price"],
+			Description:   row.get("description"),
+			Image:         row.get("image"),
+			SellerID:      sellerID,
+			CategoryID:    root.ID,
+			SubCategoryID: leaf.ID,
+			CategoryPath:  assignment.Path,
+			Attributes:    assignment.Attributes,
+			Dimensions:    dimensions,
+		}
+		if ints["discount"] != nil {
+			product.Discount = *ints["discount"]
+		}
+		if ints["stock"] != nil {
+			product.Stock = *ints["stock"]
+		}
+		if ints["weight"] != nil {
+			product.Weight = *ints["weight"]
+		}
+		err := config.WithTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+			return insertProduct(sessCtx, product, sellerID)
+		})
+		if mongo.IsDuplicateKeyError(err) {
+			return false, "SKU already exists", nil
+		}
+		return true, "", err
+	}
+
+	updateData := bson.M{}
+	if sku != "" && sku != existing.SKU {
+		updateData["sku"] = sku
+	}
+	for _, column := range []string{"name", "description", "image"} {
+		if value := row.get(column); value != "" {
+			updateData[column] = value
+		}
+	}
+	for _, column := range []string{"price", "discount", "weight"} {
+		if ints[column] != nil {
+			updateData[column] = *ints[column]
+		}
+	}
+	if categoryChanged {
+		updateData["category_id"] = root.ID
+		updateData["sub_category_id"] = leaf.ID
+		updateData["category_path"] = assignment.Path
+		updateData["attributes"] = assignment.Attributes
+	}
+	if dimensions != nil {
+		updateData["dimensions"] = dimensions
+	}
+
+	// Harga dan stok produk bervarian diturunkan dari variannya
+	stock := ints["stock"]
+	if len(existing.Variants) > 0 {
+		delete(updateData, "price")
+		stock = nil
+	}
+
+	err = config.WithTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		if len(updateData) > 0 {
+			if _, err := getProductCollection().UpdateOne(sessCtx, bson.M{"_id": existing.ID}, bson.M{"$set": updateData}); err != nil {
+				return err
+			}
+		}
+		if stock == nil {
+			return nil
+		}
+		return setStock(sessCtx, existing, *stock, &sellerID, "seller")
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return false, "SKU already exists", nil
+	}
+	return false, "", err
+}
@@ -0,0 +1,285 @@
+package handler
+
+import (
+	"be_ecommerce/config"
+	"be_ecommerce/model"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// sellerStatusTransitions adalah perubahan status yang boleh dilakukan seller sendiri.
+// Produk baru hanya menjadi active lewat moderasi admin.
+var sellerStatusTransitions = map[string][]string{
+	model.ProductDraft:         {model.ProductPendingReview, model.ProductArchived},
+	model.ProductPendingReview: {model.ProductDraft},
+	model.ProductRejected:      {model.ProductPendingReview, model.ProductDraft, model.ProductArchived},
+	model.ProductActive:        {model.ProductDraft, model.ProductArchived},
+	model.ProductArchived:      {model.ProductPendingReview},
+}
+
+// onlyActiveProducts menambahkan filter katalog publik: hanya produk active (atau produk lama
+// tanpa status). prefix dipakai bila produk berada di sub-dokumen.
+func onlyActiveProducts(filter bson.M, prefix string) bson.M {
+	filter[prefix+"status"] = bson.M{"$in": bson.A{model.ProductActive, nil}}
+	return filter
+}
+
+// newProductStatus menentukan status produk baru: draft bila diminta, selain itu langsung
+// masuk antrean moderasi
+func newProductStatus(requested string) string {
+	if requested == model.ProductDraft {
+		return model.ProductDraft
+	}
+	return model.ProductPendingReview
+}
+
+// currentProductStatus mengembalikan status produk, produk lama tanpa status dianggap active
+func currentProductStatus(product model.Product) string {
+	if product.Status == "" {
+		return model.ProductActive
+	}
+	return product.Status
+}
+
+// productStatusFilter mencocokkan status saat ini agar perubahan status yang bersamaan tidak saling menimpa
+func productStatusFilter(product model.Product) bson.M {
+	if product.Status == "" {
+		return bson.M{"_id": product.ID, "status": bson.M{"$exists": false}}
+	}
+	return bson.M{"_id": product.ID, "status": product.Status}
+}
+
+// archiveProduct menghapus produk secara lunak: produk diarsipkan dan disembunyikan dari seller,
+// tetapi dokumennya tetap ada untuk order yang mereferensikannya
+func archiveProduct(ctx context.Context, filter bson.M) (bool, error) {
+	now := time.Now()
+	filter["deleted_at"] = bson.M{"$exists": false}
+	result, err := getProductCollection().UpdateOne(ctx, filter, bson.M{"$set": bson.M{
+		"status":            model.ProductArchived,
+		"status_updated_at": now,
+		"deleted_at":        now,
+	}})
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// SetProductStatus mengubah status produk oleh seller pemiliknya (atau admin):
+// {"status": "draft" | "pending_review" | "archived" | "active"}. Produk arsip yang pernah
+// lolos moderasi dapat langsung diaktifkan kembali.
+func SetProductStatus(c *fiber.Ctx) error {
+	productID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid product ID",
+		})
+	}
+	var input struct {
+		Status string `json:"status"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+		})
+	}
+
+	var product model.Product
+	err = getProductCollection().FindOne(context.Background(), bson.M{"_id": productID, "deleted_at": bson.M{"$exists": false}}).Decode(&product)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "Product not found",
+		})
+	}
+	if !canManageProduct(c, product) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "You do not have permission to change this product",
+		})
+	}
+
+	current := currentProductStatus(product)
+	allowed := current == model.ProductArchived && input.Status == model.ProductActive && product.ApprovedAt != nil
+	for _, status := range sellerStatusTransitions[current] {
+		if status == input.Status {
+			allowed = true
+		}
+	}
+	if !allowed {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": fmt.Sprintf("Cannot change product status from %s to %s", current, input.Status),
+		})
+	}
+
+	set := bson.M{"status": input.Status, "status_updated_at": time.Now()}
+	update := bson.M{"$set": set}
+	if input.Status == model.ProductPendingReview || input.Status == model.ProductActive {
+		update["$unset"] = bson.M{"rejection_reason": ""}
+	}
+	result, err := getProductCollection().UpdateOne(context.Background(), productStatusFilter(product), update)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to update product status",
+		})
+	}
+	if result.MatchedCount == 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"message": "Product status was changed by someone else, please reload",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Product status updated successfully",
+		"status":  input.Status,
+	})
+}
+
+// GetModerationQueue mengembalikan produk untuk dimoderasi admin, yang terlama lebih dulu.
+// Mendukung ?status= (default pending_review), ?page= dan ?limit=
+func GetModerationQueue(c *fiber.Ctx) error {
+	status := c.Query("status", model.ProductPendingReview)
+	limit := c.QueryInt("limit", 50)
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+	page := c.QueryInt("page", 1)
+	if page < 1 {
+		page = 1
+	}
+
+	filter := bson.M{"status": status, "deleted_at": bson.M{"$exists": false}}
+	total, err := getProductCollection().CountDocuments(context.Background(), filter)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch products",
+		})
+	}
+	cursor, err := getProductCollection().Find(context.Background(), filter,
+		options.Find().
+			SetSort(bson.D{{Key: "status_updated_at", Value: 1}, {Key: "_id", Value: 1}}).
+			SetSkip(int64((page-1)*limit)).
+			SetLimit(int64(limit)),
+	)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch products",
+		})
+	}
+	products := []model.Product{}
+	if err := cursor.All(context.Background(), &products); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to parse products",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Moderation queue fetched successfully",
+		"data":    products,
+		"total":   total,
+	})
+}
+
+// ModerateProduct menyetujui atau menolak produk: {"action": "approve" | "reject", "reason": "..."}.
+// Produk yang sudah active juga dapat ditolak (diturunkan) dengan alasan. Seller diberi tahu lewat notifikasi.
+func ModerateProduct(c *fiber.Ctx) error {
+	productID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid product ID",
+		})
+	}
+	var input struct {
+		Action string `json:"action"`
+		Reason string `json:"reason"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+		})
+	}
+	input.Reason = strings.TrimSpace(input.Reason)
+
+	var product model.Product
+	err = getProductCollection().FindOne(context.Background(), bson.M{"_id": productID, "deleted_at": bson.M{"$exists": false}}).Decode(&product)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "Product not found",
+		})
+	}
+
+	current := currentProductStatus(product)
+	now := time.Now()
+	set := bson.M{"status_updated_at": now}
+	update := bson.M{"$set": set}
+	var title, body string
+	switch input.Action {
+	case "approve":
+		if current != model.ProductPendingReview {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "Only products pending review can be approved",
+			})
+		}
+		set["status"] = model.ProductActive
+		if product.ApprovedAt == nil {
+			set["approved_at"] = now
+		}
+		update["$unset"] = bson.M{"rejection_reason": ""}
+		title = "Product approved"
+		body = fmt.Sprintf("%s is now live in the catalogue", product.Name)
+	case "reject":
+		if current != model.ProductPendingReview && current != model.ProductActive {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "Only products pending review or active can be rejected",
+			})
+		}
+		if input.Reason == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "Reason is required",
+			})
+		}
+		set["status"] = model.ProductRejected
+		set["rejection_reason"] = input.Reason
+		title = "Product rejected"
+		body = fmt.Sprintf("%s was rejected: %s", product.Name, input.Reason)
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Action must be approve or reject",
+		})
+	}
+
+	err = config.WithTransaction(context.Background(), func(sessCtx mongo.SessionContext) error {
+		result, err := getProductCollection().UpdateOne(sessCtx, productStatusFilter(product), update)
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return mongo.ErrNoDocuments
+		}
+		return pushNotification(sessCtx, product.SellerID, model.NotificationProductModeration, title, body, map[string]string{
+			"product_id": product.ID.Hex(),
+			"status":     set["status"].(string),
+		})
+	})
+	if err == mongo.ErrNoDocuments {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"message": "Product status was changed by someone else, please reload",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to moderate product",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Product moderated successfully",
+		"status":  set["status"],
+	})
+}
@@ -0,0 +1,514 @@
+package handler
+
+import (
+	"be_ecommerce/config"
+	"be_ecommerce/model"
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var errFlashSaleSoldOut = checkoutError{fiber.StatusConflict, "Flash sale quota has run out, please review your cart"}
+
+func getPriceRuleCollection() *mongo.Collection {
+	return config.MongoClient.Database("ecommerce").Collection("price_rules")
+}
+
+func getFlashSaleCollection() *mongo.Collection {
+	return config.MongoClient.Database("ecommerce").Collection("flash_sales")
+}
+
+// flash_sale_purchases menyimpan jumlah unit flash sale yang dibeli setiap pembeli:
+// {flash_sale_id, product_id, user_id, quantity}
+func getFlashSalePurchaseCollection() *mongo.Collection {
+	return config.MongoClient.Database("ecommerce").Collection("flash_sale_purchases")
+}
+
+// effectivePrices menghitung harga yang berlaku saat ini untuk setiap produk: harga terendah
+// dari harga normal, Product.Discount, price rule aktif dan flash sale aktif yang kuotanya masih ada
+func effectivePrices(ctx context.Context, products []model.Product) (map[primitive.ObjectID]model.EffectivePrice, error) {
+	prices := make(map[primitive.ObjectID]model.EffectivePrice, len(products))
+	if len(products) == 0 {
+		return prices, nil
+	}
+
+	productIDs := make([]primitive.ObjectID, 0, len(products))
+	for _, product := range products {
+		productIDs = append(productIDs, product.ID)
+		price := model.EffectivePrice{Price: product.Price, OriginalPrice: product.Price, Source: model.PriceSourceRegular}
+		if product.Discount > 0 && product.Discount < 100 {
+			price.Price = product.Price - product.Price*product.Discount/100
+			price.Source = model.PriceSourceDiscount
+		}
+		prices[product.ID] = price
+	}
+
+	now := time.Now()
+	cursor, err := getPriceRuleCollection().Find(ctx, bson.M{
+		"product_id": bson.M{"$in": productIDs},
+		"starts_at":  bson.M{"$lte": now},
+		"ends_at":    bson.M{"$gt": now},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var rules []model.PriceRule
+	if err := cursor.All(ctx, &rules); err != nil {
+		return nil, err
+	}
+	for _, rule := range rules {
+		price := prices[rule.ProductID]
+		if rule.Price < price.Price {
+			endsAt := rule.EndsAt
+			price.Price = rule.Price
+			price.Source = model.PriceSourceRule
+			price.EndsAt = &endsAt
+			prices[rule.ProductID] = price
+		}
+	}
+
+	cursor, err = getFlashSaleCollection().Find(ctx, bson.M{
+		"active":           true,
+		"starts_at":        bson.M{"$lte": now},
+		"ends_at":          bson.M{"$gt": now},
+		"items.product_id": bson.M{"$in": productIDs},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var sales []model.FlashSale
+	if err := cursor.All(ctx, &sales); err != nil {
+		return nil, err
+	}
+	for _, sale := range sales {
+		for _, item := range sale.Items {
+			price, ok := prices[item.ProductID]
+			if !ok || item.Sold >= item.Quota || item.Price >= price.Price {
+				continue
+			}
+			saleID, endsAt := sale.ID, sale.EndsAt
+			prices[item.ProductID] = model.EffectivePrice{
+				Price:         item.Price,
+				OriginalPrice: price.OriginalPrice,
+				Source:        model.PriceSourceFlashSale,
+				EndsAt:        &endsAt,
+				FlashSaleID:   &saleID,
+				QuotaLeft:     item.Quota - item.Sold,
+				PerBuyerLimit: item.PerBuyerLimit,
+			}
+		}
+	}
+	return prices, nil
+}
+
+// variantEffectivePrice menerapkan promo produk ke harga varian secara proporsional:
+// varian yang lebih mahal mendapat potongan yang sebanding
+func variantEffectivePrice(price model.EffectivePrice, variantPrice int) model.EffectivePrice {
+	if price.OriginalPrice > 0 {
+		price.Price = variantPrice * price.Price / price.OriginalPrice
+	} else {
+		price.Price = variantPrice
+	}
+	price.OriginalPrice = variantPrice
+	return price
+}
+
+// withEffectivePrices mengisi Product.EffectivePrice untuk ditampilkan
+func withEffectivePrices(ctx context.Context, products []model.Product) error {
+	prices, err := effectivePrices(ctx, products)
+	if err != nil {
+		return err
+	}
+	for i := range products {
+		price := prices[products[i].ID]
+		products[i].EffectivePrice = &price
+	}
+	return nil
+}
+
+// withEffectivePriceDocs mengisi field effective_price pada hasil agregasi produk
+func withEffectivePriceDocs(ctx context.Context, docs []bson.M) error {
+	products := make([]model.Product, len(docs))
+	for i, doc := range docs {
+		raw, err := bson.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		if err := bson.Unmarshal(raw, &products[i]); err != nil {
+			return err
+		}
+	}
+	prices, err := effectivePrices(ctx, products)
+	if err != nil {
+		return err
+	}
+	for i, doc := range docs {
+		doc["effective_price"] = prices[products[i].ID]
+	}
+	return nil
+}
+
+// checkFlashSaleLimits memeriksa kuota dan batas pembelian per pembeli untuk item
+// yang mendapat harga flash sale
+func checkFlashSaleLimits(ctx context.Context, userID primitive.ObjectID, item model.OrderItem, price model.EffectivePrice) error {
+	if price.FlashSaleID == nil {
+		return nil
+	}
+	if item.Quantity > price.QuotaLeft {
+		return checkoutError{fiber.StatusConflict, fmt.Sprintf("Only %d of %s left at the flash sale price", price.QuotaLeft, item.Name)}
+	}
+	if price.PerBuyerLimit == 0 {
+		return nil
+	}
+
+	var purchase struct {
+		Quantity int `bson:"quantity"`
+	}
+	err := getFlashSalePurchaseCollection().FindOne(ctx, bson.M{
+		"flash_sale_id": *price.FlashSaleID,
+		"product_id":    item.ProductID,
+		"user_id":       userID,
+	}).Decode(&purchase)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return err
+	}
+	if purchase.Quantity+item.Quantity > price.PerBuyerLimit {
+		return checkoutError{fiber.StatusUnprocessableEntity, fmt.Sprintf("You can buy at most %d of %s in this flash sale", price.PerBuyerLimit, item.Name)}
+	}
+	return nil
+}
+
+// reserveFlashSale memotong kuota flash sale dan mencatat pembelian per pembeli untuk item
+// order. Panggil di dalam transaksi yang sama dengan penyimpanan order.
+func reserveFlashSale(ctx context.Context, userID primitive.ObjectID, items []model.OrderItem) error {
+	for _, item := range items {
+		if item.FlashSaleID == nil {
+			continue
+		}
+
+		var sale model.FlashSale
+		if err := getFlashSaleCollection().FindOne(ctx, bson.M{"_id": *item.FlashSaleID}).Decode(&sale); err != nil {
+			return err
+		}
+		var saleItem *model.FlashSaleItem
+		for i := range sale.Items {
+			if sale.Items[i].ProductID == item.ProductID {
+				saleItem = &sale.Items[i]
+				break
+			}
+		}
+		if saleItem == nil {
+			return errFlashSaleSoldOut
+		}
+
+		now := time.Now()
+		result, err := getFlashSaleCollection().UpdateOne(ctx,
+			bson.M{
+				"_id":       sale.ID,
+				"active":    true,
+				"starts_at": bson.M{"$lte": now},
+				"ends_at":   bson.M{"$gt": now},
+				"items": bson.M{"$elemMatch": bson.M{
+					"product_id": item.ProductID,
+					"sold":       bson.M{"$lte": saleItem.Quota - item.Quantity},
+				}},
+			},
+			bson.M{"$inc": bson.M{"items.$.sold": item.Quantity}},
+		)
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return errFlashSaleSoldOut
+		}
+
+		// Sama seperti batas voucher per pengguna: filter tidak cocok saat batas terlampaui
+		// sehingga upsert gagal pada index unik
+		if saleItem.PerBuyerLimit > 0 {
+			if item.Quantity > saleItem.PerBuyerLimit {
+				return checkoutError{fiber.StatusUnprocessableEntity, fmt.Sprintf("You can buy at most %d of %s in this flash sale", saleItem.PerBuyerLimit, item.Name)}
+			}
+			_, err := getFlashSalePurchaseCollection().UpdateOne(ctx,
+				bson.M{
+					"flash_sale_id": sale.ID,
+					"product_id":    item.ProductID,
+					"user_id":       userID,
+					"quantity":      bson.M{"$lte": saleItem.PerBuyerLimit - item.Quantity},
+				},
+				bson.M{"$inc": bson.M{"quantity": item.Quantity}},
+				options.Update().SetUpsert(true),
+			)
+			if mongo.IsDuplicateKeyError(err) {
+				return checkoutError{fiber.StatusUnprocessableEntity, fmt.Sprintf("You can buy at most %d of %s in this flash sale", saleItem.PerBuyerLimit, item.Name)}
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// releaseFlashSale mengembalikan kuota flash sale milik order yang dibatalkan
+func releaseFlashSale(ctx context.Context, userID primitive.ObjectID, items []model.OrderItem) error {
+	for _, item := range items {
+		if item.FlashSaleID == nil {
+			continue
+		}
+		if _, err := getFlashSaleCollection().UpdateOne(ctx,
+			bson.M{"_id": *item.FlashSaleID, "items.product_id": item.ProductID},
+			bson.M{"$inc": bson.M{"items.$.sold": -item.Quantity}},
+		); err != nil {
+			return err
+		}
+		if _, err := getFlashSalePurchaseCollection().UpdateOne(ctx,
+			bson.M{"flash_sale_id": *item.FlashSaleID, "product_id": item.ProductID, "user_id": userID},
+			bson.M{"$inc": bson.M{"quantity": -item.Quantity}},
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreatePriceRule membuat harga khusus berjangka untuk sebuah produk. Seller hanya untuk
+// produknya sendiri, admin untuk produk mana pun.
+func CreatePriceRule(c *fiber.Ctx) error {
+	var rule model.PriceRule
+	if err := c.BodyParser(&rule); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+		})
+	}
+
+	var product model.Product
+	err := config.MongoClient.Database("ecommerce").Collection("products").FindOne(context.Background(), bson.M{"_id": rule.ProductID}).Decode(&product)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "Product not found",
+		})
+	}
+	if !canManageProduct(c, product) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "You do not have permission to change this product's price",
+		})
+	}
+
+	switch {
+	case rule.Price < 1 || rule.Price >= product.Price:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Price must be greater than 0 and lower than the product price",
+		})
+	case rule.StartsAt.IsZero() || rule.EndsAt.IsZero() || !rule.EndsAt.After(rule.StartsAt):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "starts_at and ends_at are required and ends_at must be after starts_at",
+		})
+	}
+
+	rule.ID = primitive.NewObjectID()
+	rule.SellerID = product.SellerID
+	rule.CreatedBy = currentUserID(c)
+	rule.CreatedAt = time.Now()
+	if _, err := getPriceRuleCollection().InsertOne(context.Background(), rule); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to create price rule",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "Price rule created successfully",
+		"data":    rule,
+	})
+}
+
+// GetPriceRules mengembalikan price rule sebuah produk yang belum berakhir
+func GetPriceRules(c *fiber.Ctx) error {
+	productID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid product ID",
+		})
+	}
+
+	cursor, err := getPriceRuleCollection().Find(context.Background(),
+		bson.M{"product_id": productID, "ends_at": bson.M{"$gt": time.Now()}},
+		options.Find().SetSort(bson.M{"starts_at": 1}),
+	)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch price rules",
+		})
+	}
+	rules := []model.PriceRule{}
+	if err := cursor.All(context.Background(), &rules); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to parse price rules",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Price rules fetched successfully",
+		"data":    rules,
+	})
+}
+
+// DeletePriceRule menghapus price rule milik seller (atau oleh admin)
+func DeletePriceRule(c *fiber.Ctx) error {
+	ruleID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid price rule ID",
+		})
+	}
+
+	filter := bson.M{"_id": ruleID}
+	if role, _ := c.Locals("role").(string); role != "admin" {
+		filter["seller_id"] = currentUserID(c)
+	}
+	result, err := getPriceRuleCollection().DeleteOne(context.Background(), filter)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to delete price rule",
+		})
+	}
+	if result.DeletedCount == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "Price rule not found",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Price rule deleted successfully",
+	})
+}
+
+// CreateFlashSale membuat kampanye flash sale (admin)
+func CreateFlashSale(c *fiber.Ctx) error {
+	var sale model.FlashSale
+	if err := c.BodyParser(&sale); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+		})
+	}
+
+	switch {
+	case sale.Name == "":
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Flash sale name is required",
+		})
+	case sale.StartsAt.IsZero() || sale.EndsAt.IsZero() || !sale.EndsAt.After(sale.StartsAt):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "starts_at and ends_at are required and ends_at must be after starts_at",
+		})
+	case len(sale.Items) == 0:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Flash sale items are required",
+		})
+	}
+
+	productCollection := config.MongoClient.Database("ecommerce").Collection("products")
+	seen := map[primitive.ObjectID]bool{}
+	for i, item := range sale.Items {
+		if seen[item.ProductID] {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "Each product can only appear once in a flash sale",
+			})
+		}
+		seen[item.ProductID] = true
+
+		var product model.Product
+		if err := productCollection.FindOne(context.Background(), bson.M{"_id": item.ProductID}).Decode(&product); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "Invalid product ID",
+			})
+		}
+		if item.Price < 1 || item.Price >= product.Price || item.Quota < 1 || item.PerBuyerLimit < 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": fmt.Sprintf("Invalid price, quota or per-buyer limit for %s", product.Name),
+			})
+		}
+		sale.Items[i].SellerID = product.SellerID
+		sale.Items[i].Sold = 0
+	}
+
+	sale.ID = primitive.NewObjectID()
+	sale.Active = true
+	sale.CreatedBy = currentUserID(c)
+	sale.CreatedAt = time.Now()
+	if _, err := getFlashSaleCollection().InsertOne(context.Background(), sale); err != nil {
+		log.Println("Error creating flash sale:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to create flash sale",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "Flash sale created successfully",
+		"data":    sale,
+	})
+}
+
+// GetFlashSales mengembalikan flash sale yang sedang berjalan dan yang akan datang
+func GetFlashSales(c *fiber.Ctx) error {
+	cursor, err := getFlashSaleCollection().Find(context.Background(),
+		bson.M{"active": true, "ends_at": bson.M{"$gt": time.Now()}},
+		options.Find().SetSort(bson.M{"starts_at": 1}),
+	)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch flash sales",
+		})
+	}
+	sales := []model.FlashSale{}
+	if err := cursor.All(context.Background(), &sales); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to parse flash sales",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Flash sales fetched successfully",
+		"data":    sales,
+	})
+}
+
+// SetFlashSaleActive menghentikan atau mengaktifkan kembali flash sale: {"active": false}
+func SetFlashSaleActive(c *fiber.Ctx) error {
+	saleID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid flash sale ID",
+		})
+	}
+	var input struct {
+		Active bool `json:"active"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+		})
+	}
+
+	result, err := getFlashSaleCollection().UpdateOne(context.Background(), bson.M{"_id": saleID}, bson.M{"$set": bson.M{"active": input.Active}})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to update flash sale",
+		})
+	}
+	if result.MatchedCount == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "Flash sale not found",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Flash sale updated successfully",
+	})
+}
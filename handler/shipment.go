@@ -0,0 +1,399 @@
+package handler
+
+import (
+	"be_ecommerce/config"
+	"be_ecommerce/model"
+	"be_ecommerce/shipping"
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Jeda minimal antar pengecekan resi ke provider tracking
+const trackingRefreshInterval = 30 * time.Minute
+
+var errOrderNotShippable = errors.New("order cannot be shipped")
+
+// Order yang sudah dibayar dan boleh dikirim; Shipped untuk toko lain dalam order yang sama
+var shippableOrderStatuses = []string{"Processing", "Confirmed", "Shipped"}
+
+// orderSellerIDs mengembalikan seller yang produknya ada di order
+func orderSellerIDs(order model.Order) map[primitive.ObjectID]bool {
+	sellers := map[primitive.ObjectID]bool{}
+	if !order.SellerID.IsZero() {
+		sellers[order.SellerID] = true
+	}
+	for _, item := range order.Items {
+		if !item.SellerID.IsZero() {
+			sellers[item.SellerID] = true
+		}
+	}
+	return sellers
+}
+
+// ShipOrder mencatat pengiriman oleh seller: kurir, layanan dan nomor resi. Status order
+// menjadi Shipped dan pembeli menerima email berisi resi.
+func ShipOrder(c *fiber.Ctx) error {
+	orderID, err := primitive.ObjectIDFromHex(c.Params("order_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid Order ID"})
+	}
+
+	var input struct {
+		Courier string `json:"courier"`
+		Service string `json:"service"`
+		Waybill string `json:"waybill"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	input.Waybill = strings.TrimSpace(input.Waybill)
+	if input.Waybill == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Waybill number is required"})
+	}
+
+	collection := config.MongoClient.Database("ecommerce").Collection("orders")
+	var order model.Order
+	if err := collection.FindOne(context.Background(), bson.M{"_id": orderID}).Decode(&order); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Order not found"})
+	}
+
+	sellerID := currentUserID(c)
+	if !orderSellerIDs(order)[sellerID] {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You do not have permission to ship this order"})
+	}
+
+	// Kurir dan layanan default mengikuti pilihan pembeli saat checkout
+	if input.Courier == "" {
+		input.Courier = order.Courier
+		if input.Service == "" {
+			input.Service = order.ShippingService
+		}
+	}
+	if input.Courier == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Courier is required"})
+	}
+
+	now := time.Now()
+	shipment := model.Shipment{
+		ID:        primitive.NewObjectID(),
+		SellerID:  sellerID,
+		Courier:   strings.ToLower(input.Courier),
+		Service:   input.Service,
+		Waybill:   input.Waybill,
+		Status:    model.ShipmentInTransit,
+		ShippedAt: now,
+		Events: []model.TrackingEvent{
+			{Time: now, Description: "Paket diserahkan ke kurir"},
+		},
+	}
+
+	err = config.WithTransaction(context.Background(), func(sessCtx mongo.SessionContext) error {
+		var previous model.Order
+		err := collection.FindOneAndUpdate(sessCtx,
+			bson.M{
+				"_id":                 orderID,
+				"status":              bson.M{"$in": shippableOrderStatuses},
+				"shipments.seller_id": bson.M{"$ne": sellerID},
+			},
+			bson.M{
+				"$set":  bson.M{"status": "Shipped"},
+				"$push": bson.M{"shipments": shipment},
+			},
+		).Decode(&previous)
+		if err == mongo.ErrNoDocuments {
+			return errOrderNotShippable
+		}
+		if err != nil {
+			return err
+		}
+		return afterOrderStatusChange(sessCtx, orderID, previous.Status, "Shipped")
+	})
+	if errors.Is(err, errOrderNotShippable) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Order is not paid yet or has already been shipped"})
+	}
+	if err != nil {
+		log.Println("Error shipping order:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to ship order"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Order shipped successfully", "data": shipment})
+}
+
+// GetOrderTracking mengembalikan riwayat pengiriman order untuk pembeli atau seller-nya.
+// Resi yang belum dicek selama trackingRefreshInterval diperbarui dari provider tracking.
+func GetOrderTracking(c *fiber.Ctx) error {
+	orderID, err := primitive.ObjectIDFromHex(c.Params("order_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid Order ID"})
+	}
+
+	collection := config.MongoClient.Database("ecommerce").Collection("orders")
+	var order model.Order
+	if err := collection.FindOne(context.Background(), bson.M{"_id": orderID}).Decode(&order); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Order not found"})
+	}
+
+	userID := currentUserID(c)
+	if order.UserID != userID && !orderSellerIDs(order)[userID] {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You do not have permission to view this order"})
+	}
+
+	if order.Status == "Shipped" && trackingStale(order, time.Now()) {
+		refreshed, err := refreshOrderTracking(context.Background(), order)
+		if err != nil {
+			log.Println("Error refreshing shipment tracking:", err)
+		} else {
+			order = refreshed
+		}
+	}
+
+	shipments := order.Shipments
+	if shipments == nil {
+		shipments = []model.Shipment{}
+	}
+	return c.JSON(fiber.Map{
+		"message": "Tracking fetched successfully",
+		"data": fiber.Map{
+			"order_id":     order.ID.Hex(),
+			"status":       order.Status,
+			"delivered_at": order.DeliveredAt,
+			"shipments":    shipments,
+		},
+	})
+}
+
+// ConfirmDelivery dipakai pembeli untuk menandai pesanan sudah diterima
+func ConfirmDelivery(c *fiber.Ctx) error {
+	orderID, err := primitive.ObjectIDFromHex(c.Params("order_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid Order ID"})
+	}
+
+	collection := config.MongoClient.Database("ecommerce").Collection("orders")
+	var order model.Order
+	err = collection.FindOne(context.Background(), bson.M{"_id": orderID, "user_id": currentUserID(c)}).Decode(&order)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Order not found"})
+	}
+	if order.Status != "Shipped" {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Only shipped orders can be confirmed"})
+	}
+
+	now := time.Now()
+	var confirmed []model.Shipment
+	for i := range order.Shipments {
+		if order.Shipments[i].Status != model.ShipmentDelivered {
+			markShipmentDelivered(&order.Shipments[i], now, "Diterima oleh pembeli")
+			confirmed = append(confirmed, order.Shipments[i])
+		}
+	}
+
+	updated, delivered, err := saveShipments(context.Background(), order.ID, confirmed)
+	if err != nil {
+		log.Println("Error confirming delivery:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to confirm delivery"})
+	}
+	if !delivered && updated.Status != "Shipped" {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Only shipped orders can be confirmed"})
+	}
+	if !delivered {
+		// Order multi-toko baru selesai setelah paket dari semua toko diterima
+		return c.JSON(fiber.Map{"message": "Delivery confirmed, waiting for the remaining stores to ship"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Delivery confirmed successfully"})
+}
+
+// trackingStale memeriksa apakah ada resi dalam perjalanan yang perlu dicek ulang
+func trackingStale(order model.Order, now time.Time) bool {
+	for _, shipment := range order.Shipments {
+		if shipment.Status != model.ShipmentInTransit {
+			continue
+		}
+		if shipment.LastCheckedAt == nil || now.Sub(*shipment.LastCheckedAt) >= trackingRefreshInterval {
+			return true
+		}
+	}
+	return false
+}
+
+// refreshOrderTracking mengambil riwayat terbaru setiap resi dari provider tracking.
+// Bila paket dari setiap toko di order sudah diterima, order otomatis menjadi Delivered.
+func refreshOrderTracking(ctx context.Context, order model.Order) (model.Order, error) {
+	now := time.Now()
+	var checked []model.Shipment
+	for i := range order.Shipments {
+		shipment := &order.Shipments[i]
+		if shipment.Status != model.ShipmentInTransit {
+			continue
+		}
+		shipment.LastCheckedAt = &now
+
+		result, err := shipping.DefaultTracker().Track(ctx, shipment.Courier, shipment.Waybill)
+		if errors.Is(err, shipping.ErrTrackingUnsupported) {
+			checked = append(checked, *shipment)
+			continue
+		}
+		if err != nil {
+			log.Printf("Error tracking waybill %s (%s): %v", shipment.Waybill, shipment.Courier, err)
+			checked = append(checked, *shipment)
+			continue
+		}
+
+		mergeTrackingEvents(shipment, result.Events)
+		if result.Delivered {
+			deliveredAt := now
+			if n := len(shipment.Events); n > 0 {
+				deliveredAt = shipment.Events[n-1].Time
+			}
+			markShipmentDelivered(shipment, deliveredAt, "")
+		}
+		checked = append(checked, *shipment)
+	}
+
+	updated, _, err := saveShipments(ctx, order.ID, checked)
+	if err != nil {
+		return order, err
+	}
+	return updated, nil
+}
+
+// mergeTrackingEvents menambahkan event baru (berdasarkan waktu dan deskripsi) lalu mengurutkannya
+func mergeTrackingEvents(shipment *model.Shipment, events []model.TrackingEvent) {
+	seen := make(map[string]bool, len(shipment.Events))
+	key := func(event model.TrackingEvent) string {
+		return event.Time.UTC().Format(time.RFC3339) + "|" + event.Description
+	}
+	for _, event := range shipment.Events {
+		seen[key(event)] = true
+	}
+	for _, event := range events {
+		if !seen[key(event)] {
+			seen[key(event)] = true
+			shipment.Events = append(shipment.Events, event)
+		}
+	}
+	sort.SliceStable(shipment.Events, func(i, j int) bool {
+		return shipment.Events[i].Time.Before(shipment.Events[j].Time)
+	})
+}
+
+// markShipmentDelivered menandai paket diterima; description opsional ditambahkan sebagai event
+func markShipmentDelivered(shipment *model.Shipment, at time.Time, description string) {
+	if shipment.Status == model.ShipmentDelivered {
+		return
+	}
+	shipment.Status = model.ShipmentDelivered
+	shipment.DeliveredAt = &at
+	if description != "" {
+		shipment.Events = append(shipment.Events, model.TrackingEvent{Time: at, Description: description})
+	}
+}
+
+// allSellersDelivered memeriksa apakah setiap seller di order sudah punya paket yang diterima,
+// sehingga order multi-toko tidak selesai selama masih ada toko yang belum mengirim
+func allSellersDelivered(order model.Order) bool {
+	delivered := map[primitive.ObjectID]bool{}
+	for _, shipment := range order.Shipments {
+		if shipment.Status == model.ShipmentDelivered {
+			delivered[shipment.SellerID] = true
+		}
+	}
+	sellers := orderSellerIDs(order)
+	for sellerID := range sellers {
+		if !delivered[sellerID] {
+			return false
+		}
+	}
+	return len(sellers) > 0
+}
+
+// saveShipments menyimpan shipment yang berubah satu per satu (arrayFilters pada _id-nya), sehingga
+// shipment yang ditambahkan seller lain secara bersamaan tidak tertimpa. Order yang masih Shipped
+// menjadi Delivered bila allSellersDelivered. Mengembalikan order terbaru dan true bila order
+// baru saja menjadi Delivered.
+func saveShipments(ctx context.Context, orderID primitive.ObjectID, shipments []model.Shipment) (model.Order, bool, error) {
+	collection := config.MongoClient.Database("ecommerce").Collection("orders")
+
+	var order model.Order
+	delivered := false
+	err := config.WithTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		delivered = false
+		for _, shipment := range shipments {
+			_, err := collection.UpdateOne(sessCtx,
+				bson.M{"_id": orderID, "status": "Shipped"},
+				bson.M{"$set": bson.M{"shipments.$[shipment]": shipment}},
+				options.Update().SetArrayFilters(options.ArrayFilters{Filters: []interface{}{bson.M{"shipment._id": shipment.ID}}}),
+			)
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := collection.FindOne(sessCtx, bson.M{"_id": orderID}).Decode(&order); err != nil {
+			return err
+		}
+		if order.Status != "Shipped" || !allSellersDelivered(order) {
+			return nil
+		}
+		result, err := collection.UpdateOne(sessCtx, bson.M{"_id": orderID, "status": "Shipped"}, bson.M{"$set": bson.M{"status": "Delivered"}})
+		if err != nil || result.MatchedCount == 0 {
+			return err
+		}
+		if err := afterOrderStatusChange(sessCtx, orderID, "Shipped", "Delivered"); err != nil {
+			return err
+		}
+		delivered = true
+		return collection.FindOne(sessCtx, bson.M{"_id": orderID}).Decode(&order)
+	})
+	return order, delivered, err
+}
+
+// pollShipmentTracking memperbarui resi order Shipped yang sudah lama tidak dicek
+func pollShipmentTracking(ctx context.Context) error {
+	collection := config.MongoClient.Database("ecommerce").Collection("orders")
+	cursor, err := collection.Find(ctx, bson.M{
+		"status": "Shipped",
+		"shipments": bson.M{"$elemMatch": bson.M{
+			"status": model.ShipmentInTransit,
+			"$or": bson.A{
+				bson.M{"last_checked_at": bson.M{"$exists": false}},
+				bson.M{"last_checked_at": bson.M{"$lte": time.Now().Add(-trackingRefreshInterval)}},
+			},
+		}},
+	}, options.Find().SetLimit(jobBatchSize))
+	if err != nil {
+		return err
+	}
+
+	var orders []model.Order
+	if err := cursor.All(ctx, &orders); err != nil {
+		return err
+	}
+
+	delivered := 0
+	for _, order := range orders {
+		refreshed, err := refreshOrderTracking(ctx, order)
+		if err != nil {
+			log.Printf("Error refreshing tracking for order %s: %v", order.ID.Hex(), err)
+			continue
+		}
+		if refreshed.Status == "Delivered" {
+			delivered++
+		}
+	}
+	if delivered > 0 {
+		log.Printf("Marked %d orders as delivered from tracking", delivered)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"be_ecommerce/model"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestMergeTrackingEvents(t *testing.T) {
+	base := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	at := func(hours int) time.Time { return base.Add(time.Duration(hours) * time.Hour) }
+
+	tests := []struct {
+		name     string
+		existing []model.TrackingEvent
+		incoming []model.TrackingEvent
+		want     []string
+	}{
+		{
+			name:     "new events are appended in time order",
+			existing: []model.TrackingEvent{{Time: at(0), Description: "Picked up"}},
+			incoming: []model.TrackingEvent{{Time: at(5), Description: "Arrived at hub"}, {Time: at(2), Description: "Departed"}},
+			want:     []string{"Picked up", "Departed", "Arrived at hub"},
+		},
+		{
+			name:     "duplicate events are ignored",
+			existing: []model.TrackingEvent{{Time: at(0), Description: "Picked up"}},
+			incoming: []model.TrackingEvent{{Time: at(0), Description: "Picked up"}, {Time: at(0).In(time.FixedZone("WIB", 7*3600)), Description: "Picked up"}},
+			want:     []string{"Picked up"},
+		},
+		{
+			name:     "same time with different description is kept",
+			existing: []model.TrackingEvent{{Time: at(1), Description: "Sorting"}},
+			incoming: []model.TrackingEvent{{Time: at(1), Description: "Loaded"}},
+			want:     []string{"Sorting", "Loaded"},
+		},
+		{
+			name:     "no existing events",
+			incoming: []model.TrackingEvent{{Time: at(3), Description: "Delivered"}},
+			want:     []string{"Delivered"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shipment := model.Shipment{Events: tt.existing}
+			mergeTrackingEvents(&shipment, tt.incoming)
+			if len(shipment.Events) != len(tt.want) {
+				t.Fatalf("got %d events, want %d: %+v", len(shipment.Events), len(tt.want), shipment.Events)
+			}
+			for i, event := range shipment.Events {
+				if event.Description != tt.want[i] {
+					t.Errorf("event %d = %q, want %q", i, event.Description, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestAllSellersDelivered(t *testing.T) {
+	sellerA, sellerB := primitive.NewObjectID(), primitive.NewObjectID()
+	delivered := func(seller primitive.ObjectID) model.Shipment {
+		return model.Shipment{SellerID: seller, Status: model.ShipmentDelivered}
+	}
+	inTransit := func(seller primitive.ObjectID) model.Shipment {
+		return model.Shipment{SellerID: seller, Status: model.ShipmentInTransit}
+	}
+	twoSellers := []model.OrderItem{{SellerID: sellerA}, {SellerID: sellerB}}
+
+	tests := []struct {
+		name  string
+		order model.Order
+		want  bool
+	}{
+		{"single seller delivered", model.Order{SellerID: sellerA, Items: []model.OrderItem{{SellerID: sellerA}}, Shipments: []model.Shipment{delivered(sellerA)}}, true},
+		{"single seller in transit", model.Order{SellerID: sellerA, Shipments: []model.Shipment{inTransit(sellerA)}}, false},
+		{"other seller has not shipped", model.Order{SellerID: sellerA, Items: twoSellers, Shipments: []model.Shipment{delivered(sellerA)}}, false},
+		{"other seller still in transit", model.Order{SellerID: sellerA, Items: twoSellers, Shipments: []model.Shipment{delivered(sellerA), inTransit(sellerB)}}, false},
+		{"every seller delivered", model.Order{SellerID: sellerA, Items: twoSellers, Shipments: []model.Shipment{delivered(sellerA), delivered(sellerB)}}, true},
+		{"no sellers", model.Order{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := allSellersDelivered(tt.order); got != tt.want {
+				t.Errorf("allSellersDelivered() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrackingStale(t *testing.T) {
+	now := time.Now()
+	recent, old := now.Add(-5*time.Minute), now.Add(-2*trackingRefreshInterval)
+
+	tests := []struct {
+		name      string
+		shipments []model.Shipment
+		want      bool
+	}{
+		{"never checked", []model.Shipment{{Status: model.ShipmentInTransit}}, true},
+		{"checked recently", []model.Shipment{{Status: model.ShipmentInTransit, LastCheckedAt: &recent}}, false},
+		{"checked long ago", []model.Shipment{{Status: model.ShipmentInTransit, LastCheckedAt: &old}}, true},
+		{"delivered shipments are not refreshed", []model.Shipment{{Status: model.ShipmentDelivered}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := trackingStale(model.Order{Shipments: tt.shipments}, now); got != tt.want {
+				t.Errorf("trackingStale() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
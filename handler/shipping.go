@@ -0,0 +1,201 @@
+package handler
+
+import (
+	"be_ecommerce/config"
+	"be_ecommerce/model"
+	"be_ecommerce/shipping"
+	"context"
+	"errors"
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Berat bawaan untuk produk lama yang belum diisi beratnya
+const defaultProductWeight = 1000
+
+var errShippingServiceUnavailable = errors.New("shipping service not available")
+
+// quoteShipping menghitung pilihan ongkos kirim untuk item ke alamat tujuan. Item dari
+// beberapa toko dikirim terpisah; ongkos tiap layanan dijumlahkan dan hanya layanan yang
+// tersedia di semua toko yang ditawarkan. Toko lama yang belum mengisi alamat terstruktur
+// dikenai shipping.FlatRate yang ditambahkan ke setiap layanan, agar checkout tetap bisa jalan.
+func quoteShipping(ctx context.Context, items []model.OrderItem, destination model.Address) ([]shipping.Rate, error) {
+	productIDs := make([]primitive.ObjectID, 0, len(items))
+	for _, item := range items {
+		productIDs = append(productIDs, item.ProductID)
+	}
+
+	cursor, err := config.MongoClient.Database("ecommerce").Collection("products").Find(ctx, bson.M{"_id": bson.M{"$in": productIDs}})
+	if err != nil {
+		return nil, err
+	}
+	var products []model.Product
+	if err := cursor.All(ctx, &products); err != nil {
+		return nil, err
+	}
+	productByID := make(map[primitive.ObjectID]model.Product, len(products))
+	for _, product := range products {
+		productByID[product.ID] = product
+	}
+
+	// Kelompokkan paket per toko
+	parcels := map[primitive.ObjectID]*shipping.Parcel{}
+	var sellerOrder []primitive.ObjectID
+	for _, item := range items {
+		product, ok := productByID[item.ProductID]
+		if !ok {
+			return nil, addressError{"Invalid product ID"}
+		}
+		parcel, ok := parcels[product.SellerID]
+		if !ok {
+			parcel = &shipping.Parcel{}
+			parcels[product.SellerID] = parcel
+			sellerOrder = append(sellerOrder, product.SellerID)
+		}
+
+		weight := product.Weight
+		if weight <= 0 {
+			weight = defaultProductWeight
+		}
+		parcel.Weight += weight * item.Quantity
+		if product.Dimensions != nil {
+			parcel.Volume += product.Dimensions.Volume() * item.Quantity
+		}
+	}
+
+	var combined []shipping.Rate
+	quoted, flat := false, shipping.Rate{}
+	for _, sellerID := range sellerOrder {
+		var seller model.User
+		err := getUserCollection().FindOne(ctx, bson.M{"_id": sellerID}).Decode(&seller)
+		if err != nil && err != mongo.ErrNoDocuments {
+			return nil, err
+		}
+		if seller.StoreInfo == nil || seller.StoreInfo.Address == nil {
+			log.Printf("Seller %s has no structured store address, using flat shipping rate", sellerID.Hex())
+			rate := shipping.FlatRate(*parcels[sellerID])
+			flat.Courier, flat.Service, flat.Description = rate.Courier, rate.Service, rate.Description
+			flat.Cost += rate.Cost
+			flat.Parcels = append(flat.Parcels, shipping.ParcelCost{Origin: sellerID.Hex(), Cost: rate.Cost})
+			continue
+		}
+
+		rates, err := shipping.Default().Rates(ctx, shipping.RateRequest{
+			Origin:      *seller.StoreInfo.Address,
+			Destination: destination,
+			Parcel:      *parcels[sellerID],
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		// Ongkos setiap paket dicatat per seller, dipakai voucher gratis ongkir yang hanya berlaku untuk toko tertentu
+		if !quoted {
+			for i := range rates {
+				rates[i].Parcels = []shipping.ParcelCost{{Origin: sellerID.Hex(), Cost: rates[i].Cost}}
+			}
+			combined, quoted = rates, true
+			continue
+		}
+		var merged []shipping.Rate
+		for _, rate := range combined {
+			if other, ok := findRate(rates, rate.Courier, rate.Service); ok {
+				rate.Cost += other.Cost
+				rate.Parcels = append(append([]shipping.ParcelCost{}, rate.Parcels...), shipping.ParcelCost{Origin: sellerID.Hex(), Cost: other.Cost})
+				merged = append(merged, rate)
+			}
+		}
+		combined = merged
+	}
+
+	// Paket bertarif flat ditambahkan ke setiap layanan, atau menjadi satu-satunya layanan
+	// bila tidak ada toko yang bisa dihitung ongkosnya
+	if !quoted && flat.Service != "" {
+		combined = []shipping.Rate{flat}
+	} else {
+		for i := range combined {
+			combined[i].Cost += flat.Cost
+			combined[i].Parcels = append(combined[i].Parcels, flat.Parcels...)
+		}
+	}
+
+	if len(combined) == 0 {
+		return nil, shipping.ErrNoRates
+	}
+	shipping.SortByCost(combined)
+	return combined, nil
+}
+
+// selectShippingRate memilih layanan yang diminta pembeli; tanpa pilihan dipakai yang termurah
+func selectShippingRate(rates []shipping.Rate, courier, service string) (shipping.Rate, error) {
+	if courier == "" && service == "" {
+		return rates[0], nil
+	}
+	if rate, ok := findRate(rates, courier, service); ok {
+		return rate, nil
+	}
+	return shipping.Rate{}, errShippingServiceUnavailable
+}
+
+func findRate(rates []shipping.Rate, courier, service string) (shipping.Rate, bool) {
+	for _, rate := range rates {
+		if rate.Courier == courier && rate.Service == service {
+			return rate, true
+		}
+	}
+	return shipping.Rate{}, false
+}
+
+// shippingErrorResponse menulis response error ongkos kirim; key mengikuti format
+// response handler pemanggil ("error" atau "message")
+func shippingErrorResponse(c *fiber.Ctx, key string, err error) error {
+	switch {
+	case errors.Is(err, shipping.ErrNoRates):
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{key: "No shipping service available for this address"})
+	case errors.Is(err, errShippingServiceUnavailable):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{key: "Selected shipping service is not available"})
+	}
+	if status, _ := addressErrorStatus(err); status != fiber.StatusInternalServerError {
+		return shippingAddressError(c, key, err)
+	}
+	log.Println("Error calculating shipping cost:", err)
+	return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{key: "Failed to calculate shipping cost"})
+}
+
+// GetShippingRates mengembalikan pilihan kurir dan ongkos kirim untuk item ke alamat pembeli
+func GetShippingRates(c *fiber.Ctx) error {
+	var input struct {
+		AddressID string            `json:"address_id"`
+		Address   *model.Address    `json:"address"`
+		Items     []model.OrderItem `json:"items"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+		})
+	}
+	if len(input.Items) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Items are required",
+		})
+	}
+
+	address, err := resolveShippingAddress(context.Background(), currentUserID(c), input.AddressID, input.Address)
+	if err != nil {
+		return shippingAddressError(c, "message", err)
+	}
+
+	rates, err := quoteShipping(context.Background(), input.Items, *address)
+	if err != nil {
+		return shippingErrorResponse(c, "message", err)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Shipping rates fetched successfully",
+		"data":    rates,
+	})
+}
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"be_ecommerce/config"
+	"be_ecommerce/model"
+	"be_ecommerce/notifications"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var errInsufficientStock = errors.New("insufficient stock")
+
+// reserveStock memotong stok setiap item order dan mencatatnya di ledger sebagai reservasi.
+// Panggil di dalam transaksi agar potongan yang sudah terjadi dibatalkan bila salah satu
+// item kekurangan stok. Untuk item bervarian, stok varian dan total stok produk dipotong bersama.
+func reserveStock(ctx context.Context, order model.Order) error {
+	orderID, userID := order.ID, order.UserID
+	for _, item := range order.Items {
+		err := applyStockChange(ctx, stockChange{
+			ProductID: item.ProductID,
+			VariantID: item.VariantID,
+			Type:      model.MovementReservation,
+			Quantity:  item.Quantity,
+			Change:    -item.Quantity,
+			ActorID:   &userID,
+			ActorRole: "customer",
+			OrderID:   &orderID,
+		})
+		if err == errInsufficientStock {
+			if item.Variant != "" {
+				return fmt.Errorf("%w for %s (%s)", errInsufficientStock, item.Name, item.Variant)
+			}
+			return fmt.Errorf("%w for %s", errInsufficientStock, item.Name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// releaseStock mengembalikan stok item order yang dibatalkan dan mencatatnya di ledger
+func releaseStock(ctx context.Context, order model.Order, reason string) error {
+	orderID := order.ID
+	for _, item := range order.Items {
+		err := applyStockChange(ctx, stockChange{
+			ProductID: item.ProductID,
+			VariantID: item.VariantID,
+			Type:      model.MovementRelease,
+			Quantity:  item.Quantity,
+			Change:    item.Quantity,
+			Reason:    reason,
+			OrderID:   &orderID,
+		})
+		// Produk atau varian yang sudah dihapus tidak perlu dikembalikan stoknya
+		if err != nil && err != errInsufficientStock {
+			return err
+		}
+	}
+	return nil
+}
+
+// cancellableOrderStatuses adalah status order yang masih boleh dibatalkan karena belum dikirim
+var cancellableOrderStatuses = []string{"Pending", "Processing", "Confirmed"}
+
+// cancelPendingOrder membatalkan order yang masih Pending. Mengembalikan mongo.ErrNoDocuments jika order
+// sudah tidak Pending. Panggil di dalam config.WithTransaction.
+func cancelPendingOrder(ctx context.Context, orderID primitive.ObjectID, reason string) error {
+	return cancelOrder(ctx, orderID, []string{"Pending"}, reason)
+}
+
+// cancelOrder membatalkan order yang statusnya salah satu dari statuses, mengembalikan stok serta kuota
+// voucher dan flash sale-nya serta mengirim notifikasi in-app dan email pembatalan. Mengembalikan
+// mongo.ErrNoDocuments jika status order tidak cocok. Panggil di dalam config.WithTransaction.
+func cancelOrder(ctx context.Context, orderID primitive.ObjectID, statuses []string, reason string) error {
+	now := time.Now()
+	var order model.Order
+	collection := config.MongoClient.Database("ecommerce").Collection("orders")
+	err := collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": orderID, "status": bson.M{"$in": statuses}},
+		bson.M{"$set": bson.M{"status": "Cancelled", "cancelled_at": now, "cancel_reason": reason}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&order)
+	if err != nil {
+		return err
+	}
+
+	// Order lama dibuat sebelum stok dipotong saat checkout, jadi tidak ada yang dikembalikan
+	if order.StockReserved {
+		if err := releaseStock(ctx, order, reason); err != nil {
+			return err
+		}
+	}
+	if err := releaseFlashSale(ctx, order.UserID, order.Items); err != nil {
+		return err
+	}
+	if order.VoucherCode != "" {
+		if err := releaseVoucher(ctx, order.ID); err != nil {
+			return err
+		}
+	}
+	if err := pushOrderStatus(ctx, order); err != nil {
+		return err
+	}
+	return notifyOrder(ctx, order, notifications.TemplateOrderCancelled)
+}
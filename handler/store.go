@@ -39,9 +39,16 @@ func GetStoreDetails(c *fiber.Ctx) error {
 		})
 	}
 
+	// Toko milik seller yang disuspend tidak ditampilkan
+	if isSuspended(store) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "Store is not active",
+		})
+	}
+
 	// Ambil produk yang terkait dengan toko ini
 	productCollection := config.MongoClient.Database("ecommerce").Collection("products")
-	cursor, err := productCollection.Find(context.Background(), bson.M{"seller_id": objectID})
+	cursor, err := productCollection.Find(context.Background(), onlyActiveProducts(bson.M{"seller_id": objectID}, ""))
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"message": "Failed to fetch products",
@@ -55,6 +62,11 @@ func GetStoreDetails(c *fiber.Ctx) error {
 			"message": "Failed to parse products",
 		})
 	}
+	if err := withEffectivePrices(context.Background(), products); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to calculate product prices",
+		})
+	}
 
 	// Kembalikan detail toko dan produk
 	return c.JSON(fiber.Map{
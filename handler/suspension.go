@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"be_ecommerce/model"
+	"context"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// isSuspended mengecek apakah penangguhan akun masih berlaku
+func isSuspended(user model.User) bool {
+	if !user.Suspended {
+		return false
+	}
+	if user.Suspension == nil || user.Suspension.ExpiresAt == nil {
+		return true
+	}
+	return user.Suspension.ExpiresAt.After(time.Now())
+}
+
+// activeSuspensionFilter mencocokkan akun yang penangguhannya masih berlaku
+func activeSuspensionFilter() bson.M {
+	return bson.M{
+		"suspended": true,
+		"$or": []bson.M{
+			{"suspension.expires_at": nil},
+			{"suspension.expires_at": bson.M{"$gt": time.Now()}},
+		},
+	}
+}
+
+// liftExpiredSuspension menghapus penangguhan yang sudah melewati masa berlakunya
+func liftExpiredSuspension(ctx context.Context, user model.User) error {
+	if !user.Suspended || isSuspended(user) {
+		return nil
+	}
+	_, err := getUserCollection().UpdateOne(ctx,
+		bson.M{"_id": user.ID},
+		bson.M{"$set": bson.M{"suspended": false}, "$unset": bson.M{"suspension": ""}},
+	)
+	return err
+}
+
+// suspendedSellerIDs mengambil ID seller yang sedang disuspend,
+// dipakai untuk menyembunyikan produk mereka dari katalog
+func suspendedSellerIDs(ctx context.Context) ([]primitive.ObjectID, error) {
+	filter := activeSuspensionFilter()
+	filter["roles"] = "seller"
+
+	cursor, err := getUserCollection().Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	ids := []primitive.ObjectID{}
+	for cursor.Next(ctx) {
+		var user struct {
+			ID primitive.ObjectID `bson:"_id"`
+		}
+		if err := cursor.Decode(&user); err != nil {
+			return nil, err
+		}
+		ids = append(ids, user.ID)
+	}
+	return ids, cursor.Err()
+}
+
+// suspensionResponse menyusun payload error untuk akun yang disuspend
+func suspensionResponse(user model.User) fiber.Map {
+	response := fiber.Map{
+		"message": "Account suspended",
+	}
+	if user.Suspension != nil {
+		response["reason"] = user.Suspension.Reason
+		if user.Suspension.ExpiresAt != nil {
+			response["expires_at"] = user.Suspension.ExpiresAt
+		}
+	}
+	return response
+}
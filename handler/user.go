@@ -3,6 +3,7 @@ package handler
 import (
 	"be_ecommerce/config"
 	"be_ecommerce/model"
+	"be_ecommerce/notifications"
 	"be_ecommerce/utils"
 	"context"
 	"fmt"
@@ -116,10 +117,12 @@ func GetUserProfile(c *fiber.Ctx) error {
 	// Siapkan respons dengan data user (hapus password)
 	user.Password = ""
 	response := fiber.Map{
-		"id":       user.ID.Hex(),
-		"username": user.Username,
-		"email":    user.Email,
-		"roles":    user.Roles,
+		"id":             user.ID.Hex(),
+		"username":       user.Username,
+		"email":          user.Email,
+		"roles":          user.Roles,
+		"email_verified": isEmailVerified(user),
+		"language":       user.Language,
 	}
 
 	// Tambahkan `store_status` jika ada
@@ -183,6 +186,7 @@ func EditProfile(c *fiber.Ctx) error {
 	// Parsing data dari request body
 	var updatedData struct {
 		Username string `json:"username"`
+		Language string `json:"language"`
 	}
 	if err := c.BodyParser(&updatedData); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -197,14 +201,23 @@ func EditProfile(c *fiber.Ctx) error {
 		})
 	}
 
+	// Validasi bahasa email (opsional)
+	fields := bson.M{"username": updatedData.Username}
+	if updatedData.Language != "" {
+		if updatedData.Language != notifications.LangID && updatedData.Language != notifications.LangEN {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "Language must be 'id' or 'en'",
+			})
+		}
+		fields["language"] = updatedData.Language
+	}
+
 	// Update username di database
 	collection := config.MongoClient.Database("ecommerce").Collection("users")
 	_, err = collection.UpdateOne(
 		context.Background(),
 		bson.M{"_id": objectID},
-		bson.M{"$set": bson.M{
-			"username": updatedData.Username,
-		}},
+		bson.M{"$set": fields},
 	)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -230,40 +243,42 @@ func VerifyOTP(c *fiber.Ctx) error {
 		})
 	}
 
-	log.Println("Verifying OTP for email:", body.Email, "with token:", body.ResetToken)
-
-	// Cek token reset
-	collection := config.MongoClient.Database("ecommerce").Collection("users")
-	var user model.User
-	err := collection.FindOne(
-		context.Background(),
-		bson.M{"email": body.Email, "reset_token": body.ResetToken},
-	).Decode(&user)
+	log.Println("Verifying OTP for email:", body.Email)
 
+	// Cek OTP, percobaan gagal dibatasi
+	user, err := checkOTP(context.Background(), body.Email, otpPurposePasswordReset, body.ResetToken)
+	if err == nil {
+		// OTP hanya bisa dipakai sekali
+		err = consumeOTP(context.Background(), user, otpPurposePasswordReset)
+	}
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			log.Println("Invalid or expired token for email:", body.Email)
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"message": "Invalid OTP",
+		status := otpErrorStatus(err)
+		if status == fiber.StatusInternalServerError {
+			log.Println("Database error:", err)
+			return c.Status(status).JSON(fiber.Map{
+				"message": "Server error",
 			})
 		}
-		log.Println("Database error:", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Server error",
+		log.Println("OTP verification failed for email:", body.Email, "-", err)
+		return c.Status(status).JSON(fiber.Map{
+			"message": err.Error(),
 		})
 	}
 
-	// Periksa apakah token telah kedaluwarsa
-	if time.Now().After(user.ResetTokenExpiry) {
-		log.Println("OTP expired for email:", body.Email)
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"message": "OTP expired",
+	// Terbitkan izin sekali pakai untuk ResetPassword
+	grant, err := issueResetGrant(context.Background(), user.ID)
+	if err != nil {
+		log.Println("Error issuing reset grant:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Server error",
 		})
 	}
 
 	log.Println("OTP verified successfully for email:", body.Email)
 	return c.JSON(fiber.Map{
-		"message": "OTP verified successfully",
+		"message":     "OTP verified successfully",
+		"reset_grant": grant,
+		"expires_in":  int(otpTTL[otpPurposePasswordResetGrant].Seconds()),
 	})
 }
 
@@ -296,36 +311,33 @@ func SendPasswordResetEmail(c *fiber.Ctx) error {
 		})
 	}
 
-	// Generate OTP dan expiry time
-	resetToken := utils.GenerateRandomToken(6) // Contoh fungsi utilitas untuk membuat token OTP
-	expiry := time.Now().Add(10 * time.Minute) // OTP berlaku selama 10 menit
-
-	// Simpan OTP dan expiry ke database
-	_, err = collection.UpdateOne(
-		context.Background(),
-		bson.M{"email": body.Email},
-		bson.M{"$set": bson.M{
-			"reset_token":       resetToken,
-			"reset_token_expiry": expiry,
-		}},
-	)
-	if err != nil {
-		log.Println("Error saving OTP to database:", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Failed to save OTP",
+	// Generate OTP numerik (hanya hash-nya yang disimpan, berlaku selama 10 menit)
+	// dan titipkan email ke outbox dalam satu transaksi
+	err = config.WithTransaction(context.Background(), func(sessCtx mongo.SessionContext) error {
+		resetToken, err := issueOTP(sessCtx, user, otpPurposePasswordReset)
+		if err != nil {
+			return err
+		}
+		return notifyUser(sessCtx, user, notifications.TemplatePasswordReset, notifications.CodeEmail{
+			Name:           user.Username,
+			Code:           resetToken,
+			ExpiresMinutes: int(otpTTL[otpPurposePasswordReset].Minutes()),
 		})
-	}
-
-	// Kirim OTP ke email pengguna
-	err = utils.SendEmail(body.Email, "Password Reset", fmt.Sprintf("Your OTP: %s", resetToken))
+	})
 	if err != nil {
-		log.Println("Error sending email:", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Failed to send email",
+		status := otpErrorStatus(err)
+		if status == fiber.StatusInternalServerError {
+			log.Println("Error issuing password reset OTP:", err)
+			return c.Status(status).JSON(fiber.Map{
+				"message": "Failed to send email",
+			})
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"message": err.Error(),
 		})
 	}
 
-	log.Println("Password reset email sent successfully to:", body.Email)
+	log.Println("Password reset email queued for:", body.Email)
 	return c.JSON(fiber.Map{
 		"message": "Password reset email sent successfully",
 	})
@@ -335,7 +347,7 @@ func ResetPassword(c *fiber.Ctx) error {
 	// Parsing data dari request body
 	var body struct {
 		Email       string `json:"email"`
-		ResetToken  string `json:"reset_token"`
+		ResetGrant  string `json:"reset_grant"`
 		NewPassword string `json:"new_password"`
 	}
 	if err := c.BodyParser(&body); err != nil {
@@ -344,28 +356,26 @@ func ResetPassword(c *fiber.Ctx) error {
 		})
 	}
 
-	// Validasi reset token
-	collection := config.MongoClient.Database("ecommerce").Collection("users")
-	var user model.User
-	err := collection.FindOne(context.Background(), bson.M{
-		"email":       body.Email,
-		"reset_token": body.ResetToken,
-	}).Decode(&user)
-	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"message": "Invalid or expired reset token",
-			})
-		}
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Failed to verify reset token",
+	if body.NewPassword == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "New password is required",
 		})
 	}
 
-	// Cek apakah token sudah kedaluwarsa
-	if time.Now().After(user.ResetTokenExpiry) {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"message": "Reset token expired",
+	// Validasi izin reset dari VerifyOTP, izin langsung dihapus agar tidak bisa dipakai ulang
+	user, err := checkOTP(context.Background(), body.Email, otpPurposePasswordResetGrant, body.ResetGrant)
+	if err == nil {
+		err = consumeOTP(context.Background(), user, otpPurposePasswordResetGrant)
+	}
+	if err != nil {
+		status := otpErrorStatus(err)
+		if status == fiber.StatusInternalServerError {
+			return c.Status(status).JSON(fiber.Map{
+				"message": "Failed to verify reset grant",
+			})
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"message": "Invalid or expired reset grant",
 		})
 	}
 
@@ -377,14 +387,12 @@ func ResetPassword(c *fiber.Ctx) error {
 		})
 	}
 
-	// Update password dan hapus reset token
+	// Update password
+	collection := config.MongoClient.Database("ecommerce").Collection("users")
 	_, err = collection.UpdateOne(
 		context.Background(),
-		bson.M{"email": body.Email},
-		bson.M{
-			"$set": bson.M{"password": hashedPassword},
-			"$unset": bson.M{"reset_token": "", "reset_token_expiry": ""},
-		},
+		bson.M{"_id": user.ID},
+		bson.M{"$set": bson.M{"password": hashedPassword}},
 	)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -443,6 +451,13 @@ func CreateCustomer(c *fiber.Ctx) error {
 		"message": "Customer created successfully",
 	})
 }
+// customerEditableFields adalah field profil yang boleh diubah lewat UpdateCustomer
+var customerEditableFields = map[string]bool{
+	"username": true,
+	"email":    true,
+	"language": true,
+}
+
 func UpdateCustomer(c *fiber.Ctx) error {
 	// Parsing body request
 	var body struct {
@@ -470,14 +485,25 @@ func UpdateCustomer(c *fiber.Ctx) error {
 		})
 	}
 
-	// Validasi: pastikan tidak ada field sensitif yang diperbarui
-	disallowedFields := []string{"_id", "password", "roles"}
-	for _, field := range disallowedFields {
-		if _, exists := body.Updates[field]; exists {
+	// Validasi: hanya field profil yang boleh diperbarui. Field lain (password, roles, OTP,
+	// status verifikasi, penangguhan, termasuk path bertitik seperti one_time_codes.*) ditolak.
+	for field, value := range body.Updates {
+		if !customerEditableFields[field] {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 				"message": fmt.Sprintf("Field '%s' cannot be updated", field),
 			})
 		}
+		text, ok := value.(string)
+		if !ok || strings.TrimSpace(text) == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": fmt.Sprintf("Field '%s' must be a non-empty string", field),
+			})
+		}
+		if field == "language" && text != notifications.LangID && text != notifications.LangEN {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "Language must be 'id' or 'en'",
+			})
+		}
 	}
 
 	// Pastikan data yang akan diupdate tidak kosong
@@ -492,7 +518,46 @@ func UpdateCustomer(c *fiber.Ctx) error {
 	filter := bson.M{"_id": userID, "roles": "customer"}
 	update := bson.M{"$set": body.Updates}
 
-	result, err := collection.UpdateOne(context.Background(), filter, update)
+	// Email baru harus belum dipakai dan diverifikasi ulang; kode verifikasi dikirim ke email baru
+	newEmail, emailChanged := body.Updates["email"].(string)
+	if emailChanged {
+		var current model.User
+		if err := collection.FindOne(context.Background(), filter).Decode(&current); err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": "Customer not found or no changes made",
+			})
+		}
+		emailChanged = newEmail != current.Email
+	}
+	if emailChanged {
+		count, err := collection.CountDocuments(context.Background(), bson.M{"email": newEmail, "_id": bson.M{"$ne": userID}})
+		if err != nil {
+			log.Println("Error updating customer:", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": "Error updating customer",
+			})
+		}
+		if count > 0 {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"message": "Email already registered",
+			})
+		}
+		body.Updates["email_verified"] = false
+		update["$unset"] = bson.M{"one_time_codes." + otpPurposeEmailVerification: ""}
+	}
+
+	var result *mongo.UpdateResult
+	err = config.WithTransaction(context.Background(), func(sessCtx mongo.SessionContext) error {
+		var err error
+		if result, err = collection.UpdateOne(sessCtx, filter, update); err != nil || result.MatchedCount == 0 || !emailChanged {
+			return err
+		}
+		var user model.User
+		if err := collection.FindOne(sessCtx, bson.M{"_id": userID}).Decode(&user); err != nil {
+			return err
+		}
+		return sendVerificationEmail(sessCtx, user)
+	})
 	if err != nil {
 		log.Println("Error updating customer:", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -506,6 +571,11 @@ func UpdateCustomer(c *fiber.Ctx) error {
 			"message": "Customer not found or no changes made",
 		})
 	}
+	if emailChanged {
+		return c.JSON(fiber.Map{
+			"message": "Customer updated successfully, please verify the new email address",
+		})
+	}
 
 	return c.JSON(fiber.Map{
 		"message": "Customer updated successfully",
@@ -721,12 +791,7 @@ func GetSellerByID(c *fiber.Ctx) error {
 			}
 			return "unknown"
 		}(),
-		"suspended": func() bool {
-			if seller.StoreStatus != nil && *seller.StoreStatus == "suspended" {
-				return true
-			}
-			return false
-		}(),
+		"suspended": isSuspended(seller),
 	}
 
 	return c.JSON(response)
@@ -892,6 +957,19 @@ func UpdateProductForSeller(c *fiber.Ctx) error {
         }
     }
 
+    weight, dimensions, err := parseProductShipping(form)
+    if err != nil {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+            "message": err.Error(),
+        })
+    }
+    if weight != nil {
+        updateData["weight"] = *weight
+    }
+    if dimensions != nil {
+        updateData["dimensions"] = dimensions
+    }
+
     // **Update Image jika ada upload file baru**
     fileHeaders := form.File["image"]
     if len(fileHeaders) > 0 {
@@ -909,8 +987,45 @@ func UpdateProductForSeller(c *fiber.Ctx) error {
         updateData["image"] = imagePath
     }
 
+    // Harga dan stok produk bervarian diturunkan dari variannya
+    if len(existingProduct.Variants) > 0 {
+        delete(updateData, "price")
+        delete(updateData, "stock")
+    }
+
+    // Kategori dan atribut divalidasi terhadap pohon kategori
+    message, err := categoryUpdateFields(context.Background(), objectID, updateData, c.FormValue("attributes"))
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+            "message": "Failed to update product",
+            "error":   err.Error(),
+        })
+    }
+    if message != "" {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+            "message": message,
+        })
+    }
+
+    // Stok tidak ditimpa langsung, selisihnya dicatat di ledger sebagai adjustment
+    stock, stockChanged := updateData["stock"].(int)
+    delete(updateData, "stock")
+
     // Update produk di database
-    _, err = productCollection.UpdateOne(context.Background(), bson.M{"_id": objectID}, bson.M{"$set": updateData})
+    err = config.WithTransaction(context.Background(), func(sessCtx mongo.SessionContext) error {
+        if _, err := productCollection.UpdateOne(sessCtx, bson.M{"_id": objectID}, bson.M{"$set": updateData}); err != nil {
+            return err
+        }
+        if !stockChanged {
+            return nil
+        }
+        return setStock(sessCtx, existingProduct, stock, &sellerID, "seller")
+    })
+    if err == errInsufficientStock {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+            "message": "Stock must be 0 or greater",
+        })
+    }
     if err != nil {
         return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
             "message": "Failed to update product",
@@ -966,9 +1081,8 @@ func DeleteProductForSeller(c *fiber.Ctx) error {
         })
     }
 
-    // Hapus hanya jika produk milik seller
-    productCollection := config.MongoClient.Database("ecommerce").Collection("products")
-    result, err := productCollection.DeleteOne(context.Background(), bson.M{"_id": objectID, "seller_id": sellerID})
+    // Hapus hanya jika produk milik seller; produk diarsipkan (soft delete) karena masih direferensikan order lama
+    found, err := archiveProduct(context.Background(), bson.M{"_id": objectID, "seller_id": sellerID})
     if err != nil {
         return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
             "message": "Failed to delete product",
@@ -976,7 +1090,7 @@ func DeleteProductForSeller(c *fiber.Ctx) error {
         })
     }
 
-    if result.DeletedCount == 0 {
+    if !found {
         return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
             "message": "Forbidden: You do not have permission to delete this product",
         })
@@ -1110,122 +1224,142 @@ func DeleteCustomerSeller(c *fiber.Ctx) error {
 	})
 }
 
-// Suspend User Account
+// SuspendUser menangguhkan akun pengguna (customer maupun seller)
 func SuspendUser(c *fiber.Ctx) error {
-	id := c.Params("id")
-	userID, err := primitive.ObjectIDFromHex(id)
+	return suspendAccount(c, bson.M{}, "User account")
+}
+
+// UnsuspendUser mencabut penangguhan akun pengguna
+func UnsuspendUser(c *fiber.Ctx) error {
+	return unsuspendAccount(c, bson.M{}, "User account")
+}
+
+// SuspendSeller menangguhkan akun seller, produknya ikut disembunyikan dari katalog
+func SuspendSeller(c *fiber.Ctx) error {
+	return suspendAccount(c, bson.M{"roles": "seller"}, "Seller")
+}
+
+// UnsuspendSeller mencabut penangguhan akun seller
+func UnsuspendSeller(c *fiber.Ctx) error {
+	return unsuspendAccount(c, bson.M{"roles": "seller"}, "Seller")
+}
+
+func suspendAccount(c *fiber.Ctx, filter bson.M, label string) error {
+	objectID, err := primitive.ObjectIDFromHex(c.Params("id"))
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"message": "Invalid ID",
 		})
 	}
 
-	collection := getUserCollection()
-	_, err = collection.UpdateOne(context.Background(), bson.M{"_id": userID}, bson.M{"$set": bson.M{"suspended": true}})
-	if err != nil {
-		log.Println("Error suspending user:", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Error suspending user",
+	var request struct {
+		Reason    string     `json:"reason"`
+		ExpiresAt *time.Time `json:"expires_at"` // Kosongkan untuk penangguhan tanpa batas waktu
+	}
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
 		})
 	}
-
-	return c.JSON(fiber.Map{
-		"message": "User account suspended successfully",
-	})
-}
-
-func UnsuspendUser(c *fiber.Ctx) error {
-	id := c.Params("id")
-	userID, err := primitive.ObjectIDFromHex(id)
-	if err != nil {
+	if strings.TrimSpace(request.Reason) == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": "Invalid ID",
+			"message": "Suspension reason is required",
 		})
 	}
-
-	collection := getUserCollection()
-	_, err = collection.UpdateOne(context.Background(), bson.M{"_id": userID}, bson.M{"$set": bson.M{"suspended": false}})
-	if err != nil {
-		log.Println("Error unsuspending user:", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Error unsuspending user",
+	if request.ExpiresAt != nil && !request.ExpiresAt.After(time.Now()) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "expires_at must be in the future",
 		})
 	}
 
-	return c.JSON(fiber.Map{
-		"message": "User account unsuspended successfully",
-	})
-}
-func SuspendSeller(c *fiber.Ctx) error {
-	sellerID := c.Params("id")
-	log.Println("Suspend request received for seller ID:", sellerID)
+	// Admin yang melakukan penangguhan diambil dari middleware AdminOnly
+	adminID, _ := primitive.ObjectIDFromHex(fmt.Sprint(c.Locals("user_id")))
 
-	objectID, err := primitive.ObjectIDFromHex(sellerID)
-	if err != nil {
-		log.Println("Invalid seller ID format:", sellerID)
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": "Invalid seller ID format",
-		})
+	suspension := model.Suspension{
+		Reason:      request.Reason,
+		SuspendedBy: adminID,
+		SuspendedAt: time.Now(),
+		ExpiresAt:   request.ExpiresAt,
 	}
 
+	// Penangguhan dan email pemberitahuannya disimpan dalam satu transaksi
+	filter["_id"] = objectID
 	collection := getUserCollection()
-	filter := bson.M{"_id": objectID, "roles": bson.M{"$all": []string{"seller"}}}
-	update := bson.M{"$set": bson.M{"suspended": true}}
+	err = config.WithTransaction(context.Background(), func(sessCtx mongo.SessionContext) error {
+		var user model.User
+		err := collection.FindOneAndUpdate(
+			sessCtx,
+			filter,
+			bson.M{"$set": bson.M{"suspended": true, "suspension": suspension}},
+		).Decode(&user)
+		if err != nil {
+			return err
+		}
 
-	result, err := collection.UpdateOne(context.Background(), filter, update)
+		email := notifications.SuspensionEmail{Name: user.Username, Reason: suspension.Reason}
+		if suspension.ExpiresAt != nil {
+			email.Until = suspension.ExpiresAt.Format("02 Jan 2006 15:04 MST")
+		}
+		return notifyUser(sessCtx, user, notifications.TemplateAccountSuspended, email)
+	})
 	if err != nil {
-		log.Println("Error suspending seller:", err)
+		if err == mongo.ErrNoDocuments {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": label + " not found",
+			})
+		}
+		log.Println("Error suspending account:", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Failed to suspend seller",
-		})
-	}
-
-	if result.ModifiedCount == 0 {
-		log.Println("Seller not found or already suspended:", sellerID)
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"message": "Seller not found or already suspended",
+			"message": "Failed to suspend account",
 		})
 	}
 
-	log.Println("Seller suspended successfully:", sellerID)
+	log.Println("Account suspended:", objectID.Hex())
 	return c.JSON(fiber.Map{
-		"message": "Seller suspended successfully",
+		"message":    label + " suspended successfully",
+		"suspension": suspension,
 	})
 }
 
-func UnsuspendSeller(c *fiber.Ctx) error {
-	sellerID := c.Params("id")
-	log.Println("Received unsuspend request for seller ID:", sellerID)
-
-	objectID, err := primitive.ObjectIDFromHex(sellerID)
+func unsuspendAccount(c *fiber.Ctx, filter bson.M, label string) error {
+	objectID, err := primitive.ObjectIDFromHex(c.Params("id"))
 	if err != nil {
-		log.Println("Invalid ObjectID:", sellerID)
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": "Invalid seller ID format",
+			"message": "Invalid ID",
 		})
 	}
 
+	filter["_id"] = objectID
+	filter["suspended"] = true
 	collection := getUserCollection()
-	filter := bson.M{"_id": objectID, "roles": bson.M{"$all": []string{"seller"}}}
-	update := bson.M{"$set": bson.M{"suspended": false}}
+	err = config.WithTransaction(context.Background(), func(sessCtx mongo.SessionContext) error {
+		var user model.User
+		err := collection.FindOneAndUpdate(
+			sessCtx,
+			filter,
+			bson.M{"$set": bson.M{"suspended": false}, "$unset": bson.M{"suspension": ""}},
+		).Decode(&user)
+		if err != nil {
+			return err
+		}
 
-	result, err := collection.UpdateOne(context.Background(), filter, update)
+		email := notifications.SuspensionEmail{Name: user.Username}
+		return notifyUser(sessCtx, user, notifications.TemplateAccountReinstated, email)
+	})
 	if err != nil {
-		log.Println("Database error:", err)
+		if err == mongo.ErrNoDocuments {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": label + " not found or not suspended",
+			})
+		}
+		log.Println("Error unsuspending account:", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Failed to unsuspend seller",
-		})
-	}
-
-	if result.ModifiedCount == 0 {
-		log.Println("No matching seller found or already unsuspended:", sellerID)
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"message": "Seller not found or already unsuspended",
+			"message": "Failed to unsuspend account",
 		})
 	}
 
-	log.Println("Seller unsuspended successfully:", sellerID)
+	log.Println("Account unsuspended:", objectID.Hex())
 	return c.JSON(fiber.Map{
-		"message": "Seller unsuspended successfully",
+		"message": label + " unsuspended successfully",
 	})
 }
@@ -0,0 +1,397 @@
+package handler
+
+import (
+	"be_ecommerce/config"
+	"be_ecommerce/model"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	productUploadDir       = "./uploads/products"
+	maxVariantImageSize    = 5 * 1024 * 1024
+	maxProductOptions      = 3
+	maxProductVariantCount = 100
+)
+
+func getProductCollection() *mongo.Collection {
+	return config.MongoClient.Database("ecommerce").Collection("products")
+}
+
+// canManageProduct memeriksa apakah pengguna adalah admin atau seller pemilik produk
+func canManageProduct(c *fiber.Ctx, product model.Product) bool {
+	if role, _ := c.Locals("role").(string); role == "admin" {
+		return true
+	}
+	return product.SellerID == currentUserID(c)
+}
+
+// skipVariantManagedFields membuang price dan stock dari update produk bervarian, karena
+// keduanya diturunkan dari varian dan hanya diubah lewat SetProductVariants
+func skipVariantManagedFields(ctx context.Context, productID primitive.ObjectID, updateData bson.M) error {
+	count, err := getProductCollection().CountDocuments(ctx, bson.M{"_id": productID, "variants.0": bson.M{"$exists": true}})
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		delete(updateData, "price")
+		delete(updateData, "stock")
+	}
+	return nil
+}
+
+// normalizeVariants memvalidasi pilihan dan kombinasi varian. Setiap varian harus memiliki
+// tepat satu nilai untuk setiap pilihan dan tidak boleh ada kombinasi atau SKU ganda.
+// Varian tanpa ID (atau dengan ID yang tidak dikenal) mendapat ID baru. Mengembalikan pesan
+// kesalahan, atau string kosong jika valid.
+func normalizeVariants(existing model.Product, options []model.ProductOption, variants []model.ProductVariant) string {
+	if len(options) == 0 && len(variants) == 0 {
+		return ""
+	}
+	if len(options) == 0 || len(variants) == 0 {
+		return "Options and variants must be set together"
+	}
+	if len(options) > maxProductOptions {
+		return fmt.Sprintf("A product can have at most %d option types", maxProductOptions)
+	}
+	if len(variants) > maxProductVariantCount {
+		return fmt.Sprintf("A product can have at most %d variants", maxProductVariantCount)
+	}
+
+	values := make(map[string]map[string]bool, len(options))
+	for i, option := range options {
+		name := strings.TrimSpace(option.Name)
+		if name == "" || values[name] != nil {
+			return "Option names must be unique and not empty"
+		}
+		values[name] = map[string]bool{}
+		for j, value := range option.Values {
+			value = strings.TrimSpace(value)
+			if value == "" || values[name][value] {
+				return fmt.Sprintf("Values of option %s must be unique and not empty", name)
+			}
+			values[name][value] = true
+			options[i].Values[j] = value
+		}
+		if len(values[name]) == 0 {
+			return fmt.Sprintf("Option %s needs at least one value", name)
+		}
+		options[i].Name = name
+	}
+
+	known := map[primitive.ObjectID]model.ProductVariant{}
+	for _, variant := range existing.Variants {
+		known[variant.ID] = variant
+	}
+	combinations := map[string]bool{}
+	skus := map[string]bool{}
+	for i, variant := range variants {
+		if len(variant.Options) != len(options) {
+			return fmt.Sprintf("Variant %d must have a value for every option", i+1)
+		}
+		parts := make([]string, 0, len(options))
+		for _, option := range options {
+			value := strings.TrimSpace(variant.Options[option.Name])
+			if !values[option.Name][value] {
+				return fmt.Sprintf("Variant %d has an invalid value for %s", i+1, option.Name)
+			}
+			variant.Options[option.Name] = value
+			parts = append(parts, value)
+		}
+		combination := strings.Join(parts, "\x00")
+		if combinations[combination] {
+			return fmt.Sprintf("Variant %s is listed more than once", variant.Label(options))
+		}
+		combinations[combination] = true
+
+		variant.SKU = strings.TrimSpace(variant.SKU)
+		if variant.SKU == "" || skus[variant.SKU] {
+			return "Each variant needs a unique SKU"
+		}
+		skus[variant.SKU] = true
+		if variant.Price < 1 || variant.Stock < 0 {
+			return fmt.Sprintf("Variant %s needs a price above 0 and stock of at least 0", variant.Label(options))
+		}
+
+		// Gambar varian lama dipertahankan bila tidak dikirim ulang
+		if previous, ok := known[variant.ID]; !ok {
+			variant.ID = primitive.NewObjectID()
+		} else if variant.Image == "" {
+			variant.Image = previous.Image
+		}
+		variants[i] = variant
+	}
+	return ""
+}
+
+// errStockChanged menandakan stok produk berubah di antara pembacaan dan update varian
+var errStockChanged = errors.New("product stock changed")
+
+// SetProductVariants mengganti pilihan dan varian produk. Harga produk menjadi harga varian
+// termurah dan stok produk menjadi jumlah stok semua varian. Body kosong menghapus varian.
+func SetProductVariants(c *fiber.Ctx) error {
+	productID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid product ID",
+		})
+	}
+
+	var input struct {
+		Options  []model.ProductOption  `json:"options"`
+		Variants []model.ProductVariant `json:"variants"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+		})
+	}
+
+	collection := getProductCollection()
+	var product model.Product
+	if err := collection.FindOne(context.Background(), bson.M{"_id": productID}).Decode(&product); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "Product not found",
+		})
+	}
+	if !canManageProduct(c, product) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "You do not have permission to change this product",
+		})
+	}
+
+	if message := normalizeVariants(product, input.Options, input.Variants); message != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": message,
+		})
+	}
+
+	var update bson.M
+	if len(input.Variants) == 0 {
+		update = bson.M{"$unset": bson.M{"options": "", "variants": ""}}
+	} else {
+		price, stock := input.Variants[0].Price, 0
+		for _, variant := range input.Variants {
+			if variant.Price < price {
+				price = variant.Price
+			}
+			stock += variant.Stock
+		}
+		update = bson.M{"$set": bson.M{
+			"options":  input.Options,
+			"variants": input.Variants,
+			"price":    price,
+			"stock":    stock,
+		}}
+	}
+
+	// Selisih stok setiap varian dicatat di ledger sebagai adjustment. Produk dibaca ulang di dalam
+	// transaksi dan update dijaga dengan stok yang dibaca, agar order yang mengurangi stok di antara
+	// pembacaan dan update tidak tertimpa atau tercatat dengan selisih yang salah
+	actorID, role := actorFromCtx(c)
+	err = config.WithTransaction(context.Background(), func(sessCtx mongo.SessionContext) error {
+		var current model.Product
+		if err := collection.FindOne(sessCtx, bson.M{"_id": productID}).Decode(&current); err != nil {
+			return err
+		}
+		result, err := collection.UpdateOne(sessCtx, bson.M{"_id": productID, "stock": current.Stock}, update)
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return errStockChanged
+		}
+		for _, variant := range input.Variants {
+			previous, _ := current.Variant(variant.ID)
+			delta := variant.Stock - previous.Stock
+			if delta == 0 {
+				continue
+			}
+			variantID := variant.ID
+			quantity := delta
+			if quantity < 0 {
+				quantity = -quantity
+			}
+			err := recordMovement(sessCtx, product, stockChange{
+				VariantID: &variantID,
+				Type:      model.MovementAdjustment,
+				Quantity:  quantity,
+				Change:    delta,
+				Reason:    "Variants updated",
+				ActorID:   actorID,
+				ActorRole: role,
+			}, variant.Stock)
+			if err != nil {
+				return err
+			}
+		}
+
+		var updated model.Product
+		if err := collection.FindOne(sessCtx, bson.M{"_id": productID}).Decode(&updated); err != nil {
+			return err
+		}
+		return updateStockFlags(sessCtx, updated)
+	})
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"message": "SKU is already used by another of your products",
+			})
+		}
+		if errors.Is(err, errStockChanged) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"message": "Product stock changed while saving, please reload and try again",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to update variants",
+		})
+	}
+
+	if err := collection.FindOne(context.Background(), bson.M{"_id": productID}).Decode(&product); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch product",
+		})
+	}
+	return c.JSON(fiber.Map{
+		"message": "Variants updated successfully",
+		"data":    product,
+	})
+}
+
+// UploadVariantImage menyimpan gambar varian ke uploads/products/<product_id>
+func UploadVariantImage(c *fiber.Ctx) error {
+	productID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid product ID",
+		})
+	}
+	variantID, err := primitive.ObjectIDFromHex(c.Params("variant_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid variant ID",
+		})
+	}
+
+	collection := getProductCollection()
+	var product model.Product
+	if err := collection.FindOne(context.Background(), bson.M{"_id": productID}).Decode(&product); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "Product not found",
+		})
+	}
+	if !canManageProduct(c, product) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "You do not have permission to change this product",
+		})
+	}
+	if _, ok := product.Variant(variantID); !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "Variant not found",
+		})
+	}
+
+	file, err := c.FormFile("image")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Image is required",
+		})
+	}
+	ext := strings.ToLower(filepath.Ext(file.Filename))
+	if !chatImageExtensions[ext] || !strings.HasPrefix(file.Header.Get("Content-Type"), "image/") {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Unsupported image format",
+		})
+	}
+	if file.Size > maxVariantImageSize {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": fmt.Sprintf("Image must be smaller than %d MB", maxVariantImageSize/1024/1024),
+		})
+	}
+
+	dir := filepath.Join(productUploadDir, productID.Hex())
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to save image",
+		})
+	}
+	path := filepath.Join(dir, variantID.Hex()+ext)
+	if err := c.SaveFile(file, path); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to save image",
+		})
+	}
+
+	image := "/" + filepath.ToSlash(path)
+	_, err = collection.UpdateOne(context.Background(),
+		bson.M{"_id": productID, "variants._id": variantID},
+		bson.M{"$set": bson.M{"variants.$.image": image}},
+	)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to update variant",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Variant image uploaded successfully",
+		"image":   image,
+	})
+}
+
+// variantSelector menyusun data pemilih varian untuk detail produk: nilai setiap pilihan
+// beserta ketersediaannya, dan daftar varian dengan harga efektifnya
+func variantSelector(product model.Product, price model.EffectivePrice) fiber.Map {
+	if len(product.Variants) == 0 {
+		return nil
+	}
+
+	options := make([]fiber.Map, 0, len(product.Options))
+	for _, option := range product.Options {
+		values := make([]fiber.Map, 0, len(option.Values))
+		for _, value := range option.Values {
+			available := false
+			for _, variant := range product.Variants {
+				if variant.Options[option.Name] == value && variant.Stock > 0 {
+					available = true
+					break
+				}
+			}
+			values = append(values, fiber.Map{"value": value, "available": available})
+		}
+		options = append(options, fiber.Map{"name": option.Name, "values": values})
+	}
+
+	variants := make([]fiber.Map, 0, len(product.Variants))
+	for _, variant := range product.Variants {
+		image := variant.Image
+		if image == "" {
+			image = product.Image
+		}
+		variants = append(variants, fiber.Map{
+			"id":              variant.ID,
+			"sku":             variant.SKU,
+			"label":           variant.Label(product.Options),
+			"options":         variant.Options,
+			"price":           variant.Price,
+			"effective_price": variantEffectivePrice(price, variant.Price),
+			"stock":           variant.Stock,
+			"image":           image,
+		})
+	}
+
+	return fiber.Map{
+		"options":  options,
+		"variants": variants,
+	}
+}
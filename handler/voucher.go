@@ -0,0 +1,553 @@
+package handler
+
+import (
+	"be_ecommerce/config"
+	"be_ecommerce/model"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	errVoucherNotFound      = checkoutError{fiber.StatusNotFound, "Voucher not found"}
+	errVoucherLimitReached  = checkoutError{fiber.StatusConflict, "Voucher usage limit has been reached"}
+	errVoucherUserLimit     = checkoutError{fiber.StatusConflict, "You have reached the usage limit for this voucher"}
+	errVoucherNotApplicable = checkoutError{fiber.StatusUnprocessableEntity, "Voucher does not apply to the items in your cart"}
+)
+
+func getVoucherCollection() *mongo.Collection {
+	return config.MongoClient.Database("ecommerce").Collection("vouchers")
+}
+
+// voucher_usages menyimpan jumlah pemakaian voucher per pengguna: {voucher_id, user_id, count}
+func getVoucherUsageCollection() *mongo.Collection {
+	return config.MongoClient.Database("ecommerce").Collection("voucher_usages")
+}
+
+func getVoucherRedemptionCollection() *mongo.Collection {
+	return config.MongoClient.Database("ecommerce").Collection("voucher_redemptions")
+}
+
+func normalizeVoucherCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// voucherCovers memeriksa apakah produk termasuk cakupan voucher
+func voucherCovers(voucher model.Voucher, product model.Product) bool {
+	if voucher.SellerID != nil && product.SellerID != *voucher.SellerID {
+		return false
+	}
+	switch voucher.Scope {
+	case model.VoucherScopePlatform, model.VoucherScopeStore:
+		return true
+	case model.VoucherScopeCategory:
+		for _, id := range voucher.CategoryIDs {
+			if id == product.CategoryID || id == product.SubCategoryID {
+				return true
+			}
+			// Kategori perantara di pohon kategori
+			for _, step := range product.CategoryPath {
+				if id == step {
+					return true
+				}
+			}
+		}
+	case model.VoucherScopeProduct:
+		for _, id := range voucher.ProductIDs {
+			if id == product.ID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// applyVoucher memvalidasi voucher untuk pengguna dan item order lalu mengisi potongannya
+// ke pricing. Kuota dibaca tanpa dikunci; redeemVoucher memeriksanya ulang secara atomik.
+func applyVoucher(ctx context.Context, pricing *orderPricing, userID primitive.ObjectID, code string) error {
+	var voucher model.Voucher
+	err := getVoucherCollection().FindOne(ctx, bson.M{"code": normalizeVoucherCode(code)}).Decode(&voucher)
+	if err == mongo.ErrNoDocuments {
+		return errVoucherNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	switch {
+	case !voucher.Active:
+		return errVoucherNotFound
+	case now.Before(voucher.StartsAt):
+		return checkoutError{fiber.StatusUnprocessableEntity, "Voucher is not active yet"}
+	case !voucher.EndsAt.IsZero() && now.After(voucher.EndsAt):
+		return checkoutError{fiber.StatusUnprocessableEntity, "Voucher has expired"}
+	case voucher.UsageLimit > 0 && voucher.UsedCount >= voucher.UsageLimit:
+		return errVoucherLimitReached
+	}
+
+	if voucher.PerUserLimit > 0 {
+		var usage struct {
+			Count int `bson:"count"`
+		}
+		err := getVoucherUsageCollection().FindOne(ctx, bson.M{"voucher_id": voucher.ID, "user_id": userID}).Decode(&usage)
+		if err != nil && err != mongo.ErrNoDocuments {
+			return err
+		}
+		if usage.Count >= voucher.PerUserLimit {
+			return errVoucherUserLimit
+		}
+	}
+
+	// Minimal belanja dan potongan dihitung dari subtotal item yang tercakup voucher
+	eligible := 0
+	for _, item := range pricing.Items {
+		if voucherCovers(voucher, pricing.products[item.ProductID]) {
+			eligible += item.Price * item.Quantity
+		}
+	}
+	if eligible == 0 {
+		return errVoucherNotApplicable
+	}
+	if eligible < voucher.MinSpend {
+		return checkoutError{fiber.StatusUnprocessableEntity, fmt.Sprintf("Minimum spend of Rp%d is required for this voucher", voucher.MinSpend)}
+	}
+
+	pricing.Discount, pricing.ShippingDiscount = 0, 0
+	switch voucher.Type {
+	case model.VoucherPercentage:
+		pricing.Discount = eligible * voucher.Value / 100
+		if voucher.MaxDiscount > 0 && pricing.Discount > voucher.MaxDiscount {
+			pricing.Discount = voucher.MaxDiscount
+		}
+	case model.VoucherFixed:
+		pricing.Discount = voucher.Value
+		if pricing.Discount > eligible {
+			pricing.Discount = eligible
+		}
+	case model.VoucherFreeShipping:
+		pricing.ShippingDiscount = freeShippingDiscount(voucher, *pricing)
+	}
+
+	pricing.Voucher = &voucher
+	pricing.VoucherCode = voucher.Code
+	return nil
+}
+
+// freeShippingDiscount menghitung potongan voucher gratis ongkir: hanya ongkos paket dari toko
+// yang punya item tercakup voucher, dibatasi nilai voucher bila diisi
+func freeShippingDiscount(voucher model.Voucher, pricing orderPricing) int {
+	if pricing.Rate == nil {
+		return 0
+	}
+	covered := map[string]bool{}
+	for _, item := range pricing.Items {
+		if voucherCovers(voucher, pricing.products[item.ProductID]) {
+			covered[item.SellerID.Hex()] = true
+		}
+	}
+
+	discount := 0
+	for _, parcel := range pricing.Rate.Parcels {
+		if covered[parcel.Origin] {
+			discount += parcel.Cost
+		}
+	}
+	if discount > pricing.ShippingCost {
+		discount = pricing.ShippingCost
+	}
+	if voucher.Value > 0 && discount > voucher.Value {
+		discount = voucher.Value
+	}
+	return discount
+}
+
+// redeemVoucher mencatat pemakaian voucher oleh order. Panggil di dalam transaksi yang sama
+// dengan penyimpanan order agar kuota tidak terpakai oleh order yang gagal dibuat.
+func redeemVoucher(ctx context.Context, order model.Order, voucher *model.Voucher) error {
+	now := time.Now()
+	filter := bson.M{"_id": voucher.ID, "active": true, "starts_at": bson.M{"$lte": now}}
+	if !voucher.EndsAt.IsZero() {
+		filter["ends_at"] = bson.M{"$gt": now}
+	}
+	if voucher.UsageLimit > 0 {
+		filter["used_count"] = bson.M{"$lt": voucher.UsageLimit}
+	}
+	result, err := getVoucherCollection().UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"used_count": 1}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errVoucherLimitReached
+	}
+
+	// Jika kuota pengguna habis, filter tidak cocok dan upsert gagal karena index unik
+	// {voucher_id, user_id}; dengan begitu dua checkout bersamaan tidak bisa melewati batas
+	if voucher.PerUserLimit > 0 {
+		_, err := getVoucherUsageCollection().UpdateOne(ctx,
+			bson.M{"voucher_id": voucher.ID, "user_id": order.UserID, "count": bson.M{"$lt": voucher.PerUserLimit}},
+			bson.M{"$inc": bson.M{"count": 1}},
+			options.Update().SetUpsert(true),
+		)
+		if mongo.IsDuplicateKeyError(err) {
+			return errVoucherUserLimit
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = getVoucherRedemptionCollection().InsertOne(ctx, model.VoucherRedemption{
+		VoucherID:        voucher.ID,
+		Code:             voucher.Code,
+		UserID:           order.UserID,
+		OrderID:          order.ID,
+		Discount:         order.Discount,
+		ShippingDiscount: order.ShippingDiscount,
+		Status:           model.RedemptionApplied,
+		CreatedAt:        now,
+	})
+	return err
+}
+
+// releaseVoucher mengembalikan kuota voucher milik order yang dibatalkan
+func releaseVoucher(ctx context.Context, orderID primitive.ObjectID) error {
+	now := time.Now()
+	var redemption model.VoucherRedemption
+	err := getVoucherRedemptionCollection().FindOneAndUpdate(ctx,
+		bson.M{"order_id": orderID, "status": model.RedemptionApplied},
+		bson.M{"$set": bson.M{"status": model.RedemptionReleased, "released_at": now}},
+	).Decode(&redemption)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := getVoucherCollection().UpdateOne(ctx,
+		bson.M{"_id": redemption.VoucherID, "used_count": bson.M{"$gt": 0}},
+		bson.M{"$inc": bson.M{"used_count": -1}},
+	); err != nil {
+		return err
+	}
+	_, err = getVoucherUsageCollection().UpdateOne(ctx,
+		bson.M{"voucher_id": redemption.VoucherID, "user_id": redemption.UserID, "count": bson.M{"$gt": 0}},
+		bson.M{"$inc": bson.M{"count": -1}},
+	)
+	return err
+}
+
+// cartOrderItems mengubah item keranjang pengguna yang dipilih untuk checkout menjadi item order.
+// Item diperiksa dengan reviewCart seperti di GET /cart: item yang tidak tersedia (produk diarsipkan,
+// varian hilang, stok habis atau seller ditangguhkan) dilewati dan kuantitas dibatasi stok.
+func cartOrderItems(ctx context.Context, userID primitive.ObjectID) ([]model.OrderItem, error) {
+	var cart model.Cart
+	err := config.MongoClient.Database("ecommerce").Collection("carts").FindOne(ctx, bson.M{"user_id": userID.Hex()}).Decode(&cart)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	lines, _, err := reviewCart(ctx, &cart)
+	if err != nil {
+		return nil, err
+	}
+	return selectedOrderItems(lines), nil
+}
+
+// selectedOrderItems mengambil item keranjang yang dipilih dan dapat di-checkout sebagai item order
+func selectedOrderItems(lines []cartLine) []model.OrderItem {
+	items := make([]model.OrderItem, 0, len(lines))
+	for _, line := range lines {
+		if line.Item.Deselected || !line.Available {
+			continue
+		}
+		productID, err := primitive.ObjectIDFromHex(line.Item.ProductID)
+		if err != nil {
+			continue
+		}
+		item := model.OrderItem{ProductID: productID, Quantity: line.Item.Quantity}
+		if variantID, err := primitive.ObjectIDFromHex(line.Item.VariantID); err == nil {
+			item.VariantID = &variantID
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// ApplyVoucher memvalidasi kode voucher dan mengembalikan rincian harga keranjang setelah
+// potongan. Item diambil dari body atau dari keranjang pengguna; ongkos kirim ikut dihitung
+// bila address_id atau address dikirim.
+func ApplyVoucher(c *fiber.Ctx) error {
+	var input struct {
+		Code      string            `json:"code"`
+		AddressID string            `json:"address_id"`
+		Address   *model.Address    `json:"address"`
+		Courier   string            `json:"courier"`
+		Service   string            `json:"service"`
+		Items     []model.OrderItem `json:"items"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+		})
+	}
+	if normalizeVoucherCode(input.Code) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Voucher code is required",
+		})
+	}
+
+	userID := currentUserID(c)
+	items := input.Items
+	if len(items) == 0 {
+		var err error
+		items, err = cartOrderItems(context.Background(), userID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": "Failed to fetch cart",
+			})
+		}
+	}
+
+	var address *model.Address
+	if input.AddressID != "" || input.Address != nil {
+		var err error
+		address, err = resolveShippingAddress(context.Background(), userID, input.AddressID, input.Address)
+		if err != nil {
+			return shippingAddressError(c, "message", err)
+		}
+	}
+
+	pricing, err := priceOrder(context.Background(), userID, items, address, input.Courier, input.Service, input.Code)
+	if err != nil {
+		return pricingErrorResponse(c, "message", err)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Voucher applied successfully",
+		"data":    pricing,
+	})
+}
+
+// voucherManager mengembalikan ID toko jika pengguna adalah seller; admin mengelola semua voucher
+func voucherManager(c *fiber.Ctx) (isAdmin bool, sellerID primitive.ObjectID, ok bool) {
+	if role, _ := c.Locals("role").(string); role == "admin" {
+		return true, primitive.NilObjectID, true
+	}
+	var seller model.User
+	err := getUserCollection().FindOne(context.Background(), bson.M{"_id": currentUserID(c), "roles": "seller", "store_status": "approved"}).Decode(&seller)
+	if err != nil {
+		return false, primitive.NilObjectID, false
+	}
+	return false, seller.ID, true
+}
+
+// CreateVoucher membuat voucher baru. Admin bebas memilih cakupan; seller hanya dapat
+// membuat voucher toko atau produk miliknya sendiri.
+func CreateVoucher(c *fiber.Ctx) error {
+	isAdmin, sellerID, ok := voucherManager(c)
+	if !ok {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "Forbidden: Only admins and sellers can create vouchers",
+		})
+	}
+
+	var voucher model.Voucher
+	if err := c.BodyParser(&voucher); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+		})
+	}
+
+	voucher.Code = normalizeVoucherCode(voucher.Code)
+	if voucher.Scope == "" {
+		voucher.Scope = model.VoucherScopePlatform
+	}
+	if !isAdmin {
+		if voucher.Scope != model.VoucherScopeStore && voucher.Scope != model.VoucherScopeProduct {
+			voucher.Scope = model.VoucherScopeStore
+		}
+		voucher.SellerID = &sellerID
+	}
+	if voucher.Scope == model.VoucherScopeStore && voucher.SellerID == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "seller_id is required for store vouchers",
+		})
+	}
+	if message := validateVoucher(voucher); message != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": message,
+		})
+	}
+
+	// Seller hanya boleh memasukkan produk tokonya sendiri
+	if !isAdmin && voucher.Scope == model.VoucherScopeProduct {
+		count, err := config.MongoClient.Database("ecommerce").Collection("products").CountDocuments(context.Background(),
+			bson.M{"_id": bson.M{"$in": voucher.ProductIDs}, "seller_id": sellerID})
+		if err != nil || int(count) != len(voucher.ProductIDs) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"message": "Vouchers can only cover your own products",
+			})
+		}
+	}
+
+	voucher.ID = primitive.NewObjectID()
+	voucher.UsedCount = 0
+	voucher.Active = true
+	voucher.CreatedBy = currentUserID(c)
+	voucher.CreatedAt = time.Now()
+	if voucher.StartsAt.IsZero() {
+		voucher.StartsAt = voucher.CreatedAt
+	}
+
+	if _, err := getVoucherCollection().InsertOne(context.Background(), voucher); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"message": "Voucher code already exists",
+			})
+		}
+		log.Println("Error creating voucher:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to create voucher",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "Voucher created successfully",
+		"data":    voucher,
+	})
+}
+
+// validateVoucher mengembalikan pesan kesalahan, atau string kosong jika voucher valid
+func validateVoucher(voucher model.Voucher) string {
+	switch {
+	case voucher.Code == "":
+		return "Voucher code is required"
+	case voucher.Name == "":
+		return "Voucher name is required"
+	case voucher.Value < 0 || voucher.MinSpend < 0 || voucher.MaxDiscount < 0:
+		return "Voucher amounts must not be negative"
+	case voucher.UsageLimit < 0 || voucher.PerUserLimit < 0:
+		return "Usage limits must not be negative"
+	case !voucher.EndsAt.IsZero() && !voucher.EndsAt.After(voucher.StartsAt):
+		return "ends_at must be after starts_at"
+	}
+
+	switch voucher.Type {
+	case model.VoucherPercentage:
+		if voucher.Value < 1 || voucher.Value > 100 {
+			return "Percentage value must be between 1 and 100"
+		}
+	case model.VoucherFixed:
+		if voucher.Value < 1 {
+			return "Fixed discount value must be greater than 0"
+		}
+	case model.VoucherFreeShipping:
+	default:
+		return "Invalid voucher type"
+	}
+
+	switch voucher.Scope {
+	case model.VoucherScopePlatform, model.VoucherScopeStore:
+	case model.VoucherScopeCategory:
+		if len(voucher.CategoryIDs) == 0 {
+			return "category_ids is required for category vouchers"
+		}
+	case model.VoucherScopeProduct:
+		if len(voucher.ProductIDs) == 0 {
+			return "product_ids is required for product vouchers"
+		}
+	default:
+		return "Invalid voucher scope"
+	}
+	return ""
+}
+
+// GetVouchers mengembalikan semua voucher untuk admin atau voucher toko milik seller
+func GetVouchers(c *fiber.Ctx) error {
+	isAdmin, sellerID, ok := voucherManager(c)
+	if !ok {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "Forbidden: Only admins and sellers can manage vouchers",
+		})
+	}
+
+	filter := bson.M{}
+	if !isAdmin {
+		filter["seller_id"] = sellerID
+	}
+	cursor, err := getVoucherCollection().Find(context.Background(), filter, options.Find().SetSort(bson.M{"created_at": -1}))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch vouchers",
+		})
+	}
+	vouchers := []model.Voucher{}
+	if err := cursor.All(context.Background(), &vouchers); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to parse vouchers",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Vouchers fetched successfully",
+		"data":    vouchers,
+	})
+}
+
+// SetVoucherActive mengaktifkan atau menonaktifkan voucher: {"active": false}
+func SetVoucherActive(c *fiber.Ctx) error {
+	isAdmin, sellerID, ok := voucherManager(c)
+	if !ok {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "Forbidden: Only admins and sellers can manage vouchers",
+		})
+	}
+
+	voucherID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid voucher ID",
+		})
+	}
+	var input struct {
+		Active bool `json:"active"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+		})
+	}
+
+	filter := bson.M{"_id": voucherID}
+	if !isAdmin {
+		filter["seller_id"] = sellerID
+	}
+	result, err := getVoucherCollection().UpdateOne(context.Background(), filter, bson.M{"$set": bson.M{"active": input.Active}})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to update voucher",
+		})
+	}
+	if result.MatchedCount == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "Voucher not found",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Voucher updated successfully",
+	})
+}
@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"be_ecommerce/model"
+	"be_ecommerce/shipping"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestValidateVoucher(t *testing.T) {
+	start := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	valid := func() model.Voucher {
+		return model.Voucher{
+			Code:     "HEMAT10",
+			Name:     "Hemat 10%",
+			Type:     model.VoucherPercentage,
+			Value:    10,
+			Scope:    model.VoucherScopePlatform,
+			StartsAt: start,
+			EndsAt:   start.Add(24 * time.Hour),
+		}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(v *model.Voucher)
+		want   string
+	}{
+		{"valid percentage", func(v *model.Voucher) {}, ""},
+		{"missing code", func(v *model.Voucher) { v.Code = "" }, "Voucher code is required"},
+		{"missing name", func(v *model.Voucher) { v.Name = "" }, "Voucher name is required"},
+		{"negative min spend", func(v *model.Voucher) { v.MinSpend = -1 }, "Voucher amounts must not be negative"},
+		{"negative usage limit", func(v *model.Voucher) { v.PerUserLimit = -1 }, "Usage limits must not be negative"},
+		{"ends before start", func(v *model.Voucher) { v.EndsAt = start }, "ends_at must be after starts_at"},
+		{"no end date", func(v *model.Voucher) { v.EndsAt = time.Time{} }, ""},
+		{"percentage over 100", func(v *model.Voucher) { v.Value = 101 }, "Percentage value must be between 1 and 100"},
+		{"fixed zero", func(v *model.Voucher) { v.Type, v.Value = model.VoucherFixed, 0 }, "Fixed discount value must be greater than 0"},
+		{"free shipping without cap", func(v *model.Voucher) { v.Type, v.Value = model.VoucherFreeShipping, 0 }, ""},
+		{"unknown type", func(v *model.Voucher) { v.Type = "cashback" }, "Invalid voucher type"},
+		{"category scope without categories", func(v *model.Voucher) { v.Scope = model.VoucherScopeCategory }, "category_ids is required for category vouchers"},
+		{"product scope without products", func(v *model.Voucher) { v.Scope = model.VoucherScopeProduct }, "product_ids is required for product vouchers"},
+		{"unknown scope", func(v *model.Voucher) { v.Scope = "global" }, "Invalid voucher scope"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			voucher := valid()
+			tt.modify(&voucher)
+			if got := validateVoucher(voucher); got != tt.want {
+				t.Errorf("validateVoucher() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFreeShippingDiscount(t *testing.T) {
+	sellerA, sellerB := primitive.NewObjectID(), primitive.NewObjectID()
+	productA, productB := primitive.NewObjectID(), primitive.NewObjectID()
+
+	pricing := orderPricing{
+		Items: []model.OrderItem{
+			{ProductID: productA, SellerID: sellerA},
+			{ProductID: productB, SellerID: sellerB},
+		},
+		ShippingCost: 30000,
+		Rate: &shipping.Rate{Cost: 30000, Parcels: []shipping.ParcelCost{
+			{Origin: sellerA.Hex(), Cost: 12000},
+			{Origin: sellerB.Hex(), Cost: 18000},
+		}},
+		products: map[primitive.ObjectID]model.Product{
+			productA: {ID: productA, SellerID: sellerA},
+			productB: {ID: productB, SellerID: sellerB},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		voucher model.Voucher
+		want    int
+	}{
+		{"platform voucher covers every parcel", model.Voucher{Scope: model.VoucherScopePlatform}, 30000},
+		{"store voucher covers its own parcel", model.Voucher{Scope: model.VoucherScopeStore, SellerID: &sellerA}, 12000},
+		{"product voucher covers the product's seller", model.Voucher{Scope: model.VoucherScopeProduct, ProductIDs: []primitive.ObjectID{productB}}, 18000},
+		{"capped by voucher value", model.Voucher{Scope: model.VoucherScopePlatform, Value: 20000}, 20000},
+		{"no covered items", model.Voucher{Scope: model.VoucherScopeProduct, ProductIDs: []primitive.ObjectID{primitive.NewObjectID()}}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := freeShippingDiscount(tt.voucher, pricing); got != tt.want {
+				t.Errorf("freeShippingDiscount() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
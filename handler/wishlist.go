@@ -0,0 +1,772 @@
+package handler
+
+import (
+	"be_ecommerce/config"
+	"be_ecommerce/model"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// Batas jumlah wishlist per pengguna dan produk per wishlist
+	maxWishlists     = 20
+	maxWishlistItems = 200
+
+	defaultWishlistName = "Favorites"
+)
+
+func getWishlistCollection() *mongo.Collection {
+	return config.MongoClient.Database("ecommerce").Collection("wishlists")
+}
+
+// defaultWishlist mengambil wishlist default pengguna, dibuat bila belum ada. Dua request yang
+// bersamaan bisa sama-sama mencoba membuatnya; yang kalah mendapat duplicate key dari index unik
+// lalu mengulang sekali dan menemukan wishlist yang sudah dibuat.
+func defaultWishlist(ctx context.Context, userID primitive.ObjectID) (model.Wishlist, error) {
+	var wishlist model.Wishlist
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		now := time.Now()
+		err = getWishlistCollection().FindOneAndUpdate(ctx,
+			bson.M{"user_id": userID, "is_default": true},
+			bson.M{"$setOnInsert": bson.M{
+				"_id":        primitive.NewObjectID(),
+				"name":       defaultWishlistName,
+				"items":      []model.WishlistItem{},
+				"created_at": now,
+				"updated_at": now,
+			}},
+			options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+		).Decode(&wishlist)
+		if !mongo.IsDuplicateKeyError(err) {
+			break
+		}
+	}
+	return wishlist, err
+}
+
+// findWishlist mengambil wishlist milik pengguna berdasarkan parameter :id, "default" untuk wishlist default
+func findWishlist(c *fiber.Ctx) (model.Wishlist, error) {
+	userID := currentUserID(c)
+	if c.Params("id") == "default" {
+		return defaultWishlist(c.Context(), userID)
+	}
+	wishlistID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return model.Wishlist{}, mongo.ErrNoDocuments
+	}
+	var wishlist model.Wishlist
+	err = getWishlistCollection().FindOne(c.Context(), bson.M{"_id": wishlistID, "user_id": userID}).Decode(&wishlist)
+	return wishlist, err
+}
+
+// wishlistNotFound menulis response untuk kesalahan findWishlist
+func wishlistNotFound(c *fiber.Ctx, err error) error {
+	if err == mongo.ErrNoDocuments {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "Wishlist not found",
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"message": "Failed to fetch wishlist",
+	})
+}
+
+// wishlistItemFilter mencocokkan item wishlist berdasarkan produk dan varian (nil untuk item tanpa varian)
+func wishlistItemFilter(productID primitive.ObjectID, variantID *primitive.ObjectID) bson.M {
+	if variantID == nil {
+		return bson.M{"product_id": productID, "variant_id": nil}
+	}
+	return bson.M{"product_id": productID, "variant_id": *variantID}
+}
+
+// wishlistItemState mengembalikan harga efektif dan status stok item wishlist saat ini
+func wishlistItemState(product model.Product, variantID *primitive.ObjectID, price model.EffectivePrice) (int, bool) {
+	if variantID != nil {
+		if variant, ok := product.Variant(*variantID); ok {
+			return variantEffectivePrice(price, variant.Price).Price, variant.Stock <= 0
+		}
+	}
+	return price.Price, product.OutOfStock || product.Stock <= 0
+}
+
+// parseVariantID membaca variant_id opsional dari request
+func parseVariantID(value string) (*primitive.ObjectID, error) {
+	if value == "" {
+		return nil, nil
+	}
+	variantID, err := primitive.ObjectIDFromHex(value)
+	if err != nil {
+		return nil, err
+	}
+	return &variantID, nil
+}
+
+// GetWishlists mengembalikan semua wishlist pengguna (default lebih dulu) beserta detail produk
+// dan harga efektifnya
+func GetWishlists(c *fiber.Ctx) error {
+	userID := currentUserID(c)
+	if _, err := defaultWishlist(c.Context(), userID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch wishlists",
+		})
+	}
+
+	cursor, err := getWishlistCollection().Find(c.Context(), bson.M{"user_id": userID},
+		options.Find().SetSort(bson.D{{Key: "is_default", Value: -1}, {Key: "created_at", Value: 1}}))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch wishlists",
+		})
+	}
+	wishlists := []model.Wishlist{}
+	if err := cursor.All(c.Context(), &wishlists); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to parse wishlists",
+		})
+	}
+
+	// Muat semua produk sekaligus lalu pasangkan ke item
+	var productIDs []primitive.ObjectID
+	for _, wishlist := range wishlists {
+		for _, item := range wishlist.Items {
+			productIDs = append(productIDs, item.ProductID)
+		}
+	}
+	products := []model.Product{}
+	if len(productIDs) > 0 {
+		cursor, err := getProductCollection().Find(c.Context(), bson.M{"_id": bson.M{"$in": productIDs}})
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": "Failed to fetch products",
+			})
+		}
+		if err := cursor.All(c.Context(), &products); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": "Failed to parse products",
+			})
+		}
+		if err := withEffectivePrices(c.Context(), products); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": "Failed to calculate product prices",
+			})
+		}
+	}
+	byID := map[primitive.ObjectID]*model.Product{}
+	for i := range products {
+		byID[products[i].ID] = &products[i]
+	}
+	for i := range wishlists {
+		for j := range wishlists[i].Items {
+			wishlists[i].Items[j].Product = byID[wishlists[i].Items[j].ProductID]
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Wishlists fetched successfully",
+		"data":    wishlists,
+	})
+}
+
+// CreateWishlist membuat wishlist bernama baru: {"name": "..."}
+func CreateWishlist(c *fiber.Ctx) error {
+	var input struct {
+		Name string `json:"name"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+		})
+	}
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" || len(input.Name) > 50 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Name is required and must be at most 50 characters",
+		})
+	}
+
+	// Wishlist default dibuat lebih dulu agar namanya tidak terpakai wishlist lain
+	userID := currentUserID(c)
+	if _, err := defaultWishlist(c.Context(), userID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to create wishlist",
+		})
+	}
+	count, err := getWishlistCollection().CountDocuments(c.Context(), bson.M{"user_id": userID})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to create wishlist",
+		})
+	}
+	if count >= maxWishlists {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": fmt.Sprintf("You can have at most %d wishlists", maxWishlists),
+		})
+	}
+
+	now := time.Now()
+	wishlist := model.Wishlist{
+		ID:        primitive.NewObjectID(),
+		UserID:    userID,
+		Name:      input.Name,
+		Items:     []model.WishlistItem{},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	_, err = getWishlistCollection().InsertOne(c.Context(), wishlist)
+	if mongo.IsDuplicateKeyError(err) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"message": "A wishlist with this name already exists",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to create wishlist",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "Wishlist created successfully",
+		"data":    wishlist,
+	})
+}
+
+// RenameWishlist mengganti nama wishlist: {"name": "..."}
+func RenameWishlist(c *fiber.Ctx) error {
+	var input struct {
+		Name string `json:"name"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+		})
+	}
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" || len(input.Name) > 50 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Name is required and must be at most 50 characters",
+		})
+	}
+
+	wishlist, err := findWishlist(c)
+	if err != nil {
+		return wishlistNotFound(c, err)
+	}
+	_, err = getWishlistCollection().UpdateOne(c.Context(), bson.M{"_id": wishlist.ID}, bson.M{"$set": bson.M{
+		"name":       input.Name,
+		"updated_at": time.Now(),
+	}})
+	if mongo.IsDuplicateKeyError(err) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"message": "A wishlist with this name already exists",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to rename wishlist",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Wishlist renamed successfully",
+	})
+}
+
+// DeleteWishlist menghapus wishlist beserta isinya. Wishlist default tidak dapat dihapus.
+func DeleteWishlist(c *fiber.Ctx) error {
+	wishlist, err := findWishlist(c)
+	if err != nil {
+		return wishlistNotFound(c, err)
+	}
+	if wishlist.IsDefault {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "The default wishlist cannot be deleted",
+		})
+	}
+	if _, err := getWishlistCollection().DeleteOne(c.Context(), bson.M{"_id": wishlist.ID}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to delete wishlist",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Wishlist deleted successfully",
+	})
+}
+
+// AddWishlistItem menambahkan produk ke wishlist: {"product_id": "...", "variant_id": "..."}.
+// variant_id opsional, tanpa varian seluruh produk yang disimpan.
+func AddWishlistItem(c *fiber.Ctx) error {
+	var input struct {
+		ProductID string `json:"product_id"`
+		VariantID string `json:"variant_id"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+		})
+	}
+	productID, err := primitive.ObjectIDFromHex(input.ProductID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid product ID",
+		})
+	}
+	variantID, err := parseVariantID(input.VariantID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid variant ID",
+		})
+	}
+
+	var product model.Product
+	err = getProductCollection().FindOne(c.Context(), onlyActiveProducts(bson.M{"_id": productID}, "")).Decode(&product)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "Product not found",
+		})
+	}
+	if variantID != nil {
+		if _, ok := product.Variant(*variantID); !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "Invalid variant",
+			})
+		}
+	}
+
+	wishlist, err := findWishlist(c)
+	if err != nil {
+		return wishlistNotFound(c, err)
+	}
+	for _, item := range wishlist.Items {
+		if item.ProductID == productID && ((item.VariantID == nil && variantID == nil) || (item.VariantID != nil && variantID != nil && *item.VariantID == *variantID)) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"message": "Product already in wishlist",
+			})
+		}
+	}
+	if len(wishlist.Items) >= maxWishlistItems {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": fmt.Sprintf("A wishlist can hold at most %d products", maxWishlistItems),
+		})
+	}
+
+	prices, err := effectivePrices(c.Context(), []model.Product{product})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to calculate product price",
+		})
+	}
+	price, outOfStock := wishlistItemState(product, variantID, prices[product.ID])
+	now := time.Now()
+	item := model.WishlistItem{
+		ProductID:      productID,
+		VariantID:      variantID,
+		PriceWhenAdded: price,
+		LastPrice:      price,
+		OutOfStock:     outOfStock,
+		AddedAt:        now,
+	}
+
+	// Filter mengulang pemeriksaan duplikat dan batas isi agar request bersamaan tidak melewatinya
+	result, err := getWishlistCollection().UpdateOne(c.Context(),
+		bson.M{
+			"_id":   wishlist.ID,
+			"items": bson.M{"$not": bson.M{"$elemMatch": wishlistItemFilter(productID, variantID)}},
+			fmt.Sprintf("items.%d", maxWishlistItems-1): bson.M{"$exists": false},
+		},
+		bson.M{
+			"$push": bson.M{"items": item},
+			"$set":  bson.M{"updated_at": now},
+		},
+	)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to add product to wishlist",
+		})
+	}
+	if result.MatchedCount == 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"message": "Wishlist was changed, please try again",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "Product added to wishlist successfully",
+	})
+}
+
+// RemoveWishlistItem menghapus produk dari wishlist. ?variant_id= untuk item bervarian.
+func RemoveWishlistItem(c *fiber.Ctx) error {
+	productID, err := primitive.ObjectIDFromHex(c.Params("product_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid product ID",
+		})
+	}
+	variantID, err := parseVariantID(c.Query("variant_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid variant ID",
+		})
+	}
+
+	wishlist, err := findWishlist(c)
+	if err != nil {
+		return wishlistNotFound(c, err)
+	}
+	result, err := getWishlistCollection().UpdateOne(c.Context(),
+		bson.M{"_id": wishlist.ID, "items": bson.M{"$elemMatch": wishlistItemFilter(productID, variantID)}},
+		bson.M{
+			"$pull": bson.M{"items": wishlistItemFilter(productID, variantID)},
+			"$set":  bson.M{"updated_at": time.Now()},
+		},
+	)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to remove product from wishlist",
+		})
+	}
+	if result.MatchedCount == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "Product not in wishlist",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Product removed from wishlist successfully",
+	})
+}
+
+// MoveWishlistItemToCart memindahkan produk dari wishlist ke keranjang:
+// {"variant_id": "...", "quantity": 1}. ?variant_id= menunjuk item wishlist bervarian;
+// variant_id di body dipakai bila produk disimpan tanpa memilih varian. Seperti penggabungan
+// keranjang tamu, kuantitas di keranjang dibatasi stok yang tersedia.
+func MoveWishlistItemToCart(c *fiber.Ctx) error {
+	productID, err := primitive.ObjectIDFromHex(c.Params("product_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid product ID",
+		})
+	}
+	variantID, err := parseVariantID(c.Query("variant_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid variant ID",
+		})
+	}
+	var input struct {
+		VariantID string `json:"variant_id"`
+		Quantity  int    `json:"quantity"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&input); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "Invalid request body",
+			})
+		}
+	}
+
+	wishlist, err := findWishlist(c)
+	if err != nil {
+		return wishlistNotFound(c, err)
+	}
+
+	cartItem := model.CartItem{ProductID: productID.Hex(), VariantID: input.VariantID, Quantity: input.Quantity}
+	if variantID != nil {
+		cartItem.VariantID = variantID.Hex()
+	}
+	owner := cartOwner{UserID: currentUserID(c).Hex()}
+	err = config.WithTransaction(c.Context(), func(sessCtx mongo.SessionContext) error {
+		result, err := getWishlistCollection().UpdateOne(sessCtx,
+			bson.M{"_id": wishlist.ID, "items": bson.M{"$elemMatch": wishlistItemFilter(productID, variantID)}},
+			bson.M{
+				"$pull": bson.M{"items": wishlistItemFilter(productID, variantID)},
+				"$set":  bson.M{"updated_at": time.Now()},
+			},
+		)
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return checkoutError{fiber.StatusNotFound, "Product not in wishlist"}
+		}
+		item := cartItem
+		if item.Quantity, err = cappedCartQuantity(sessCtx, owner, item); err != nil {
+			return err
+		}
+		return addToCart(sessCtx, owner, item)
+	})
+	if err != nil {
+		return cartErrorResponse(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Product moved to cart successfully",
+	})
+}
+
+// cappedCartQuantity menghitung kuantitas yang boleh ditambahkan agar jumlahnya di keranjang tidak
+// melebihi stok produk atau varian. Produk atau varian yang tidak valid dibiarkan ditolak addToCart.
+func cappedCartQuantity(ctx context.Context, owner cartOwner, item model.CartItem) (int, error) {
+	quantity := item.Quantity
+	if quantity < 1 {
+		quantity = 1
+	}
+	productID, err := primitive.ObjectIDFromHex(item.ProductID)
+	if err != nil {
+		return quantity, nil
+	}
+	var product model.Product
+	if err := getProductCollection().FindOne(ctx, bson.M{"_id": productID}).Decode(&product); err != nil {
+		return quantity, nil
+	}
+	stock := product.Stock
+	if len(product.Variants) > 0 {
+		variantID, err := primitive.ObjectIDFromHex(item.VariantID)
+		if err != nil {
+			return quantity, nil
+		}
+		variant, ok := product.Variant(variantID)
+		if !ok {
+			return quantity, nil
+		}
+		stock = variant.Stock
+	} else {
+		item.VariantID = ""
+	}
+	if stock <= 0 {
+		return quantity, nil
+	}
+
+	var cart model.Cart
+	err = getCartCollection().FindOne(ctx, owner.filter()).Decode(&cart)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return 0, err
+	}
+	current := 0
+	if i := cartItemIndex(cart.Products, item.ProductID, item.VariantID); i >= 0 {
+		current = cart.Products[i].Quantity
+	}
+	if current >= stock {
+		return 0, checkoutError{fiber.StatusConflict, fmt.Sprintf("Your cart already has all %d available items of this product", stock)}
+	}
+	return minInt(quantity, stock-current), nil
+}
+
+// sendWishlistAlerts memberi tahu pengguna bila harga efektif produk di wishlist turun atau
+// produk yang habis tersedia kembali. Setiap item menyimpan harga dan status stok terakhir
+// yang diketahui sehingga alert yang sama tidak dikirim dua kali.
+func sendWishlistAlerts(ctx context.Context) error {
+	cursor, err := getWishlistCollection().Find(ctx, bson.M{"items.0": bson.M{"$exists": true}},
+		options.Find().SetSort(bson.D{{Key: "user_id", Value: 1}}).SetBatchSize(jobBatchSize))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	// Produk yang sama di beberapa wishlist seorang pengguna hanya diberi tahu sekali
+	var currentUser primitive.ObjectID
+	alerted := map[string]bool{}
+
+	batch := make([]model.Wishlist, 0, jobBatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		defer func() { batch = batch[:0] }()
+
+		var productIDs []primitive.ObjectID
+		for _, wishlist := range batch {
+			for _, item := range wishlist.Items {
+				productIDs = append(productIDs, item.ProductID)
+			}
+		}
+		cursor, err := getProductCollection().Find(ctx, onlyActiveProducts(bson.M{"_id": bson.M{"$in": productIDs}}, ""))
+		if err != nil {
+			return err
+		}
+		var products []model.Product
+		if err := cursor.All(ctx, &products); err != nil {
+			return err
+		}
+		prices, err := effectivePrices(ctx, products)
+		if err != nil {
+			return err
+		}
+		byID := map[primitive.ObjectID]model.Product{}
+		for _, product := range products {
+			byID[product.ID] = product
+		}
+
+		for _, wishlist := range batch {
+			if wishlist.UserID != currentUser {
+				currentUser = wishlist.UserID
+				alerted = map[string]bool{}
+			}
+			if err := alertWishlist(ctx, wishlist, byID, prices, alerted); err != nil {
+				log.Printf("Error sending wishlist alerts for %s: %v", wishlist.ID.Hex(), err)
+			}
+		}
+		return nil
+	}
+
+	for cursor.Next(ctx) {
+		var wishlist model.Wishlist
+		if err := cursor.Decode(&wishlist); err != nil {
+			return err
+		}
+		batch = append(batch, wishlist)
+		if len(batch) == jobBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+	return flush()
+}
+
+// alertWishlist membandingkan setiap item satu wishlist dengan keadaan produk saat ini,
+// menyimpan keadaan baru dan mengirim notifikasi dalam satu transaksi
+func alertWishlist(ctx context.Context, wishlist model.Wishlist, products map[primitive.ObjectID]model.Product, prices map[primitive.ObjectID]model.EffectivePrice, alerted map[string]bool) error {
+	return config.WithTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		for _, item := range wishlist.Items {
+			product, ok := products[item.ProductID]
+			if !ok {
+				continue // Produk dihapus atau tidak aktif
+			}
+			price, outOfStock := wishlistItemState(product, item.VariantID, prices[product.ID])
+			if price == item.LastPrice && outOfStock == item.OutOfStock {
+				continue
+			}
+
+			filter := wishlistItemFilter(item.ProductID, item.VariantID)
+			arrayFilter := bson.M{}
+			for key, value := range filter {
+				arrayFilter["item."+key] = value
+			}
+			_, err := getWishlistCollection().UpdateOne(sessCtx,
+				bson.M{"_id": wishlist.ID},
+				bson.M{"$set": bson.M{"items.$[item].last_price": price, "items.$[item].out_of_stock": outOfStock}},
+				options.Update().SetArrayFilters(options.ArrayFilters{Filters: []interface{}{arrayFilter}}),
+			)
+			if err != nil {
+				return err
+			}
+
+			key := item.ProductID.Hex()
+			if item.VariantID != nil {
+				key += "/" + item.VariantID.Hex()
+			}
+			data := map[string]string{"product_id": item.ProductID.Hex(), "wishlist_id": wishlist.ID.Hex()}
+			if item.VariantID != nil {
+				data["variant_id"] = item.VariantID.Hex()
+			}
+
+			var title, body string
+			switch {
+			case outOfStock:
+				continue
+			case item.OutOfStock:
+				title = "Back in stock"
+				body = fmt.Sprintf("%s from your wishlist is available again", product.Name)
+			case price < item.LastPrice:
+				title = "Price drop"
+				body = fmt.Sprintf("%s from your wishlist is now Rp%d (was Rp%d)", product.Name, price, item.LastPrice)
+			default:
+				continue
+			}
+			if alerted[key] {
+				continue
+			}
+			alerted[key] = true
+			if err := pushNotification(sessCtx, wishlist.UserID, model.NotificationWishlistAlert, title, body, data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// MigrateFavorites memindahkan daftar favorit lama (koleksi favorites) ke wishlist default
+// pemiliknya. Aman dijalankan berulang kali; dokumen favorit dihapus setelah dipindahkan.
+func MigrateFavorites(ctx context.Context) error {
+	collection := config.MongoClient.Database("ecommerce").Collection("favorites")
+	cursor, err := collection.Find(ctx, bson.M{})
+	if err != nil {
+		return err
+	}
+	var favorites []model.Favorite
+	if err := cursor.All(ctx, &favorites); err != nil {
+		return err
+	}
+
+	for _, favorite := range favorites {
+		userID, err := primitive.ObjectIDFromHex(favorite.UserID)
+		if err != nil {
+			continue
+		}
+		wishlist, err := defaultWishlist(ctx, userID)
+		if err != nil {
+			return err
+		}
+		existing := map[primitive.ObjectID]bool{}
+		for _, item := range wishlist.Items {
+			existing[item.ProductID] = true
+		}
+
+		var items []model.WishlistItem
+		for _, hex := range favorite.ProductIDs {
+			productID, err := primitive.ObjectIDFromHex(hex)
+			if err != nil || existing[productID] {
+				continue
+			}
+			existing[productID] = true
+			var product model.Product
+			if err := getProductCollection().FindOne(ctx, bson.M{"_id": productID}).Decode(&product); err != nil {
+				continue
+			}
+			prices, err := effectivePrices(ctx, []model.Product{product})
+			if err != nil {
+				return err
+			}
+			price, outOfStock := wishlistItemState(product, nil, prices[productID])
+			items = append(items, model.WishlistItem{
+				ProductID:      productID,
+				PriceWhenAdded: price,
+				LastPrice:      price,
+				OutOfStock:     outOfStock,
+				AddedAt:        wishlist.CreatedAt,
+			})
+		}
+
+		err = config.WithTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+			if len(items) > 0 {
+				_, err := getWishlistCollection().UpdateOne(sessCtx, bson.M{"_id": wishlist.ID}, bson.M{
+					"$push": bson.M{"items": bson.M{"$each": items}},
+				})
+				if err != nil {
+					return err
+				}
+			}
+			_, err := collection.DeleteOne(sessCtx, bson.M{"user_id": favorite.UserID})
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -2,19 +2,64 @@ package main
 
 import (
 	"be_ecommerce/config"
+	"be_ecommerce/handler"
+	"be_ecommerce/notifications"
+	"be_ecommerce/outbox"
+	"be_ecommerce/realtime"
 	"be_ecommerce/router"
+	"be_ecommerce/scheduler"
+	"context"
 	"log"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors" // Import CORS middleware
 	"github.com/gofiber/fiber/v2/middleware/logger"
 )
 
+// shutdownTimeout adalah batas waktu menunggu request dan job yang sedang berjalan saat shutdown
+const shutdownTimeout = 30 * time.Second
+
 func main() {
 	// Initialize MongoDB connection
 	config.CreateDBConnection()
 
+	// Start outbox worker for background side effects (email, dll.)
+	if err := outbox.EnsureIndexes(context.Background()); err != nil {
+		log.Printf("Warning: cannot create outbox indexes: %v", err)
+	}
+	if err := handler.MigrateCategoryTree(context.Background()); err != nil {
+		log.Printf("Warning: cannot migrate categories: %v", err)
+	}
+	if err := handler.MigrateFavorites(context.Background()); err != nil {
+		log.Printf("Warning: cannot migrate favorites: %v", err)
+	}
+	if err := handler.EnsureIndexes(context.Background()); err != nil {
+		log.Printf("Warning: cannot create indexes: %v", err)
+	}
+	workers, err := strconv.Atoi(os.Getenv("OUTBOX_WORKERS"))
+	if err != nil || workers < 1 {
+		workers = 4
+	}
+	worker := outbox.NewWorker(workers)
+	worker.Handle(notifications.EventEmail, notifications.DeliverEmail)
+	worker.Start()
+
+	// Start scheduled jobs (order kedaluwarsa, pengingat keranjang, pembersihan data)
+	jobs := scheduler.New()
+	for _, job := range handler.ScheduledJobs() {
+		jobs.Add(job)
+	}
+	jobs.Start()
+
+	// Teruskan notifikasi dan pesan chat baru ke koneksi SSE
+	realtimeCtx, stopRealtime := context.WithCancel(context.Background())
+	go handler.WatchRealtime(realtimeCtx)
+
 	// Initialize Fiber app
 	app := fiber.New()
 
@@ -32,6 +77,42 @@ func main() {
 	if port == "" {
 		port = "3000"
 	}
-	log.Printf("Server running on port %s", port)
-	log.Fatal(app.Listen(":" + port))
+	go func() {
+		log.Printf("Server running on port %s", port)
+		if err := app.Listen(":" + port); err != nil {
+			log.Fatal(err)
+		}
+	}()
+
+	// Graceful shutdown: berhenti menerima request, selesaikan job terjadwal dan outbox lalu tutup koneksi
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	<-quit
+	log.Println("Shutting down...")
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	// Tutup stream SSE lebih dulu agar tidak menahan shutdown server
+	stopRealtime()
+	realtime.Default.Close()
+
+	if err := app.ShutdownWithContext(ctx); err != nil {
+		log.Printf("Error shutting down server: %v", err)
+	}
+	if err := jobs.Stop(ctx); err != nil {
+		log.Printf("Error stopping scheduler: %v", err)
+	}
+	if err := worker.Stop(ctx); err != nil {
+		log.Printf("Error stopping outbox worker: %v", err)
+	}
+	if closer, ok := notifications.Default().(interface{ Close() error }); ok {
+		if err := closer.Close(); err != nil {
+			log.Printf("Error closing notifier: %v", err)
+		}
+	}
+	if err := config.MongoClient.Disconnect(ctx); err != nil {
+		log.Printf("Error disconnecting MongoDB: %v", err)
+	}
+	log.Println("Server stopped")
 }
@@ -0,0 +1,40 @@
+package model
+
+import (
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Address adalah alamat terstruktur. Province, District, SubDistrict dan Village
+// mengikuti hierarki pada model.Region.
+type Address struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id"`
+	Label       string             `json:"label,omitempty" bson:"label,omitempty"` // contoh: "Rumah", "Kantor"
+	Recipient   string             `json:"recipient" bson:"recipient"`
+	Phone       string             `json:"phone" bson:"phone"`
+	Street      string             `json:"street" bson:"street"`
+	Village     string             `json:"village" bson:"village"`
+	SubDistrict string             `json:"sub_district" bson:"sub_district"`
+	District    string             `json:"district" bson:"district"`
+	Province    string             `json:"province" bson:"province"`
+	PostalCode  string             `json:"postal_code" bson:"postal_code"`
+	Latitude    *float64           `json:"lat,omitempty" bson:"lat,omitempty"`
+	Longitude   *float64           `json:"long,omitempty" bson:"long,omitempty"`
+	IsDefault   bool               `json:"is_default" bson:"is_default"`
+}
+
+// String memformat alamat menjadi satu baris, dipakai untuk field teks lama dan email
+func (a Address) String() string {
+	parts := []string{}
+	for _, part := range []string{a.Street, a.Village, a.SubDistrict, a.District, a.Province, a.PostalCode} {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	address := strings.Join(parts, ", ")
+	if a.Recipient != "" {
+		address = a.Recipient + " (" + a.Phone + "), " + address
+	}
+	return address
+}
@@ -1,15 +1,27 @@
 package model
 
+import "time"
+
 type CartItem struct {
     ProductID   string `json:"product_id" bson:"product_id"`
+    VariantID   string `json:"variant_id,omitempty" bson:"variant_id,omitempty"` // Wajib untuk produk yang punya varian
     ProductName string `json:"product_name" bson:"product_name"`
-    Price       int    `json:"price" bson:"price"`
+    Price       int    `json:"price" bson:"price"` // Harga efektif terakhir yang dilihat pembeli, untuk mendeteksi perubahan harga
     Quantity    int    `json:"quantity" bson:"quantity"`
     UserID      string `json:"user_id" bson:"user_id"` // Tambahkan field UserID
+    Deselected  bool   `json:"-" bson:"deselected,omitempty"` // Item dipilih untuk checkout kecuali pembeli membatalkan pilihannya
+    Note        string `json:"note,omitempty" bson:"note,omitempty"` // Catatan pembeli untuk seller, contoh pilihan ukuran atau warna
 }
 
 
+// Cart adalah keranjang milik pengguna (UserID) atau tamu (GuestID, dari cart token)
 type Cart struct {
-	UserID   string     `json:"user_id" bson:"user_id"`
-	Products []CartItem `json:"products" bson:"products"`
+	UserID         string     `json:"user_id,omitempty" bson:"user_id,omitempty"`
+	GuestID        string     `json:"-" bson:"guest_id,omitempty"`
+	Products       []CartItem `json:"products" bson:"products"`
+	SavedForLater  []CartItem `json:"saved_for_later,omitempty" bson:"saved_for_later,omitempty"` // Disimpan untuk nanti, tidak ikut checkout
+	StoreNotes     map[string]string `json:"store_notes,omitempty" bson:"store_notes,omitempty"` // Catatan per toko, kunci seller ID
+	UpdatedAt      time.Time  `json:"updated_at" bson:"updated_at"`
+	ReminderSentAt *time.Time `json:"-" bson:"reminder_sent_at,omitempty"` // Pengingat keranjang terakhir dikirim
+	ViewedAt       *time.Time `json:"-" bson:"viewed_at,omitempty"`        // Terakhir dibuka pemiliknya, dasar kedaluwarsa keranjang pengguna
 }
\ No newline at end of file
@@ -2,11 +2,32 @@ package model
 
 import "go.mongodb.org/mongo-driver/bson/primitive"
 
-// Category represents the schema for storing product categories
+// Tipe nilai atribut kategori
+const (
+	AttributeText    = "text"
+	AttributeNumber  = "number"
+	AttributeSelect  = "select"
+	AttributeBoolean = "boolean"
+)
+
+// Category represents the schema for storing product categories. Kategori membentuk pohon
+// dengan kedalaman bebas lewat ParentID; Ancestors menyimpan jalur dari akar ke induk langsung.
 type Category struct {
-	ID         primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
-	Name       string             `json:"name" bson:"name"`
-	SubCategories []SubCategory   `json:"sub_categories" bson:"sub_categories"`
+	ID         primitive.ObjectID   `json:"id,omitempty" bson:"_id,omitempty"`
+	Name       string               `json:"name" bson:"name"`
+	Slug       string               `json:"slug" bson:"slug"`
+	ParentID   *primitive.ObjectID  `json:"parent_id,omitempty" bson:"parent_id,omitempty"`
+	Ancestors  []primitive.ObjectID `json:"ancestors" bson:"ancestors"`
+	Order      int                  `json:"order" bson:"order"`
+	Icon       string               `json:"icon,omitempty" bson:"icon,omitempty"`
+	Attributes []CategoryAttribute  `json:"attributes,omitempty" bson:"attributes,omitempty"` // Diwarisi oleh sub-kategori
+
+	// SubCategories adalah anak langsung untuk klien lama. Di database hanya ada pada data
+	// sebelum pohon kategori dan dipindahkan oleh MigrateCategoryTree.
+	SubCategories []SubCategory       `json:"sub_categories" bson:"sub_categories,omitempty"`
+	Children      []Category          `json:"children,omitempty" bson:"-"`
+	Breadcrumb    []CategoryCrumb     `json:"breadcrumb,omitempty" bson:"-"`
+	Schema        []CategoryAttribute `json:"schema,omitempty" bson:"-"` // Atribut efektif termasuk warisan induk
 }
 
 // SubCategory represents a sub-category under a category
@@ -14,3 +35,19 @@ type SubCategory struct {
 	ID   primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
 	Name string             `json:"name" bson:"name"`
 }
+
+// CategoryCrumb adalah satu langkah breadcrumb kategori
+type CategoryCrumb struct {
+	ID   primitive.ObjectID `json:"id"`
+	Name string             `json:"name"`
+	Slug string             `json:"slug"`
+}
+
+// CategoryAttribute adalah satu atribut produk yang ditentukan kategori, contoh RAM atau ukuran layar
+type CategoryAttribute struct {
+	Name     string   `json:"name" bson:"name"`
+	Type     string   `json:"type" bson:"type"`
+	Options  []string `json:"options,omitempty" bson:"options,omitempty"` // Untuk tipe select
+	Unit     string   `json:"unit,omitempty" bson:"unit,omitempty"`
+	Required bool     `json:"required" bson:"required"`
+}
@@ -0,0 +1,51 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Conversation adalah thread percakapan antara pelanggan dan sebuah toko,
+// opsional terkait dengan produk atau order tertentu
+type Conversation struct {
+	ID             primitive.ObjectID  `json:"id" bson:"_id"`
+	CustomerID     primitive.ObjectID  `json:"customer_id" bson:"customer_id"`
+	SellerID       primitive.ObjectID  `json:"seller_id" bson:"seller_id"` // ID user pemilik toko
+	ProductID      *primitive.ObjectID `json:"product_id,omitempty" bson:"product_id"`
+	OrderID        *primitive.ObjectID `json:"order_id,omitempty" bson:"order_id"`
+	LastMessage    *ChatPreview        `json:"last_message,omitempty" bson:"last_message,omitempty"`
+	CustomerUnread int                 `json:"customer_unread" bson:"customer_unread"`
+	SellerUnread   int                 `json:"seller_unread" bson:"seller_unread"`
+	CustomerReadAt *time.Time          `json:"customer_read_at,omitempty" bson:"customer_read_at,omitempty"`
+	SellerReadAt   *time.Time          `json:"seller_read_at,omitempty" bson:"seller_read_at,omitempty"`
+	CreatedAt      time.Time           `json:"created_at" bson:"created_at"`
+	UpdatedAt      time.Time           `json:"updated_at" bson:"updated_at"`
+}
+
+// ChatPreview adalah ringkasan pesan terakhir untuk daftar percakapan
+type ChatPreview struct {
+	SenderID primitive.ObjectID `json:"sender_id" bson:"sender_id"`
+	Text     string             `json:"text" bson:"text"`
+	HasImage bool               `json:"has_image" bson:"has_image"`
+	SentAt   time.Time          `json:"sent_at" bson:"sent_at"`
+}
+
+// ChatMessage adalah satu pesan dalam percakapan
+type ChatMessage struct {
+	ID             primitive.ObjectID `json:"id" bson:"_id"`
+	ConversationID primitive.ObjectID `json:"conversation_id" bson:"conversation_id"`
+	SenderID       primitive.ObjectID `json:"sender_id" bson:"sender_id"`
+	RecipientID    primitive.ObjectID `json:"recipient_id" bson:"recipient_id"`
+	Text           string             `json:"text,omitempty" bson:"text,omitempty"`
+	Attachments    []ChatAttachment   `json:"attachments,omitempty" bson:"attachments,omitempty"`
+	CreatedAt      time.Time          `json:"created_at" bson:"created_at"`
+	ReadAt         *time.Time         `json:"read_at,omitempty" bson:"read_at,omitempty"` // Tanda sudah dibaca penerima
+}
+
+// ChatAttachment adalah lampiran pesan, saat ini hanya gambar
+type ChatAttachment struct {
+	Type string `json:"type" bson:"type"`
+	URL  string `json:"url" bson:"url"`
+	Name string `json:"name" bson:"name"`
+}
@@ -1,6 +1,39 @@
 package model
 
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Favorite adalah format daftar favorit lama (satu daftar per pengguna, ID berupa string).
+// Hanya dibaca oleh MigrateFavorites untuk dipindahkan ke wishlist default.
 type Favorite struct {
-	UserID    string   `json:"user_id" bson:"user_id"`
+	UserID     string   `json:"user_id" bson:"user_id"`
 	ProductIDs []string `json:"product_ids" bson:"product_ids"`
 }
+
+// Wishlist adalah daftar keinginan bernama milik pengguna. Setiap pengguna punya satu
+// wishlist default yang dibuat otomatis saat pertama kali dipakai.
+type Wishlist struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id"`
+	UserID    primitive.ObjectID `json:"user_id" bson:"user_id"`
+	Name      string             `json:"name" bson:"name"`
+	IsDefault bool               `json:"is_default" bson:"is_default"`
+	Items     []WishlistItem     `json:"items" bson:"items"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+	UpdatedAt time.Time          `json:"updated_at" bson:"updated_at"`
+}
+
+// WishlistItem adalah satu produk (atau varian) di wishlist. LastPrice dan OutOfStock adalah
+// keadaan terakhir yang diketahui, pembanding untuk alert turun harga dan stok kembali.
+type WishlistItem struct {
+	ProductID      primitive.ObjectID  `json:"product_id" bson:"product_id"`
+	VariantID      *primitive.ObjectID `json:"variant_id,omitempty" bson:"variant_id,omitempty"`
+	PriceWhenAdded int                 `json:"price_when_added" bson:"price_when_added"`
+	LastPrice      int                 `json:"-" bson:"last_price"`
+	OutOfStock     bool                `json:"-" bson:"out_of_stock"`
+	AddedAt        time.Time           `json:"added_at" bson:"added_at"`
+
+	Product *Product `json:"product,omitempty" bson:"-"` // Diisi saat ditampilkan
+}
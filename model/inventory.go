@@ -0,0 +1,34 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Jenis pergerakan stok
+const (
+	MovementRestock     = "restock"
+	MovementSale        = "sale"        // Reservasi menjadi penjualan saat pembayaran diterima; stok tidak berubah lagi
+	MovementReservation = "reservation" // Stok dipotong saat checkout
+	MovementRelease     = "release"     // Reservasi dikembalikan karena order dibatalkan
+	MovementReturn      = "return"
+	MovementAdjustment  = "adjustment"
+)
+
+// InventoryMovement mencatat satu perubahan stok produk atau varian
+type InventoryMovement struct {
+	ID         primitive.ObjectID  `json:"id" bson:"_id,omitempty"`
+	ProductID  primitive.ObjectID  `json:"product_id" bson:"product_id"`
+	VariantID  *primitive.ObjectID `json:"variant_id,omitempty" bson:"variant_id,omitempty"`
+	SellerID   primitive.ObjectID  `json:"seller_id" bson:"seller_id"`
+	Type       string              `json:"type" bson:"type"`
+	Quantity   int                 `json:"quantity" bson:"quantity"`       // Jumlah unit yang terlibat
+	Change     int                 `json:"change" bson:"change"`           // Selisih stok, negatif berarti berkurang
+	StockAfter int                 `json:"stock_after" bson:"stock_after"` // Stok varian (atau produk) setelah perubahan
+	Reason     string              `json:"reason,omitempty" bson:"reason,omitempty"`
+	ActorID    *primitive.ObjectID `json:"actor_id,omitempty" bson:"actor_id,omitempty"` // Kosong berarti sistem
+	ActorRole  string              `json:"actor_role" bson:"actor_role"`
+	OrderID    *primitive.ObjectID `json:"order_id,omitempty" bson:"order_id,omitempty"`
+	CreatedAt  time.Time           `json:"created_at" bson:"created_at"`
+}
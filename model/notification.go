@@ -0,0 +1,31 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Tipe notifikasi in-app
+const (
+	NotificationOrderStatus       = "order_status"
+	NotificationNewOrder          = "new_order"
+	NotificationSellerApplication = "seller_application"
+	NotificationLowStock          = "low_stock"
+	NotificationProductImport     = "product_import"
+	NotificationProductModeration = "product_moderation"
+	NotificationWishlistAlert     = "wishlist_alert"
+)
+
+// Notification adalah notifikasi in-app milik seorang pengguna
+type Notification struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id"`
+	UserID    primitive.ObjectID `json:"user_id" bson:"user_id"`
+	Type      string             `json:"type" bson:"type"`
+	Title     string             `json:"title" bson:"title"`
+	Body      string             `json:"body" bson:"body"`
+	Data      map[string]string  `json:"data,omitempty" bson:"data,omitempty"`
+	Read      bool               `json:"read" bson:"read"`
+	ReadAt    *time.Time         `json:"read_at,omitempty" bson:"read_at,omitempty"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+}
@@ -14,19 +14,38 @@ type Order struct {
 	Items          []OrderItem        `bson:"items" json:"items"`
 	TotalAmount    int                `bson:"total_amount" json:"total_amount"`
 	ShippingCost   int                `bson:"shipping_cost" json:"shipping_cost"`
-	ShippingAddress string            `bson:"shipping_address" json:"shipping_address"`
-	Status string 					  `bson:"status" json:"status" validate:"oneof=Pending Processing Shipped Delivered"`
+	Courier        string             `bson:"courier,omitempty" json:"courier,omitempty"`                 // Kurir yang dipilih saat checkout
+	ShippingService string            `bson:"shipping_service,omitempty" json:"shipping_service,omitempty"` // Layanan kurir, contoh REG
+	VoucherCode    string             `bson:"voucher_code,omitempty" json:"voucher_code,omitempty"`
+	Discount       int                `bson:"discount,omitempty" json:"discount,omitempty"`                   // Potongan voucher untuk item
+	ShippingDiscount int              `bson:"shipping_discount,omitempty" json:"shipping_discount,omitempty"` // Potongan voucher untuk ongkir
+	ShippingAddress string            `bson:"shipping_address" json:"shipping_address"` // Teks dari Address.String()
+	Address        *Address           `bson:"address,omitempty" json:"address,omitempty"` // Salinan alamat saat order dibuat
+	Status string 					  `bson:"status" json:"status" validate:"oneof=Pending Processing Confirmed Shipped Delivered Completed Cancelled"`
 	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
 	PaymentDate time.Time 			  `bson:"payment_date,omitempty" json:"payment_date"`
 	PaymentToken   string             `bson:"payment_token,omitempty" json:"payment_token"`
+	StockReserved  bool               `bson:"stock_reserved,omitempty" json:"-"` // Stok sudah dipotong saat order dibuat
+	DeliveredAt    *time.Time         `bson:"delivered_at,omitempty" json:"delivered_at,omitempty"`
+	CompletedAt    *time.Time         `bson:"completed_at,omitempty" json:"completed_at,omitempty"`
+	CancelledAt    *time.Time         `bson:"cancelled_at,omitempty" json:"cancelled_at,omitempty"`
+	CancelReason   string             `bson:"cancel_reason,omitempty" json:"cancel_reason,omitempty"`
+	Shipments      []Shipment         `bson:"shipments,omitempty" json:"shipments,omitempty"`
 }
 
 // OrderItem menyimpan item dalam sebuah order
 type OrderItem struct {
 	ProductID primitive.ObjectID `bson:"product_id,omitempty" json:"product_id"`
 	Name      string             `bson:"name" json:"name"`
+	VariantID *primitive.ObjectID `bson:"variant_id,omitempty" json:"variant_id,omitempty"`
+	Variant   string             `bson:"variant,omitempty" json:"variant,omitempty"` // Label varian, contoh "M / Merah"
+	SKU       string             `bson:"sku,omitempty" json:"sku,omitempty"`
 	Quantity  int                `bson:"quantity" json:"quantity"`
 	Price     int                `bson:"price" json:"price"`
 	SellerID  primitive.ObjectID `bson:"seller_id,omitempty" json:"seller_id"`
+	OriginalPrice int            `bson:"original_price,omitempty" json:"original_price,omitempty"` // Harga sebelum promo
+	FlashSaleID *primitive.ObjectID `bson:"flash_sale_id,omitempty" json:"flash_sale_id,omitempty"`
+	Note      string             `bson:"note,omitempty" json:"note,omitempty"`             // Catatan pembeli untuk item ini
+	StoreNote string             `bson:"store_note,omitempty" json:"store_note,omitempty"` // Catatan pembeli untuk toko penjual item ini
 	Product   *Product           `bson:"product,omitempty" json:"product"`  // Tambahkan informasi produk langsung di OrderItem
 }
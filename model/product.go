@@ -1,17 +1,104 @@
 package model
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Status siklus hidup produk. Hanya produk active yang tampil di katalog dan dapat dibeli;
+// produk lama tanpa status dianggap active.
+const (
+	ProductDraft         = "draft"
+	ProductPendingReview = "pending_review"
+	ProductActive        = "active"
+	ProductRejected      = "rejected"
+	ProductArchived      = "archived"
+)
 
 // Product model represents the product schema for MongoDB
 type Product struct {
 	ID            primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
 	Name          string             `json:"name" bson:"name"`
+	SKU           string             `json:"sku,omitempty" bson:"sku,omitempty"` // Unik per seller, kunci import CSV
 	Price         int                `json:"price" bson:"price"`
 	Stock 		  int 				 `json:"stock" bson:"stock"`
-	Discount      int                `json:"discount" bson:"discount"`
+	LowStockThreshold int            `json:"low_stock_threshold,omitempty" bson:"low_stock_threshold,omitempty"` // 0 berarti hanya diberi tahu saat habis
+	OutOfStock    bool               `json:"out_of_stock" bson:"out_of_stock,omitempty"`
+	LowStockAlerted bool             `json:"-" bson:"low_stock_alerted,omitempty"` // Seller sudah diberi tahu, direset saat stok kembali di atas batas
+	Discount      int                `json:"discount" bson:"discount"` // Persen potongan tanpa batas waktu
 	Image         string             `json:"image" bson:"image"`
 	Description   string             `json:"description" bson:"description"`
 	SellerID      primitive.ObjectID `json:"seller_id" bson:"seller_id"`
 	CategoryID    primitive.ObjectID `json:"category_id" bson:"category_id"`
-	SubCategoryID primitive.ObjectID `json:"sub_category_id" bson:"sub_category_id"`
+	SubCategoryID primitive.ObjectID `json:"sub_category_id" bson:"sub_category_id"` // Kategori terdalam; CategoryID adalah akarnya
+	CategoryPath  []primitive.ObjectID `json:"category_path,omitempty" bson:"category_path,omitempty"` // Akar sampai SubCategoryID, untuk query per cabang
+	Attributes    map[string]string  `json:"attributes,omitempty" bson:"attributes,omitempty"`       // Sesuai skema atribut kategori
+	Weight        int                `json:"weight" bson:"weight"`                             // Gram, untuk ongkos kirim
+	Dimensions    *Dimensions        `json:"dimensions,omitempty" bson:"dimensions,omitempty"` // Untuk berat volumetrik
+	Options       []ProductOption    `json:"options,omitempty" bson:"options,omitempty"`   // Jenis pilihan, contoh ukuran dan warna
+	Variants      []ProductVariant   `json:"variants,omitempty" bson:"variants,omitempty"` // Kombinasi pilihan; Price dan Stock produk diturunkan dari sini
+	Status        string             `json:"status" bson:"status,omitempty"`
+	RejectionReason string           `json:"rejection_reason,omitempty" bson:"rejection_reason,omitempty"` // Alasan moderasi admin saat status rejected
+	StatusUpdatedAt *time.Time       `json:"status_updated_at,omitempty" bson:"status_updated_at,omitempty"`
+	ApprovedAt    *time.Time         `json:"approved_at,omitempty" bson:"approved_at,omitempty"` // Pertama kali lolos moderasi
+	DeletedAt     *time.Time         `json:"deleted_at,omitempty" bson:"deleted_at,omitempty"`   // Soft delete; produk tetap ada untuk order lama
+	EffectivePrice *EffectivePrice   `json:"effective_price,omitempty" bson:"-"`                 // Diisi saat produk ditampilkan
+}
+
+// IsActive memeriksa apakah produk tampil di katalog dan dapat dibeli
+func (p Product) IsActive() bool {
+	return p.Status == "" || p.Status == ProductActive
+}
+
+// ProductOption adalah satu jenis pilihan produk beserta nilainya, contoh {"size", ["S", "M", "L"]}
+type ProductOption struct {
+	Name   string   `json:"name" bson:"name"`
+	Values []string `json:"values" bson:"values"`
+}
+
+// ProductVariant adalah satu kombinasi pilihan produk dengan SKU, harga, stok dan gambarnya sendiri
+type ProductVariant struct {
+	ID      primitive.ObjectID `json:"id" bson:"_id"`
+	SKU     string             `json:"sku" bson:"sku"`
+	Options map[string]string  `json:"options" bson:"options"` // Nama pilihan -> nilai
+	Price   int                `json:"price" bson:"price"`
+	Stock   int                `json:"stock" bson:"stock"`
+	Image   string             `json:"image,omitempty" bson:"image,omitempty"`
+}
+
+// Variant mencari varian berdasarkan ID
+func (p Product) Variant(id primitive.ObjectID) (ProductVariant, bool) {
+	for _, variant := range p.Variants {
+		if variant.ID == id {
+			return variant, true
+		}
+	}
+	return ProductVariant{}, false
+}
+
+// Label menggabungkan nilai pilihan varian sesuai urutan pilihan produk, contoh "M / Merah"
+func (v ProductVariant) Label(options []ProductOption) string {
+	label := ""
+	for _, option := range options {
+		if value, ok := v.Options[option.Name]; ok {
+			if label != "" {
+				label += " / "
+			}
+			label += value
+		}
+	}
+	return label
+}
+
+// Dimensions adalah ukuran kemasan produk dalam sentimeter
+type Dimensions struct {
+	Length int `json:"length" bson:"length"`
+	Width  int `json:"width" bson:"width"`
+	Height int `json:"height" bson:"height"`
+}
+
+// Volume mengembalikan volume kemasan dalam cm³
+func (d Dimensions) Volume() int {
+	return d.Length * d.Width * d.Height
 }
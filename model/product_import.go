@@ -0,0 +1,44 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Status job import produk
+const (
+	ImportQueued     = "queued"
+	ImportProcessing = "processing"
+	ImportCompleted  = "completed"
+	ImportFailed     = "failed"
+)
+
+// ProductImportJob adalah satu unggahan CSV produk seller yang diproses di latar belakang.
+// Baris mentah disimpan di Rows sampai job selesai agar job dapat dilanjutkan setelah restart.
+type ProductImportJob struct {
+	ID            primitive.ObjectID   `json:"id" bson:"_id,omitempty"`
+	SellerID      primitive.ObjectID   `json:"seller_id" bson:"seller_id"`
+	FileName      string               `json:"file_name" bson:"file_name"`
+	Status        string               `json:"status" bson:"status"`
+	Header        []string             `json:"-" bson:"header,omitempty"`
+	Rows          [][]string           `json:"-" bson:"rows,omitempty"`
+	TotalRows     int                  `json:"total_rows" bson:"total_rows"`
+	ProcessedRows int                  `json:"processed_rows" bson:"processed_rows"`
+	Created       int                  `json:"created" bson:"created"`
+	Updated       int                  `json:"updated" bson:"updated"`
+	Failed        int                  `json:"failed" bson:"failed"`
+	Errors        []ProductImportError `json:"errors,omitempty" bson:"errors,omitempty"`
+	Message       string               `json:"message,omitempty" bson:"message,omitempty"` // Alasan job gagal seluruhnya
+	CreatedAt     time.Time            `json:"created_at" bson:"created_at"`
+	StartedAt     *time.Time           `json:"started_at,omitempty" bson:"started_at,omitempty"`
+	HeartbeatAt   *time.Time           `json:"-" bson:"heartbeat_at,omitempty"`
+	FinishedAt    *time.Time           `json:"finished_at,omitempty" bson:"finished_at,omitempty"`
+}
+
+// ProductImportError adalah satu baris CSV yang ditolak beserta alasannya
+type ProductImportError struct {
+	Row     int    `json:"row" bson:"row"` // Nomor baris di file, header adalah baris 1
+	SKU     string `json:"sku" bson:"sku"`
+	Message string `json:"message" bson:"message"`
+}
@@ -0,0 +1,61 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Sumber harga efektif produk
+const (
+	PriceSourceRegular   = "regular"
+	PriceSourceDiscount  = "discount" // Product.Discount (persen) tanpa batas waktu
+	PriceSourceRule      = "price_rule"
+	PriceSourceFlashSale = "flash_sale"
+)
+
+// PriceRule adalah harga khusus produk yang berlaku dalam rentang waktu tertentu
+type PriceRule struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	ProductID primitive.ObjectID `json:"product_id" bson:"product_id"`
+	SellerID  primitive.ObjectID `json:"seller_id" bson:"seller_id"`
+	Price     int                `json:"price" bson:"price"`
+	StartsAt  time.Time          `json:"starts_at" bson:"starts_at"`
+	EndsAt    time.Time          `json:"ends_at" bson:"ends_at"`
+	CreatedBy primitive.ObjectID `json:"created_by" bson:"created_by"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+}
+
+// FlashSale adalah kampanye harga terbatas untuk sejumlah produk
+type FlashSale struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Name      string             `json:"name" bson:"name"`
+	StartsAt  time.Time          `json:"starts_at" bson:"starts_at"`
+	EndsAt    time.Time          `json:"ends_at" bson:"ends_at"`
+	Active    bool               `json:"active" bson:"active"`
+	Items     []FlashSaleItem    `json:"items" bson:"items"`
+	CreatedBy primitive.ObjectID `json:"created_by" bson:"created_by"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+}
+
+// FlashSaleItem adalah produk dalam flash sale beserta kuotanya
+type FlashSaleItem struct {
+	ProductID     primitive.ObjectID `json:"product_id" bson:"product_id"`
+	SellerID      primitive.ObjectID `json:"seller_id" bson:"seller_id"`
+	Price         int                `json:"price" bson:"price"`
+	Quota         int                `json:"quota" bson:"quota"` // Jumlah unit yang dijual dengan harga flash sale
+	Sold          int                `json:"sold" bson:"sold"`
+	PerBuyerLimit int                `json:"per_buyer_limit" bson:"per_buyer_limit"` // 0 berarti tanpa batas
+}
+
+// EffectivePrice adalah harga produk yang berlaku saat ini. Katalog, detail produk,
+// keranjang dan checkout memakai perhitungan yang sama.
+type EffectivePrice struct {
+	Price         int                 `json:"price"`
+	OriginalPrice int                 `json:"original_price"`
+	Source        string              `json:"source"`
+	EndsAt        *time.Time          `json:"ends_at,omitempty"`
+	FlashSaleID   *primitive.ObjectID `json:"flash_sale_id,omitempty"`
+	QuotaLeft     int                 `json:"quota_left,omitempty"`
+	PerBuyerLimit int                 `json:"per_buyer_limit,omitempty"`
+}
@@ -0,0 +1,34 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Status pengiriman dalam Shipment
+const (
+	ShipmentInTransit = "in_transit"
+	ShipmentDelivered = "delivered"
+)
+
+// Shipment adalah satu paket yang dikirim untuk sebuah order (nomor resi dari kurir)
+type Shipment struct {
+	ID            primitive.ObjectID `json:"id" bson:"_id"`
+	SellerID      primitive.ObjectID `json:"seller_id" bson:"seller_id"`
+	Courier       string             `json:"courier" bson:"courier"`
+	Service       string             `json:"service,omitempty" bson:"service,omitempty"`
+	Waybill       string             `json:"waybill" bson:"waybill"` // Nomor resi
+	Status        string             `json:"status" bson:"status"`
+	ShippedAt     time.Time          `json:"shipped_at" bson:"shipped_at"`
+	DeliveredAt   *time.Time         `json:"delivered_at,omitempty" bson:"delivered_at,omitempty"`
+	LastCheckedAt *time.Time         `json:"last_checked_at,omitempty" bson:"last_checked_at,omitempty"` // Terakhir dicek ke provider tracking
+	Events        []TrackingEvent    `json:"events" bson:"events"`
+}
+
+// TrackingEvent adalah satu riwayat perjalanan paket
+type TrackingEvent struct {
+	Time        time.Time `json:"time" bson:"time"`
+	Description string    `json:"description" bson:"description"`
+	Location    string    `json:"location,omitempty" bson:"location,omitempty"`
+}
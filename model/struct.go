@@ -7,6 +7,17 @@ type Location struct {
 	Coordinates [][][]float64 `bson:"coordinates" json:"coordinates"`
 }
 
+// Point adalah titik GeoJSON [long, lat], dipakai untuk lokasi toko (index 2dsphere)
+type Point struct {
+	Type        string    `bson:"type" json:"type"`
+	Coordinates []float64 `bson:"coordinates" json:"coordinates"`
+}
+
+// NewPoint membuat Point GeoJSON dari longitude dan latitude
+func NewPoint(long, lat float64) *Point {
+	return &Point{Type: "Point", Coordinates: []float64{long, lat}}
+}
+
 type Region struct {
 	ID          primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
 	Province    string             `bson:"province" json:"province"`
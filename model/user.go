@@ -8,28 +8,53 @@ import (
 
 // LoginRequest represents a user login payload
 type LoginRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email     string `json:"email"`
+	Password  string `json:"password"`
+	CartToken string `json:"cart_token"` // Opsional, keranjang tamu yang digabung ke akun
 }
 
 // User represents the user schema for MongoDB
 type User struct {
-	ID          primitive.ObjectID `bson:"_id,omitempty"`
-	Username    string             `json:"username" bson:"username"`
-	Email       string             `json:"email" bson:"email"`
-	Password    string             `json:"password" bson:"password"` // Tambahkan field Password
-	Roles       []string           `json:"roles" bson:"roles"`
-	SellerID    *primitive.ObjectID  `bson:"seller_id,omitempty" json:"seller_id,omitempty"`
-	StoreStatus *string            `json:"store_status,omitempty" bson:"store_status,omitempty"`
-	StoreInfo   *StoreInfo         `json:"store_info,omitempty" bson:"store_info,omitempty"`
-	ResetToken       string             `json:"reset_token,omitempty" bson:"reset_token,omitempty"`
-	ResetTokenExpiry time.Time          `json:"reset_token_expiry,omitempty" bson:"reset_token_expiry,omitempty"`
+	ID             primitive.ObjectID     `bson:"_id,omitempty"`
+	Username       string                 `json:"username" bson:"username"`
+	Email          string                 `json:"email" bson:"email"`
+	Password       string                 `json:"password" bson:"password"` // Tambahkan field Password
+	Roles          []string               `json:"roles" bson:"roles"`
+	SellerID       *primitive.ObjectID    `bson:"seller_id,omitempty" json:"seller_id,omitempty"`
+	StoreStatus    *string                `json:"store_status,omitempty" bson:"store_status,omitempty"`
+	StoreInfo      *StoreInfo             `json:"store_info,omitempty" bson:"store_info,omitempty"`
+	StoreAppliedAt *time.Time             `json:"store_applied_at,omitempty" bson:"store_applied_at,omitempty"` // Waktu permohonan seller terakhir
+	Language       string                 `json:"language,omitempty" bson:"language,omitempty"`                 // "id" (default) atau "en", dipakai untuk email
+	EmailVerified  *bool                  `json:"email_verified,omitempty" bson:"email_verified,omitempty"`     // nil untuk akun lama sebelum verifikasi email
+	OneTimeCodes   map[string]OneTimeCode `json:"-" bson:"one_time_codes,omitempty"`                            // key: keperluan OTP
+	Suspended      bool                   `json:"suspended" bson:"suspended"`
+	Suspension     *Suspension            `json:"suspension,omitempty" bson:"suspension,omitempty"`
+	Addresses      []Address              `json:"addresses,omitempty" bson:"addresses,omitempty"` // Buku alamat pelanggan
+}
+
+// OneTimeCode menyimpan kode OTP untuk satu keperluan (reset password, verifikasi email)
+// Kode hanya disimpan dalam bentuk hash
+type OneTimeCode struct {
+	CodeHash  string    `json:"-" bson:"code_hash"`
+	ExpiresAt time.Time `json:"expires_at" bson:"expires_at"`
+	SentAt    time.Time `json:"sent_at" bson:"sent_at"`
+	Attempts  int       `json:"attempts" bson:"attempts"` // Jumlah verifikasi gagal
+}
+
+// Suspension menyimpan detail penangguhan akun oleh admin
+type Suspension struct {
+	Reason      string             `json:"reason" bson:"reason"`
+	SuspendedBy primitive.ObjectID `json:"suspended_by" bson:"suspended_by"`
+	SuspendedAt time.Time          `json:"suspended_at" bson:"suspended_at"`
+	ExpiresAt   *time.Time         `json:"expires_at,omitempty" bson:"expires_at,omitempty"` // nil berarti tanpa batas waktu
 }
 type StoreInfo struct {
-	StoreName   string `json:"store_name" bson:"store_name"`
-	FullAddress string `json:"full_address" bson:"full_address"`
-	NIK         string `json:"nik" bson:"nik"`
-	PhotoSelfie string `json:"photo_selfie" bson:"photo_selfie"`
+	StoreName   string   `json:"store_name" bson:"store_name"`
+	FullAddress string   `json:"full_address" bson:"full_address"` // Diisi dari Address.String() untuk pembaca lama
+	Address     *Address `json:"address,omitempty" bson:"address,omitempty"`
+	Location    *Point   `json:"location,omitempty" bson:"location,omitempty"` // Titik toko untuk pencarian terdekat
+	NIK         string   `json:"nik" bson:"nik"`
+	PhotoSelfie string   `json:"photo_selfie" bson:"photo_selfie"`
 }
 
 // StoreInfo represents additional information for becoming a seller
@@ -0,0 +1,66 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Jenis potongan voucher
+const (
+	VoucherPercentage   = "percentage"    // Value dalam persen, dibatasi MaxDiscount
+	VoucherFixed        = "fixed"         // Value dalam rupiah
+	VoucherFreeShipping = "free_shipping" // Value batas potongan ongkir, 0 berarti gratis penuh
+)
+
+// Cakupan produk yang mendapat potongan voucher
+const (
+	VoucherScopePlatform = "platform"
+	VoucherScopeStore    = "store"
+	VoucherScopeCategory = "category"
+	VoucherScopeProduct  = "product"
+)
+
+// Voucher adalah kode promo yang bisa dipakai saat checkout
+type Voucher struct {
+	ID           primitive.ObjectID   `json:"id" bson:"_id,omitempty"`
+	Code         string               `json:"code" bson:"code"` // Selalu huruf besar
+	Name         string               `json:"name" bson:"name"`
+	Description  string               `json:"description,omitempty" bson:"description,omitempty"`
+	Type         string               `json:"type" bson:"type"`
+	Value        int                  `json:"value" bson:"value"`
+	MaxDiscount  int                  `json:"max_discount,omitempty" bson:"max_discount,omitempty"` // 0 berarti tanpa batas
+	MinSpend     int                  `json:"min_spend" bson:"min_spend"`                           // Dihitung dari subtotal item yang tercakup
+	Scope        string               `json:"scope" bson:"scope"`
+	SellerID     *primitive.ObjectID  `json:"seller_id,omitempty" bson:"seller_id,omitempty"` // Toko pemilik voucher
+	CategoryIDs  []primitive.ObjectID `json:"category_ids,omitempty" bson:"category_ids,omitempty"`
+	ProductIDs   []primitive.ObjectID `json:"product_ids,omitempty" bson:"product_ids,omitempty"`
+	UsageLimit   int                  `json:"usage_limit" bson:"usage_limit"`       // Total pemakaian, 0 berarti tanpa batas
+	PerUserLimit int                  `json:"per_user_limit" bson:"per_user_limit"` // 0 berarti tanpa batas
+	UsedCount    int                  `json:"used_count" bson:"used_count"`
+	StartsAt     time.Time            `json:"starts_at" bson:"starts_at"`
+	EndsAt       time.Time            `json:"ends_at" bson:"ends_at"`
+	Active       bool                 `json:"active" bson:"active"`
+	CreatedBy    primitive.ObjectID   `json:"created_by" bson:"created_by"`
+	CreatedAt    time.Time            `json:"created_at" bson:"created_at"`
+}
+
+// Status VoucherRedemption
+const (
+	RedemptionApplied  = "applied"
+	RedemptionReleased = "released" // Order dibatalkan, kuota dikembalikan
+)
+
+// VoucherRedemption mencatat pemakaian voucher oleh sebuah order
+type VoucherRedemption struct {
+	ID               primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	VoucherID        primitive.ObjectID `json:"voucher_id" bson:"voucher_id"`
+	Code             string             `json:"code" bson:"code"`
+	UserID           primitive.ObjectID `json:"user_id" bson:"user_id"`
+	OrderID          primitive.ObjectID `json:"order_id" bson:"order_id"`
+	Discount         int                `json:"discount" bson:"discount"`
+	ShippingDiscount int                `json:"shipping_discount" bson:"shipping_discount"`
+	Status           string             `json:"status" bson:"status"`
+	CreatedAt        time.Time          `json:"created_at" bson:"created_at"`
+	ReleasedAt       *time.Time         `json:"released_at,omitempty" bson:"released_at,omitempty"`
+}
@@ -0,0 +1,37 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// LogNotifier menulis email ke stdout atau file, untuk development tanpa server SMTP
+type LogNotifier struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewLogNotifier menulis ke file pada path (append), atau stdout jika path kosong
+func NewLogNotifier(path string) (*LogNotifier, error) {
+	if path == "" {
+		return &LogNotifier{w: os.Stdout}, nil
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &LogNotifier{w: file}, nil
+}
+
+// Send menuliskan pesan beserta waktu pengiriman
+func (n *LogNotifier) Send(ctx context.Context, msg Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	_, err := fmt.Fprintf(n.w, "----- %s\nTo: %s\nSubject: %s\n\n%s\n",
+		time.Now().Format(time.RFC3339), msg.To, msg.Subject, msg.HTMLBody)
+	return err
+}
@@ -0,0 +1,39 @@
+package notifications
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryNotifier menyimpan pesan di memori, dipakai untuk pengujian
+type MemoryNotifier struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+// NewMemoryNotifier membuat MemoryNotifier kosong
+func NewMemoryNotifier() *MemoryNotifier {
+	return &MemoryNotifier{}
+}
+
+// Send menyimpan pesan
+func (n *MemoryNotifier) Send(ctx context.Context, msg Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.messages = append(n.messages, msg)
+	return nil
+}
+
+// Messages mengembalikan salinan pesan yang sudah dikirim
+func (n *MemoryNotifier) Messages() []Message {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]Message(nil), n.messages...)
+}
+
+// Reset menghapus semua pesan yang tersimpan
+func (n *MemoryNotifier) Reset() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.messages = nil
+}
@@ -0,0 +1,75 @@
+package notifications
+
+import (
+	"context"
+	"log"
+	"os"
+	"sync"
+)
+
+// Message adalah email yang sudah dirender dan siap dikirim
+type Message struct {
+	To       string `bson:"to"`
+	Subject  string `bson:"subject"`
+	HTMLBody string `bson:"html_body"`
+}
+
+// Notifier mengirimkan Message melalui suatu kanal (SMTP, log, memori)
+type Notifier interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+var (
+	defaultNotifier Notifier
+	defaultOnce     sync.Once
+	defaultMu       sync.RWMutex
+)
+
+// Default mengembalikan notifier yang dipilih lewat env NOTIFIER_DRIVER:
+// "smtp" (default), "log" untuk development, atau "memory".
+func Default() Notifier {
+	defaultOnce.Do(func() {
+		var n Notifier
+		switch os.Getenv("NOTIFIER_DRIVER") {
+		case "log":
+			logNotifier, err := NewLogNotifier(os.Getenv("NOTIFIER_LOG_FILE"))
+			if err != nil {
+				log.Printf("Warning: cannot open notifier log file, using stdout: %v", err)
+				logNotifier, _ = NewLogNotifier("")
+			}
+			n = logNotifier
+		case "memory":
+			n = NewMemoryNotifier()
+		default:
+			n = NewSMTPNotifierFromEnv()
+		}
+
+		defaultMu.Lock()
+		if defaultNotifier == nil {
+			defaultNotifier = n
+		}
+		defaultMu.Unlock()
+	})
+
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultNotifier
+}
+
+// SetDefault mengganti notifier default, misalnya dengan MemoryNotifier saat pengujian
+func SetDefault(n Notifier) {
+	defaultOnce.Do(func() {})
+	defaultMu.Lock()
+	defaultNotifier = n
+	defaultMu.Unlock()
+}
+
+// Notify merender template sesuai bahasa penerima lalu mengirimkannya lewat notifier default
+func Notify(ctx context.Context, to string, lang string, tmpl Template, data interface{}) error {
+	msg, err := Render(tmpl, lang, data)
+	if err != nil {
+		return err
+	}
+	msg.To = to
+	return Default().Send(ctx, msg)
+}
@@ -0,0 +1,30 @@
+package notifications
+
+import (
+	"be_ecommerce/outbox"
+	"context"
+	"fmt"
+)
+
+// EventEmail adalah tipe event outbox untuk email yang sudah dirender
+const EventEmail = "email.send"
+
+// Enqueue merender template lalu menitipkan email ke outbox agar dikirim oleh worker.
+// Jika ctx adalah mongo.SessionContext, email hanya terkirim bila transaksinya berhasil.
+func Enqueue(ctx context.Context, to string, lang string, tmpl Template, data interface{}) error {
+	msg, err := Render(tmpl, lang, data)
+	if err != nil {
+		return err
+	}
+	msg.To = to
+	return outbox.Enqueue(ctx, EventEmail, msg)
+}
+
+// DeliverEmail adalah handler outbox yang mengirim email lewat notifier default
+func DeliverEmail(ctx context.Context, event outbox.Event) error {
+	var msg Message
+	if err := event.Decode(&msg); err != nil {
+		return fmt.Errorf("%w: %v", outbox.ErrPermanent, err)
+	}
+	return Default().Send(ctx, msg)
+}
@@ -0,0 +1,85 @@
+package notifications
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"sync"
+
+	"gopkg.in/gomail.v2"
+)
+
+// SMTPNotifier mengirim email lewat SMTP dan memakai ulang koneksi antar pengiriman
+type SMTPNotifier struct {
+	dialer *gomail.Dialer
+	from   string
+
+	mu     sync.Mutex
+	sender gomail.SendCloser
+}
+
+// NewSMTPNotifier membuat notifier SMTP
+func NewSMTPNotifier(host string, port int, username, password, from string) *SMTPNotifier {
+	return &SMTPNotifier{
+		dialer: gomail.NewDialer(host, port, username, password),
+		from:   from,
+	}
+}
+
+// NewSMTPNotifierFromEnv membaca konfigurasi EMAIL_* dari environment
+func NewSMTPNotifierFromEnv() *SMTPNotifier {
+	sender := os.Getenv("EMAIL_SENDER")
+	port, _ := strconv.Atoi(os.Getenv("EMAIL_PORT"))
+	return NewSMTPNotifier(os.Getenv("EMAIL_HOST"), port, sender, os.Getenv("EMAIL_PASSWORD"), sender)
+}
+
+// Send mengirim pesan; jika koneksi lama sudah putus, koneksi dibuka ulang sekali
+func (n *SMTPNotifier) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	mail := gomail.NewMessage()
+	mail.SetHeader("From", n.from)
+	mail.SetHeader("To", msg.To)
+	mail.SetHeader("Subject", msg.Subject)
+	mail.SetBody("text/html", msg.HTMLBody)
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for attempt := 0; attempt < 2; attempt++ {
+		if n.sender == nil {
+			sender, err := n.dialer.Dial()
+			if err != nil {
+				return err
+			}
+			n.sender = sender
+		}
+
+		err := gomail.Send(n.sender, mail)
+		if err == nil {
+			return nil
+		}
+
+		// Koneksi kemungkinan sudah ditutup server, buka koneksi baru
+		n.sender.Close()
+		n.sender = nil
+		if attempt == 1 {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close menutup koneksi SMTP yang sedang terbuka
+func (n *SMTPNotifier) Close() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.sender == nil {
+		return nil
+	}
+	err := n.sender.Close()
+	n.sender = nil
+	return err
+}
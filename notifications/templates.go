@@ -0,0 +1,183 @@
+package notifications
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"strconv"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+)
+
+// Template adalah nama template email
+type Template string
+
+const (
+	TemplateOrderPlaced       Template = "order_placed"
+	TemplatePaymentReceived   Template = "payment_received"
+	TemplateOrderShipped      Template = "order_shipped"
+	TemplateSellerApproved    Template = "seller_approved"
+	TemplateSellerRejected    Template = "seller_rejected"
+	TemplatePasswordReset     Template = "password_reset"
+	TemplateEmailVerification Template = "email_verification"
+	TemplateAccountSuspended  Template = "account_suspended"
+	TemplateAccountReinstated Template = "account_reinstated"
+	TemplateOrderCancelled    Template = "order_cancelled"
+	TemplateCartReminder      Template = "cart_reminder"
+	TemplateLowStock          Template = "low_stock"
+)
+
+// Bahasa yang didukung, bahasa Indonesia sebagai default
+const (
+	LangID = "id"
+	LangEN = "en"
+)
+
+// OrderEmail adalah data untuk template order_placed, payment_received, order_shipped dan order_cancelled
+type OrderEmail struct {
+	Name            string
+	OrderID         string
+	Items           []OrderEmailItem
+	ShippingCost    int
+	TotalAmount     int
+	ShippingAddress string
+	Courier         string // Diisi saat order dikirim
+	Waybill         string
+}
+
+// OrderEmailItem adalah satu baris produk dalam OrderEmail
+type OrderEmailItem struct {
+	Name     string
+	Quantity int
+	Price    int
+}
+
+// CartReminderEmail adalah data untuk template cart_reminder
+type CartReminderEmail struct {
+	Name  string
+	Items []OrderEmailItem
+	Total int
+}
+
+// LowStockEmail adalah data untuk template low_stock
+type LowStockEmail struct {
+	Name        string
+	ProductName string
+	Stock       int
+	Threshold   int
+}
+
+// SellerApplicationEmail adalah data untuk template seller_approved dan seller_rejected
+type SellerApplicationEmail struct {
+	Name      string
+	StoreName string
+	Reason    string
+}
+
+// CodeEmail adalah data untuk template password_reset dan email_verification
+type CodeEmail struct {
+	Name           string
+	Code           string
+	ExpiresMinutes int
+}
+
+// SuspensionEmail adalah data untuk template account_suspended dan account_reinstated
+type SuspensionEmail struct {
+	Name   string
+	Reason string
+	Until  string // Kosong berarti tanpa batas waktu
+}
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+// parsedTemplate menyimpan isi email (html/template, di-escape untuk HTML) dan subject-nya
+// (text/template, karena subject adalah teks biasa sehingga "&" tidak boleh menjadi "&amp;")
+type parsedTemplate struct {
+	body    *template.Template
+	subject *texttemplate.Template
+}
+
+var (
+	templateCache   = map[string]parsedTemplate{}
+	templateCacheMu sync.Mutex
+)
+
+var templateFuncs = template.FuncMap{
+	"rupiah": formatRupiah,
+}
+
+var subjectFuncs = texttemplate.FuncMap{
+	"rupiah": formatRupiah,
+}
+
+// formatRupiah memformat angka menjadi "Rp 1.250.000"
+func formatRupiah(amount int) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	digits := strconv.Itoa(amount)
+	var groups []string
+	for len(digits) > 3 {
+		groups = append([]string{digits[len(digits)-3:]}, groups...)
+		digits = digits[:len(digits)-3]
+	}
+	groups = append([]string{digits}, groups...)
+	return sign + "Rp " + strings.Join(groups, ".")
+}
+
+// normalizeLang mengembalikan LangEN untuk "en*" dan LangID untuk lainnya
+func normalizeLang(lang string) string {
+	if strings.HasPrefix(strings.ToLower(lang), LangEN) {
+		return LangEN
+	}
+	return LangID
+}
+
+func loadTemplate(tmpl Template, lang string) (parsedTemplate, error) {
+	key := string(tmpl) + "." + lang
+	templateCacheMu.Lock()
+	defer templateCacheMu.Unlock()
+
+	if t, ok := templateCache[key]; ok {
+		return t, nil
+	}
+
+	file := fmt.Sprintf("templates/%s.html", key)
+	body, err := template.New("layout.html").Funcs(templateFuncs).ParseFS(templateFS, "templates/layout.html", file)
+	if err != nil {
+		return parsedTemplate{}, err
+	}
+	subject, err := texttemplate.New(key).Funcs(subjectFuncs).ParseFS(templateFS, file)
+	if err != nil {
+		return parsedTemplate{}, err
+	}
+	t := parsedTemplate{body: body, subject: subject}
+	templateCache[key] = t
+	return t, nil
+}
+
+// Render menghasilkan subject dan isi HTML dari template dalam bahasa penerima
+func Render(tmpl Template, lang string, data interface{}) (Message, error) {
+	t, err := loadTemplate(tmpl, normalizeLang(lang))
+	if err != nil {
+		return Message{}, err
+	}
+
+	var subject, body bytes.Buffer
+	if err := t.subject.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, err
+	}
+	if err := t.body.ExecuteTemplate(&body, "layout.html", data); err != nil {
+		return Message{}, err
+	}
+
+	return Message{
+		Subject:  strings.TrimSpace(subject.String()),
+		HTMLBody: body.String(),
+	}, nil
+}
@@ -0,0 +1,75 @@
+package notifications
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name        string
+		tmpl        Template
+		lang        string
+		data        interface{}
+		subject     string
+		bodyContain string
+	}{
+		{
+			name:        "subject is plain text",
+			tmpl:        TemplateSellerApproved,
+			lang:        LangEN,
+			data:        SellerApplicationEmail{Name: "Budi", StoreName: "Tom & Jerry's"},
+			subject:     "Your store Tom & Jerry's has been approved",
+			bodyContain: "<strong>Tom &amp; Jerry&#39;s</strong>",
+		},
+		{
+			name:        "default language is Indonesian",
+			tmpl:        TemplateSellerApproved,
+			lang:        "",
+			data:        SellerApplicationEmail{Name: "Budi", StoreName: "Toko Budi"},
+			subject:     "Toko Toko Budi telah disetujui",
+			bodyContain: "Halo Budi",
+		},
+		{
+			name:        "regional English falls back to en",
+			tmpl:        TemplateOrderPlaced,
+			lang:        "en-US",
+			data:        OrderEmail{Name: "Ani", OrderID: "abc123", TotalAmount: 1250000},
+			subject:     "Order #abc123 has been placed",
+			bodyContain: "Rp 1.250.000",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := Render(tt.tmpl, tt.lang, tt.data)
+			if err != nil {
+				t.Fatalf("Render returned error: %v", err)
+			}
+			if msg.Subject != tt.subject {
+				t.Errorf("subject = %q, want %q", msg.Subject, tt.subject)
+			}
+			if !strings.Contains(msg.HTMLBody, tt.bodyContain) {
+				t.Errorf("body does not contain %q:\n%s", tt.bodyContain, msg.HTMLBody)
+			}
+		})
+	}
+}
+
+func TestFormatRupiah(t *testing.T) {
+	tests := []struct {
+		amount int
+		want   string
+	}{
+		{0, "Rp 0"},
+		{999, "Rp 999"},
+		{1000, "Rp 1.000"},
+		{1250000, "Rp 1.250.000"},
+		{-15000, "-Rp 15.000"},
+	}
+	for _, tt := range tests {
+		if got := formatRupiah(tt.amount); got != tt.want {
+			t.Errorf("formatRupiah(%d) = %q, want %q", tt.amount, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package outbox
+
+import (
+	"be_ecommerce/config"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Status event outbox
+const (
+	StatusPending    = "pending"
+	StatusProcessing = "processing"
+	StatusDone       = "done"
+	StatusDead       = "dead"
+)
+
+// DefaultMaxAttempts adalah jumlah percobaan sebelum event dipindah ke dead-letter
+const DefaultMaxAttempts = 8
+
+// Event adalah efek samping (email, panggilan API eksternal) yang menunggu diproses worker
+type Event struct {
+	ID            primitive.ObjectID `bson:"_id" json:"id"`
+	Type          string             `bson:"type" json:"type"`
+	Payload       bson.Raw           `bson:"payload" json:"-"`
+	Status        string             `bson:"status" json:"status"`
+	Attempts      int                `bson:"attempts" json:"attempts"`
+	MaxAttempts   int                `bson:"max_attempts" json:"max_attempts"`
+	NextAttemptAt time.Time          `bson:"next_attempt_at" json:"next_attempt_at"`
+	LockedUntil   *time.Time         `bson:"locked_until,omitempty" json:"locked_until,omitempty"`
+	LastError     string             `bson:"last_error,omitempty" json:"last_error,omitempty"`
+	CreatedAt     time.Time          `bson:"created_at" json:"created_at"`
+	ProcessedAt   *time.Time         `bson:"processed_at,omitempty" json:"processed_at,omitempty"`
+}
+
+// Decode membaca payload event ke dalam v
+func (e Event) Decode(v interface{}) error {
+	return bson.Unmarshal(e.Payload, v)
+}
+
+// Collection mengembalikan koleksi outbox
+func Collection() *mongo.Collection {
+	return config.MongoClient.Database("ecommerce").Collection("outbox")
+}
+
+// DeadLetterCollection mengembalikan koleksi event yang gagal permanen
+func DeadLetterCollection() *mongo.Collection {
+	return config.MongoClient.Database("ecommerce").Collection("outbox_dead_letters")
+}
+
+// EnsureIndexes membuat index yang dipakai worker saat mengambil event
+func EnsureIndexes(ctx context.Context) error {
+	_, err := Collection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "next_attempt_at", Value: 1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "locked_until", Value: 1}}},
+	})
+	return err
+}
+
+// Enqueue menyimpan event baru ke outbox. Jika ctx adalah mongo.SessionContext,
+// event ikut tersimpan (atau batal) bersama perubahan bisnis dalam transaksi yang sama.
+func Enqueue(ctx context.Context, eventType string, payload interface{}) error {
+	raw, err := bson.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	_, err = Collection().InsertOne(ctx, Event{
+		ID:            primitive.NewObjectID(),
+		Type:          eventType,
+		Payload:       raw,
+		Status:        StatusPending,
+		MaxAttempts:   DefaultMaxAttempts,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	})
+	return err
+}
@@ -0,0 +1,239 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Handler memproses satu event. Error yang dikembalikan membuat event dijadwalkan ulang.
+type Handler func(ctx context.Context, event Event) error
+
+const (
+	defaultPollInterval = 2 * time.Second
+	defaultLease        = 2 * time.Minute
+	baseBackoff         = 5 * time.Second
+	maxBackoff          = time.Hour
+)
+
+// ErrPermanent menandai kegagalan yang tidak perlu dicoba ulang.
+// Bungkus dengan fmt.Errorf("%w: ...", ErrPermanent) agar event langsung masuk dead-letter.
+var ErrPermanent = errors.New("permanent failure")
+
+// Worker menjalankan sejumlah goroutine yang mengambil event dari outbox lalu memprosesnya
+type Worker struct {
+	concurrency  int
+	pollInterval time.Duration
+	lease        time.Duration
+	handlers     map[string]Handler
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewWorker membuat worker dengan jumlah goroutine tertentu
+func NewWorker(concurrency int) *Worker {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &Worker{
+		concurrency:  concurrency,
+		pollInterval: defaultPollInterval,
+		lease:        defaultLease,
+		handlers:     map[string]Handler{},
+	}
+}
+
+// Handle mendaftarkan handler untuk tipe event tertentu. Dipanggil sebelum Start.
+func (w *Worker) Handle(eventType string, h Handler) {
+	w.handlers[eventType] = h
+}
+
+// Start menjalankan pool worker di background
+func (w *Worker) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+
+	for i := 0; i < w.concurrency; i++ {
+		w.wg.Add(1)
+		go func() {
+			defer w.wg.Done()
+			w.loop(ctx)
+		}()
+	}
+	log.Printf("Outbox worker started with %d goroutines", w.concurrency)
+}
+
+// Stop menghentikan pengambilan event baru dan menunggu event yang sedang diproses selesai.
+// Jika ctx habis lebih dulu, event yang belum selesai akan diambil ulang setelah lease berakhir.
+func (w *Worker) Stop(ctx context.Context) error {
+	if w.cancel == nil {
+		return nil
+	}
+	w.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Println("Outbox worker stopped")
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *Worker) loop(ctx context.Context) {
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		event, err := w.claim(ctx)
+		if err != nil {
+			if err != mongo.ErrNoDocuments && ctx.Err() == nil {
+				log.Println("Error claiming outbox event:", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(w.pollInterval):
+			}
+			continue
+		}
+
+		// Event yang sudah diambil diselesaikan walaupun shutdown sedang berjalan
+		w.process(context.Background(), event)
+	}
+}
+
+// claim mengambil satu event yang jatuh tempo, termasuk event yang lease-nya sudah habis
+func (w *Worker) claim(ctx context.Context) (Event, error) {
+	now := time.Now()
+	lockedUntil := now.Add(w.lease)
+
+	var event Event
+	err := Collection().FindOneAndUpdate(ctx,
+		bson.M{"$or": []bson.M{
+			{"status": StatusPending, "next_attempt_at": bson.M{"$lte": now}},
+			{"status": StatusProcessing, "locked_until": bson.M{"$lt": now}},
+		}},
+		bson.M{"$set": bson.M{"status": StatusProcessing, "locked_until": lockedUntil}},
+		options.FindOneAndUpdate().
+			SetSort(bson.D{{Key: "next_attempt_at", Value: 1}}).
+			SetReturnDocument(options.After),
+	).Decode(&event)
+	return event, err
+}
+
+func (w *Worker) process(ctx context.Context, event Event) {
+	err := w.run(ctx, event)
+	now := time.Now()
+
+	if err == nil {
+		_, err = Collection().UpdateOne(ctx, bson.M{"_id": event.ID}, bson.M{
+			"$set":   bson.M{"status": StatusDone, "processed_at": now},
+			"$unset": bson.M{"locked_until": ""},
+			"$inc":   bson.M{"attempts": 1},
+		})
+		if err != nil {
+			log.Println("Error marking outbox event as done:", err)
+		}
+		return
+	}
+
+	attempts := event.Attempts + 1
+	maxAttempts := event.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+
+	if attempts >= maxAttempts || errors.Is(err, ErrPermanent) {
+		log.Printf("Outbox event %s (%s) moved to dead letter after %d attempts: %v", event.ID.Hex(), event.Type, attempts, err)
+		w.deadLetter(ctx, event, attempts, err)
+		return
+	}
+
+	log.Printf("Outbox event %s (%s) failed, attempt %d/%d: %v", event.ID.Hex(), event.Type, attempts, maxAttempts, err)
+	_, updateErr := Collection().UpdateOne(ctx, bson.M{"_id": event.ID}, bson.M{
+		"$set": bson.M{
+			"status":          StatusPending,
+			"attempts":        attempts,
+			"last_error":      err.Error(),
+			"next_attempt_at": now.Add(backoff(attempts)),
+		},
+		"$unset": bson.M{"locked_until": ""},
+	})
+	if updateErr != nil {
+		log.Println("Error rescheduling outbox event:", updateErr)
+	}
+}
+
+// run memanggil handler dan mengubah panic menjadi error agar worker tetap hidup
+func (w *Worker) run(ctx context.Context, event Event) (err error) {
+	handler, ok := w.handlers[event.Type]
+	if !ok {
+		return fmt.Errorf("no handler registered for event type %q", event.Type)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, w.lease)
+	defer cancel()
+	return handler(ctx, event)
+}
+
+// deadLetter menyalin event ke koleksi dead-letter lalu menandainya di outbox
+func (w *Worker) deadLetter(ctx context.Context, event Event, attempts int, cause error) {
+	now := time.Now()
+	event.Status = StatusDead
+	event.Attempts = attempts
+	event.LastError = cause.Error()
+	event.LockedUntil = nil
+	event.ProcessedAt = &now
+
+	_, err := DeadLetterCollection().InsertOne(ctx, event)
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		log.Println("Error writing outbox dead letter:", err)
+		return
+	}
+
+	_, err = Collection().UpdateOne(ctx, bson.M{"_id": event.ID}, bson.M{
+		"$set": bson.M{
+			"status":       StatusDead,
+			"attempts":     attempts,
+			"last_error":   event.LastError,
+			"processed_at": now,
+		},
+		"$unset": bson.M{"locked_until": ""},
+	})
+	if err != nil {
+		log.Println("Error marking outbox event as dead:", err)
+	}
+}
+
+// backoff menghitung jeda eksponensial dengan jitter untuk percobaan berikutnya
+func backoff(attempts int) time.Duration {
+	delay := baseBackoff << uint(attempts-1)
+	if delay <= 0 || delay > maxBackoff {
+		delay = maxBackoff
+	}
+	jitter := time.Duration(rand.Int63n(int64(delay) / 5))
+	return delay + jitter
+}
@@ -0,0 +1,162 @@
+package realtime
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Event adalah pesan yang dikirim ke klien yang sedang terhubung
+type Event struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+}
+
+// subscriberBuffer adalah jumlah event yang ditampung per koneksi sebelum event baru dibuang
+const subscriberBuffer = 16
+
+// Hub meneruskan event ke koneksi (SSE) milik pengguna yang terhubung ke instance ini
+type Hub struct {
+	mu     sync.RWMutex
+	subs   map[string]map[chan Event]struct{}
+	closed bool
+}
+
+// NewHub membuat hub kosong
+func NewHub() *Hub {
+	return &Hub{subs: map[string]map[chan Event]struct{}{}}
+}
+
+// Default adalah hub yang dipakai aplikasi
+var Default = NewHub()
+
+// Subscribe mendaftarkan koneksi baru untuk pengguna. Panggil fungsi yang dikembalikan
+// saat koneksi ditutup. Channel ditutup oleh hub saat Close dipanggil.
+func (h *Hub) Subscribe(userID string) (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	if h.subs[userID] == nil {
+		h.subs[userID] = map[chan Event]struct{}{}
+	}
+	h.subs[userID][ch] = struct{}{}
+
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, ok := h.subs[userID][ch]; ok {
+			delete(h.subs[userID], ch)
+			if len(h.subs[userID]) == 0 {
+				delete(h.subs, userID)
+			}
+			close(ch)
+		}
+	}
+}
+
+// Publish mengirim event ke semua koneksi milik pengguna tanpa memblokir.
+// Koneksi yang lambat akan kehilangan event; klien dapat memuat ulang dari endpoint daftar.
+func (h *Hub) Publish(userID string, event Event) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for ch := range h.subs[userID] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// Close menutup semua koneksi, dipanggil saat shutdown agar stream SSE berakhir
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for userID, chans := range h.subs {
+		for ch := range chans {
+			close(ch)
+		}
+		delete(h.subs, userID)
+	}
+}
+
+// Router menentukan penerima dan isi event dari dokumen yang baru disisipkan
+type Router func(doc bson.Raw) (recipients []string, event Event, ok bool)
+
+// Watch mengikuti insert pada koleksi melalui change stream dan meneruskannya ke subscriber.
+// Karena change stream hanya memuat perubahan yang sudah di-commit, event tidak pernah
+// terkirim untuk transaksi yang dibatalkan, dan setiap instance aplikasi menerima event yang sama.
+// Watch berjalan sampai ctx dibatalkan dan menyambung ulang bila stream terputus.
+func (h *Hub) Watch(ctx context.Context, collection *mongo.Collection, route Router) {
+	h.WatchChanges(ctx, collection, []string{"insert"}, route)
+}
+
+// WatchChanges sama dengan Watch untuk jenis operasi tertentu (misalnya "insert" dan "update").
+// Untuk update, Router menerima dokumen lengkap setelah perubahan.
+func (h *Hub) WatchChanges(ctx context.Context, collection *mongo.Collection, operations []string, route Router) {
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: bson.M{"operationType": bson.M{"$in": operations}}}}}
+	var resumeToken bson.Raw
+
+	for ctx.Err() == nil {
+		opts := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+		if resumeToken != nil {
+			opts.SetResumeAfter(resumeToken)
+		}
+
+		stream, err := collection.Watch(ctx, pipeline, opts)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Realtime: cannot watch %s: %v", collection.Name(), err)
+			}
+			sleep(ctx, 5*time.Second)
+			continue
+		}
+
+		for stream.Next(ctx) {
+			var change struct {
+				FullDocument bson.Raw `bson:"fullDocument"`
+			}
+			if err := stream.Decode(&change); err != nil {
+				log.Printf("Realtime: cannot decode change from %s: %v", collection.Name(), err)
+				continue
+			}
+			resumeToken = stream.ResumeToken()
+			if change.FullDocument == nil {
+				// Dokumen sudah dihapus sebelum lookup
+				continue
+			}
+
+			recipients, event, ok := route(change.FullDocument)
+			if !ok {
+				continue
+			}
+			for _, userID := range recipients {
+				h.Publish(userID, event)
+			}
+		}
+		if err := stream.Err(); err != nil && ctx.Err() == nil {
+			log.Printf("Realtime: change stream on %s closed: %v", collection.Name(), err)
+			// Token lama mungkin sudah tidak valid, mulai dari posisi terbaru
+			resumeToken = nil
+		}
+		stream.Close(context.Background())
+		sleep(ctx, time.Second)
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
@@ -2,11 +2,15 @@ package router
 
 import (
 	"be_ecommerce/handler"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 func SetupRoutes(app *fiber.App) {
+	// Tolak semua request bertoken dari akun yang sedang disuspend
+	app.Use(handler.RejectSuspended)
+
 	// Auth routes
 	app.Get("/", handler.GetHome)
 	app.Post("/register", handler.Register)
@@ -16,9 +20,12 @@ func SetupRoutes(app *fiber.App) {
 	app.Put("/users/reset-password", handler.ResetPassword)
 	app.Post("/users/send-password-reset-email", handler.SendPasswordResetEmail)
 	app.Post("/users/verify-otp", handler.VerifyOTP)
+	app.Post("/users/verify-email", handler.VerifyEmail)
+	app.Post("/users/resend-verification", handler.ResendVerificationEmail)
 	// Product routes
 	app.Post("/products", handler.CreateProduct)
-	app.Get("/products", handler.GetAllProducts)
+	app.Get("/products", handler.GetAllProducts) // ?district= atau ?same_city=true&lat=&long=
+	app.Get("/products/nearby", handler.GetNearbyProducts)
 	app.Get("/products/:id", handler.GetProductDetail)
 	app.Get("/products/:product_id/rating", handler.GetProductRating)
 	// Endpoint untuk mendapatkan produk berdasarkan ID
@@ -26,25 +33,45 @@ func SetupRoutes(app *fiber.App) {
 	app.Put("/products/:id", handler.UpdateProductByID)
 	app.Delete("/products/:id", handler.DeleteProductByID)
 
-	app.Static("/uploads", "./uploads")
-
-	app.Post("/categories", handler.AddCategory)                 // Tambahkan kategori baru
-	app.Post("/categories/sub", handler.AddSubCategory)          // Tambahkan sub-kategori ke kategori
-	app.Get("/categories", handler.GetCategories)                // Dapatkan semua kategori dan sub-kategori
-	app.Put("/categories/:id", handler.UpdateCategory)           // Update kategori berdasarkan ID
-	app.Put("/categories/sub/:id", handler.UpdateSubCategory)    // Update sub-kategori berdasarkan ID
-	app.Delete("/categories/:id", handler.DeleteCategory)        // Hapus kategori berdasarkan ID
-	app.Delete("/categories/sub/:id", handler.DeleteSubCategory) // Hapus sub-kategori berdasarkan ID
+	// Lampiran chat lama di uploads/chat hanya bisa diambil lewat /chats/:id/attachments/:name
+	app.Static("/uploads", "./uploads", fiber.Static{
+		Next: func(c *fiber.Ctx) bool {
+			return strings.HasPrefix(c.Path(), "/uploads/chat/")
+		},
+	})
+
+	// Data geo petapedia
+	app.Post("/api/getroad", handler.GetRoad)
+	app.Post("/api/getregion", handler.GetRegion)
+
+	// Kategori hanya boleh diubah admin; pohon dan detailnya publik
+	app.Post("/categories", handler.AdminOnly, handler.AddCategory)                 // Tambahkan kategori baru
+	app.Post("/categories/sub", handler.AdminOnly, handler.AddSubCategory)          // Tambahkan sub-kategori ke kategori
+	app.Get("/categories", handler.GetCategories)                                   // Dapatkan pohon kategori
+	app.Get("/categories/:id", handler.GetCategory)                                 // Detail kategori (ID atau slug) dengan breadcrumb dan skema atribut
+	app.Put("/categories/:id", handler.AdminOnly, handler.UpdateCategory)           // Update kategori berdasarkan ID
+	app.Put("/categories/sub/:id", handler.AdminOnly, handler.UpdateSubCategory)    // Update sub-kategori berdasarkan ID
+	app.Delete("/categories/:id", handler.AdminOnly, handler.DeleteCategory)        // Hapus kategori berdasarkan ID
+	app.Delete("/categories/sub/:id", handler.AdminOnly, handler.DeleteSubCategory) // Hapus sub-kategori berdasarkan ID
 
 	app.Post("/reviews", handler.AddReview)                 // Tambahkan review baru
 	app.Get("/reviews/:product_id", handler.GetReviews)     // Ambil semua review untuk produk
 	app.Put("/reviews/:review_id", handler.UpdateReview)    // Perbarui review
 	app.Delete("/reviews/:review_id", handler.DeleteReview) // Hapus review
 
+	// Keranjang pengguna (token login) atau tamu (header X-Cart-Token); tamu mendapat cart token
+	// dari response dan keranjangnya digabung saat login atau lewat /cart/merge
 	app.Post("/cart", handler.AddToCart)
 	app.Get("/cart", handler.FetchCart)
 	app.Post("/cart/update", handler.UpdateCartItem)
 	app.Post("/cart/delete", handler.RemoveFromCart)
+	app.Post("/cart/select", handler.SelectCartItems) // Pilih item untuk checkout
+	app.Post("/cart/save-for-later", handler.SaveForLater)
+	app.Post("/cart/move-to-cart", handler.MoveSavedToCart)
+	app.Post("/cart/saved/delete", handler.RemoveSavedItem)
+	app.Post("/cart/note", handler.SetCartItemNote)
+	app.Post("/cart/store-note", handler.SetCartStoreNote)
+	app.Post("/cart/merge", handler.AuthRequired, handler.MergeGuestCart)
 
 	// Customer applies as seller
 	app.Post("/apply-as-seller", handler.ApplyAsSeller)
@@ -53,6 +80,12 @@ func SetupRoutes(app *fiber.App) {
 	app.Post("/admin/approve-seller", handler.ApproveSeller)
 	app.Post("/admin/reject-seller", handler.RejectSeller)
 
+	// Admin suspends/unsuspends accounts
+	app.Post("/admin/users/:id/suspend", handler.AdminOnly, handler.SuspendUser)
+	app.Post("/admin/users/:id/unsuspend", handler.AdminOnly, handler.UnsuspendUser)
+	app.Post("/admin/sellers/:id/suspend", handler.AdminOnly, handler.SuspendSeller)
+	app.Post("/admin/sellers/:id/unsuspend", handler.AdminOnly, handler.UnsuspendSeller)
+
 	app.Get("/users/:id", handler.GetUserByID)
 
 	// Customer Routes
@@ -69,6 +102,12 @@ func SetupRoutes(app *fiber.App) {
 	app.Get("/seller/products", handler.GetProductsByUserID)
 	app.Post("/seller/products", handler.CreateProductForSeller)
 	app.Put("/seller/products/:id", handler.UpdateProductForSeller)
+	// Import (CSV, diproses di latar belakang) dan export katalog seller
+	app.Post("/seller/products/import", handler.AuthRequired, handler.ImportProducts)
+	app.Get("/seller/products/export", handler.AuthRequired, handler.ExportProducts)
+	app.Get("/seller/products/imports", handler.AuthRequired, handler.GetProductImports)
+	app.Get("/seller/products/imports/:id", handler.AuthRequired, handler.GetProductImport)
+	app.Get("/seller/products/imports/:id/errors", handler.AuthRequired, handler.GetProductImportErrors)
 	app.Delete("/seller/products/:id", handler.DeleteProductForSeller)
 
 	// Customer-Seller Routes
@@ -84,9 +123,15 @@ func SetupRoutes(app *fiber.App) {
 	app.Put("/orders/status/:order_id", handler.UpdateOrderStatusHandler)    // Update order status
 	app.Delete("/orders/:order_id", handler.DeleteSellerOrderHandler) // Delete an order
 	app.Post("/payment", handler.CreatePaymentHandler)
+	app.Post("/payment/notification", handler.PaymentNotificationHandler) // Webhook Midtrans
 
 	app.Get("/orders", handler.GetOrdersHandler)    // Untuk customer
 
+	// Pengiriman: seller mencatat resi, pembeli melacak dan mengonfirmasi penerimaan
+	app.Put("/orders/:order_id/ship", handler.AuthRequired, handler.ShipOrder)
+	app.Get("/orders/:order_id/tracking", handler.AuthRequired, handler.GetOrderTracking)
+	app.Post("/orders/:order_id/confirm-delivery", handler.AuthRequired, handler.ConfirmDelivery)
+
 	// Seller melihat order yang berisi produknya
 	app.Get("/seller/orders", handler.GetOrdersBySellerHandler)
 
@@ -95,7 +140,91 @@ func SetupRoutes(app *fiber.App) {
 	app.Post("/become-seller", handler.BecomeSeller)
 
 	// Endpoint untuk store
+	app.Get("/stores/nearby", handler.GetNearbyStores) // ?lat=&long=&radius=(km)
 	app.Get("/stores/:id", handler.GetStoreDetails) // Mendapatkan detail store dan produk terkait
 
 	app.Get("/dashboard-data", handler.GetDashboardData)
+
+	// Notifikasi in-app; stream memakai Server-Sent Events (token boleh lewat ?token=)
+	app.Get("/notifications/stream", handler.AuthRequiredStream, handler.StreamNotifications)
+	app.Get("/notifications", handler.AuthRequired, handler.GetNotifications)
+	app.Put("/notifications/read-all", handler.AuthRequired, handler.MarkAllNotificationsRead)
+	app.Put("/notifications/:id/read", handler.AuthRequired, handler.MarkNotificationRead)
+
+	// Buku alamat pelanggan
+	addresses := app.Group("/addresses", handler.AuthRequired)
+	addresses.Get("/", handler.GetAddresses)
+	addresses.Post("/", handler.AddAddress)
+	addresses.Put("/:id", handler.UpdateAddress)
+	addresses.Delete("/:id", handler.DeleteAddress)
+	addresses.Put("/:id/default", handler.SetDefaultAddress)
+
+	// Pilihan wilayah bertingkat untuk formulir alamat (?province=&district=&sub_district=)
+	app.Get("/regions/options", handler.GetRegionOptions)
+
+	// Ongkos kirim dari alamat toko ke alamat pembeli; checkout memakai perhitungan yang sama
+	app.Post("/shipping/rates", handler.AuthRequired, handler.GetShippingRates)
+	app.Put("/seller/store/address", handler.AuthRequired, handler.UpdateStoreAddress)
+
+	// Voucher: pembeli mengecek potongan keranjang; admin dan seller mengelola voucher
+	vouchers := app.Group("/vouchers", handler.AuthRequired)
+	vouchers.Post("/apply", handler.ApplyVoucher)
+	vouchers.Get("/", handler.GetVouchers)
+	vouchers.Post("/", handler.CreateVoucher)
+	vouchers.Put("/:id/active", handler.SetVoucherActive)
+
+	// Varian produk (ukuran, warna, ...) dengan SKU, harga, stok dan gambar masing-masing
+	app.Put("/products/:id/variants", handler.AuthRequired, handler.SetProductVariants)
+	app.Post("/products/:id/variants/:variant_id/image", handler.AuthRequired, handler.UploadVariantImage)
+
+	// Harga promo berjangka per produk (seller/admin) dan flash sale (admin)
+	app.Get("/products/:id/price-rules", handler.GetPriceRules)
+	app.Post("/price-rules", handler.AuthRequired, handler.CreatePriceRule)
+	app.Delete("/price-rules/:id", handler.AuthRequired, handler.DeletePriceRule)
+	app.Get("/flash-sales", handler.GetFlashSales)
+	app.Post("/flash-sales", handler.AdminOnly, handler.CreateFlashSale)
+	app.Put("/flash-sales/:id/active", handler.AdminOnly, handler.SetFlashSaleActive)
+
+	// Status produk (draft, pending_review, active, rejected, archived) dan antrean moderasi admin
+	app.Put("/products/:id/status", handler.AuthRequired, handler.SetProductStatus)
+	app.Get("/admin/products/moderation", handler.AdminOnly, handler.GetModerationQueue)
+	app.Put("/admin/products/:id/moderation", handler.AdminOnly, handler.ModerateProduct)
+
+	// Ledger stok: riwayat pergerakan, restock/return/adjustment manual dan peringatan stok menipis
+	inventory := app.Group("/inventory", handler.AuthRequired)
+	inventory.Get("/low-stock", handler.GetLowStockProducts)
+	inventory.Get("/:product_id/movements", handler.GetInventoryMovements)
+	inventory.Post("/:product_id/movements", handler.AddInventoryMovement)
+	inventory.Put("/:product_id/threshold", handler.SetLowStockThreshold)
+
+	// Wishlist bernama milik pengguna; :id "default" menunjuk wishlist default.
+	// Alert turun harga dan stok kembali dikirim lewat notifikasi in-app.
+	wishlists := app.Group("/wishlists", handler.AuthRequired)
+	wishlists.Get("/", handler.GetWishlists)
+	wishlists.Post("/", handler.CreateWishlist)
+	wishlists.Put("/:id", handler.RenameWishlist)
+	wishlists.Delete("/:id", handler.DeleteWishlist)
+	wishlists.Post("/:id/items", handler.AddWishlistItem)
+	wishlists.Delete("/:id/items/:product_id", handler.RemoveWishlistItem) // ?variant_id=
+	wishlists.Post("/:id/items/:product_id/move-to-cart", handler.MoveWishlistItemToCart)
+
+	// Chat pembeli-toko; pesan baru dan tanda dibaca dikirim lewat /notifications/stream
+	chats := app.Group("/chats", handler.AuthRequired)
+	chats.Post("/", handler.StartConversation)
+	chats.Get("/", handler.GetConversations) // ?as=seller untuk kotak masuk toko
+	chats.Get("/:id/messages", handler.GetConversationMessages)
+	chats.Post("/:id/messages", handler.SendChatMessage)
+	chats.Put("/:id/read", handler.MarkConversationRead)
+	chats.Get("/:id/attachments/:name", handler.GetChatAttachment)
+
+	// Admin reporting (mendukung ?from=YYYY-MM-DD&to=YYYY-MM-DD&format=csv)
+	admin := app.Group("/admin/reports", handler.AdminOnly)
+	admin.Get("/summary", handler.GetAdminReportSummary)
+	admin.Get("/gmv", handler.GetAdminGMVReport)
+	admin.Get("/registrations", handler.GetAdminRegistrationReport)
+	admin.Get("/sellers", handler.GetAdminSellerReport)
+	admin.Get("/seller-applications", handler.GetAdminPendingApplicationsReport)
+	admin.Get("/suspended", handler.GetAdminSuspendedAccountsReport)
+	admin.Get("/stuck-orders", handler.GetAdminStuckOrdersReport)
+	admin.Get("/reviews", handler.GetAdminReviewReport)
 }
@@ -0,0 +1,161 @@
+package scheduler
+
+import (
+	"be_ecommerce/config"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Job adalah pekerjaan berkala yang dijalankan scheduler
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+}
+
+// Scheduler menjalankan Job secara berkala di dalam proses.
+// Setiap eksekusi dilindungi lock di MongoDB sehingga bila ada beberapa instance
+// aplikasi, sebuah job hanya dijalankan oleh satu instance per interval.
+type Scheduler struct {
+	jobs  []Job
+	owner string
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New membuat scheduler kosong
+func New() *Scheduler {
+	host, _ := os.Hostname()
+	return &Scheduler{owner: fmt.Sprintf("%s-%d-%d", host, os.Getpid(), time.Now().UnixNano())}
+}
+
+// Add mendaftarkan job. Dipanggil sebelum Start.
+func (s *Scheduler) Add(job Job) {
+	s.jobs = append(s.jobs, job)
+}
+
+func lockCollection() *mongo.Collection {
+	return config.MongoClient.Database("ecommerce").Collection("scheduler_locks")
+}
+
+// Start menjalankan setiap job di goroutine masing-masing
+func (s *Scheduler) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	for _, job := range s.jobs {
+		s.wg.Add(1)
+		go func(job Job) {
+			defer s.wg.Done()
+			s.loop(ctx, job)
+		}(job)
+	}
+	log.Printf("Scheduler started with %d jobs", len(s.jobs))
+}
+
+// Stop menghentikan scheduler dan menunggu job yang sedang berjalan selesai
+func (s *Scheduler) Stop(ctx context.Context) error {
+	if s.cancel == nil {
+		return nil
+	}
+	s.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Println("Scheduler stopped")
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Scheduler) loop(ctx context.Context, job Job) {
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		s.runOnce(ctx, job)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runOnce mengambil lock job lalu menjalankannya jika lock berhasil didapat
+func (s *Scheduler) runOnce(ctx context.Context, job Job) {
+	acquired, err := s.acquire(ctx, job)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Scheduler: failed to acquire lock for %s: %v", job.Name, err)
+		}
+		return
+	}
+	if !acquired {
+		return
+	}
+
+	started := time.Now()
+	runErr := s.run(ctx, job)
+
+	status := bson.M{"last_run_at": started, "last_duration_ms": time.Since(started).Milliseconds()}
+	if runErr != nil {
+		log.Printf("Scheduler: job %s failed: %v", job.Name, runErr)
+		status["last_error"] = runErr.Error()
+	} else {
+		status["last_error"] = ""
+	}
+	_, err = lockCollection().UpdateOne(context.Background(), bson.M{"_id": job.Name, "owner": s.owner}, bson.M{"$set": status})
+	if err != nil {
+		log.Printf("Scheduler: failed to record run of %s: %v", job.Name, err)
+	}
+}
+
+// run menjalankan job dan mengubah panic menjadi error
+func (s *Scheduler) run(ctx context.Context, job Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return job.Run(ctx)
+}
+
+// acquire memegang lock job selama satu interval. Lock yang masih berlaku milik
+// instance lain membuat job dilewati pada tick ini.
+func (s *Scheduler) acquire(ctx context.Context, job Job) (bool, error) {
+	now := time.Now()
+	// Sedikit lebih pendek dari interval agar tick berikutnya tidak terlewat karena jitter
+	lockedUntil := now.Add(job.Interval - job.Interval/10)
+
+	_, err := lockCollection().UpdateOne(ctx,
+		bson.M{"_id": job.Name, "locked_until": bson.M{"$lte": now}},
+		bson.M{"$set": bson.M{"owner": s.owner, "locked_until": lockedUntil}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		// Upsert bentrok dengan dokumen lock yang masih berlaku milik instance lain
+		if mongo.IsDuplicateKeyError(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
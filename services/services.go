@@ -1,6 +1,10 @@
 package services
 
 import (
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/hex"
+
 	"github.com/veritrans/go-midtrans"
 )
 
@@ -16,4 +20,12 @@ func MidtransClient() *midtrans.Client {
 	c.ClientKey = clientKey
 	c.APIEnvType = midtrans.Sandbox // Gunakan Sandbox untuk testing, ubah ke Production untuk live
 	return &c                       // Mengembalikan pointer ke client
-}
\ No newline at end of file
+}
+
+// VerifyMidtransSignature memverifikasi signature_key pada notifikasi Midtrans:
+// SHA512(order_id + status_code + gross_amount + server_key)
+func VerifyMidtransSignature(orderID, statusCode, grossAmount, signature string) bool {
+	sum := sha512.Sum512([]byte(orderID + statusCode + grossAmount + serverKey))
+	expected := hex.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(signature)) == 1
+}
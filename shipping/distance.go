@@ -0,0 +1,165 @@
+package shipping
+
+import (
+	"be_ecommerce/config"
+	"be_ecommerce/model"
+	"context"
+	"fmt"
+	"math"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DistanceService adalah satu layanan kalkulator jarak. Ongkos per paket:
+// BaseCost + kg * (PerKg + jarak_km * PerKgKm), dibulatkan ke atas kelipatan 500.
+type DistanceService struct {
+	Code        string
+	Description string
+	BaseCost    int
+	PerKg       int
+	PerKgKm     float64
+	KmPerDay    float64 // Jarak tempuh per hari untuk estimasi waktu kirim
+}
+
+// DistanceCalculator menghitung ongkos kirim dari jarak toko ke pembeli memakai data
+// geo petapedia: titik alamat diambil dari lat/long atau titik tengah batas desa
+// (model.Region), dan tujuan tanpa jalan di sekitarnya dikenai biaya daerah terpencil.
+type DistanceCalculator struct {
+	Courier            string
+	Services           []DistanceService
+	RoadFactor         float64 // Pengali jarak garis lurus menjadi perkiraan jarak jalan
+	RemoteRoadDistance float64 // Meter; tanpa jalan dalam radius ini tujuan dianggap terpencil
+	RemoteSurcharge    int
+}
+
+// NewDistanceCalculator membuat kalkulator dengan tarif bawaan REG dan EXPRESS
+func NewDistanceCalculator() *DistanceCalculator {
+	return &DistanceCalculator{
+		Courier: "internal",
+		Services: []DistanceService{
+			{Code: "REG", Description: "Layanan reguler", BaseCost: 5000, PerKg: 4000, PerKgKm: 10, KmPerDay: 300},
+			{Code: "EXPRESS", Description: "Layanan kilat", BaseCost: 8000, PerKg: 6000, PerKgKm: 15, KmPerDay: 800},
+		},
+		RoadFactor:         1.3,
+		RemoteRoadDistance: 2000,
+		RemoteSurcharge:    10000,
+	}
+}
+
+// Rates mengimplementasikan RateProvider
+func (d *DistanceCalculator) Rates(ctx context.Context, req RateRequest) ([]Rate, error) {
+	originLong, originLat, err := Locate(ctx, req.Origin)
+	if err != nil {
+		return nil, fmt.Errorf("resolve origin: %w", err)
+	}
+	destLong, destLat, err := Locate(ctx, req.Destination)
+	if err != nil {
+		return nil, fmt.Errorf("resolve destination: %w", err)
+	}
+
+	km := haversineKm(originLat, originLong, destLat, destLong) * d.RoadFactor
+	kg := int(math.Ceil(float64(req.Parcel.BillableWeight()) / 1000))
+	if kg < 1 {
+		kg = 1
+	}
+
+	surcharge := 0
+	if d.RemoteSurcharge > 0 {
+		remote, err := isRemote(ctx, destLong, destLat, d.RemoteRoadDistance)
+		if err != nil {
+			return nil, err
+		}
+		if remote {
+			surcharge = d.RemoteSurcharge
+		}
+	}
+
+	rates := make([]Rate, 0, len(d.Services))
+	for _, service := range d.Services {
+		cost := float64(service.BaseCost) + float64(kg)*(float64(service.PerKg)+km*service.PerKgKm)
+		days := 1 + int(km/service.KmPerDay)
+		rates = append(rates, Rate{
+			Courier:     d.Courier,
+			Service:     service.Code,
+			Description: service.Description,
+			Cost:        roundUp(int(math.Ceil(cost))+surcharge, 500),
+			ETD:         fmt.Sprintf("%d-%d", days, days+1),
+		})
+	}
+	if len(rates) == 0 {
+		return nil, ErrNoRates
+	}
+	SortByCost(rates)
+	return rates, nil
+}
+
+// Locate mengembalikan koordinat (long, lat) alamat; bila alamat tidak punya
+// koordinat dipakai titik tengah batas desa/kelurahannya
+func Locate(ctx context.Context, address model.Address) (float64, float64, error) {
+	if address.Latitude != nil && address.Longitude != nil {
+		return *address.Longitude, *address.Latitude, nil
+	}
+
+	var region model.Region
+	err := config.MongoClient.Database("petapedia").Collection("region").FindOne(ctx, bson.M{
+		"province":     address.Province,
+		"district":     address.District,
+		"sub_district": address.SubDistrict,
+		"village":      address.Village,
+	}, options.FindOne().SetCollation(&options.Collation{Locale: "en", Strength: 2})).Decode(&region)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(region.Border.Coordinates) == 0 || len(region.Border.Coordinates[0]) == 0 {
+		return 0, 0, fmt.Errorf("region %s has no border", region.Village)
+	}
+
+	// Rata-rata titik ring luar sudah cukup untuk perkiraan jarak
+	var sumLong, sumLat float64
+	ring := region.Border.Coordinates[0]
+	for _, point := range ring {
+		sumLong += point[0]
+		sumLat += point[1]
+	}
+	return sumLong / float64(len(ring)), sumLat / float64(len(ring)), nil
+}
+
+// isRemote memeriksa apakah tidak ada jalan dalam radius tertentu dari titik tujuan
+func isRemote(ctx context.Context, long, lat, maxDistance float64) (bool, error) {
+	err := config.MongoClient.Database("petapedia").Collection("roads").FindOne(ctx, bson.M{
+		"geometry": bson.M{
+			"$nearSphere": bson.M{
+				"$geometry": bson.M{
+					"type":        "Point",
+					"coordinates": []float64{long, lat},
+				},
+				"$maxDistance": maxDistance,
+			},
+		},
+	}, options.FindOne().SetProjection(bson.M{"_id": 1})).Err()
+	if err == mongo.ErrNoDocuments {
+		return true, nil
+	}
+	return false, err
+}
+
+// haversineKm menghitung jarak garis lurus dua titik di permukaan bumi dalam kilometer
+func haversineKm(lat1, long1, lat2, long2 float64) float64 {
+	const earthRadiusKm = 6371
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	dLat := toRad(lat2 - lat1)
+	dLong := toRad(long2 - long1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLong/2)*math.Sin(dLong/2)
+	return earthRadiusKm * 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+func roundUp(value, step int) int {
+	if value%step == 0 {
+		return value
+	}
+	return (value/step + 1) * step
+}
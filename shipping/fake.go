@@ -0,0 +1,55 @@
+package shipping
+
+import (
+	"context"
+	"sync"
+)
+
+// FakeProvider mengembalikan rate tetap dan mencatat setiap permintaan, untuk pengujian
+// dan development tanpa data geo maupun API kurir
+type FakeProvider struct {
+	mu       sync.Mutex
+	rates    []Rate
+	err      error
+	requests []RateRequest
+}
+
+// NewFakeProvider membuat FakeProvider; tanpa argumen dipakai satu layanan flat Rp10.000
+func NewFakeProvider(rates ...Rate) *FakeProvider {
+	if len(rates) == 0 {
+		rates = []Rate{{Courier: "fake", Service: "FLAT", Description: "Flat rate", Cost: 10000, ETD: "1-2"}}
+	}
+	return &FakeProvider{rates: rates}
+}
+
+// Rates mengimplementasikan RateProvider
+func (f *FakeProvider) Rates(ctx context.Context, req RateRequest) ([]Rate, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, req)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return append([]Rate(nil), f.rates...), nil
+}
+
+// SetRates mengganti rate yang dikembalikan
+func (f *FakeProvider) SetRates(rates ...Rate) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rates = rates
+}
+
+// SetError membuat Rates selalu gagal dengan err (nil untuk menormalkan kembali)
+func (f *FakeProvider) SetError(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.err = err
+}
+
+// Requests mengembalikan salinan permintaan yang pernah diterima
+func (f *FakeProvider) Requests() []RateRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]RateRequest(nil), f.requests...)
+}
@@ -0,0 +1,30 @@
+package shipping
+
+import (
+	"math"
+	"os"
+	"strconv"
+)
+
+// Tarif flat bawaan per kg untuk toko yang belum punya alamat terstruktur
+const defaultFlatRatePerKg = 10000
+
+// FlatRate adalah tarif cadangan untuk paket dari toko yang belum mengisi alamat terstruktur
+// (hanya FullAddress lama), sehingga asal pengiriman tidak bisa ditentukan. Ongkosnya
+// SHIPPING_FLAT_RATE_PER_KG (default 10000) per kg berat yang ditagihkan, minimal 1 kg.
+func FlatRate(parcel Parcel) Rate {
+	perKg, err := strconv.Atoi(os.Getenv("SHIPPING_FLAT_RATE_PER_KG"))
+	if err != nil || perKg < 0 {
+		perKg = defaultFlatRatePerKg
+	}
+	kg := int(math.Ceil(float64(parcel.BillableWeight()) / 1000))
+	if kg < 1 {
+		kg = 1
+	}
+	return Rate{
+		Courier:     "internal",
+		Service:     "FLAT",
+		Description: "Tarif flat",
+		Cost:        kg * perKg,
+	}
+}
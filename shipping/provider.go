@@ -0,0 +1,112 @@
+package shipping
+
+import (
+	"be_ecommerce/model"
+	"context"
+	"errors"
+	"log"
+	"os"
+	"sort"
+	"sync"
+)
+
+// ErrNoRates dikembalikan provider bila tidak ada layanan yang bisa mengirim ke tujuan
+var ErrNoRates = errors.New("no shipping rates available")
+
+// Parcel adalah paket yang akan dikirim. Weight dalam gram, Volume dalam cm³
+// (panjang x lebar x tinggi) untuk menghitung berat volumetrik.
+type Parcel struct {
+	Weight int `json:"weight"`
+	Volume int `json:"volume"`
+}
+
+// BillableWeight mengembalikan berat yang ditagihkan dalam gram: yang lebih besar
+// antara berat aktual dan berat volumetrik (volume / 6000 kg)
+func (p Parcel) BillableWeight() int {
+	volumetric := p.Volume / 6
+	if volumetric > p.Weight {
+		return volumetric
+	}
+	return p.Weight
+}
+
+// RateRequest berisi alamat asal (toko), alamat tujuan (pembeli) dan paket
+type RateRequest struct {
+	Origin      model.Address
+	Destination model.Address
+	Parcel      Parcel
+}
+
+// Rate adalah satu pilihan layanan pengiriman beserta ongkosnya dalam rupiah
+type Rate struct {
+	Courier     string       `json:"courier" bson:"courier"`
+	Service     string       `json:"service" bson:"service"`
+	Description string       `json:"description,omitempty" bson:"description,omitempty"`
+	Cost        int          `json:"cost" bson:"cost"`
+	ETD         string       `json:"etd,omitempty" bson:"etd,omitempty"`         // Estimasi hari, contoh "2-3"
+	Parcels     []ParcelCost `json:"parcels,omitempty" bson:"parcels,omitempty"` // Rincian per paket bila rate menggabungkan beberapa asal
+}
+
+// ParcelCost adalah ongkos satu paket dalam rate gabungan; Origin adalah kunci asal paket dari pemanggil (contoh seller ID)
+type ParcelCost struct {
+	Origin string `json:"origin" bson:"origin"`
+	Cost   int    `json:"cost" bson:"cost"`
+}
+
+// RateProvider menghitung ongkos kirim (kalkulator jarak/berat bawaan, RajaOngkir, fake)
+type RateProvider interface {
+	Rates(ctx context.Context, req RateRequest) ([]Rate, error)
+}
+
+var (
+	defaultProvider RateProvider
+	defaultOnce     sync.Once
+	defaultMu       sync.RWMutex
+)
+
+// Default mengembalikan provider yang dipilih lewat env SHIPPING_RATE_PROVIDER:
+// "distance" (default), "rajaongkir", atau "fake".
+func Default() RateProvider {
+	defaultOnce.Do(func() {
+		var p RateProvider
+		switch os.Getenv("SHIPPING_RATE_PROVIDER") {
+		case "rajaongkir":
+			rajaOngkir, err := NewRajaOngkirProviderFromEnv()
+			if err != nil {
+				log.Printf("Warning: RajaOngkir is not configured, using distance calculator: %v", err)
+				p = NewDistanceCalculator()
+			} else {
+				p = rajaOngkir
+			}
+		case "fake":
+			p = NewFakeProvider()
+		default:
+			p = NewDistanceCalculator()
+		}
+
+		defaultMu.Lock()
+		if defaultProvider == nil {
+			defaultProvider = p
+		}
+		defaultMu.Unlock()
+	})
+
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultProvider
+}
+
+// SetDefault mengganti provider default, misalnya dengan FakeProvider saat pengujian
+func SetDefault(p RateProvider) {
+	defaultOnce.Do(func() {})
+	defaultMu.Lock()
+	defaultProvider = p
+	defaultMu.Unlock()
+}
+
+// SortByCost mengurutkan rate dari yang termurah
+func SortByCost(rates []Rate) {
+	sort.SliceStable(rates, func(i, j int) bool {
+		return rates[i].Cost < rates[j].Cost
+	})
+}
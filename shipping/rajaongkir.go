@@ -0,0 +1,258 @@
+package shipping
+
+import (
+	"be_ecommerce/model"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RajaOngkirProvider memakai API RajaOngkir (/city, /cost dan /waybill). Kabupaten/kota alamat
+// dicocokkan ke city_id RajaOngkir berdasarkan nama.
+type RajaOngkirProvider struct {
+	apiKey   string
+	baseURL  string
+	couriers []string
+	client   *http.Client
+
+	mu     sync.Mutex
+	cities map[string]string // nama kabupaten/kota ternormalisasi -> city_id
+}
+
+// NewRajaOngkirProvider membuat provider RajaOngkir untuk daftar kurir tertentu (jne, pos, tiki, ...)
+func NewRajaOngkirProvider(apiKey, baseURL string, couriers []string) *RajaOngkirProvider {
+	return &RajaOngkirProvider{
+		apiKey:   apiKey,
+		baseURL:  strings.TrimRight(baseURL, "/"),
+		couriers: couriers,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// NewRajaOngkirProviderFromEnv membaca RAJAONGKIR_API_KEY, RAJAONGKIR_BASE_URL dan RAJAONGKIR_COURIERS
+func NewRajaOngkirProviderFromEnv() (*RajaOngkirProvider, error) {
+	apiKey := os.Getenv("RAJAONGKIR_API_KEY")
+	if apiKey == "" {
+		return nil, errors.New("RAJAONGKIR_API_KEY is not set")
+	}
+	baseURL := os.Getenv("RAJAONGKIR_BASE_URL")
+	if baseURL == "" {
+		baseURL = "https://api.rajaongkir.com/starter"
+	}
+	couriers := os.Getenv("RAJAONGKIR_COURIERS")
+	if couriers == "" {
+		couriers = "jne,pos,tiki"
+	}
+	return NewRajaOngkirProvider(apiKey, baseURL, strings.Split(couriers, ",")), nil
+}
+
+type rajaOngkirStatus struct {
+	Code        int    `json:"code"`
+	Description string `json:"description"`
+}
+
+// Rates mengimplementasikan RateProvider; setiap kurir diminta terpisah
+func (r *RajaOngkirProvider) Rates(ctx context.Context, req RateRequest) ([]Rate, error) {
+	origin, err := r.cityID(ctx, req.Origin.District)
+	if err != nil {
+		return nil, err
+	}
+	destination, err := r.cityID(ctx, req.Destination.District)
+	if err != nil {
+		return nil, err
+	}
+
+	weight := req.Parcel.BillableWeight()
+	if weight < 1 {
+		weight = 1
+	}
+
+	var rates []Rate
+	for _, courier := range r.couriers {
+		courier = strings.TrimSpace(courier)
+		form := url.Values{
+			"origin":      {origin},
+			"destination": {destination},
+			"weight":      {strconv.Itoa(weight)},
+			"courier":     {courier},
+		}
+
+		var body struct {
+			RajaOngkir struct {
+				Status  rajaOngkirStatus `json:"status"`
+				Results []struct {
+					Code  string `json:"code"`
+					Costs []struct {
+						Service     string `json:"service"`
+						Description string `json:"description"`
+						Cost        []struct {
+							Value int    `json:"value"`
+							ETD   string `json:"etd"`
+						} `json:"cost"`
+					} `json:"costs"`
+				} `json:"results"`
+			} `json:"rajaongkir"`
+		}
+		if err := r.do(ctx, http.MethodPost, "/cost", strings.NewReader(form.Encode()), &body); err != nil {
+			return nil, err
+		}
+		if body.RajaOngkir.Status.Code != http.StatusOK {
+			return nil, fmt.Errorf("rajaongkir cost %s: %s", courier, body.RajaOngkir.Status.Description)
+		}
+
+		for _, result := range body.RajaOngkir.Results {
+			for _, cost := range result.Costs {
+				if len(cost.Cost) == 0 {
+					continue
+				}
+				rates = append(rates, Rate{
+					Courier:     result.Code,
+					Service:     cost.Service,
+					Description: cost.Description,
+					Cost:        cost.Cost[0].Value,
+					ETD:         strings.TrimSpace(strings.TrimSuffix(strings.ToUpper(cost.Cost[0].ETD), "HARI")),
+				})
+			}
+		}
+	}
+
+	if len(rates) == 0 {
+		return nil, ErrNoRates
+	}
+	SortByCost(rates)
+	return rates, nil
+}
+
+// cityID mencari city_id RajaOngkir untuk nama kabupaten/kota; daftar kota dimuat sekali
+func (r *RajaOngkirProvider) cityID(ctx context.Context, district string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.cities == nil {
+		var body struct {
+			RajaOngkir struct {
+				Status  rajaOngkirStatus `json:"status"`
+				Results []struct {
+					CityID   string `json:"city_id"`
+					Type     string `json:"type"`
+					CityName string `json:"city_name"`
+				} `json:"results"`
+			} `json:"rajaongkir"`
+		}
+		if err := r.do(ctx, http.MethodGet, "/city", nil, &body); err != nil {
+			return "", err
+		}
+		if body.RajaOngkir.Status.Code != http.StatusOK {
+			return "", fmt.Errorf("rajaongkir city: %s", body.RajaOngkir.Status.Description)
+		}
+
+		cities := make(map[string]string, len(body.RajaOngkir.Results))
+		for _, city := range body.RajaOngkir.Results {
+			cities[normalizeCity(city.Type+" "+city.CityName)] = city.CityID
+			// Nama tanpa jenis hanya dipakai bila tidak bentrok (contoh: Kota/Kabupaten Bandung)
+			name := normalizeCity(city.CityName)
+			if _, exists := cities[name]; !exists {
+				cities[name] = city.CityID
+			}
+		}
+		r.cities = cities
+	}
+
+	if id, ok := r.cities[normalizeCity(district)]; ok {
+		return id, nil
+	}
+	if id, ok := r.cities[normalizeCity(stripCityType(district))]; ok {
+		return id, nil
+	}
+	return "", fmt.Errorf("rajaongkir: unknown city %q", district)
+}
+
+func (r *RajaOngkirProvider) do(ctx context.Context, method, path string, body *strings.Reader, out interface{}) error {
+	var req *http.Request
+	var err error
+	if body != nil {
+		req, err = http.NewRequestWithContext(ctx, method, r.baseURL+path, body)
+	} else {
+		req, err = http.NewRequestWithContext(ctx, method, r.baseURL+path, nil)
+	}
+	if err != nil {
+		return err
+	}
+	req.Header.Set("key", r.apiKey)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func normalizeCity(name string) string {
+	name = strings.ToLower(strings.Join(strings.Fields(name), " "))
+	return strings.Replace(name, "kab. ", "kabupaten ", 1)
+}
+
+func stripCityType(name string) string {
+	name = normalizeCity(name)
+	for _, prefix := range []string{"kabupaten ", "kota "} {
+		name = strings.TrimPrefix(name, prefix)
+	}
+	return name
+}
+
+// Track mengimplementasikan TrackingProvider lewat endpoint /waybill (akun RajaOngkir Pro)
+func (r *RajaOngkirProvider) Track(ctx context.Context, courier, waybill string) (TrackingResult, error) {
+	form := url.Values{
+		"waybill": {waybill},
+		"courier": {strings.ToLower(courier)},
+	}
+
+	var body struct {
+		RajaOngkir struct {
+			Status rajaOngkirStatus `json:"status"`
+			Result struct {
+				Delivered bool `json:"delivered"`
+				Manifest  []struct {
+					Description string `json:"manifest_description"`
+					Date        string `json:"manifest_date"`
+					Time        string `json:"manifest_time"`
+					CityName    string `json:"city_name"`
+				} `json:"manifest"`
+			} `json:"result"`
+		} `json:"rajaongkir"`
+	}
+	if err := r.do(ctx, http.MethodPost, "/waybill", strings.NewReader(form.Encode()), &body); err != nil {
+		return TrackingResult{}, err
+	}
+	if body.RajaOngkir.Status.Code != http.StatusOK {
+		return TrackingResult{}, fmt.Errorf("rajaongkir waybill: %s", body.RajaOngkir.Status.Description)
+	}
+
+	// Waktu manifest dalam WIB
+	wib := time.FixedZone("WIB", 7*60*60)
+	result := TrackingResult{Delivered: body.RajaOngkir.Result.Delivered}
+	for _, manifest := range body.RajaOngkir.Result.Manifest {
+		at, err := time.ParseInLocation("2006-01-02 15:04", manifest.Date+" "+manifest.Time, wib)
+		if err != nil {
+			continue
+		}
+		result.Events = append(result.Events, model.TrackingEvent{
+			Time:        at,
+			Description: manifest.Description,
+			Location:    manifest.CityName,
+		})
+	}
+	return result, nil
+}
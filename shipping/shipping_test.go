@@ -0,0 +1,87 @@
+package shipping
+
+import (
+	"math"
+	"testing"
+)
+
+func TestBillableWeight(t *testing.T) {
+	tests := []struct {
+		name   string
+		parcel Parcel
+		want   int
+	}{
+		{"actual weight heavier", Parcel{Weight: 2000, Volume: 6000}, 2000},
+		{"volumetric weight heavier", Parcel{Weight: 500, Volume: 30 * 30 * 30}, 4500},
+		{"no dimensions", Parcel{Weight: 750}, 750},
+		{"equal weights", Parcel{Weight: 1000, Volume: 6000}, 1000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.parcel.BillableWeight(); got != tt.want {
+				t.Errorf("BillableWeight() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHaversineKm(t *testing.T) {
+	tests := []struct {
+		name                     string
+		lat1, long1, lat2, long2 float64
+		want                     float64
+		tolerance                float64
+	}{
+		{"same point", -6.2, 106.8, -6.2, 106.8, 0, 0.001},
+		{"Jakarta to Bandung", -6.2088, 106.8456, -6.9175, 107.6191, 116, 3},
+		{"one degree of latitude", 0, 0, 1, 0, 111.19, 0.1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := haversineKm(tt.lat1, tt.long1, tt.lat2, tt.long2)
+			if math.Abs(got-tt.want) > tt.tolerance {
+				t.Errorf("haversineKm() = %.2f, want %.2f ± %.2f", got, tt.want, tt.tolerance)
+			}
+		})
+	}
+}
+
+func TestRoundUp(t *testing.T) {
+	tests := []struct{ value, step, want int }{
+		{0, 500, 0},
+		{1, 500, 500},
+		{500, 500, 500},
+		{12001, 500, 12500},
+	}
+	for _, tt := range tests {
+		if got := roundUp(tt.value, tt.step); got != tt.want {
+			t.Errorf("roundUp(%d, %d) = %d, want %d", tt.value, tt.step, got, tt.want)
+		}
+	}
+}
+
+func TestFlatRate(t *testing.T) {
+	tests := []struct {
+		name   string
+		env    string
+		parcel Parcel
+		want   int
+	}{
+		{"default rate, minimum one kg", "", Parcel{Weight: 200}, 10000},
+		{"default rate rounds kg up", "", Parcel{Weight: 2100}, 30000},
+		{"configured rate", "7500", Parcel{Weight: 1000}, 7500},
+		{"invalid configuration uses default", "abc", Parcel{Weight: 1000}, 10000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SHIPPING_FLAT_RATE_PER_KG", tt.env)
+			rate := FlatRate(tt.parcel)
+			if rate.Cost != tt.want {
+				t.Errorf("FlatRate().Cost = %d, want %d", rate.Cost, tt.want)
+			}
+			if rate.Service != "FLAT" {
+				t.Errorf("FlatRate().Service = %q, want FLAT", rate.Service)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package shipping
+
+import (
+	"be_ecommerce/model"
+	"context"
+	"errors"
+	"log"
+	"os"
+	"sync"
+)
+
+// ErrTrackingUnsupported dikembalikan bila kurir tidak bisa dilacak otomatis;
+// pengiriman seperti ini dikonfirmasi manual oleh pembeli
+var ErrTrackingUnsupported = errors.New("tracking not supported for this courier")
+
+// TrackingResult adalah status terbaru sebuah resi
+type TrackingResult struct {
+	Delivered bool
+	Events    []model.TrackingEvent
+}
+
+// TrackingProvider mengambil riwayat perjalanan paket dari kurir
+type TrackingProvider interface {
+	Track(ctx context.Context, courier, waybill string) (TrackingResult, error)
+}
+
+// ManualTracker dipakai bila tidak ada API tracking: resi hanya dicatat
+type ManualTracker struct{}
+
+// Track mengimplementasikan TrackingProvider
+func (ManualTracker) Track(ctx context.Context, courier, waybill string) (TrackingResult, error) {
+	return TrackingResult{}, ErrTrackingUnsupported
+}
+
+var (
+	defaultTracker     TrackingProvider
+	defaultTrackerOnce sync.Once
+	defaultTrackerMu   sync.RWMutex
+)
+
+// DefaultTracker mengembalikan provider tracking yang dipilih lewat env
+// SHIPPING_TRACKING_PROVIDER: "manual" (default), "rajaongkir", atau "fake".
+func DefaultTracker() TrackingProvider {
+	defaultTrackerOnce.Do(func() {
+		var t TrackingProvider
+		switch os.Getenv("SHIPPING_TRACKING_PROVIDER") {
+		case "rajaongkir":
+			rajaOngkir, err := NewRajaOngkirProviderFromEnv()
+			if err != nil {
+				log.Printf("Warning: RajaOngkir is not configured, tracking is manual: %v", err)
+				t = ManualTracker{}
+			} else {
+				t = rajaOngkir
+			}
+		case "fake":
+			t = NewFakeTracker()
+		default:
+			t = ManualTracker{}
+		}
+
+		defaultTrackerMu.Lock()
+		if defaultTracker == nil {
+			defaultTracker = t
+		}
+		defaultTrackerMu.Unlock()
+	})
+
+	defaultTrackerMu.RLock()
+	defer defaultTrackerMu.RUnlock()
+	return defaultTracker
+}
+
+// SetDefaultTracker mengganti provider tracking default, misalnya dengan FakeTracker saat pengujian
+func SetDefaultTracker(t TrackingProvider) {
+	defaultTrackerOnce.Do(func() {})
+	defaultTrackerMu.Lock()
+	defaultTracker = t
+	defaultTrackerMu.Unlock()
+}
+
+// FakeTracker mengembalikan hasil tracking yang diatur lewat Set, per nomor resi
+type FakeTracker struct {
+	mu      sync.Mutex
+	results map[string]TrackingResult
+}
+
+// NewFakeTracker membuat FakeTracker kosong
+func NewFakeTracker() *FakeTracker {
+	return &FakeTracker{results: map[string]TrackingResult{}}
+}
+
+// Set menentukan hasil tracking untuk sebuah resi
+func (f *FakeTracker) Set(waybill string, result TrackingResult) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.results[waybill] = result
+}
+
+// Track mengimplementasikan TrackingProvider; resi yang belum di-Set belum punya riwayat
+func (f *FakeTracker) Track(ctx context.Context, courier, waybill string) (TrackingResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.results[waybill], nil
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"fmt"
+	"math/big"
 )
 
 // GenerateRandomToken menghasilkan kode OTP atau token unik
@@ -17,3 +18,16 @@ func GenerateRandomToken(length int) string {
 
 	return base64.RawURLEncoding.EncodeToString(token)[:length]
 }
+
+// GenerateNumericCode menghasilkan kode OTP berupa angka dengan panjang tertentu
+func GenerateNumericCode(length int) (string, error) {
+	code := make([]byte, length)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+		code[i] = byte('0' + n.Int64())
+	}
+	return string(code), nil
+}
@@ -122,3 +122,29 @@ func GetUserIDFromToken(c *fiber.Ctx) (primitive.ObjectID, error) {
 
 	return userID, nil
 }
+
+// cartTokenType membedakan token keranjang tamu dari token login
+const cartTokenType = "guest_cart"
+
+// GenerateCartToken membuat token bertanda tangan yang mengidentifikasi keranjang tamu
+func GenerateCartToken(cartID string, expiry time.Duration) (string, error) {
+	claims := jwt.MapClaims{
+		"cart_id": cartID,
+		"typ":     cartTokenType,
+		"exp":     time.Now().Add(expiry).Unix(),
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtSecret)
+}
+
+// ValidateCartToken memverifikasi token keranjang tamu dan mengembalikan ID keranjangnya
+func ValidateCartToken(tokenString string) (string, error) {
+	claims, err := ValidateJWT(tokenString)
+	if err != nil {
+		return "", err
+	}
+	cartID, _ := claims["cart_id"].(string)
+	if typ, _ := claims["typ"].(string); typ != cartTokenType || cartID == "" {
+		return "", errors.New("invalid cart token")
+	}
+	return cartID, nil
+}